@@ -0,0 +1,12 @@
+// Command go3d runs the 3D Library server and worker and administers a
+// library from the command line. Run "go3d help" for the commands.
+package main
+
+import (
+	"3d-library/internal/cli"
+	"os"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:]))
+}
@@ -0,0 +1,91 @@
+// Package api holds the OpenAPI description of the REST API. The document is
+// embedded in the binary, served at /api/openapi.json and used to generate
+// the Go client in pkg/client.
+package api
+
+//go:generate go run ./clientgen -spec openapi.json -out ../../pkg/client/client.gen.go
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec returns the raw OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+// Handler serves the OpenAPI document.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// Load parses the embedded document.
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi document: %w", err)
+	}
+	return &doc, nil
+}
+
+// CheckRoutes compares the routes registered on the router under the
+// document's server prefix with the paths in the document and reports any
+// that exist on one side only.
+func CheckRoutes(router chi.Routes) error {
+	doc, err := Load()
+	if err != nil {
+		return err
+	}
+	prefix := doc.BasePath()
+
+	documented := make(map[string]bool)
+	for _, path := range doc.Paths.Keys {
+		for _, op := range doc.Paths.Values[path].Operations() {
+			documented[op.Method+" "+prefix+path] = true
+		}
+	}
+
+	registered := make(map[string]bool)
+	err = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		if strings.HasPrefix(route, prefix+"/") {
+			registered[method+" "+route] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for route := range registered {
+		if !documented[route] {
+			problems = append(problems, "undocumented route "+route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			problems = append(problems, "documented route not registered: "+route)
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi document and routes differ:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
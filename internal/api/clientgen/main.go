@@ -0,0 +1,438 @@
+// Command clientgen generates the typed Go client in pkg/client from the
+// OpenAPI document. With -check it only reports whether the generated file is
+// up to date.
+package main
+
+import (
+	"3d-library/internal/api"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	specPath := flag.String("spec", "openapi.json", "OpenAPI document")
+	out := flag.String("out", "../../pkg/client/client.gen.go", "generated file")
+	check := flag.Bool("check", false, "fail if the generated file is out of date instead of writing it")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	doc, err := api.Parse(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *check {
+		current, _ := os.ReadFile(*out)
+		if !bytes.Equal(current, src) {
+			fmt.Fprintf(os.Stderr, "%s is out of date; run go generate ./internal/api\n", *out)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type generator struct {
+	doc *api.Document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func generate(doc *api.Document) ([]byte, error) {
+	g := &generator{doc: doc}
+
+	for _, name := range doc.Components.Schemas.Keys {
+		if err := g.schemaType(name, doc.Components.Schemas.Values[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, path := range doc.Paths.Keys {
+		item := doc.Paths.Values[path]
+		for _, op := range item.Operations() {
+			if err := g.operation(path, item, op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.Method, path, err)
+			}
+		}
+	}
+
+	body := g.buf.String()
+	var head strings.Builder
+	head.WriteString("// Code generated by internal/api/clientgen from internal/api/openapi.json; DO NOT EDIT.\n\n")
+	head.WriteString("package client\n\nimport (\n")
+	for _, pkg := range []string{"context", "fmt", "io", "net/url", "time"} {
+		if strings.Contains(body, pkg[strings.LastIndex(pkg, "/")+1:]+".") {
+			fmt.Fprintf(&head, "%q\n", pkg)
+		}
+	}
+	head.WriteString(")\n\n")
+
+	src, err := format.Source([]byte(head.String() + body))
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) schemaType(name string, s *api.Schema) error {
+	comment(&g.buf, name, s.Description)
+	if s.Type != "object" || len(s.Properties.Keys) == 0 {
+		t, err := g.goType(s, true)
+		if err != nil {
+			return err
+		}
+		g.printf("type %s %s\n\n", name, t)
+		return nil
+	}
+	g.printf("type %s struct {\n", name)
+	if err := g.fields(s); err != nil {
+		return err
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+func (g *generator) fields(s *api.Schema) error {
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	for _, prop := range s.Properties.Keys {
+		ps := s.Properties.Values[prop]
+		t, err := g.goType(ps, required[prop] && !ps.Nullable)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prop, err)
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		if ps.Description != "" {
+			g.printf("// %s\n", ps.Description)
+		}
+		g.printf("%s %s `json:\"%s\"`\n", exported(prop), t, tag)
+	}
+	return nil
+}
+
+// goType maps a schema to a Go type. Optional or nullable scalars and
+// structs become pointers; slices and maps are already nilable.
+func (g *generator) goType(s *api.Schema, present bool) (string, error) {
+	if len(s.AllOf) == 1 {
+		inner := *s.AllOf[0]
+		inner.Nullable = s.Nullable
+		return g.goType(&inner, present)
+	}
+	ptr := ""
+	if !present {
+		ptr = "*"
+	}
+	if s.Ref != "" {
+		return ptr + api.RefName(s.Ref), nil
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return ptr + "time.Time", nil
+		case "binary":
+			return "io.Reader", nil
+		}
+		return ptr + "string", nil
+	case "integer":
+		if s.Format == "int64" {
+			return ptr + "int64", nil
+		}
+		return ptr + "int", nil
+	case "number":
+		return ptr + "float64", nil
+	case "boolean":
+		return ptr + "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(s.Items, true)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if s.AdditionalProperties != nil {
+			v, err := g.goType(s.AdditionalProperties, true)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + v, nil
+		}
+		if len(s.Properties.Keys) == 0 {
+			return "map[string]interface{}", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported schema type %q", s.Type)
+}
+
+type param struct {
+	*api.Parameter
+	goName string
+	goType string
+}
+
+type success struct {
+	status      string
+	contentType string
+	goType      string
+	schemaName  string
+}
+
+func (g *generator) operation(path string, item *api.PathItem, op api.MethodOperation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("missing operationId")
+	}
+	name := exported(op.OperationID)
+
+	var pathParams, otherParams []param
+	for _, raw := range append(append([]*api.Parameter{}, item.Parameters...), op.Parameters...) {
+		p, err := g.doc.Parameter(raw)
+		if err != nil {
+			return err
+		}
+		present := p.Required || p.In == "path"
+		t, err := g.goType(p.Schema, present)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		if p.In == "path" {
+			pathParams = append(pathParams, param{p, unexported(p.Name), t})
+		} else {
+			otherParams = append(otherParams, param{p, exported(p.Name), t})
+		}
+	}
+
+	var successes []success
+	for _, status := range op.Responses.Keys {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		resp, err := g.doc.Response(op.Responses.Values[status])
+		if err != nil {
+			return err
+		}
+		if len(resp.Content.Keys) == 0 {
+			successes = append(successes, success{status: status})
+			continue
+		}
+		ct := resp.Content.Keys[0]
+		s := success{status: status, contentType: ct}
+		if ct != "application/json" {
+			s.goType = "io.ReadCloser"
+		} else {
+			schema := resp.Content.Values[ct].Schema
+			t, err := g.goType(schema, true)
+			if err != nil {
+				return err
+			}
+			s.goType = t
+			s.schemaName = t
+			if schema.Ref != "" {
+				s.goType = "*" + t
+			}
+		}
+		successes = append(successes, s)
+	}
+
+	if len(otherParams) > 0 {
+		g.printf("// %sParams holds the query and header parameters of %s.\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, p := range otherParams {
+			if p.Description != "" {
+				g.printf("// %s\n", p.Description)
+			}
+			g.printf("%s %s\n", p.goName, p.goType)
+		}
+		g.printf("}\n\n")
+	}
+
+	result := "error"
+	var jsonResults []success
+	for _, s := range successes {
+		if s.contentType == "application/json" {
+			jsonResults = append(jsonResults, s)
+		}
+	}
+	switch {
+	case len(jsonResults) > 1:
+		g.printf("// %sResponse holds whichever body the server returned.\n", name)
+		g.printf("type %sResponse struct {\nStatusCode int\n", name)
+		for _, s := range jsonResults {
+			g.printf("%s %s\n", s.schemaName, s.goType)
+		}
+		g.printf("}\n\n")
+		result = fmt.Sprintf("(*%sResponse, error)", name)
+	case len(successes) > 0 && successes[0].goType != "":
+		result = fmt.Sprintf("(%s, error)", successes[0].goType)
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, p := range pathParams {
+		args = append(args, p.goName+" "+p.goType)
+	}
+	var multipart bool
+	if op.RequestBody != nil && len(op.RequestBody.Content.Keys) > 0 {
+		ct := op.RequestBody.Content.Keys[0]
+		if ct == "multipart/form-data" {
+			multipart = true
+			args = append(args, "body io.Reader", "contentType string")
+		} else {
+			t, err := g.goType(op.RequestBody.Content.Values[ct].Schema, true)
+			if err != nil {
+				return err
+			}
+			args = append(args, "body "+t)
+		}
+	}
+	if len(otherParams) > 0 {
+		args = append(args, fmt.Sprintf("params *%sParams", name))
+	}
+
+	summary := op.Summary
+	if summary == "" {
+		summary = op.OperationID
+	}
+	g.printf("// %s: %s.\n//\n// %s %s\n", name, strings.TrimSuffix(summary, "."), op.Method, path)
+	if multipart {
+		g.printf("//\n// The body is multipart/form-data; see EncodeMultipart.\n")
+	}
+	g.printf("func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), result)
+
+	pathExpr := fmt.Sprintf("%q", path)
+	if len(pathParams) > 0 {
+		format := path
+		var values []string
+		for _, p := range pathParams {
+			format = strings.Replace(format, "{"+p.Name+"}", "%v", 1)
+			values = append(values, "url.PathEscape(fmt.Sprint("+p.goName+"))")
+		}
+		pathExpr = fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(values, ", "))
+	}
+	g.printf("req := request{method: %q, path: %s}\n", op.Method, pathExpr)
+
+	if len(otherParams) > 0 {
+		g.printf("if params != nil {\n")
+		for _, p := range otherParams {
+			field := "params." + p.goName
+			value := field
+			if strings.HasPrefix(p.goType, "*") {
+				value = "*" + field
+				g.printf("if %s != nil {\n", field)
+			}
+			switch p.In {
+			case "query":
+				g.printf("req.query().Set(%q, fmt.Sprint(%s))\n", p.Name, value)
+			case "header":
+				g.printf("req.header().Set(%q, fmt.Sprint(%s))\n", p.Name, value)
+			}
+			if strings.HasPrefix(p.goType, "*") {
+				g.printf("}\n")
+			}
+		}
+		g.printf("}\n")
+	}
+
+	if op.RequestBody != nil {
+		if multipart {
+			g.printf("req.body, req.contentType = body, contentType\n")
+		} else {
+			g.printf("req.json = body\n")
+		}
+	}
+
+	switch {
+	case len(jsonResults) > 1:
+		g.printf("out := &%sResponse{}\n", name)
+		g.printf("resp, err := c.send(ctx, req)\nif err != nil {\nreturn nil, err\n}\ndefer resp.Body.Close()\n")
+		g.printf("out.StatusCode = resp.StatusCode\nswitch resp.StatusCode {\n")
+		for _, s := range jsonResults {
+			g.printf("case %s:\nout.%s = new(%s)\nreturn out, decode(resp, out.%s)\n", s.status, s.schemaName, s.schemaName, s.schemaName)
+		}
+		g.printf("}\nreturn out, nil\n")
+	case result == "error":
+		g.printf("return c.do(ctx, req, nil)\n")
+	case successes[0].goType == "io.ReadCloser":
+		g.printf("resp, err := c.send(ctx, req)\nif err != nil {\nreturn nil, err\n}\nreturn resp.Body, nil\n")
+	default:
+		t := successes[0].goType
+		if strings.HasPrefix(t, "*") {
+			g.printf("out := new(%s)\n", t[1:])
+			g.printf("if err := c.do(ctx, req, out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n")
+		} else {
+			g.printf("var out %s\n", t)
+			g.printf("if err := c.do(ctx, req, &out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n")
+		}
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+func comment(buf *bytes.Buffer, name, description string) {
+	if description != "" {
+		fmt.Fprintf(buf, "// %s: %s\n", name, description)
+	}
+}
+
+var initialisms = map[string]string{
+	"id": "ID", "ids": "IDs", "url": "URL", "api": "API", "json": "JSON", "ok": "OK", "openapi": "OpenAPI", "ip": "IP",
+}
+
+// words splits snake_case, kebab-case and camelCase names.
+func words(name string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if part[i] >= 'A' && part[i] <= 'Z' && part[i-1] >= 'a' && part[i-1] <= 'z' {
+				out = append(out, part[start:i])
+				start = i
+			}
+		}
+		out = append(out, part[start:])
+	}
+	return out
+}
+
+func exported(name string) string {
+	var b strings.Builder
+	for _, w := range words(name) {
+		if v, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+func unexported(name string) string {
+	ws := words(name)
+	if len(ws) == 0 {
+		return name
+	}
+	first := strings.ToLower(ws[0])
+	return first + exported(strings.Join(ws[1:], "_"))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"3d-library/internal/api"
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestClientUpToDate(t *testing.T) {
+	data, err := os.ReadFile("../openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := api.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := os.ReadFile("../../../pkg/client/client.gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, src) {
+		t.Fatal("pkg/client/client.gen.go is out of date; run go generate ./internal/api")
+	}
+}
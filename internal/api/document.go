@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Document is the subset of OpenAPI 3 this project uses. Maps whose order
+// matters for generated code are decoded into Ordered.
+type Document struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      Ordered[*PathItem] `json:"paths"`
+	Components struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+		Responses  map[string]*Response  `json:"responses"`
+		Schemas    Ordered[*Schema]      `json:"schemas"`
+	} `json:"components"`
+}
+
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Post       *Operation   `json:"post"`
+	Put        *Operation   `json:"put"`
+	Patch      *Operation   `json:"patch"`
+	Delete     *Operation   `json:"delete"`
+}
+
+type Operation struct {
+	OperationID string             `json:"operationId"`
+	Summary     string             `json:"summary"`
+	Description string             `json:"description"`
+	Parameters  []*Parameter       `json:"parameters"`
+	RequestBody *RequestBody       `json:"requestBody"`
+	Responses   Ordered[*Response] `json:"responses"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                `json:"required"`
+	Content  Ordered[*MediaType] `json:"content"`
+}
+
+type Response struct {
+	Ref         string              `json:"$ref"`
+	Description string              `json:"description"`
+	Content     Ordered[*MediaType] `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string           `json:"$ref"`
+	Type                 string           `json:"type"`
+	Format               string           `json:"format"`
+	Description          string           `json:"description"`
+	Nullable             bool             `json:"nullable"`
+	Enum                 []string         `json:"enum"`
+	Required             []string         `json:"required"`
+	Properties           Ordered[*Schema] `json:"properties"`
+	Items                *Schema          `json:"items"`
+	AdditionalProperties *Schema          `json:"additionalProperties"`
+	AllOf                []*Schema        `json:"allOf"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+}
+
+// MethodOperation pairs an operation with its HTTP method.
+type MethodOperation struct {
+	Method string
+	*Operation
+}
+
+// Operations returns the operations of a path in a fixed method order.
+func (p *PathItem) Operations() []MethodOperation {
+	var ops []MethodOperation
+	for _, m := range []struct {
+		method string
+		op     *Operation
+	}{
+		{"GET", p.Get}, {"POST", p.Post}, {"PUT", p.Put}, {"PATCH", p.Patch}, {"DELETE", p.Delete},
+	} {
+		if m.op != nil {
+			ops = append(ops, MethodOperation{m.method, m.op})
+		}
+	}
+	return ops
+}
+
+// BasePath is the path of the first server, which every route lives under.
+func (d *Document) BasePath() string {
+	if len(d.Servers) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(d.Servers[0].URL, "/")
+}
+
+// Parameter resolves a parameter reference.
+func (d *Document) Parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	resolved, ok := d.Components.Parameters[RefName(p.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("unknown parameter %s", p.Ref)
+	}
+	return resolved, nil
+}
+
+// Response resolves a response reference.
+func (d *Document) Response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	resolved, ok := d.Components.Responses[RefName(r.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("unknown response %s", r.Ref)
+	}
+	return resolved, nil
+}
+
+// RefName returns the last element of a local reference such as
+// "#/components/schemas/Model".
+func RefName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// Ordered is a JSON object that remembers the order of its keys.
+type Ordered[T any] struct {
+	Keys   []string
+	Values map[string]T
+}
+
+func (o *Ordered[T]) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+	o.Keys = nil
+	o.Values = make(map[string]T)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if _, dup := o.Values[key]; !dup {
+			o.Keys = append(o.Keys, key)
+		}
+		o.Values[key] = v
+	}
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,67 @@
+// Package audit appends entries to the audit log for changes made through
+// the API and by background jobs.
+package audit
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"encoding/json"
+)
+
+// Actions recorded in the log. Add and Remove change what a library or
+// collection contains; Tag and Untag change a model's tags. Delete moves
+// libraries, models and files to the trash, Restore brings them back and
+// Purge removes them for good.
+const (
+	Create  = "create"
+	Update  = "update"
+	Delete  = "delete"
+	Restore = "restore"
+	Purge   = "purge"
+	Upload  = "upload"
+	Scan    = "scan"
+	Tag     = "tag"
+	Untag   = "untag"
+	Add     = "add"
+	Remove  = "remove"
+)
+
+// Record appends an entry for a change made by the user in ctx, from the
+// address in ctx. before and after are stored as JSON; nil leaves them
+// empty, as does an id of 0.
+func Record(ctx context.Context, st store.Store, action, entity string, id int64, before, after interface{}) error {
+	e := &models.AuditEntry{Action: action, Entity: entity, SourceIP: auth.ClientIP(ctx)}
+	switch user := auth.UserFrom(ctx); {
+	case user == nil:
+		e.Actor = "anonymous"
+	case user.ID == 0:
+		e.Actor = user.Username
+	default:
+		e.ActorID, e.Actor = &user.ID, user.Username
+	}
+	if id != 0 {
+		e.EntityID = &id
+	}
+	var err error
+	if e.Before, err = value(before); err != nil {
+		return err
+	}
+	if e.After, err = value(after); err != nil {
+		return err
+	}
+	return st.Audit().Append(ctx, e)
+}
+
+func value(v interface{}) (*models.JSON, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return nil, err
+	}
+	doc := models.JSON(b)
+	return &doc, nil
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+)
+
+// Role is what a user may do in one library. Each role includes the ones
+// before it:
+//
+//   - viewer: browse, search and download
+//   - contributor: upload, add models, tag and record prints
+//   - editor: change and delete models and files, and scan
+//   - admin: change or delete the library and manage its members
+type Role string
+
+const (
+	Viewer      Role = "viewer"
+	Contributor Role = "contributor"
+	Editor      Role = "editor"
+	Admin       Role = "admin"
+)
+
+// Roles lists the roles from least to most.
+var Roles = []Role{Viewer, Contributor, Editor, Admin}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (r Role) Valid() bool { return r.rank() > 0 }
+
+// Includes reports whether r allows everything other does.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && r.rank() >= other.rank()
+}
+
+// Access is what the user behind a request may do in each library.
+type Access struct {
+	// User is nil for anonymous requests.
+	User *models.User
+	// Roles maps library IDs to the user's role there.
+	Roles map[int64]Role
+	// ReadAll makes every library visible, as anonymous read access does.
+	ReadAll bool
+}
+
+// NewAccess builds the access of user, who may be nil, from their
+// memberships.
+func NewAccess(user *models.User, memberships []models.LibraryMember, readAll bool) *Access {
+	a := &Access{User: user, Roles: map[int64]Role{}, ReadAll: readAll}
+	for _, m := range memberships {
+		a.Roles[m.LibraryID] = Role(m.Role)
+	}
+	return a
+}
+
+// LoadAccess builds the access of user, who may be nil, reading their
+// memberships from st. Site administrators and the system user need none.
+func LoadAccess(ctx context.Context, st store.Store, user *models.User, readAll bool) (*Access, error) {
+	var memberships []models.LibraryMember
+	if user != nil && user.ID != 0 && !user.Admin {
+		var err error
+		if memberships, err = st.Members().ForUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return NewAccess(user, memberships, readAll), nil
+}
+
+// Role returns the user's role in the library, or "" for none. Site
+// administrators are admins of every library.
+func (a *Access) Role(libraryID int64) Role {
+	if a.User != nil && a.User.Admin {
+		return Admin
+	}
+	if role, ok := a.Roles[libraryID]; ok {
+		return role
+	}
+	if a.ReadAll {
+		return Viewer
+	}
+	return ""
+}
+
+func (a *Access) Can(libraryID int64, need Role) bool {
+	return a.Role(libraryID).Includes(need)
+}
+
+// Owns reports whether the user may change something owned by ownerID,
+// which site administrators may whoever owns it.
+func (a *Access) Owns(ownerID *int64) bool {
+	if a.User == nil {
+		return false
+	}
+	return a.User.Admin || ownerID != nil && *ownerID == a.User.ID
+}
+
+// Libraries returns the IDs of the libraries the user can see, or nil when
+// they can see every library.
+func (a *Access) Libraries() []int64 {
+	if a.ReadAll || a.User != nil && a.User.Admin {
+		return nil
+	}
+	ids := []int64{}
+	for id := range a.Roles {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+type accessKey struct{}
+
+func WithAccess(ctx context.Context, a *Access) context.Context {
+	return context.WithValue(ctx, accessKey{}, a)
+}
+
+// AccessFrom returns the request's access. Without one, nothing is
+// allowed.
+func AccessFrom(ctx context.Context) *Access {
+	if a, ok := ctx.Value(accessKey{}).(*Access); ok {
+		return a
+	}
+	return &Access{User: UserFrom(ctx)}
+}
@@ -0,0 +1,104 @@
+// Package auth hashes passwords and the secrets behind sessions and API
+// tokens, and carries the signed-in user through a request's context.
+package auth
+
+import (
+	"3d-library/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TokenPrefix starts every API token so they are easy to spot in scripts
+// and secret scanners.
+const TokenPrefix = "g3d_"
+
+// SharePrefix starts the secret in every share link.
+const SharePrefix = "g3s_"
+
+// ErrPassword is returned by CheckPassword for a wrong password.
+var ErrPassword = errors.New("wrong password")
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword compares password with a hash from HashPassword. An empty
+// hash, as for a user who cannot sign in with a password, never matches,
+// but still takes as long as a real comparison.
+func CheckPassword(hash, password string) error {
+	if hash == "" {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if hash == dummyHash || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPassword
+		}
+		return err
+	}
+	if hash == dummyHash {
+		return ErrPassword
+	}
+	return nil
+}
+
+// dummyHash is compared against when there is no user, so failed logins
+// take the same time whether or not the username exists.
+var dummyHash = func() string {
+	hash, _ := HashPassword("go3d-no-such-user")
+	return hash
+}()
+
+// NewSecret returns a random secret starting with prefix and the hash to
+// store in its place.
+func NewSecret(prefix string) (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = prefix + base64.RawURLEncoding.EncodeToString(b)
+	return secret, HashSecret(secret), nil
+}
+
+// HashSecret hashes a session ID or API token for lookup. The secrets are
+// random, so a fast hash is enough.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// System is the user the go3d command acts as when it runs the server's
+// handlers in process: an administrator without an account.
+var System = &models.User{Username: "go3d", Admin: true}
+
+type userKey struct{}
+
+func WithUser(ctx context.Context, u *models.User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// UserFrom returns the user making the request, or nil when it is
+// anonymous.
+func UserFrom(ctx context.Context) *models.User {
+	u, _ := ctx.Value(userKey{}).(*models.User)
+	return u
+}
+
+type clientIPKey struct{}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP returns the address the request came from, or "" outside a
+// request.
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GroupRule gives members of an identity provider group a role in a
+// library, named by name or ID, or in every library for "*".
+type GroupRule struct {
+	Group   string
+	Library string
+	Role    Role
+}
+
+// ParseGroupRule reads a rule written as GROUP=LIBRARY:ROLE.
+func ParseGroupRule(s string) (GroupRule, error) {
+	group, rest, ok := strings.Cut(s, "=")
+	i := strings.LastIndex(rest, ":")
+	if !ok || i < 0 {
+		return GroupRule{}, fmt.Errorf("%q is not GROUP=LIBRARY:ROLE", s)
+	}
+	rule := GroupRule{Group: strings.TrimSpace(group), Library: strings.TrimSpace(rest[:i]), Role: Role(strings.TrimSpace(rest[i+1:]))}
+	if rule.Group == "" || rule.Library == "" {
+		return GroupRule{}, fmt.Errorf("%q is not GROUP=LIBRARY:ROLE", s)
+	}
+	if !rule.Role.Valid() {
+		return GroupRule{}, fmt.Errorf("%q: role must be viewer, contributor, editor or admin", s)
+	}
+	return rule, nil
+}
+
+func (g GroupRule) matches(l models.Library) bool {
+	return g.Library == "*" || strings.EqualFold(g.Library, l.Name) || g.Library == strconv.FormatInt(l.ID, 10)
+}
+
+// Groups turns the groups an identity provider or proxy reports for a
+// user into Go3D rights.
+type Groups struct {
+	// Admins lists the groups whose members are site administrators. When
+	// empty, admin rights are managed in Go3D only.
+	Admins []string
+	Rules  []GroupRule
+}
+
+// RoleChange is a membership Sync added, changed or removed. Before is nil
+// for a new one and After for a removed one.
+type RoleChange struct {
+	LibraryID int64
+	Before    *models.LibraryMember
+	After     *models.LibraryMember
+}
+
+// Sync brings the user's admin flag and memberships in line with groups,
+// returning the memberships it changed. Only libraries that some rule names
+// are touched, so roles granted by hand elsewhere stay. Nothing is written
+// when nothing changed.
+func (g *Groups) Sync(ctx context.Context, st store.Store, user *models.User, groups []string) ([]RoleChange, error) {
+	member := func(group string) bool { return slices.Contains(groups, group) }
+
+	if len(g.Admins) > 0 {
+		admin := slices.ContainsFunc(g.Admins, member)
+		if admin != user.Admin {
+			user.Admin = admin
+			if err := st.Users().Update(ctx, user); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(g.Rules) == 0 {
+		return nil, nil
+	}
+
+	libraries, err := st.Libraries().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := st.Members().ForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	have := map[int64]*models.LibraryMember{}
+	for i := range current {
+		have[current[i].LibraryID] = &current[i]
+	}
+
+	var changes []RoleChange
+
+	for _, l := range libraries {
+		managed := false
+		var want Role
+		for _, rule := range g.Rules {
+			if !rule.matches(l) {
+				continue
+			}
+			managed = true
+			if member(rule.Group) && !want.Includes(rule.Role) {
+				want = rule.Role
+			}
+		}
+		old := have[l.ID]
+		switch {
+		case !managed || old != nil && Role(old.Role) == want:
+		case want == "" && old != nil:
+			if err := st.Members().Remove(ctx, l.ID, user.ID); err != nil {
+				return nil, err
+			}
+			changes = append(changes, RoleChange{LibraryID: l.ID, Before: old})
+		case want != "":
+			m := &models.LibraryMember{LibraryID: l.ID, UserID: user.ID, Role: string(want)}
+			if err := st.Members().Set(ctx, m); err != nil {
+				return nil, err
+			}
+			changes = append(changes, RoleChange{LibraryID: l.ID, Before: old, After: m})
+		}
+	}
+	return changes, nil
+}
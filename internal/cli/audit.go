@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func init() {
+	register(
+		&command{name: "audit list", summary: "Show recent changes from the audit log", setup: setupAuditList},
+		&command{name: "audit export", summary: "Download the audit log as CSV or JSON", setup: setupAuditExport},
+	)
+}
+
+// auditFlags are the filters both audit commands take.
+type auditFlags struct {
+	actor, action, entity, since, until *string
+	entityID                            *int64
+}
+
+func newAuditFlags(fs *flag.FlagSet) *auditFlags {
+	return &auditFlags{
+		actor:    fs.String("actor", "", "only changes by this user; go3d for the system"),
+		action:   fs.String("action", "", "only this action: create, update, delete, upload, scan, tag, untag, add or remove"),
+		entity:   fs.String("entity", "", "only this kind of entity, such as model or library"),
+		entityID: fs.Int64("id", 0, "only changes to the entity with this id"),
+		since:    fs.String("since", "", "only changes at or after this date or RFC 3339 time"),
+		until:    fs.String("until", "", "only changes before this date or RFC 3339 time"),
+	}
+}
+
+// set returns a pointer to v, or nil for an unset flag.
+func set[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return &v
+}
+
+func setupAuditList(fs *flag.FlagSet) runFunc {
+	f := newAuditFlags(fs)
+	limit := fs.Int("limit", 50, "show this many entries, newest first")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 || *limit < 1 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		page, err := c.ListAudit(ctx, &client.ListAuditParams{
+			Limit: limit, Actor: set(*f.actor), Action: set(*f.action), Entity: set(*f.entity),
+			EntityID: set(*f.entityID), Since: set(*f.since), Until: set(*f.until),
+		})
+		if err != nil {
+			return err
+		}
+		return e.print(page.Items, func(w io.Writer) {
+			fmt.Fprintln(w, "TIME\tACTOR\tACTION\tENTITY\tSOURCE")
+			for _, a := range page.Items {
+				entity := a.Entity
+				if a.EntityID != nil {
+					entity = fmt.Sprintf("%s %d", a.Entity, *a.EntityID)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.CreatedAt.Local().Format("2006-01-02 15:04:05"), a.Actor, a.Action, entity, a.SourceIP)
+			}
+		})
+	}
+}
+
+func setupAuditExport(fs *flag.FlagSet) runFunc {
+	f := newAuditFlags(fs)
+	format := fs.String("format", "csv", "csv or json")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 || *format != "csv" && *format != "json" {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		body, err := c.ExportAudit(ctx, &client.ExportAuditParams{
+			Format: format, Order: ptr("asc"), Actor: set(*f.actor), Action: set(*f.action), Entity: set(*f.entity),
+			EntityID: set(*f.entityID), Since: set(*f.since), Until: set(*f.until),
+		})
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		w := e.out
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		_, err = io.Copy(w, body)
+		return err
+	}
+}
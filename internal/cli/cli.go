@@ -0,0 +1,150 @@
+// Package cli implements go3d: the web server, the worker, and admin
+// commands that work on the database directly or, with -server, on a
+// running server through its REST API.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// errUsage makes Main print the command's usage and exit 2.
+var errUsage = errors.New("usage")
+
+type runFunc func(ctx context.Context, e *env, args []string) error
+
+type command struct {
+	name    string // "library add"
+	args    string // arguments after the flags, for usage
+	summary string
+	// setup registers the command's own flags and returns what runs it.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = map[string]*command{}
+
+func register(cmds ...*command) {
+	for _, c := range cmds {
+		commands[c.name] = c
+	}
+}
+
+// Main runs go3d with args, not counting the program name, and returns the
+// exit code.
+func Main(args []string) int {
+	cmd, rest := lookup(args)
+	if cmd == nil {
+		if len(args) == 1 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help") {
+			usage(os.Stdout)
+			return 0
+		}
+		if len(args) > 0 && !usageGroup(os.Stderr, args[0]) {
+			fmt.Fprintf(os.Stderr, "go3d: unknown command %q\n\n", strings.Join(args[:min(len(args), 2)], " "))
+			usage(os.Stderr)
+		} else if len(args) == 0 {
+			usage(os.Stderr)
+		}
+		return 2
+	}
+
+	fs := flag.NewFlagSet("go3d "+cmd.name, flag.ContinueOnError)
+	e := newEnv(fs)
+	run := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: go3d %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	// Flags may come before, between or after the arguments.
+	var positional []string
+	for {
+		if err := fs.Parse(rest); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	defer e.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, e, positional)
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		e.printError(err)
+		return 1
+	}
+	return 0
+}
+
+// lookup finds the command named by the first one or two words of args and
+// returns it with the remaining arguments.
+func lookup(args []string) (*command, []string) {
+	if len(args) >= 2 {
+		if c, ok := commands[args[0]+" "+args[1]]; ok {
+			return c, args[2:]
+		}
+	}
+	if len(args) >= 1 {
+		if c, ok := commands[args[0]]; ok {
+			return c, args[1:]
+		}
+	}
+	return nil, nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: go3d COMMAND [flags] [args]")
+	fmt.Fprintln(w)
+	listCommands(w, "")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Every command takes -config FILE; admin commands also take -server URL")
+	fmt.Fprintln(w, "to work through a running server's API instead of the database, -token")
+	fmt.Fprintln(w, "to authenticate there, and -json for machine-readable output. Run")
+	fmt.Fprintln(w, "go3d COMMAND -h for its flags.")
+}
+
+// usageGroup lists the subcommands of group, such as "library", and
+// reports whether it has any.
+func usageGroup(w io.Writer, group string) bool {
+	if listCommands(io.Discard, group+" ") == 0 {
+		return false
+	}
+	fmt.Fprintf(w, "usage: go3d %s SUBCOMMAND [flags] [args]\n\n", group)
+	listCommands(w, group+" ")
+	return true
+}
+
+// listCommands lists the commands whose names start with prefix and
+// returns how many there are.
+func listCommands(w io.Writer, prefix string) int {
+	names := []string{}
+	for name := range commands {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := commands[name]
+		fmt.Fprintf(w, "  %-32s %s\n", strings.TrimSpace(name+" "+c.args), c.summary)
+	}
+	return len(names)
+}
@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/config"
+	"3d-library/internal/jobs"
+	"3d-library/internal/migrate"
+	"3d-library/internal/server"
+	"3d-library/internal/store/sqlstore"
+	"3d-library/pkg/client"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// env holds the flags every command shares and the connections a command
+// opens, which are closed when it finishes.
+type env struct {
+	configFile string
+	serverURL  string
+	token      string
+	json       bool
+	out        io.Writer
+
+	cfg   *config.Config
+	db    *sqlx.DB
+	queue jobs.Queue
+	api   *client.Client
+}
+
+func newEnv(fs *flag.FlagSet) *env {
+	e := &env{out: os.Stdout}
+	fs.StringVar(&e.configFile, "config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	fs.StringVar(&e.serverURL, "server", os.Getenv("GO3D_SERVER"), "base URL of a running server, e.g. http://nas:3000; empty uses the database")
+	fs.StringVar(&e.token, "token", os.Getenv("GO3D_TOKEN"), "API token to act as its user")
+	fs.BoolVar(&e.json, "json", false, "print JSON")
+	return e
+}
+
+func (e *env) close() {
+	if e.queue != nil {
+		e.queue.Close()
+	}
+	if e.db != nil {
+		e.db.Close()
+	}
+}
+
+func (e *env) config() (*config.Config, error) {
+	if e.cfg != nil {
+		return e.cfg, nil
+	}
+	cfg, err := config.Load(e.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	e.cfg = cfg
+	return cfg, nil
+}
+
+// database connects to the configured database for commands that need it
+// directly. It refuses a schema with pending migrations, which only the
+// migrate command and the server apply.
+func (e *env) database() (*sqlx.DB, error) {
+	if e.db != nil {
+		return e.db, nil
+	}
+	if e.serverURL != "" {
+		return nil, errors.New("this command works on the database directly and cannot use -server")
+	}
+	cfg, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+	db, err := server.Setup(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.db = db
+	pending, err := migrate.Check(db)
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("%d migrations pending; run \"go3d migrate up\"", pending)
+	}
+	if err := server.DefaultRoots(cfg, db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// client returns an API client for the server at -server or, without it,
+// one that runs the server's handlers in this process against the
+// database, so both paths apply the same validation. In process, commands
+// act as auth.System unless given a token: whoever can open the database
+// already controls it.
+func (e *env) client() (*client.Client, error) {
+	if e.api != nil {
+		return e.api, nil
+	}
+	if e.serverURL != "" {
+		e.api = client.New(strings.TrimSuffix(e.serverURL, "/") + "/api")
+		e.setToken()
+		return e.api, nil
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return nil, err
+	}
+	cfg := *e.cfg
+	cfg.Log.Requests = false
+	e.queue = server.NewQueue(&cfg, db)
+	// There is no one else to share the server with in process, so
+	// nothing is rate limited.
+	router := server.NewRouter(&cfg, sqlstore.New(db), db, e.queue, nil)
+
+	// https, so session cookies marked Secure are sent back.
+	e.api = client.New("https://go3d/api")
+	e.api.HTTPClient = &http.Client{Transport: handlerTransport{router}}
+	e.setToken()
+	return e.api, nil
+}
+
+func (e *env) setToken() {
+	if e.token != "" {
+		e.api.Header.Set("Authorization", "Bearer "+e.token)
+	}
+}
+
+// handlerTransport answers requests by calling an http.Handler directly.
+// Requests without a token or session cookie are made as auth.System.
+type handlerTransport struct{ h http.Handler }
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	if req.Header.Get("Authorization") == "" && req.Header.Get("Cookie") == "" {
+		req = req.WithContext(auth.WithUser(req.Context(), auth.System))
+	}
+	t.h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// print writes v as JSON with -json, and otherwise calls text to write it
+// for people. text writes to a tabwriter, so columns separated by tabs line
+// up.
+func (e *env) print(v interface{}, text func(w io.Writer)) error {
+	if e.json {
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+// printError reports err on stderr, as the API's error envelope with -json.
+func (e *env) printError(err error) {
+	if !e.json {
+		fmt.Fprintln(os.Stderr, "go3d:", err)
+		return
+	}
+	body := client.ErrorResponse{Error: client.APIError{Code: "error", Message: err.Error()}}
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		body.Error = apiErr.APIError
+	}
+	enc := json.NewEncoder(os.Stderr)
+	enc.SetIndent("", "  ")
+	enc.Encode(body)
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func init() {
+	register(&command{name: "export", summary: "Write libraries, models with their files and tags, and collections as JSON", setup: setupExport})
+}
+
+type export struct {
+	ExportedAt  time.Time          `json:"exported_at"`
+	Libraries   []client.Library   `json:"libraries"`
+	Models      []exportModel      `json:"models"`
+	Collections []exportCollection `json:"collections"`
+}
+
+type exportModel struct {
+	client.Model
+	Tags  []string           `json:"tags"`
+	Files []client.ModelFile `json:"files"`
+}
+
+type exportCollection struct {
+	client.Collection
+	ModelIDs []int64 `json:"model_ids"`
+}
+
+// setupExport always writes JSON. With -library or -q only the matching
+// models are exported, and collections list only those members.
+func setupExport(fs *flag.FlagSet) runFunc {
+	library := fs.String("library", "", "only models in this library, by id or name")
+	query := fs.String("q", "", "only models matching this search query")
+	output := fs.String("o", "", "write to this file instead of standard output")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+
+		out := export{ExportedAt: time.Now().UTC(), Collections: []exportCollection{}}
+		if out.Libraries, err = c.ListLibraries(ctx); err != nil {
+			return err
+		}
+		list, err := listModels(ctx, c, *library, *query)
+		if err != nil {
+			return err
+		}
+		included := map[int64]bool{}
+		out.Models = make([]exportModel, len(list))
+		for i, m := range list {
+			included[m.ID] = true
+			em := exportModel{Model: m, Tags: []string{}}
+			tags, err := c.ListModelTags(ctx, m.ID)
+			if err != nil {
+				return err
+			}
+			for _, t := range tags {
+				em.Tags = append(em.Tags, t.Name)
+			}
+			files := &client.ListModelFilesParams{Limit: ptr(500)}
+			em.Files, err = pages(func(cursor *string) ([]client.ModelFile, *string, error) {
+				files.Cursor = cursor
+				page, err := c.ListModelFiles(ctx, m.ID, files)
+				if err != nil {
+					return nil, nil, err
+				}
+				return page.Items, page.NextCursor, nil
+			})
+			if err != nil {
+				return err
+			}
+			out.Models[i] = em
+		}
+
+		cparams := &client.ListCollectionsParams{Limit: ptr(500)}
+		collections, err := pages(func(cursor *string) ([]client.Collection, *string, error) {
+			cparams.Cursor = cursor
+			page, err := c.ListCollections(ctx, cparams)
+			if err != nil {
+				return nil, nil, err
+			}
+			return page.Items, page.NextCursor, nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, col := range collections {
+			ec := exportCollection{Collection: col, ModelIDs: []int64{}}
+			mparams := &client.ListCollectionModelsParams{Limit: ptr(500)}
+			members, err := pages(func(cursor *string) ([]client.Model, *string, error) {
+				mparams.Cursor = cursor
+				page, err := c.ListCollectionModels(ctx, col.ID, mparams)
+				if err != nil {
+					return nil, nil, err
+				}
+				return page.Items, page.NextCursor, nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, m := range members {
+				if included[m.ID] {
+					ec.ModelIDs = append(ec.ModelIDs, m.ID)
+				}
+			}
+			out.Collections = append(out.Collections, ec)
+		}
+
+		w := e.out
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+		if *output != "" && !e.json {
+			fmt.Fprintf(os.Stderr, "exported %d models to %s\n", len(out.Models), *output)
+		}
+		return nil
+	}
+}
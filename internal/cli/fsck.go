@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/store"
+	"3d-library/internal/store/sqlstore"
+	"3d-library/internal/thumbnail"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register(&command{name: "fsck", summary: "Check the database against the files on disk", setup: setupFsck})
+}
+
+// problem is one inconsistency found by fsck.
+type problem struct {
+	Kind   string `json:"kind"`
+	ID     int64  `json:"id,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Detail string `json:"detail"`
+	Fixed  bool   `json:"fixed"`
+}
+
+// setupFsck reports libraries, models and files whose paths are gone, files
+// whose size changed, previews pointing at another model's file and cached
+// thumbnails of deleted models. With -fix it moves missing files to the
+// trash, resets bad previews and removes stray thumbnails; the rest needs a
+// rescan or a person. It exits 1 while anything is left unfixed.
+func setupFsck(fs *flag.FlagSet) runFunc {
+	fix := fs.Bool("fix", false, "repair what can be repaired without touching model files")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		db, err := e.database()
+		if err != nil {
+			return err
+		}
+		st := sqlstore.New(db)
+		// Fixes go in the audit log as made by the system.
+		system := auth.WithUser(ctx, auth.System)
+		problems := []problem{}
+
+		var libraries []struct {
+			ID   int64  `db:"id"`
+			Path string `db:"path"`
+		}
+		if err := db.SelectContext(ctx, &libraries, "SELECT id, path FROM libraries WHERE deleted_at IS NULL ORDER BY id"); err != nil {
+			return err
+		}
+		for _, l := range libraries {
+			if info, err := os.Stat(l.Path); err != nil || !info.IsDir() {
+				problems = append(problems, problem{Kind: "library_missing", ID: l.ID, Path: l.Path, Detail: "library directory is missing"})
+			}
+		}
+
+		var modelRows []struct {
+			ID      int64  `db:"id"`
+			Path    string `db:"path"`
+			Trashed bool   `db:"trashed"`
+		}
+		err = db.SelectContext(ctx, &modelRows, "SELECT id, path, deleted_at IS NOT NULL AS trashed FROM models ORDER BY id")
+		if err != nil {
+			return err
+		}
+		// Models in the trash keep their thumbnails but are not checked.
+		modelIDs := map[int64]bool{}
+		for _, m := range modelRows {
+			modelIDs[m.ID] = true
+			if m.Trashed {
+				continue
+			}
+			if info, err := os.Stat(m.Path); err != nil || !info.IsDir() {
+				problems = append(problems, problem{Kind: "model_missing", ID: m.ID, Path: m.Path, Detail: "model directory is missing"})
+			}
+		}
+
+		var files []struct {
+			ID      int64  `db:"id"`
+			ModelID int64  `db:"model_id"`
+			Path    string `db:"path"`
+			Size    int64  `db:"size"`
+		}
+		if err := db.SelectContext(ctx, &files, "SELECT id, model_id, path, size FROM model_files WHERE deleted_at IS NULL ORDER BY id"); err != nil {
+			return err
+		}
+		touched := map[int64]bool{}
+		for _, f := range files {
+			info, err := os.Stat(f.Path)
+			switch {
+			case err != nil:
+				p := problem{Kind: "file_missing", ID: f.ID, Path: f.Path, Detail: fmt.Sprintf("file of model %d is missing", f.ModelID)}
+				if *fix {
+					removed, err := st.Files().Delete(ctx, f.ID)
+					if err != nil {
+						return err
+					}
+					if err := audit.Record(system, st, audit.Delete, "file", f.ID, removed, nil); err != nil {
+						return err
+					}
+					p.Fixed, touched[f.ModelID] = true, true
+				}
+				problems = append(problems, p)
+			case info.Size() != f.Size:
+				problems = append(problems, problem{Kind: "file_size", ID: f.ID, Path: f.Path,
+					Detail: fmt.Sprintf("size is %d on disk but %d recorded; rescan the library", info.Size(), f.Size)})
+			}
+		}
+
+		var previews []int64
+		err = db.SelectContext(ctx, &previews, `
+			SELECT m.id FROM models m JOIN model_files f ON f.id = m.preview_file_id
+			WHERE f.model_id <> m.id ORDER BY m.id`)
+		if err != nil {
+			return err
+		}
+		for _, id := range previews {
+			p := problem{Kind: "preview_foreign", ID: id, Detail: "preview is a file of another model"}
+			if *fix {
+				if err := st.Models().SetPreview(ctx, id, nil); err != nil {
+					return err
+				}
+				if err := audit.Record(system, st, audit.Update, "model", id, nil, map[string]interface{}{"preview_file_id": nil}); err != nil {
+					return err
+				}
+				p.Fixed, touched[id] = true, true
+			}
+			problems = append(problems, p)
+		}
+		for id := range touched {
+			// Deleting a preview file clears the preview; pick another.
+			m, err := st.Models().Get(ctx, id)
+			if err != nil {
+				return err
+			}
+			if m.PreviewFileID == nil {
+				store.ChooseDefaultPreview(ctx, st, id)
+			}
+			if err := st.Models().RefreshStats(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		thumbs, err := os.ReadDir(thumbnail.Dir())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, t := range thumbs {
+			id, err := strconv.ParseInt(strings.TrimSuffix(t.Name(), ".png"), 10, 64)
+			if err != nil || modelIDs[id] {
+				continue
+			}
+			p := problem{Kind: "thumbnail_orphan", ID: id, Path: filepath.Join(thumbnail.Dir(), t.Name()), Detail: "thumbnail of a model that no longer exists"}
+			if *fix {
+				if err := os.Remove(p.Path); err != nil {
+					return err
+				}
+				p.Fixed = true
+			}
+			problems = append(problems, p)
+		}
+
+		unfixed := 0
+		for _, p := range problems {
+			if !p.Fixed {
+				unfixed++
+			}
+		}
+		if err := e.print(problems, func(w io.Writer) {
+			for _, p := range problems {
+				status := ""
+				if p.Fixed {
+					status = "fixed"
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", p.Kind, p.ID, p.Detail, p.Path, status)
+			}
+			if len(problems) == 0 {
+				fmt.Fprintln(w, "no problems found")
+			}
+		}); err != nil {
+			return err
+		}
+		if unfixed > 0 {
+			return fmt.Errorf("%d problems left unfixed", unfixed)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"3d-library/internal/jobs"
+	"3d-library/internal/store/sqlstore"
+	"3d-library/pkg/client"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register(
+		&command{name: "library list", summary: "List libraries", setup: setupLibraryList},
+		&command{name: "library add", args: "NAME PATH", summary: "Add a library for an existing directory", setup: setupLibraryAdd},
+		&command{name: "library scan", args: "LIBRARY", summary: "Queue a scan of a library, by id or name", setup: setupLibraryScan},
+		&command{name: "library members", args: "LIBRARY", summary: "List the users with a role in a library", setup: setupLibraryMembers},
+		&command{name: "library grant", args: "LIBRARY USER ROLE", summary: "Give a user viewer, contributor, editor or admin rights in a library", setup: setupLibraryGrant},
+		&command{name: "library revoke", args: "LIBRARY USER", summary: "Take away a user's role in a library", setup: setupLibraryRevoke},
+	)
+}
+
+func setupLibraryList(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		list, err := c.ListLibraries(ctx)
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tNAME\tSTORAGE\tPATH")
+			for _, l := range list {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", l.ID, l.Name, l.Storage, l.Path)
+			}
+		})
+	}
+}
+
+func setupLibraryAdd(fs *flag.FlagSet) runFunc {
+	storage := fs.String("storage", "", "storage kind; empty uses the server default")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 2 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		body := client.LibraryCreate{Name: args[0], Path: args[1]}
+		if *storage != "" {
+			body.Storage = storage
+		}
+		l, err := c.CreateLibrary(ctx, body)
+		if err != nil {
+			return err
+		}
+		return e.print(l, func(w io.Writer) {
+			fmt.Fprintf(w, "added library %d %q at %s\n", l.ID, l.Name, l.Path)
+		})
+	}
+}
+
+func setupLibraryScan(fs *flag.FlagSet) runFunc {
+	now := fs.Bool("now", false, "scan in this process and wait, instead of queueing a job (database only)")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		l, err := findLibrary(ctx, c, args[0])
+		if err != nil {
+			return err
+		}
+
+		if *now {
+			db, err := e.database()
+			if err != nil {
+				return err
+			}
+			task, err := jobs.NewScanLibraryTask(l.ID, l.Path)
+			if err != nil {
+				return err
+			}
+			if err := jobs.HandleScanLibraryTask(ctx, task, sqlstore.New(db), db); err != nil {
+				return err
+			}
+			return e.print(map[string]interface{}{"library_id": l.ID, "scanned": true}, func(w io.Writer) {
+				fmt.Fprintf(w, "scanned library %d %q\n", l.ID, l.Name)
+			})
+		}
+
+		res, err := c.ScanLibrary(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		return e.print(res, func(w io.Writer) {
+			fmt.Fprintf(w, "queued scan of library %d %q as job %s\n", l.ID, l.Name, res.JobID)
+		})
+	}
+}
+
+func setupLibraryMembers(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		l, err := findLibrary(ctx, c, args[0])
+		if err != nil {
+			return err
+		}
+		list, err := c.ListLibraryMembers(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "USER ID\tUSERNAME\tROLE")
+			for _, m := range list {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", m.UserID, m.Username, m.Role)
+			}
+		})
+	}
+}
+
+func setupLibraryGrant(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 3 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		l, err := findLibrary(ctx, c, args[0])
+		if err != nil {
+			return err
+		}
+		m, err := c.SetLibraryMember(ctx, l.ID, client.LibraryMemberSet{Username: args[1], Role: client.Role(args[2])})
+		if err != nil {
+			return err
+		}
+		return e.print(m, func(w io.Writer) {
+			fmt.Fprintf(w, "%q is now %s of library %q\n", m.Username, m.Role, l.Name)
+		})
+	}
+}
+
+func setupLibraryRevoke(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 2 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		l, err := findLibrary(ctx, c, args[0])
+		if err != nil {
+			return err
+		}
+		list, err := c.ListLibraryMembers(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			if strings.EqualFold(m.Username, args[1]) || strconv.FormatInt(m.UserID, 10) == args[1] {
+				if err := c.RemoveLibraryMember(ctx, l.ID, m.UserID); err != nil {
+					return err
+				}
+				return e.print(map[string]int64{"library_id": l.ID, "user_id": m.UserID}, func(w io.Writer) {
+					fmt.Fprintf(w, "removed %q from library %q\n", m.Username, l.Name)
+				})
+			}
+		}
+		return fmt.Errorf("%q has no role in library %q", args[1], l.Name)
+	}
+}
+
+// findLibrary looks a library up by id, or else by name.
+func findLibrary(ctx context.Context, c *client.Client, ref string) (*client.Library, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return c.GetLibrary(ctx, id)
+	}
+	list, err := c.ListLibraries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var match *client.Library
+	for i, l := range list {
+		if l.Name == ref {
+			return &list[i], nil
+		}
+		if strings.EqualFold(l.Name, ref) {
+			if match != nil {
+				return nil, fmt.Errorf("library name %q is ambiguous; use its id", ref)
+			}
+			match = &list[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no library named %q", ref)
+	}
+	return match, nil
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"archive/zip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register(
+		&command{name: "model list", summary: "List models, optionally filtered by library or search query", setup: setupModelList},
+		&command{name: "model import", args: "DIR", summary: "Upload a directory as a model into a library", setup: setupModelImport},
+	)
+}
+
+func setupModelList(fs *flag.FlagSet) runFunc {
+	library := fs.String("library", "", "only models in this library, by id or name")
+	query := fs.String("q", "", "search query, e.g. 'tag:dragon fmt:3mf'")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		list, err := listModels(ctx, c, *library, *query)
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tLIBRARY\tNAME\tSIZE\tPATH")
+			for _, m := range list {
+				fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%s\n", m.ID, m.LibraryID, m.Name, m.TotalSize, m.Path)
+			}
+		})
+	}
+}
+
+type importResult struct {
+	Model    *client.Model `json:"model"`
+	Uploaded []string      `json:"uploaded"`
+	Count    int           `json:"count"`
+}
+
+// setupModelImport uploads the directory as one ZIP archive, which the
+// server unpacks with its layout intact. Hidden files and directories are
+// left out, as the server would skip them anyway.
+func setupModelImport(fs *flag.FlagSet) runFunc {
+	library := fs.String("library", "", "library to import into, by id or name (required)")
+	name := fs.String("name", "", "model name; defaults to the directory name")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 || *library == "" {
+			return errUsage
+		}
+		dir, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		modelName := *name
+		if modelName == "" {
+			modelName = filepath.Base(dir)
+		}
+
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		l, err := findLibrary(ctx, c, *library)
+		if err != nil {
+			return err
+		}
+
+		archive, err := zipDir(dir)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(archive.Name())
+		defer archive.Close()
+
+		body, contentType, err := client.EncodeMultipart(map[string]string{"model_name": modelName},
+			client.File{Field: "files", Name: modelName + ".zip", Content: archive})
+		if err != nil {
+			return err
+		}
+		res, err := c.UploadModel(ctx, l.ID, body, contentType)
+		if err != nil {
+			return err
+		}
+		if res.Count == 0 {
+			return fmt.Errorf("no files were imported from %s", dir)
+		}
+
+		out := importResult{Uploaded: res.Uploaded, Count: res.Count}
+		found, err := listModels(ctx, c, strconv.FormatInt(l.ID, 10), fmt.Sprintf("name:%q", modelName))
+		if err != nil {
+			return err
+		}
+		for i, m := range found {
+			if m.Name == modelName {
+				out.Model = &found[i]
+				break
+			}
+		}
+		return e.print(out, func(w io.Writer) {
+			if out.Model != nil {
+				fmt.Fprintf(w, "imported %d files as model %d %q\n", out.Count, out.Model.ID, out.Model.Name)
+			} else {
+				fmt.Fprintf(w, "imported %d files as %q\n", out.Count, modelName)
+			}
+		})
+	}
+}
+
+// zipDir writes the files under dir to a temporary ZIP file and returns it
+// opened for reading.
+func zipDir(dir string) (*os.File, error) {
+	tmp, err := os.CreateTemp("", "go3d-import-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	zw := zip.NewWriter(tmp)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, src)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// listModels returns every model matching the library, by id or name, and
+// query; empty values do not filter.
+func listModels(ctx context.Context, c *client.Client, library, query string) ([]client.Model, error) {
+	params := &client.ListModelsParams{Limit: ptr(500)}
+	if library != "" {
+		l, err := findLibrary(ctx, c, library)
+		if err != nil {
+			return nil, err
+		}
+		params.LibraryID = &l.ID
+	}
+	if query != "" {
+		params.Q = &query
+	}
+	return pages(func(cursor *string) ([]client.Model, *string, error) {
+		params.Cursor = cursor
+		page, err := c.ListModels(ctx, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		return page.Items, page.NextCursor, nil
+	})
+}
+
+// pages collects every item of a listing by following next_cursor.
+func pages[T any](fetch func(cursor *string) ([]T, *string, error)) ([]T, error) {
+	all := []T{}
+	var cursor *string
+	for {
+		items, next, err := fetch(cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if next == nil || *next == "" {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+func parseModelID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%q is not a model id", s)
+	}
+	return id, nil
+}
+
+func parseModelIDs(args []string) ([]int64, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no model ids given")
+	}
+	ids := make([]int64, len(args))
+	for i, a := range args {
+		id, err := parseModelID(a)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func ptr[T any](v T) *T { return &v }
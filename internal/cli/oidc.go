@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"3d-library/internal/oidc"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	register(
+		&command{name: "oidc mock", summary: "Run a mock OpenID Connect provider for trying out single sign-on", setup: setupOIDCMock},
+	)
+}
+
+func setupOIDCMock(fs *flag.FlagSet) runFunc {
+	addr := fs.String("addr", "localhost:9400", "address to listen on")
+	issuer := fs.String("issuer", "", "issuer URL; defaults to http://ADDR")
+	clientID := fs.String("client-id", "go3d", "client ID Go3D must use")
+	clientSecret := fs.String("client-secret", "", "client secret Go3D must send; empty accepts a public client")
+	user := fs.String("user", "", "sign everyone in as this user instead of showing a login form")
+	groups := fs.String("groups", "", "comma-separated groups for -user")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		mock := &oidc.Mock{Issuer: *issuer, ClientID: *clientID, ClientSecret: *clientSecret, User: *user}
+		if mock.Issuer == "" {
+			mock.Issuer = "http://" + *addr
+		}
+		for _, g := range strings.Split(*groups, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				mock.Groups = append(mock.Groups, g)
+			}
+		}
+
+		srv := &http.Server{Addr: *addr, Handler: mock}
+		errc := make(chan error, 1)
+		go func() { errc <- srv.ListenAndServe() }()
+		fmt.Fprintf(e.out, "mock provider listening on %s\n", *addr)
+		fmt.Fprintf(e.out, "set OIDC_ISSUER=%s and OIDC_CLIENT_ID=%s\n", mock.Issuer, mock.ClientID)
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"3d-library/internal/api"
+	"3d-library/internal/config"
+	"3d-library/internal/migrate"
+	"3d-library/internal/server"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+func init() {
+	register(
+		&command{name: "serve", summary: "Run the web server", setup: setupServe},
+		&command{name: "worker", summary: "Run the background job worker", setup: setupWorker},
+		&command{name: "config print", summary: "Print the effective configuration", setup: setupConfigPrint},
+		&command{name: "migrate up", summary: "Apply pending migrations", setup: setupMigrate("up")},
+		&command{name: "migrate down", summary: "Roll back the latest migration", setup: setupMigrate("down")},
+		&command{name: "migrate status", summary: "List migrations and whether they are applied", setup: setupMigrate("status")},
+		&command{name: "migrate force", args: "VERSION", summary: "Record migrations up to VERSION as applied without running them", setup: setupMigrate("force")},
+	)
+}
+
+func setupServe(fs *flag.FlagSet) runFunc {
+	checkRoutes := fs.Bool("check-routes", false, "compare the routes with the OpenAPI document and exit")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		if *checkRoutes {
+			if err := api.CheckRoutes(server.NewRouter(config.Default(), nil, nil, nil, nil)); err != nil {
+				return err
+			}
+			fmt.Fprintln(e.out, "routes match the OpenAPI document")
+			return nil
+		}
+		cfg, err := e.config()
+		if err != nil {
+			return err
+		}
+		return server.Serve(ctx, cfg)
+	}
+}
+
+func setupWorker(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		cfg, err := e.config()
+		if err != nil {
+			return err
+		}
+		return server.Work(ctx, cfg)
+	}
+}
+
+// setupConfigPrint prints the configuration even when it fails validation,
+// followed by the problems.
+func setupConfigPrint(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		cfg, loadErr := config.Load(e.configFile)
+		if cfg != nil {
+			if err := cfg.Print(e.out); err != nil {
+				return err
+			}
+		}
+		if loadErr != nil {
+			return fmt.Errorf("invalid configuration:\n%w", loadErr)
+		}
+		return nil
+	}
+}
+
+type migrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+func setupMigrate(action string) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return func(ctx context.Context, e *env, args []string) error {
+			want := 0
+			if action == "force" {
+				want = 1
+			}
+			if len(args) != want {
+				return errUsage
+			}
+			if e.serverURL != "" {
+				return errors.New("migrate works on the database directly and cannot use -server")
+			}
+			cfg, err := e.config()
+			if err != nil {
+				return err
+			}
+			// database() refuses pending migrations, so connect here.
+			db, err := server.Setup(cfg)
+			if err != nil {
+				return err
+			}
+			e.db = db
+
+			switch action {
+			case "up":
+				done, err := migrate.Up(db)
+				names := []string{}
+				for _, m := range done {
+					names = append(names, m.Name)
+				}
+				if printErr := e.print(map[string][]string{"applied": names}, func(w io.Writer) {
+					for _, name := range names {
+						fmt.Fprintln(w, "applied", name)
+					}
+					if err == nil && len(names) == 0 {
+						fmt.Fprintln(w, "no pending migrations")
+					}
+				}); printErr != nil {
+					return printErr
+				}
+				return err
+			case "down":
+				m, err := migrate.Down(db)
+				if err != nil {
+					return err
+				}
+				var name *string
+				if m != nil {
+					name = &m.Name
+				}
+				return e.print(map[string]*string{"rolled_back": name}, func(w io.Writer) {
+					if name != nil {
+						fmt.Fprintln(w, "rolled back", *name)
+					} else {
+						fmt.Fprintln(w, "no migrations applied")
+					}
+				})
+			case "status":
+				list, err := migrate.List(db)
+				if err != nil {
+					return err
+				}
+				out := make([]migrationStatus, len(list))
+				for i, s := range list {
+					out[i] = migrationStatus(s)
+				}
+				return e.print(out, func(w io.Writer) {
+					for _, s := range list {
+						applied := "pending"
+						if s.AppliedAt != nil {
+							applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+						} else if s.Applied {
+							applied = "applied"
+						}
+						name := s.Name
+						if name == "" {
+							name = fmt.Sprintf("%03d (unknown to this build)", s.Version)
+						}
+						fmt.Fprintf(w, "%s\t%s\n", name, applied)
+					}
+				})
+			case "force":
+				version, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid version %q", args[0])
+				}
+				if err := migrate.Force(db, version); err != nil {
+					return err
+				}
+				return e.print(map[string]int64{"forced": version}, func(w io.Writer) {
+					fmt.Fprintf(w, "recorded migrations up to %d as applied\n", version)
+				})
+			}
+			return errUsage
+		}
+	}
+}
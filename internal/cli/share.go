@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func init() {
+	register(
+		&command{name: "share create", args: "model|collection ID", summary: "Create a link that shares a model or collection without an account", setup: setupShareCreate},
+		&command{name: "share list", summary: "List your share links", setup: setupShareList},
+		&command{name: "share revoke", args: "ID", summary: "Revoke a share link", setup: setupShareRevoke},
+	)
+}
+
+func setupShareCreate(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	hours := fs.Int("expires", 0, "expire the link after this many hours; 0 never expires")
+	downloads := fs.Int("max-downloads", 0, "allow this many file downloads; 0 is unlimited")
+	password := fs.Bool("password", false, "ask for a password the link will need")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 2 || *hours < 0 || *downloads < 0 {
+			return errUsage
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s id %q is not a number", args[0], args[1])
+		}
+		var body client.ShareCreate
+		switch args[0] {
+		case "model":
+			body.ModelID = &id
+		case "collection":
+			body.CollectionID = &id
+		default:
+			return errUsage
+		}
+		if *hours > 0 {
+			body.ExpiresInHours = hours
+		}
+		if *downloads > 0 {
+			body.MaxDownloads = downloads
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		if *password {
+			p, err := readPassword("Password for the link: ")
+			if err != nil {
+				return err
+			}
+			body.Password = &p
+		}
+		l, err := c.CreateShare(ctx, body)
+		if err != nil {
+			return err
+		}
+		return e.print(l, func(w io.Writer) {
+			fmt.Fprintln(w, l.URL)
+		})
+	}
+}
+
+func setupShareList(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	all := fs.Bool("all", false, "list everyone's links (administrators)")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		var params *client.ListSharesParams
+		if *all {
+			everyone := int64(0)
+			params = &client.ListSharesParams{UserID: &everyone}
+		}
+		list, err := c.ListShares(ctx, params)
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tSHARES\tPREFIX\tEXPIRES\tDOWNLOADS\tPASSWORD")
+			for _, l := range list {
+				kind, id := "collection", l.CollectionID
+				if l.ModelID != nil {
+					kind, id = "model", l.ModelID
+				}
+				expires, downloads := "never", strconv.Itoa(l.Downloads)
+				if l.ExpiresAt != nil {
+					expires = l.ExpiresAt.Local().Format("2006-01-02 15:04")
+				}
+				if l.MaxDownloads != nil {
+					downloads += "/" + strconv.Itoa(*l.MaxDownloads)
+				}
+				fmt.Fprintf(w, "%d\t%s %d\t%s…\t%s\t%s\t%t\n", l.ID, kind, *id, l.Prefix, expires, downloads, l.HasPassword)
+			}
+		})
+	}
+}
+
+func setupShareRevoke(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("share link id %q is not a number", args[0])
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteShare(ctx, id); err != nil {
+			return err
+		}
+		return e.print(map[string]int64{"revoked": id}, func(w io.Writer) {
+			fmt.Fprintf(w, "revoked share link %d\n", id)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	register(
+		&command{name: "tag list", summary: "List tags, or one model's tags with -model", setup: setupTagList},
+		&command{name: "tag add", args: "MODEL TAG...", summary: "Tag a model, creating tags as needed", setup: setupTagChange(true)},
+		&command{name: "tag remove", args: "MODEL TAG...", summary: "Remove tags from a model", setup: setupTagChange(false)},
+	)
+}
+
+func setupTagList(fs *flag.FlagSet) runFunc {
+	model := fs.Int64("model", 0, "list this model's tags")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		var list []client.Tag
+		if *model != 0 {
+			list, err = c.ListModelTags(ctx, *model)
+		} else {
+			params := &client.ListTagsParams{Limit: ptr(500)}
+			list, err = pages(func(cursor *string) ([]client.Tag, *string, error) {
+				params.Cursor = cursor
+				page, err := c.ListTags(ctx, params)
+				if err != nil {
+					return nil, nil, err
+				}
+				return page.Items, page.NextCursor, nil
+			})
+		}
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tNAME")
+			for _, t := range list {
+				fmt.Fprintf(w, "%d\t%s\n", t.ID, t.Name)
+			}
+		})
+	}
+}
+
+// setupTagChange adds or removes tags and prints the model's tags after.
+// Removing a tag the model does not have is an error.
+func setupTagChange(add bool) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return func(ctx context.Context, e *env, args []string) error {
+			if len(args) < 2 {
+				return errUsage
+			}
+			id, err := parseModelID(args[0])
+			if err != nil {
+				return err
+			}
+			c, err := e.client()
+			if err != nil {
+				return err
+			}
+
+			if add {
+				for _, name := range args[1:] {
+					if err := c.AddModelTag(ctx, id, client.TagAdd{Tag: name}); err != nil {
+						return err
+					}
+				}
+			} else {
+				current, err := c.ListModelTags(ctx, id)
+				if err != nil {
+					return err
+				}
+				for _, name := range args[1:] {
+					tagID := int64(0)
+					for _, t := range current {
+						if strings.EqualFold(t.Name, strings.TrimSpace(name)) {
+							tagID = t.ID
+						}
+					}
+					if tagID == 0 {
+						return fmt.Errorf("model %d is not tagged %q", id, name)
+					}
+					if err := c.RemoveModelTag(ctx, id, tagID); err != nil {
+						return err
+					}
+				}
+			}
+
+			list, err := c.ListModelTags(ctx, id)
+			if err != nil {
+				return err
+			}
+			return e.print(list, func(w io.Writer) {
+				names := make([]string, len(list))
+				for i, t := range list {
+					names[i] = t.Name
+				}
+				fmt.Fprintf(w, "model %d tags: %s\n", id, strings.Join(names, ", "))
+			})
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// rebuildBatch keeps each bulk request small enough for the server to run
+// it right away instead of as a background job.
+const rebuildBatch = 200
+
+func init() {
+	register(&command{name: "thumbnails rebuild", args: "[MODEL...]", summary: "Re-render thumbnails and previews of the given or matching models", setup: setupThumbnailsRebuild})
+}
+
+func setupThumbnailsRebuild(fs *flag.FlagSet) runFunc {
+	library := fs.String("library", "", "rebuild models in this library, by id or name")
+	query := fs.String("q", "", "rebuild models matching this search query")
+	all := fs.Bool("all", false, "rebuild every model")
+	return func(ctx context.Context, e *env, args []string) error {
+		selectors := 0
+		for _, set := range []bool{len(args) > 0, *library != "" || *query != "", *all} {
+			if set {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		if len(args) > 0 {
+			if ids, err = parseModelIDs(args); err != nil {
+				return err
+			}
+		} else {
+			list, err := listModels(ctx, c, *library, *query)
+			if err != nil {
+				return err
+			}
+			for _, m := range list {
+				ids = append(ids, m.ID)
+			}
+		}
+
+		summary := client.BulkSummary{Operation: "regenerate_previews", Results: []client.BulkResult{}}
+		for start := 0; start < len(ids); start += rebuildBatch {
+			batch := ids[start:min(start+rebuildBatch, len(ids))]
+			res, err := c.RunBulk(ctx, client.BulkRequest{Operation: "regenerate_previews", ModelIDs: batch})
+			if err != nil {
+				return err
+			}
+			if res.BulkSummary == nil {
+				return fmt.Errorf("server queued the rebuild as bulk job %d instead of running it", res.BulkJob.ID)
+			}
+			summary.Succeeded += res.BulkSummary.Succeeded
+			summary.Failed += res.BulkSummary.Failed
+			summary.Results = append(summary.Results, res.BulkSummary.Results...)
+		}
+
+		if err := e.print(summary, func(w io.Writer) {
+			for _, r := range summary.Results {
+				if !r.OK {
+					fmt.Fprintf(w, "model %d\tfailed\t%s\n", r.ModelID, deref(r.Error))
+				}
+			}
+			fmt.Fprintf(w, "rebuilt %d models, %d failed\n", summary.Succeeded, summary.Failed)
+		}); err != nil {
+			return err
+		}
+		if summary.Failed > 0 {
+			return fmt.Errorf("%d models failed", summary.Failed)
+		}
+		return nil
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
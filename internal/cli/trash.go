@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func init() {
+	register(
+		&command{name: "trash list", summary: "List deleted libraries, models and files you can restore", setup: setupTrashList},
+		&command{name: "trash restore", args: "library|model|file ID", summary: "Restore a deleted library, model or file", setup: setupTrashRestore},
+	)
+}
+
+func setupTrashList(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		trash, err := c.ListTrash(ctx)
+		if err != nil {
+			return err
+		}
+		return e.print(trash, func(w io.Writer) {
+			const when = "2006-01-02 15:04"
+			fmt.Fprintln(w, "KIND\tID\tDELETED\tPATH")
+			for _, l := range trash.Libraries {
+				fmt.Fprintf(w, "library\t%d\t%s\t%s\n", l.ID, l.DeletedAt.Local().Format(when), l.Path)
+			}
+			for _, m := range trash.Models {
+				fmt.Fprintf(w, "model\t%d\t%s\t%s\n", m.ID, m.DeletedAt.Local().Format(when), m.Path)
+			}
+			for _, f := range trash.Files {
+				fmt.Fprintf(w, "file\t%d\t%s\t%s\n", f.ID, f.DeletedAt.Local().Format(when), f.Path)
+			}
+		})
+	}
+}
+
+func setupTrashRestore(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 2 {
+			return errUsage
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s id %q is not a number", args[0], args[1])
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		var restored interface{}
+		var path string
+		switch args[0] {
+		case "library":
+			l, err := c.RestoreLibrary(ctx, id)
+			if err != nil {
+				return err
+			}
+			restored, path = l, l.Path
+		case "model":
+			m, err := c.RestoreModel(ctx, id)
+			if err != nil {
+				return err
+			}
+			restored, path = m, m.Path
+		case "file":
+			f, err := c.RestoreFile(ctx, id)
+			if err != nil {
+				return err
+			}
+			restored, path = f, f.Path
+		default:
+			return errUsage
+		}
+		return e.print(restored, func(w io.Writer) {
+			fmt.Fprintf(w, "Restored %s %d: %s\n", args[0], id, path)
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"3d-library/pkg/client"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http/cookiejar"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func init() {
+	register(
+		&command{name: "user list", summary: "List users", setup: setupUserList},
+		&command{name: "user create", args: "NAME", summary: "Create a user, reading the password from the terminal or stdin", setup: setupUserCreate},
+		&command{name: "user password", args: "USER", summary: "Set a user's password and sign them out", setup: setupUserPassword},
+		&command{name: "user disable", args: "USER", summary: "Disable a user, ending their sessions and API tokens", setup: setupUserDisabled(true)},
+		&command{name: "user enable", args: "USER", summary: "Enable a disabled user", setup: setupUserDisabled(false)},
+		&command{name: "token create", args: "NAME", summary: "Create an API token for scripts", setup: setupTokenCreate},
+		&command{name: "token list", summary: "List your API tokens", setup: setupTokenList},
+		&command{name: "token revoke", args: "ID", summary: "Revoke one of your API tokens", setup: setupTokenRevoke},
+	)
+}
+
+func setupUserList(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		list, err := c.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tUSERNAME\tADMIN\tDISABLED\tLAST LOGIN")
+			for _, u := range list {
+				last := "never"
+				if u.LastLoginAt != nil {
+					last = u.LastLoginAt.Local().Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(w, "%d\t%s\t%t\t%t\t%s\n", u.ID, u.Username, u.Admin, u.Disabled, last)
+			}
+		})
+	}
+}
+
+func setupUserCreate(fs *flag.FlagSet) runFunc {
+	admin := fs.Bool("admin", false, "make the user an administrator")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		password, err := readPassword("Password for " + args[0] + ": ")
+		if err != nil {
+			return err
+		}
+		u, err := c.CreateUser(ctx, client.UserCreate{Username: args[0], Password: password, Admin: admin})
+		if err != nil {
+			return err
+		}
+		return e.print(u, func(w io.Writer) {
+			kind := "user"
+			if u.Admin {
+				kind = "administrator"
+			}
+			fmt.Fprintf(w, "created %s %d %q\n", kind, u.ID, u.Username)
+		})
+	}
+}
+
+func setupUserPassword(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		c, err := e.client()
+		if err != nil {
+			return err
+		}
+		u, err := findUser(ctx, c, args[0])
+		if err != nil {
+			return err
+		}
+		password, err := readPassword("New password for " + u.Username + ": ")
+		if err != nil {
+			return err
+		}
+		if u, err = c.UpdateUser(ctx, u.ID, client.UserUpdate{Password: &password}, nil); err != nil {
+			return err
+		}
+		return e.print(u, func(w io.Writer) {
+			fmt.Fprintf(w, "set the password of %q\n", u.Username)
+		})
+	}
+}
+
+func setupUserDisabled(disabled bool) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return func(ctx context.Context, e *env, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			c, err := e.client()
+			if err != nil {
+				return err
+			}
+			u, err := findUser(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			if u, err = c.UpdateUser(ctx, u.ID, client.UserUpdate{Disabled: &disabled}, nil); err != nil {
+				return err
+			}
+			return e.print(u, func(w io.Writer) {
+				state := "enabled"
+				if u.Disabled {
+					state = "disabled"
+				}
+				fmt.Fprintf(w, "%s %q\n", state, u.Username)
+			})
+		}
+	}
+}
+
+// findUser looks a user up by id or, failing that, by username.
+func findUser(ctx context.Context, c *client.Client, ref string) (*client.User, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return c.GetUser(ctx, id)
+	}
+	list, err := c.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range list {
+		if strings.EqualFold(u.Username, ref) {
+			return &u, nil
+		}
+	}
+	return nil, fmt.Errorf("no user named %q", ref)
+}
+
+func setupTokenCreate(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user to create the token; not needed with -token")
+	days := fs.Int("expires", 0, "expire the token after this many days; 0 never expires")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 || *days < 0 {
+			return errUsage
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		body := client.APITokenCreate{Name: args[0]}
+		if *days > 0 {
+			body.ExpiresInDays = days
+		}
+		t, err := c.CreateToken(ctx, body)
+		if err != nil {
+			return err
+		}
+		return e.print(t, func(w io.Writer) {
+			fmt.Fprintln(w, t.Token)
+		})
+	}
+}
+
+func setupTokenList(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		list, err := c.ListTokens(ctx)
+		if err != nil {
+			return err
+		}
+		return e.print(list, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tNAME\tPREFIX\tEXPIRES\tLAST USED")
+			for _, t := range list {
+				expires, used := "never", "never"
+				if t.ExpiresAt != nil {
+					expires = t.ExpiresAt.Local().Format("2006-01-02")
+				}
+				if t.LastUsedAt != nil {
+					used = t.LastUsedAt.Local().Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s…\t%s\t%s\n", t.ID, t.Name, t.Prefix, expires, used)
+			}
+		})
+	}
+}
+
+func setupTokenRevoke(fs *flag.FlagSet) runFunc {
+	user := fs.String("user", "", "sign in as this user; not needed with -token")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("token id %q is not a number", args[0])
+		}
+		c, err := e.signedIn(ctx, *user)
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteToken(ctx, id); err != nil {
+			return err
+		}
+		return e.print(map[string]int64{"revoked": id}, func(w io.Writer) {
+			fmt.Fprintf(w, "revoked token %d\n", id)
+		})
+	}
+}
+
+// signedIn returns a client acting as a real user, for commands about the
+// caller's own account: the -token user, or username after asking for the
+// password.
+func (e *env) signedIn(ctx context.Context, username string) (*client.Client, error) {
+	c, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+	if e.token != "" {
+		return c, nil
+	}
+	if username == "" {
+		return nil, errors.New("pass -user NAME to sign in, or -token")
+	}
+	password, err := readPassword("Password for " + username + ": ")
+	if err != nil {
+		return nil, err
+	}
+	jar, _ := cookiejar.New(nil)
+	hc := *c.HTTPClient
+	hc.Jar = jar
+	c.HTTPClient = &hc
+	if _, err := c.Login(ctx, client.Login{Username: username, Password: password}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readPassword prompts for a password without echoing it, or reads the
+// first line of stdin when it is not a terminal.
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -1,43 +1,487 @@
+// Package config loads the settings shared by the web server and the
+// worker: built-in defaults, then an optional YAML or TOML file, then
+// environment variables, checked by Validate.
 package config
 
 import (
-	"log"
+	"3d-library/internal/auth"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Each setting's env tag lists the variables that override it, first
+// match wins.
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	RedisAddr  string
-	ServerPort string
-	ServerHost string
+	Server     Server     `yaml:"server" toml:"server"`
+	Database   Database   `yaml:"database" toml:"database"`
+	Redis      Redis      `yaml:"redis" toml:"redis"`
+	Jobs       Jobs       `yaml:"jobs" toml:"jobs"`
+	Uploads    Uploads    `yaml:"uploads" toml:"uploads"`
+	Thumbnails Thumbnails `yaml:"thumbnails" toml:"thumbnails"`
+	Libraries  Libraries  `yaml:"libraries" toml:"libraries"`
+	Trash      Trash      `yaml:"trash" toml:"trash"`
+	Limits     Limits     `yaml:"limits" toml:"limits"`
+	Auth       Auth       `yaml:"auth" toml:"auth"`
+	Log        Log        `yaml:"log" toml:"log"`
 }
 
-func Load() *Config {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
-	}
+type Server struct {
+	// Host is the address to listen on; empty means every interface.
+	Host string `yaml:"host" toml:"host" env:"SERVER_HOST"`
+	Port int    `yaml:"port" toml:"port" env:"SERVER_PORT,PORT"`
+	// PublicURL is where users reach the server, for log messages. An
+	// https URL also marks session cookies Secure. It defaults to
+	// http://localhost:Port.
+	PublicURL string `yaml:"public_url" toml:"public_url" env:"PUBLIC_URL"`
+	// CORSOrigins lists the other origins, such as https://tools.lan:8080,
+	// whose pages may call the API with the user's session. Empty allows
+	// only the server's own pages. The env var is separated by commas.
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins" env:"CORS_ORIGINS" sep:","`
+}
+
+type Database struct {
+	// URL is postgres://... or sqlite:///path/to/go3d.db.
+	URL         string `yaml:"url" toml:"url" env:"DATABASE_URL"`
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate" env:"AUTO_MIGRATE"`
+}
+
+type Redis struct {
+	Addr string `yaml:"addr" toml:"addr" env:"REDIS_ADDR"`
+}
+
+type Jobs struct {
+	// Queue is "redis", "embedded", or empty to use Redis when it answers.
+	Queue       string `yaml:"queue" toml:"queue" env:"JOB_QUEUE"`
+	Concurrency int    `yaml:"concurrency" toml:"concurrency" env:"WORKER_CONCURRENCY"`
+}
+
+type Uploads struct {
+	// MaxSizeMB caps a whole upload request.
+	MaxSizeMB int64 `yaml:"max_size_mb" toml:"max_size_mb" env:"UPLOAD_MAX_MB"`
+	// MaxImageMB caps the image sent to search by image.
+	MaxImageMB int64 `yaml:"max_image_mb" toml:"max_image_mb" env:"IMAGE_SEARCH_MAX_MB"`
+	// MaxExtractMB caps what one upload writes, with its ZIP archives
+	// unpacked.
+	MaxExtractMB int64 `yaml:"max_extract_mb" toml:"max_extract_mb" env:"UPLOAD_MAX_EXTRACT_MB"`
+	// MaxFiles caps the files one upload writes, counting ZIP entries.
+	MaxFiles int `yaml:"max_files" toml:"max_files" env:"UPLOAD_MAX_FILES"`
+	// MaxRatio caps how many times larger than its compressed size a ZIP
+	// entry may unpack to.
+	MaxRatio int64 `yaml:"max_ratio" toml:"max_ratio" env:"UPLOAD_MAX_RATIO"`
+	// MaxFolderDepth caps how many folders deep in a model a ZIP entry may
+	// be. ZIP archives inside a ZIP are refused whatever this is.
+	MaxFolderDepth int `yaml:"max_folder_depth" toml:"max_folder_depth" env:"UPLOAD_MAX_FOLDER_DEPTH"`
+	// UserQuotaMB caps the files each user has uploaded, across libraries.
+	// Zero is no cap.
+	UserQuotaMB int64 `yaml:"user_quota_mb" toml:"user_quota_mb" env:"UPLOAD_USER_QUOTA_MB"`
+}
+
+type Thumbnails struct {
+	Dir string `yaml:"dir" toml:"dir" env:"THUMBNAIL_DIR"`
+}
+
+type Libraries struct {
+	// AllowedRoots limits library paths to these directories and what is
+	// under them. Empty confines them to the folders of the libraries that
+	// exist when the server starts, so new libraries must go inside one;
+	// with no libraries at all, none can be created. The env var is a list
+	// separated like PATH.
+	AllowedRoots []string `yaml:"allowed_roots" toml:"allowed_roots" env:"LIBRARY_ROOTS"`
+}
+
+type Trash struct {
+	// RetentionDays is how long deleted libraries, models and files can be
+	// restored before a job removes them for good. 0 keeps them forever.
+	RetentionDays int `yaml:"retention_days" toml:"retention_days" env:"TRASH_RETENTION_DAYS"`
+	// PurgeFiles makes that job delete the files of libraries and models
+	// from disk too. Files deleted from disk on their own wait in the
+	// library's .trash folder and always go.
+	PurgeFiles bool `yaml:"purge_files" toml:"purge_files" env:"TRASH_PURGE_FILES"`
+}
+
+// Limits caps request bodies and rates. Rates count the requests of each
+// signed-in user, and of each client address whoever makes them; the
+// search, download and scan rates count those endpoints again, per user or
+// per address when anonymous. A zero rate is no limit.
+type Limits struct {
+	// MaxBodyKB caps request bodies except uploads and image searches,
+	// which have their own caps under uploads.
+	MaxBodyKB int64 `yaml:"max_body_kb" toml:"max_body_kb" env:"MAX_BODY_KB"`
+	// BodyKB overrides the caps of single routes, each "METHOD /api/path=KB"
+	// with the path as the API documents it, such as "POST /api/bulk=4096".
+	// The env var is separated by commas.
+	BodyKB []string `yaml:"body_kb" toml:"body_kb" env:"BODY_LIMITS_KB" sep:","`
+
+	UserPerMinute     int `yaml:"user_per_minute" toml:"user_per_minute" env:"RATE_LIMIT_USER"`
+	IPPerMinute       int `yaml:"ip_per_minute" toml:"ip_per_minute" env:"RATE_LIMIT_IP"`
+	SearchPerMinute   int `yaml:"search_per_minute" toml:"search_per_minute" env:"RATE_LIMIT_SEARCH"`
+	DownloadPerMinute int `yaml:"download_per_minute" toml:"download_per_minute" env:"RATE_LIMIT_DOWNLOAD"`
+	ScansPerHour      int `yaml:"scans_per_hour" toml:"scans_per_hour" env:"RATE_LIMIT_SCANS"`
+	// RateStore is "redis", "memory", or empty to count in Redis when it
+	// answers.
+	RateStore string `yaml:"rate_store" toml:"rate_store" env:"RATE_LIMIT_STORE"`
+}
 
+type Auth struct {
+	// AnonymousRead lets requests without a session or token read the
+	// API. Changes always need a user.
+	AnonymousRead bool `yaml:"anonymous_read" toml:"anonymous_read" env:"AUTH_ANONYMOUS_READ"`
+	// SessionHours is how long a web UI login lasts.
+	SessionHours int `yaml:"session_hours" toml:"session_hours" env:"AUTH_SESSION_HOURS"`
+	// AdminGroups are the identity provider or proxy groups whose members
+	// are administrators. Empty leaves admin rights to Go3D.
+	AdminGroups []string `yaml:"admin_groups" toml:"admin_groups" env:"AUTH_ADMIN_GROUPS" sep:","`
+	// GroupRoles map groups to library roles as GROUP=LIBRARY:ROLE, where
+	// LIBRARY is a library name, ID or * for all of them. Roles in the
+	// libraries named here follow the groups at every sign-in.
+	GroupRoles []string `yaml:"group_roles" toml:"group_roles" env:"AUTH_GROUP_ROLES" sep:","`
+	OIDC       OIDC     `yaml:"oidc" toml:"oidc"`
+	Proxy      Proxy    `yaml:"proxy" toml:"proxy"`
+}
+
+// OIDC enables single sign-on through an OpenID Connect provider when
+// Issuer is set.
+type OIDC struct {
+	Issuer   string `yaml:"issuer" toml:"issuer" env:"OIDC_ISSUER"`
+	ClientID string `yaml:"client_id" toml:"client_id" env:"OIDC_CLIENT_ID"`
+	// ClientSecret is left empty for a public client.
+	ClientSecret string `yaml:"client_secret" toml:"client_secret" env:"OIDC_CLIENT_SECRET"`
+	// RedirectURL defaults to the public URL plus /api/auth/oidc/callback.
+	RedirectURL string   `yaml:"redirect_url" toml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes      []string `yaml:"scopes" toml:"scopes" env:"OIDC_SCOPES" sep:","`
+	// UsernameClaim names the claim used as the username, falling back
+	// to email and then the subject when it is missing or unusable.
+	UsernameClaim string `yaml:"username_claim" toml:"username_claim" env:"OIDC_USERNAME_CLAIM"`
+	GroupsClaim   string `yaml:"groups_claim" toml:"groups_claim" env:"OIDC_GROUPS_CLAIM"`
+	// LinkExisting lets a first OIDC sign-in take over the local user of
+	// the same name. Only turn it on when the provider controls usernames.
+	LinkExisting bool `yaml:"link_existing" toml:"link_existing" env:"OIDC_LINK_EXISTING"`
+}
+
+// Proxy trusts a reverse proxy that has already authenticated the user to
+// name them in a header. It is enabled by listing the proxy's addresses.
+type Proxy struct {
+	// TrustedProxies are IP addresses or CIDR ranges. The header is
+	// ignored on requests from anywhere else.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"AUTH_PROXY_TRUSTED" sep:","`
+	Header         string   `yaml:"header" toml:"header" env:"AUTH_PROXY_HEADER"`
+	// GroupsHeader holds the user's groups separated by commas, for
+	// admin_groups and group_roles.
+	GroupsHeader string `yaml:"groups_header" toml:"groups_header" env:"AUTH_PROXY_GROUPS_HEADER"`
+}
+
+type Log struct {
+	Level    string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	Format   string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+	Requests bool   `yaml:"requests" toml:"requests" env:"LOG_REQUESTS"`
+}
+
+func Default() *Config {
 	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "library3d"),
-		DBPassword: getEnv("DB_PASSWORD", "dev123"),
-		DBName:     getEnv("DB_NAME", "library3d"),
-		RedisAddr:  getEnv("REDIS_ADDR", "localhost:6379"),
-		ServerPort: getEnv("SERVER_PORT", "3000"),
-		ServerHost: getEnv("SERVER_HOST", "192.168.3.26"),
+		Server:     Server{Port: 3000},
+		Database:   Database{AutoMigrate: true},
+		Redis:      Redis{Addr: "localhost:6379"},
+		Jobs:       Jobs{Concurrency: 10},
+		Uploads:    Uploads{MaxSizeMB: 1024, MaxImageMB: 20, MaxExtractMB: 4096, MaxFiles: 10000, MaxRatio: 100, MaxFolderDepth: 10},
+		Thumbnails: Thumbnails{Dir: filepath.Join("data", "thumbnails")},
+		Trash:      Trash{RetentionDays: 30},
+		Limits: Limits{
+			MaxBodyKB:         1024,
+			UserPerMinute:     600,
+			IPPerMinute:       1200,
+			SearchPerMinute:   60,
+			DownloadPerMinute: 120,
+			ScansPerHour:      30,
+		},
+		Auth: Auth{
+			SessionHours: 720,
+			OIDC:         OIDC{Scopes: []string{"openid", "profile", "email"}, UsernameClaim: "preferred_username", GroupsClaim: "groups"},
+			Proxy:        Proxy{Header: "X-Forwarded-User", GroupsHeader: "X-Forwarded-Groups"},
+		},
+		Log: Log{Level: "info", Format: "text", Requests: true},
+	}
+}
+
+// Load reads .env if present, then path (skipped when empty), then the
+// environment, and validates the result. A configuration that fails
+// validation is returned along with the error so it can be shown.
+func Load(path string) (*Config, error) {
+	godotenv.Load()
+
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if cfg.Server.PublicURL == "" {
+		cfg.Server.PublicURL = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+	}
+	if cfg.Auth.OIDC.Issuer != "" && cfg.Auth.OIDC.RedirectURL == "" {
+		cfg.Auth.OIDC.RedirectURL = strings.TrimSuffix(cfg.Server.PublicURL, "/") + "/api/auth/oidc/callback"
+	}
+	return cfg, cfg.Validate()
+}
+
+// readFile decodes a .yaml, .yml or .toml file over cfg. Unknown keys are
+// errors so typos do not pass silently.
+func (cfg *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.NewDecoder(f).Decode(cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("%s: config files must be .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// applyEnv overrides fields of v that have an env tag with the first
+// non-empty variable it names.
+func applyEnv(v reflect.Value) error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field, sf := v.Field(i), v.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		tag := sf.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		for _, name := range strings.Split(tag, ",") {
+			value := os.Getenv(name)
+			if value == "" {
+				continue
+			}
+			if err := setValue(field, value, sf.Tag.Get("sep")); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			break
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// setValue parses value into field. Lists are split on sep, or like PATH
+// when it is empty.
+func setValue(field reflect.Value, value, sep string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		parts := filepath.SplitList(value)
+		if sep != "" {
+			parts = strings.Split(value, sep)
+		}
+		var list []string
+		for _, s := range parts {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Server.Port > 0 && cfg.Server.Port < 65536, "server.port: %d is not a valid port", cfg.Server.Port)
+	for _, origin := range cfg.Server.CORSOrigins {
+		u, err := url.Parse(origin)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/"),
+			"server.cors_origins: %q is not an origin like https://host:port", origin)
 	}
-	return defaultValue
+	check(cfg.Database.URL != "", "database.url: not set (DATABASE_URL)")
+	if cfg.Database.URL != "" {
+		scheme, _, _ := strings.Cut(cfg.Database.URL, ":")
+		switch strings.ToLower(scheme) {
+		case "postgres", "postgresql", "sqlite", "sqlite3":
+		default:
+			check(false, "database.url: unsupported scheme %q, want postgres:// or sqlite:", scheme)
+		}
+	}
+	check(cfg.Jobs.Queue == "" || cfg.Jobs.Queue == "redis" || cfg.Jobs.Queue == "embedded",
+		"jobs.queue: %q must be redis, embedded or empty", cfg.Jobs.Queue)
+	check(cfg.Jobs.Queue == "embedded" || cfg.Redis.Addr != "", "redis.addr: not set")
+	check(cfg.Jobs.Concurrency > 0, "jobs.concurrency: must be at least 1")
+	check(cfg.Uploads.MaxSizeMB > 0, "uploads.max_size_mb: must be at least 1")
+	check(cfg.Uploads.MaxImageMB > 0, "uploads.max_image_mb: must be at least 1")
+	check(cfg.Uploads.MaxExtractMB > 0, "uploads.max_extract_mb: must be at least 1")
+	check(cfg.Uploads.MaxFiles > 0, "uploads.max_files: must be at least 1")
+	check(cfg.Uploads.MaxRatio > 0, "uploads.max_ratio: must be at least 1")
+	check(cfg.Uploads.MaxFolderDepth >= 0, "uploads.max_folder_depth: must not be negative")
+	check(cfg.Uploads.UserQuotaMB >= 0, "uploads.user_quota_mb: must not be negative")
+	check(cfg.Thumbnails.Dir != "", "thumbnails.dir: not set")
+	for _, root := range cfg.Libraries.AllowedRoots {
+		check(filepath.IsAbs(root), "libraries.allowed_roots: %q is not an absolute path", root)
+	}
+	check(cfg.Trash.RetentionDays >= 0, "trash.retention_days: must not be negative")
+	check(cfg.Limits.MaxBodyKB > 0, "limits.max_body_kb: must be at least 1")
+	for _, limit := range cfg.Limits.BodyKB {
+		_, _, err := parseBodyLimit(limit)
+		check(err == nil, "limits.body_kb: %v", err)
+	}
+	for name, rate := range map[string]int{
+		"user_per_minute":     cfg.Limits.UserPerMinute,
+		"ip_per_minute":       cfg.Limits.IPPerMinute,
+		"search_per_minute":   cfg.Limits.SearchPerMinute,
+		"download_per_minute": cfg.Limits.DownloadPerMinute,
+		"scans_per_hour":      cfg.Limits.ScansPerHour,
+	} {
+		check(rate >= 0, "limits.%s: must not be negative", name)
+	}
+	check(cfg.Limits.RateStore == "" || cfg.Limits.RateStore == "redis" || cfg.Limits.RateStore == "memory",
+		"limits.rate_store: %q must be redis, memory or empty", cfg.Limits.RateStore)
+	check(cfg.Auth.SessionHours > 0, "auth.session_hours: must be at least 1")
+	for _, rule := range cfg.Auth.GroupRoles {
+		_, err := auth.ParseGroupRule(rule)
+		check(err == nil, "auth.group_roles: %v", err)
+	}
+	if o := cfg.Auth.OIDC; o.Issuer != "" {
+		u, err := url.Parse(o.Issuer)
+		check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "", "auth.oidc.issuer: %q is not an http(s) URL", o.Issuer)
+		check(o.ClientID != "", "auth.oidc.client_id: not set")
+		u, err = url.Parse(o.RedirectURL)
+		check(err == nil && u.IsAbs(), "auth.oidc.redirect_url: %q is not an absolute URL", o.RedirectURL)
+		check(slices.Contains(o.Scopes, "openid"), "auth.oidc.scopes: must include openid")
+		check(o.UsernameClaim != "" && o.GroupsClaim != "", "auth.oidc: username_claim and groups_claim must be set")
+	}
+	for _, proxy := range cfg.Auth.Proxy.TrustedProxies {
+		_, err := ParseNetwork(proxy)
+		check(err == nil, "auth.proxy.trusted_proxies: %q is not an IP address or CIDR range", proxy)
+	}
+	check(len(cfg.Auth.Proxy.TrustedProxies) == 0 || cfg.Auth.Proxy.Header != "", "auth.proxy.header: not set")
+	_, err := cfg.Log.level()
+	check(err == nil, "log.level: %v", err)
+	check(cfg.Log.Format == "text" || cfg.Log.Format == "json", "log.format: %q must be text or json", cfg.Log.Format)
+
+	return errors.Join(errs...)
+}
+
+// Print writes the effective configuration as YAML with the database
+// password and OIDC client secret hidden.
+func (cfg *Config) Print(w io.Writer) error {
+	shown := *cfg
+	if u, err := url.Parse(cfg.Database.URL); err == nil && u.User != nil {
+		shown.Database.URL = u.Redacted()
+	}
+	if shown.Auth.OIDC.ClientSecret != "" {
+		shown.Auth.OIDC.ClientSecret = "xxxxx"
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&shown); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// MaxUploadBytes and MaxImageBytes are the upload limits in bytes.
+func (cfg *Config) MaxUploadBytes() int64 { return cfg.Uploads.MaxSizeMB << 20 }
+func (cfg *Config) MaxImageBytes() int64  { return cfg.Uploads.MaxImageMB << 20 }
+func (cfg *Config) MaxBodyBytes() int64   { return cfg.Limits.MaxBodyKB << 10 }
+
+// BodyLimits maps "METHOD /api/path" to the byte cap limits.body_kb gives
+// it. Validate has checked the entries.
+func (cfg *Config) BodyLimits() map[string]int64 {
+	limits := make(map[string]int64, len(cfg.Limits.BodyKB))
+	for _, limit := range cfg.Limits.BodyKB {
+		if route, kb, err := parseBodyLimit(limit); err == nil {
+			limits[route] = kb << 10
+		}
+	}
+	return limits
+}
+
+// parseBodyLimit splits "POST /api/bulk=4096" into the route and the cap in
+// kilobytes.
+func parseBodyLimit(s string) (string, int64, error) {
+	route, size, ok := strings.Cut(s, "=")
+	method, path, _ := strings.Cut(strings.TrimSpace(route), " ")
+	kb, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+	if !ok || method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") || err != nil || kb <= 0 {
+		return "", 0, fmt.Errorf("%q must be METHOD /path=KB", s)
+	}
+	return method + " " + path, kb, nil
+}
+
+// ParseNetwork reads an IP address, as a single-address range, or a CIDR
+// range.
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is not an IP address or CIDR range", s)
+	}
+	bits := 8 * len(ip)
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits = v4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Addr is the address to listen on.
+func (cfg *Config) Addr() string {
+	return fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 }
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup routes the standard logger, which the rest of the code uses,
+// through slog with the configured level and format. Plain log calls are
+// logged at info level.
+func (l Log) Setup() {
+	level, _ := l.level()
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if l.Format == "json" {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
+}
+
+func (l Log) level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(l.Level))); err != nil {
+		return 0, fmt.Errorf("%q must be debug, info, warn or error", l.Level)
+	}
+	return level, nil
+}
@@ -2,22 +2,58 @@ package database
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
-func Connect() (*sqlx.DB, error) {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL not set")
+// Connect opens dbURL. postgres:// and postgresql:// URLs use Postgres;
+// sqlite: URLs such as sqlite:///var/lib/go3d/go3d.db or sqlite:go3d.db open
+// a SQLite file.
+func Connect(dbURL string) (*sqlx.DB, error) {
+	driver, dsn, err := parseURL(dbURL)
+	if err != nil {
+		return nil, err
 	}
-
-	db, err := sqlx.Connect("postgres", dbURL)
+	db, err := sqlx.Connect(driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if driver == "sqlite" && strings.Contains(dsn, ":memory:") {
+		// Every connection to :memory: is a separate database.
+		db.SetMaxOpenConns(1)
+	}
 
 	return db, nil
 }
+
+func parseURL(dbURL string) (driver, dsn string, err error) {
+	scheme, rest, _ := strings.Cut(dbURL, ":")
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return "postgres", dbURL, nil
+	case "sqlite", "sqlite3":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return "", "", fmt.Errorf("database URL: sqlite URL needs a file path")
+		}
+		return "sqlite", sqliteDSN(path), nil
+	}
+	return "", "", fmt.Errorf("database URL: unsupported scheme %q", scheme)
+}
+
+// IsSQLite reports whether db was opened with the SQLite driver, for the
+// few queries whose syntax differs.
+func IsSQLite(db *sqlx.DB) bool {
+	return db != nil && db.DriverName() == "sqlite"
+}
+
+// ILike is the case-insensitive LIKE operator for db, including the escape
+// clause SQLite needs to honour backslash escapes as Postgres does.
+func ILike(db *sqlx.DB, column, placeholder string) string {
+	if IsSQLite(db) {
+		return column + " LIKE " + placeholder + ` ESCAPE '\'`
+	}
+	return column + " ILIKE " + placeholder
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// sqliteTimeFormat is how timestamps are stored in SQLite. It matches the
+// driver's "sqlite" time format so values written by NOW() and values bound
+// from Go compare equal, and it sorts as text.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	// Queries are written for Postgres; NOW() is the only function they
+	// use that SQLite lacks.
+	sqlite.MustRegisterScalarFunction("now", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+}
+
+// sqliteDSN enables foreign keys, which SQLite leaves off by default, and
+// WAL with a busy timeout so the web server and workers can share the file.
+// Transactions take the write lock up front so they cannot deadlock when
+// upgrading from a read.
+func sqliteDSN(path string) string {
+	params := []string{
+		"_pragma=foreign_keys(1)",
+		"_pragma=busy_timeout(5000)",
+		"_txlock=immediate",
+		"_time_format=sqlite",
+	}
+	if path != ":memory:" {
+		params = append(params, "_pragma=journal_mode(WAL)")
+	}
+	return "file:" + path + "?" + strings.Join(params, "&")
+}
+
+// SQLiteError unwraps a SQLite error into its extended result code and
+// message, such as 2067 and "UNIQUE constraint failed: tags.name".
+func SQLiteError(err error) (code int, msg string, ok bool) {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, "", false
+	}
+	msg = strings.TrimPrefix(sqliteErr.Error(), "constraint failed: ")
+	msg = strings.TrimSuffix(msg, fmt.Sprintf(" (%d)", sqliteErr.Code()))
+	return sqliteErr.Code(), msg, true
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// checkLibrary checks that the request's user has role in the library. A
+// library they cannot see at all is reported as a missing entity, so its
+// contents are not revealed.
+func checkLibrary(r *http.Request, libraryID int64, role auth.Role, entity string) error {
+	a := auth.AccessFrom(r.Context())
+	if a.Can(libraryID, role) {
+		return nil
+	}
+	if a.Can(libraryID, auth.Viewer) {
+		return forbidden(fmt.Sprintf("you need the %s role in this library", role))
+	}
+	return notFound(entity)
+}
+
+// visibleLibraries returns the libraries the request's user can see, nil
+// meaning all, for store.ModelFilter.LibraryIDs.
+func visibleLibraries(r *http.Request) []int64 {
+	return auth.AccessFrom(r.Context()).Libraries()
+}
+
+// loadModel loads the model named by the URL parameter and checks the
+// user's role in its library.
+func loadModel(r *http.Request, st store.Store, param string, role auth.Role) (*models.Model, error) {
+	id, err := idParam(r, param)
+	if err != nil {
+		return nil, err
+	}
+	model, err := st.Models().Get(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, notFound("model")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLibrary(r, model.LibraryID, role, "model"); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// loadFile is loadModel for the file named by the id parameter.
+func loadFile(r *http.Request, st store.Store, role auth.Role) (*models.ModelFile, error) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	file, err := st.Files().Get(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, notFound("file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	model, err := st.Models().Get(r.Context(), file.ModelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, notFound("file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLibrary(r, model.LibraryID, role, "file"); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// requireSiteAdmin allows only site administrators, for changes that reach
+// beyond a single library.
+func requireSiteAdmin(r *http.Request) error {
+	user := auth.UserFrom(r.Context())
+	if user == nil || !user.Admin {
+		return forbidden("only administrators can do this")
+	}
+	return nil
+}
+
+// gone reports whether err means the target is missing or hidden from the
+// user. Deletes treat both as already done, so they reveal nothing.
+func gone(err error) bool {
+	return toAPIError(err).Status == 404
+}
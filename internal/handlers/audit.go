@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// record adds a change to the audit log. The change has already been made
+// by then, so a failure is logged rather than failing the request.
+func record(r *http.Request, st store.Store, action, entity string, id int64, before, after interface{}) {
+	if err := audit.Record(r.Context(), st, action, entity, id, before, after); err != nil {
+		log.Printf("Audit log: %s %s %d: %v", action, entity, id, err)
+	}
+}
+
+type AuditHandler struct {
+	store store.Store
+}
+
+func NewAuditHandler(st store.Store) *AuditHandler {
+	return &AuditHandler{store: st}
+}
+
+// List returns audit log entries, newest first by default.
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := parseListQuery(r, store.AuditSorts, "created", true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := h.store.Audit().List(r.Context(), f, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, pageOf(page))
+}
+
+// Export streams every entry matching the filters as CSV or as a JSON
+// array, depending on ?format=.
+func (h *AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := parseListQuery(r, store.AuditSorts, "created", true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p.Limit = maxPageSize
+
+	format := r.URL.Query().Get("format")
+	var write func(e models.AuditEntry) error
+	var finish func() error
+	switch format {
+	case "", "json":
+		format = "json"
+		enc := json.NewEncoder(w)
+		sep := "["
+		write = func(e models.AuditEntry) error {
+			if _, err := w.Write([]byte(sep)); err != nil {
+				return err
+			}
+			sep = ","
+			return enc.Encode(e)
+		}
+		finish = func() error {
+			if sep == "[" {
+				w.Write([]byte(sep))
+			}
+			_, err := w.Write([]byte("]\n"))
+			return err
+		}
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "created_at", "actor_id", "actor", "action", "entity", "entity_id", "source_ip", "before", "after"})
+		write = func(e models.AuditEntry) error {
+			return cw.Write([]string{
+				strconv.FormatInt(e.ID, 10),
+				e.CreatedAt.UTC().Format(time.RFC3339),
+				optionalID(e.ActorID),
+				e.Actor,
+				e.Action,
+				e.Entity,
+				optionalID(e.EntityID),
+				e.SourceIP,
+				optionalJSON(e.Before),
+				optionalJSON(e.After),
+			})
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		writeError(w, badRequest("format must be csv or json"))
+		return
+	}
+
+	// The first page is fetched before any output so its errors still get
+	// a proper response.
+	page, err := h.store.Audit().List(r.Context(), f, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	contentType := "application/json"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="go3d-audit-`+time.Now().UTC().Format("20060102")+"."+format+`"`)
+	for {
+		for _, e := range page.Items {
+			if err := write(e); err != nil {
+				return
+			}
+		}
+		if page.Next == nil {
+			break
+		}
+		p.After = page.Next
+		if page, err = h.store.Audit().List(r.Context(), f, p); err != nil {
+			log.Printf("Audit export: %v", err)
+			return
+		}
+	}
+	if err := finish(); err != nil {
+		log.Printf("Audit export: %v", err)
+	}
+}
+
+// auditFilter reads the actor, action, entity, entity_id, since and until
+// filters. Times are RFC 3339 or plain dates.
+func auditFilter(r *http.Request) (store.AuditFilter, error) {
+	q := r.URL.Query()
+	f := store.AuditFilter{Actor: q.Get("actor"), Action: q.Get("action"), Entity: q.Get("entity")}
+	if v := q.Get("entity_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			return f, badRequest("entity_id must be a positive integer")
+		}
+		f.EntityID = id
+	}
+	for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		var err error
+		if *t, err = time.Parse(time.RFC3339, v); err != nil {
+			if *t, err = time.Parse(time.DateOnly, v); err != nil {
+				return f, badRequest("%s must be a date or an RFC 3339 time", name)
+			}
+		}
+	}
+	return f, nil
+}
+
+func optionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func optionalJSON(v *models.JSON) string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/oidc"
+	"3d-library/internal/store"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SessionCookie holds the web UI's session ID.
+const SessionCookie = "go3d_session"
+
+// AuthOptions configures an AuthHandler.
+type AuthOptions struct {
+	// AnonymousRead lets requests without credentials use GET endpoints.
+	AnonymousRead bool
+	SessionTTL    time.Duration
+	// SecureCookies marks the session cookie for HTTPS only.
+	SecureCookies bool
+	// TrustedOrigins may send requests with the session cookie besides the
+	// server's own pages.
+	TrustedOrigins []string
+
+	// OIDC turns on single sign-on; nil leaves it off.
+	OIDC          *oidc.Provider
+	UsernameClaim string
+	GroupsClaim   string
+	// LinkExisting lets a first OIDC sign-in claim the local user of the
+	// same name.
+	LinkExisting bool
+
+	// ProxyNetworks are the reverse proxies trusted to name the user in
+	// ProxyHeader, with their groups in ProxyGroupsHeader.
+	ProxyNetworks     []*net.IPNet
+	ProxyHeader       string
+	ProxyGroupsHeader string
+
+	// Groups maps identity provider and proxy groups to admin rights and
+	// library roles; nil leaves them to Go3D.
+	Groups *auth.Groups
+}
+
+type AuthHandler struct {
+	store store.Store
+	opts  AuthOptions
+}
+
+func NewAuthHandler(st store.Store, opts AuthOptions) *AuthHandler {
+	return &AuthHandler{store: st, opts: opts}
+}
+
+func unauthorized(message string) *APIError {
+	return &APIError{Status: 401, Code: "unauthorized", Message: message}
+}
+
+func forbidden(message string) *APIError {
+	return &APIError{Status: 403, Code: "forbidden", Message: message}
+}
+
+func writeUnauthorized(w http.ResponseWriter, err *APIError) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="go3d"`)
+	writeError(w, err)
+}
+
+// Authenticate identifies the user from an "Authorization: Bearer" API
+// token, a trusted proxy's header or the session cookie, in that order, and
+// loads their library roles. A bad token is refused outright; a stale
+// cookie leaves the request anonymous.
+// Requests that arrive with a user already in their context, as the go3d
+// command's in-process ones do, keep it.
+func (h *AuthHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(auth.WithClientIP(r.Context(), h.ClientIP(r)))
+		if user := auth.UserFrom(r.Context()); user != nil {
+			h.serveAs(w, r, next, user)
+			return
+		}
+
+		if header := r.Header.Get("Authorization"); header != "" {
+			scheme, secret, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(secret) == "" {
+				writeUnauthorized(w, unauthorized("Authorization must be \"Bearer TOKEN\""))
+				return
+			}
+			token, err := h.store.Tokens().GetByHash(r.Context(), auth.HashSecret(strings.TrimSpace(secret)))
+			if errors.Is(err, store.ErrNotFound) {
+				writeUnauthorized(w, unauthorized("invalid or expired API token"))
+				return
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			user, ok := h.activeUser(w, r, token.UserID)
+			if !ok {
+				return
+			}
+			h.store.Tokens().Touch(r.Context(), token.ID)
+			h.serveAs(w, r, next, user)
+			return
+		}
+
+		if name := h.proxyUsername(r); name != "" {
+			// Browsers send the proxy's login along with every request,
+			// just as they would a cookie.
+			if !safeMethod(r.Method) && !h.sameOrigin(r) {
+				writeError(w, forbidden("cross-site request refused"))
+				return
+			}
+			user, ok := h.proxyUser(w, r, name)
+			if !ok {
+				return
+			}
+			h.serveAs(w, r, next, user)
+			return
+		}
+
+		if cookie, err := r.Cookie(SessionCookie); err == nil && cookie.Value != "" {
+			sess, err := h.store.Sessions().Get(r.Context(), auth.HashSecret(cookie.Value))
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				writeError(w, err)
+				return
+			}
+			if sess != nil {
+				if !safeMethod(r.Method) && !h.sameOrigin(r) {
+					writeError(w, forbidden("cross-site request refused"))
+					return
+				}
+				user, ok := h.activeUser(w, r, sess.UserID)
+				if !ok {
+					return
+				}
+				h.serveAs(w, r, next, user)
+				return
+			}
+		}
+		h.serveAs(w, r, next, nil)
+	})
+}
+
+// serveAs passes the request on with user, nil when anonymous, and their
+// access in its context.
+func (h *AuthHandler) serveAs(w http.ResponseWriter, r *http.Request, next http.Handler, user *models.User) {
+	access, err := auth.LoadAccess(r.Context(), h.store, user, h.opts.AnonymousRead)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx := auth.WithUser(r.Context(), user)
+	ctx = auth.WithAccess(ctx, access)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// activeUser loads the user behind a session or token, answering 401 when
+// they have been disabled.
+func (h *AuthHandler) activeUser(w http.ResponseWriter, r *http.Request, id int64) (*models.User, bool) {
+	user, err := h.store.Users().Get(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) || err == nil && user.Disabled {
+		writeUnauthorized(w, unauthorized("user is disabled"))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// sameOrigin guards cookie-authenticated changes against cross-site
+// requests: browsers send Origin or Sec-Fetch-Site with them, and other
+// clients do not carry the cookie.
+func (h *AuthHandler) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		site := r.Header.Get("Sec-Fetch-Site")
+		return site == "" || site == "same-origin" || site == "none"
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	for _, trusted := range h.opts.TrustedOrigins {
+		if strings.TrimSuffix(trusted, "/") == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireUser refuses anonymous requests, except reads when anonymous
+// read access is on.
+func (h *AuthHandler) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.UserFrom(r.Context()) == nil && !(h.opts.AnonymousRead && safeMethod(r.Method)) {
+			writeUnauthorized(w, unauthorized("sign in or send an API token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin refuses requests from anyone but administrators.
+func (h *AuthHandler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFrom(r.Context())
+		if user == nil {
+			writeUnauthorized(w, unauthorized("sign in or send an API token"))
+			return
+		}
+		if !user.Admin {
+			writeError(w, forbidden("only administrators can do this"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.store.Users().GetByName(r.Context(), strings.TrimSpace(req.Username))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	err = auth.CheckPassword(hash, req.Password)
+	if err != nil && !errors.Is(err, auth.ErrPassword) {
+		writeError(w, err)
+		return
+	}
+	if err != nil || user.Disabled {
+		writeError(w, &APIError{Status: 401, Code: "invalid_credentials", Message: "wrong username or password"})
+		return
+	}
+
+	if err := h.startSession(w, r, user.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Users().RecordLogin(r.Context(), user.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	now := time.Now().UTC()
+	user.LastLoginAt = &now
+	writeJSON(w, 200, user)
+}
+
+// startSession stores a new session for the user and sets its cookie. Only
+// a hash of the ID is stored, so a copy of the database cannot be used to
+// sign in.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int64) error {
+	secret, hash, err := auth.NewSecret("")
+	if err != nil {
+		return err
+	}
+	sess := &models.Session{ID: hash, UserID: userID, ExpiresAt: time.Now().Add(h.opts.SessionTTL)}
+	if err := h.store.Sessions().Create(r.Context(), sess); err != nil {
+		return err
+	}
+	http.SetCookie(w, h.cookie(secret, sess.ExpiresAt))
+	return nil
+}
+
+func (h *AuthHandler) cookie(value string, expires time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     SessionCookie,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   h.opts.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		c.MaxAge = -1
+	} else {
+		c.Expires = expires
+	}
+	return c
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(SessionCookie); err == nil && cookie.Value != "" {
+		err := h.store.Sessions().Delete(r.Context(), auth.HashSecret(cookie.Value))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			writeError(w, err)
+			return
+		}
+	}
+	http.SetCookie(w, h.cookie("", time.Time{}))
+	w.WriteHeader(204)
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFrom(r.Context())
+	if user == nil {
+		writeUnauthorized(w, unauthorized("not signed in"))
+		return
+	}
+	writeJSON(w, 200, user)
+}
+
+// ChangePassword sets the caller's password and signs out their other
+// sessions.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v := &validation{}
+	err := auth.CheckPassword(user.PasswordHash, req.CurrentPassword)
+	if err != nil && !errors.Is(err, auth.ErrPassword) {
+		writeError(w, err)
+		return
+	}
+	v.check(err == nil, "current_password", "is wrong")
+	v.add("new_password", validPassword(req.NewPassword))
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	before := *user
+	user.PasswordHash = hash
+	if err := h.store.Users().Update(r.Context(), user); err != nil {
+		writeUpdateError(w, err, "user")
+		return
+	}
+	record(r, h.store, audit.Update, "user", user.ID, before, userChange{*user, true})
+	if err := h.store.Sessions().DeleteForUser(r.Context(), user.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := r.Cookie(SessionCookie); err == nil {
+		if err := h.startSession(w, r, user.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	w.WriteHeader(204)
+}
+
+// account returns the signed-in user, answering 401 for anonymous requests
+// and for auth.System, which has no account.
+func account(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user := auth.UserFrom(r.Context())
+	if user == nil || user == auth.System {
+		writeUnauthorized(w, unauthorized("not signed in"))
+		return nil, false
+	}
+	return user, true
+}
+
+// NewToken is an API token as returned once, when it is created.
+type NewToken struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+func (h *AuthHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := h.store.Tokens().ListForUser(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, tokens)
+}
+
+func (h *AuthHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name          string `json:"name"`
+		ExpiresInDays *int   `json:"expires_in_days"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v := &validation{}
+	name, err := validName(&req.Name)
+	v.add("name", err)
+	v.check(req.ExpiresInDays == nil || *req.ExpiresInDays > 0, "expires_in_days", "must be at least 1")
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	secret, hash, err := auth.NewSecret(auth.TokenPrefix)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	token := NewToken{Token: secret, APIToken: models.APIToken{
+		UserID: user.ID,
+		Name:   name,
+		Hash:   hash,
+		Prefix: secret[:len(auth.TokenPrefix)+4],
+	}}
+	if req.ExpiresInDays != nil {
+		expires := time.Now().UTC().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+	if err := h.store.Tokens().Create(r.Context(), &token.APIToken); err != nil {
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Create, "token", token.ID, nil, token.APIToken)
+	writeJSON(w, 201, token)
+}
+
+func (h *AuthHandler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tokens, err := h.store.Tokens().ListForUser(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Tokens().Delete(r.Context(), user.ID, id); err != nil {
+		writeLookupError(w, err, "token")
+		return
+	}
+	for _, t := range tokens {
+		if t.ID == id {
+			record(r, h.store, audit.Delete, "token", id, t, nil)
+		}
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/jobs"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Requests touching more models than this are queued as a background job
+// instead of running inside the HTTP request.
+const bulkSyncLimit = 200
+
+type BulkHandler struct {
+	store  store.Store
+	db     *sqlx.DB
+	client jobs.Queue
+}
+
+func NewBulkHandler(st store.Store, db *sqlx.DB, client jobs.Queue) *BulkHandler {
+	return &BulkHandler{store: st, db: db, client: client}
+}
+
+type bulkRequest struct {
+	jobs.BulkRequest
+	Query string `json:"query"`
+}
+
+func (h *BulkHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req bulkRequest
+	if err := decodeStrict(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Query != "" && len(req.ModelIDs) > 0 {
+		writeError(w, invalidRequest("use either model_ids or query, not both"))
+		return
+	}
+	if req.Query != "" {
+		q, err := parseSearch(req.Query)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		req.ModelIDs, err = h.store.Models().IDs(r.Context(), store.ModelFilter{Query: q, LibraryIDs: visibleLibraries(r)})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, invalidRequest("%s", err))
+		return
+	}
+	if req.Operation == jobs.BulkAddToCollection || req.Operation == jobs.BulkRemoveFromCollection {
+		collection, err := h.store.Collections().Get(r.Context(), req.CollectionID)
+		if err != nil {
+			writeLookupError(w, err, "collection")
+			return
+		}
+		if err := checkOwner(r, collection); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if req.Operation == jobs.BulkMoveLibrary {
+		if err := checkLibrary(r, req.LibraryID, auth.Contributor, "library"); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	access := auth.AccessFrom(r.Context())
+
+	if len(req.ModelIDs) <= bulkSyncLimit {
+		results, err := jobs.RunBulk(r.Context(), h.store, h.db, &req.BulkRequest, access)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, 200, bulkResponse(req.Operation, results))
+		return
+	}
+
+	raw, _ := json.Marshal(req.BulkRequest)
+	job := models.BulkJob{
+		Operation: req.Operation,
+		Request:   string(raw),
+		Total:     len(req.ModelIDs),
+		UserID:    jobUser(r),
+		SourceIP:  auth.ClientIP(r.Context()),
+	}
+	if err := h.store.BulkJobs().Create(r.Context(), &job); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	task, err := jobs.NewBulkTask(job.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := h.client.Enqueue(task); err != nil {
+		if failErr := h.store.BulkJobs().Fail(r.Context(), job.ID, err.Error()); failErr != nil {
+			log.Printf("Bulk job %d: %v", job.ID, failErr)
+		}
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, 202, job)
+}
+
+func (h *BulkHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	job, err := h.store.BulkJobs().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "bulk job")
+		return
+	}
+	// Jobs are private to whoever queued them.
+	if user := auth.UserFrom(r.Context()); user == nil || !user.Admin && (job.UserID == nil || *job.UserID != user.ID) {
+		writeError(w, notFound("bulk job"))
+		return
+	}
+
+	var summary *bulkSummary
+	if job.Results != nil {
+		var results []jobs.BulkResult
+		json.Unmarshal([]byte(*job.Results), &results)
+		summary = bulkResponse(job.Operation, results)
+	}
+	writeJSON(w, 200, struct {
+		models.BulkJob
+		Summary *bulkSummary `json:"summary"`
+	}{*job, summary})
+}
+
+// jobUser is the user a queued job runs as, or nil for the system user.
+func jobUser(r *http.Request) *int64 {
+	if user := auth.UserFrom(r.Context()); user != nil && user.ID != 0 {
+		return &user.ID
+	}
+	return nil
+}
+
+type bulkSummary struct {
+	Operation string            `json:"operation"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []jobs.BulkResult `json:"results"`
+}
+
+func bulkResponse(operation string, results []jobs.BulkResult) *bulkSummary {
+	s := &bulkSummary{Operation: operation, Results: results}
+	for _, res := range results {
+		if res.OK {
+			s.Succeeded++
+		} else {
+			s.Failed++
+		}
+	}
+	return s
+}
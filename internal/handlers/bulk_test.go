@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/jobs"
+	"3d-library/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type bulkJob struct {
+	models.BulkJob
+	Summary *struct {
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+	} `json:"summary"`
+}
+
+// addUser creates a user who signs in with the API token secret.
+func addUser(t *testing.T, a *testAPI, name, secret string) *models.User {
+	t.Helper()
+	ctx := context.Background()
+	user := &models.User{Username: name}
+	if err := a.st.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	token := &models.APIToken{UserID: user.ID, Name: "test", Hash: auth.HashSecret(secret), Prefix: secret[:4]}
+	if err := a.st.Tokens().Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestBulkQueued(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Minis")
+	// One more than runs inside the request.
+	ids := make([]int64, 201)
+	for i := range ids {
+		m := &models.Model{LibraryID: library.ID, Name: fmt.Sprint("model ", i), Path: fmt.Sprintf("%s/m%d", library.Path, i)}
+		if err := a.st.Models().Create(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = m.ID
+	}
+	addUser(t, a, "carol", "g3d_carol")
+	request := map[string]interface{}{"operation": "add_tags", "model_ids": ids, "tags": []string{"painted"}}
+
+	var job bulkJob
+	a.do("POST", "/bulk", request).expect(t, 202, &job)
+	if job.ID == 0 || job.Status != "queued" || job.Total != len(ids) {
+		t.Fatalf("queued %+v", job.BulkJob)
+	}
+	if len(a.queue.tasks) != 1 || a.queue.tasks[0].Type() != jobs.TypeBulk {
+		t.Fatalf("enqueued %v", a.queue.tasks)
+	}
+
+	path := fmt.Sprintf("/bulk/%d", job.ID)
+	var got bulkJob
+	a.do("GET", path, nil).expect(t, 200, &got)
+	if got.Status != "queued" || got.Summary != nil {
+		t.Errorf("before running: %+v", got)
+	}
+	// Jobs are private to whoever queued them.
+	a.do("GET", path, nil, "Authorization", "Bearer g3d_carol").expect(t, 404, nil)
+
+	// A job the queue refuses is recorded as failed. Nothing else takes an
+	// ID in between, so it is the next one.
+	a.queue.err = errors.New("queue is down")
+	a.do("POST", "/bulk", request).expect(t, 500, nil)
+	var failed bulkJob
+	a.do("GET", fmt.Sprintf("/bulk/%d", job.ID+1), nil).expect(t, 200, &failed)
+	if failed.Status != "failed" || failed.Error == nil || *failed.Error != "queue is down" {
+		t.Errorf("refused job: %+v", failed.BulkJob)
+	}
+	a.queue.err = nil
+
+	if err := jobs.HandleBulkTask(context.Background(), a.queue.tasks[0], a.st, nil); err != nil {
+		t.Fatal(err)
+	}
+	var done bulkJob
+	a.do("GET", path, nil).expect(t, 200, &done)
+	if done.Status != "done" || done.FinishedAt == nil || done.Summary == nil || done.Summary.Succeeded != len(ids) || done.Summary.Failed != 0 {
+		t.Errorf("after running: %+v", done)
+	}
+}
@@ -1,98 +1,367 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
 	"3d-library/internal/models"
-	"encoding/json"
+	"3d-library/internal/search"
+	"3d-library/internal/store"
+	"errors"
 	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 )
 
 type CollectionHandler struct {
-	db *sqlx.DB
+	store store.Store
 }
 
-func NewCollectionHandler(db *sqlx.DB) *CollectionHandler {
-	return &CollectionHandler{db: db}
+func NewCollectionHandler(st store.Store) *CollectionHandler {
+	return &CollectionHandler{store: st}
 }
 
 func (h *CollectionHandler) List(w http.ResponseWriter, r *http.Request) {
-	var collections []models.Collection
-	err := h.db.Select(&collections, "SELECT * FROM collections ORDER BY created_at DESC")
+	p, err := parseListQuery(r, store.CollectionSorts, "created", true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	collections, err := h.store.Collections().List(r.Context(), r.URL.Query().Get("q"), p)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(collections)
+	writeJSON(w, 200, pageOf(collections))
 }
 
 func (h *CollectionHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var collection models.Collection
-	err := h.db.Get(&collection, "SELECT * FROM collections WHERE id = $1", id)
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	collection, err := h.store.Collections().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "collection")
+		return
+	}
+	setETag(w, collection.UpdatedAt)
+	writeJSON(w, 200, collection)
+}
+
+func (h *CollectionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req struct {
+		Name  *string          `json:"name"`
+		Query optional[string] `json:"query"`
+	}
+	if err := decodeStrict(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	collection, err := h.store.Collections().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "collection")
+		return
+	}
+	if err := checkOwner(r, collection); err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ifMatch(r, collection.UpdatedAt) {
+		writeError(w, preconditionFailed("collection"))
+		return
+	}
+	before := *collection
+
+	v := &validation{}
+	changed := false
+	if req.Name != nil {
+		name, err := validName(req.Name)
+		v.add("name", err)
+		collection.Name, changed = name, true
+	}
+	if req.Query.Set {
+		if req.Query.Value != nil {
+			_, err := search.Parse(*req.Query.Value)
+			v.add("query", err)
+		}
+		collection.Query, changed = req.Query.Value, true
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if changed {
+		if err := h.store.Collections().Update(r.Context(), collection); err != nil {
+			writeUpdateError(w, err, "collection")
+			return
+		}
+		record(r, h.store, audit.Update, "collection", collection.ID, before, collection)
+	}
+	setETag(w, collection.UpdatedAt)
+	writeJSON(w, 200, collection)
+}
+
+func (h *CollectionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		writeError(w, err)
+		return
+	}
+	collection, err := h.store.Collections().Get(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(204)
 		return
 	}
-	json.NewEncoder(w).Encode(collection)
+	if err == nil {
+		err = checkOwner(r, collection)
+	}
+	if err == nil {
+		err = h.store.Collections().Delete(r.Context(), id)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if err == nil {
+		record(r, h.store, audit.Delete, "collection", id, collection, nil)
+	}
+	w.WriteHeader(204)
 }
 
 func (h *CollectionHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var collection models.Collection
-	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
-		http.Error(w, err.Error(), 400)
+	if err := decodeJSON(r, &collection); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	err := h.db.QueryRow(
-		"INSERT INTO collections (name) VALUES ($1) RETURNING id, created_at",
-		collection.Name,
-	).Scan(&collection.ID, &collection.CreatedAt)
+	v := &validation{}
+	name, err := validName(&collection.Name)
+	v.add("name", err)
+	if collection.Query != nil {
+		_, err := search.Parse(*collection.Query)
+		v.add("query", err)
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	collection.Name = name
+	collection.UserID = nil
+	if user := auth.UserFrom(r.Context()); user != nil && user.ID != 0 {
+		collection.UserID = &user.ID
+	}
 
-	if err != nil {
-		http.Error(w, err.Error(), 500)
+	if err := h.store.Collections().Create(r.Context(), &collection); err != nil {
+		writeError(w, err)
 		return
 	}
+	record(r, h.store, audit.Create, "collection", collection.ID, nil, collection)
 
-	w.WriteHeader(201)
-	json.NewEncoder(w).Encode(collection)
+	writeJSON(w, 201, collection)
 }
 
 func (h *CollectionHandler) AddModel(w http.ResponseWriter, r *http.Request) {
-	collectionID := chi.URLParam(r, "id")
+	collectionID, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	var req struct {
 		ModelID int64 `json:"model_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), 400)
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	v := &validation{}
+	v.check(req.ModelID > 0, "model_id", "is required")
+	if err := v.err(); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	_, err := h.db.Exec(
-		"INSERT INTO model_collections (model_id, collection_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-		req.ModelID, collectionID,
-	)
+	collection, err := h.store.Collections().Get(r.Context(), collectionID)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeLookupError(w, err, "collection")
+		return
+	}
+	if err := checkOwner(r, collection); err != nil {
+		writeError(w, err)
+		return
+	}
+	if collection.Query != nil {
+		writeError(w, conflict("smart collection members come from its query"))
+		return
+	}
+	if err := h.checkModel(r, req.ModelID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.store.Collections().AddModel(r.Context(), collectionID, req.ModelID); err != nil {
+		writeError(w, err)
 		return
 	}
+	record(r, h.store, audit.Add, "collection", collectionID, nil, map[string]int64{"model_id": req.ModelID})
 
 	w.WriteHeader(204)
 }
 
+func (h *CollectionHandler) RemoveModel(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	modelID, err := idParam(r, "modelID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	collection, err := h.store.Collections().Get(r.Context(), collectionID)
+	if err != nil {
+		writeLookupError(w, err, "collection")
+		return
+	}
+	if err := checkOwner(r, collection); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.checkModel(r, modelID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Collections().RemoveModel(r.Context(), collectionID, modelID); err != nil {
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Remove, "collection", collectionID, map[string]int64{"model_id": modelID}, nil)
+	w.WriteHeader(204)
+}
+
 func (h *CollectionHandler) GetModels(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var modelsList []models.Model
-	err := h.db.Select(&modelsList, `
-		SELECT m.* FROM models m
-		JOIN model_collections mc ON m.id = mc.model_id
-		WHERE mc.collection_id = $1
-		ORDER BY m.created_at DESC
-	`, id)
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := parseListQuery(r, store.ModelSorts, "created", true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	collection, err := h.store.Collections().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "collection")
+		return
+	}
+
+	filter, err := collectionFilter(collection)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter.LibraryIDs = visibleLibraries(r)
+
+	page, err := h.store.Models().List(r.Context(), filter, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, pageOf(page))
+}
+
+// collectionFilter selects the models in a collection: its manual members,
+// or for a smart collection the matches of its query.
+func collectionFilter(c *models.Collection) (store.ModelFilter, error) {
+	if c.Query == nil {
+		return store.ModelFilter{CollectionID: c.ID}, nil
+	}
+	q, err := parseSearch(*c.Query)
+	if err != nil {
+		return store.ModelFilter{}, err
+	}
+	return store.ModelFilter{Query: q}, nil
+}
+
+// checkModel checks that the user may change which collections the model
+// is in, which like tagging needs the contributor role.
+func (h *CollectionHandler) checkModel(r *http.Request, modelID int64) error {
+	model, err := h.store.Models().Get(r.Context(), modelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return notFound("model")
+	}
+	if err != nil {
+		return err
+	}
+	return checkLibrary(r, model.LibraryID, auth.Contributor, "model")
+}
+
+// checkOwner checks that the user may change the collection, which only
+// its owner and site administrators may.
+func checkOwner(r *http.Request, c *models.Collection) error {
+	if !auth.AccessFrom(r.Context()).Owns(c.UserID) {
+		return forbidden("only the collection's owner or an administrator can change it")
+	}
+	return nil
+}
+
+// SetQuery turns a collection into a smart collection, replaces its rule, or
+// with a null query turns it back into a manual collection.
+func (h *CollectionHandler) SetQuery(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req struct {
+		Query *string `json:"query"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Query != nil {
+		v := &validation{}
+		_, err := search.Parse(*req.Query)
+		v.add("query", err)
+		if err := v.err(); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	var before, collection *models.Collection
+	err = h.store.InTx(r.Context(), func(tx store.Store) error {
+		c, err := tx.Collections().Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if err := checkOwner(r, c); err != nil {
+			return err
+		}
+		if !ifMatch(r, c.UpdatedAt) {
+			return preconditionFailed("collection")
+		}
+		old := *c
+		before = &old
+		c.Query = req.Query
+		collection = c
+		return tx.Collections().Update(r.Context(), c)
+	})
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeUpdateError(w, err, "collection")
 		return
 	}
-	json.NewEncoder(w).Encode(modelsList)
+	record(r, h.store, audit.Update, "collection", id, before, collection)
+	setETag(w, collection.UpdatedAt)
+	writeJSON(w, 200, collection)
 }
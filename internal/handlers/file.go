@@ -1,61 +1,252 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
 	"3d-library/internal/models"
-	"encoding/json"
+	"3d-library/internal/safepath"
+	"3d-library/internal/store"
+	"errors"
+	"log"
 	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
+	"os"
 )
 
 type FileHandler struct {
-	db *sqlx.DB
+	store store.Store
 }
 
-func NewFileHandler(db *sqlx.DB) *FileHandler {
-	return &FileHandler{db: db}
+func NewFileHandler(st store.Store) *FileHandler {
+	return &FileHandler{store: st}
 }
 
 func (h *FileHandler) GetModelFiles(w http.ResponseWriter, r *http.Request) {
-	modelID := chi.URLParam(r, "id")
-	var files []models.ModelFile
-	err := h.db.Select(&files, "SELECT * FROM model_files WHERE model_id = $1 ORDER BY filename", modelID)
+	model, err := loadModel(r, h.store, "id", auth.Viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := parseListQuery(r, store.FileSorts, "name", false)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(files)
+	files, err := h.store.Files().ListByModel(r.Context(), model.ID, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, pageOf(files))
 }
 
 func (h *FileHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var file models.ModelFile
-	err := h.db.Get(&file, "SELECT * FROM model_files WHERE id = $1", id)
+	file, err := loadFile(r, h.store, auth.Viewer)
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(file)
+	writeJSON(w, 200, file)
 }
 
+// Delete moves the file to the trash. The mode query parameter says what
+// happens on disk: "forget" leaves the file there and makes scans skip it,
+// "disk" moves it into the library's .trash folder.
 func (h *FileHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	_, err := h.db.Exec("DELETE FROM model_files WHERE id = $1", id)
+	file, err := loadFile(r, h.store, auth.Editor)
+	if gone(err) {
+		w.WriteHeader(204)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode != "forget" && mode != "disk" {
+		writeError(w, badRequest("mode must be forget or disk"))
 		return
 	}
+	library, err := h.libraryOf(r, file)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var deleted *models.ModelFile
+	var trashed string
+	err = h.store.InTx(r.Context(), func(tx store.Store) error {
+		if deleted, err = tx.Files().Delete(r.Context(), file.ID); err != nil {
+			return err
+		}
+		if mode == "forget" {
+			rel, err := safepath.Rel(library.Path, file.Path)
+			if err != nil {
+				return outsideLibrary(file)
+			}
+			return tx.Files().Ignore(r.Context(), library.ID, rel)
+		}
+		if trashed, err = safepath.Trash(library.Path, file.Path); err != nil {
+			return diskError(file, err)
+		}
+		deleted.TrashPath = &trashed
+		return tx.Files().SetTrashPath(r.Context(), file.ID, &trashed)
+	})
+	if err != nil && trashed != "" {
+		// The row is still there, so the file goes back too.
+		if err := safepath.Untrash(library.Path, trashed, file.Path); err != nil {
+			log.Printf("Putting back %s: %v", file.Path, err)
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.refreshModel(r, deleted)
+	record(r, h.store, audit.Delete, "file", file.ID, file, map[string]interface{}{"mode": mode, "trash_path": deleted.TrashPath})
 	w.WriteHeader(204)
 }
 
+// Restore brings the file back from the trash, and back into its folder if
+// it was deleted from disk.
+func (h *FileHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var file *models.ModelFile
+	var moved bool
+	var library *models.Library
+	err = h.store.InTx(r.Context(), func(tx store.Store) error {
+		if file, err = tx.Files().Restore(r.Context(), id); err != nil {
+			return err
+		}
+		// Files in the trash cannot be loaded, so access is checked
+		// after restoring and undoes it when denied.
+		model, err := tx.Models().Get(r.Context(), file.ModelID)
+		if err != nil {
+			return err
+		}
+		if err := checkLibrary(r, model.LibraryID, auth.Editor, "file"); err != nil {
+			return err
+		}
+		if library, err = tx.Libraries().Get(r.Context(), model.LibraryID); err != nil {
+			return err
+		}
+		if file.TrashPath == nil {
+			rel, err := safepath.Rel(library.Path, file.Path)
+			if err != nil {
+				return outsideLibrary(file)
+			}
+			return tx.Files().Unignore(r.Context(), library.ID, rel)
+		}
+		if err := tx.Files().SetTrashPath(r.Context(), file.ID, nil); err != nil {
+			return err
+		}
+		err = safepath.Untrash(library.Path, *file.TrashPath, file.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			return conflict("%s is missing from the library trash", *file.TrashPath)
+		}
+		if err != nil {
+			return diskError(file, err)
+		}
+		moved = true
+		return nil
+	})
+	if err != nil && moved {
+		if err := os.Rename(file.Path, *file.TrashPath); err != nil {
+			log.Printf("Putting %s back in the trash: %v", file.Path, err)
+		}
+	}
+	if err != nil {
+		writeLookupError(w, err, "file")
+		return
+	}
+	file.TrashPath = nil
+	h.refreshModel(r, file)
+	record(r, h.store, audit.Restore, "file", file.ID, nil, file)
+	writeJSON(w, 200, file)
+}
+
+// libraryOf loads the library holding a file.
+func (h *FileHandler) libraryOf(r *http.Request, file *models.ModelFile) (*models.Library, error) {
+	model, err := h.store.Models().Get(r.Context(), file.ModelID)
+	if err != nil {
+		return nil, err
+	}
+	return h.store.Libraries().Get(r.Context(), model.LibraryID)
+}
+
+func outsideLibrary(file *models.ModelFile) *APIError {
+	return conflict("%s is outside its library folder", file.Path)
+}
+
+// diskError explains why a file could not be moved into or out of the
+// library trash.
+func diskError(file *models.ModelFile, err error) error {
+	switch {
+	case errors.Is(err, safepath.ErrOutside):
+		return conflict("moving %s would leave its library folder", file.Path)
+	case errors.Is(err, os.ErrNotExist):
+		return conflict("%s is missing from disk; forget it instead", file.Path)
+	case errors.Is(err, os.ErrExist):
+		return conflict("%s already exists on disk", file.Path)
+	}
+	return err
+}
+
+// refreshModel updates the model's size and dimensions after one of its
+// files was deleted or restored. A deleted preview gives way to the
+// default one, and a restored file may fill a model without one.
+func (h *FileHandler) refreshModel(r *http.Request, file *models.ModelFile) {
+	ctx := r.Context()
+	h.store.Models().RefreshStats(ctx, file.ModelID)
+	model, err := h.store.Models().Get(ctx, file.ModelID)
+	if err != nil {
+		return
+	}
+	switch {
+	case file.DeletedAt != nil && model.PreviewFileID != nil && *model.PreviewFileID == file.ID:
+		h.store.Models().SetPreview(ctx, model.ID, nil)
+		store.ChooseDefaultPreview(ctx, h.store, model.ID)
+	case file.DeletedAt == nil && model.PreviewFileID == nil:
+		store.ChooseDefaultPreview(ctx, h.store, model.ID)
+	}
+}
+
 func (h *FileHandler) Serve(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var file models.ModelFile
-	err := h.db.Get(&file, "SELECT * FROM model_files WHERE id = $1", id)
+	file, err := loadFile(r, h.store, auth.Viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	library, err := h.libraryOf(r, file)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	f, err := safepath.Open(library.Path, file.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, notFound("file"))
+		return
+	}
+	if errors.Is(err, safepath.ErrOutside) || errors.Is(err, safepath.ErrOutsideRoots) {
+		writeError(w, forbidden("the file is outside its library"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		writeError(w, notFound("file"))
 		return
 	}
-	http.ServeFile(w, r, file.Path)
+	http.ServeContent(w, r, file.Filename, info.ModTime(), f)
 }
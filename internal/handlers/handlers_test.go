@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/config"
+	"3d-library/internal/safepath"
+	"3d-library/internal/server"
+	"3d-library/internal/store/memstore"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+// testAPI serves the full router on an empty memstore. Requests without an
+// Authorization header are made as auth.System, as the go3d command's are.
+// Libraries may be anywhere in the temporary directory, and tasks are
+// kept in queue rather than run.
+type testAPI struct {
+	t     *testing.T
+	srv   *httptest.Server
+	st    *memstore.Store
+	queue *testQueue
+}
+
+// testQueue keeps the tasks enqueued, or fails with err when it is set.
+type testQueue struct {
+	mu    sync.Mutex
+	tasks []*asynq.Task
+	err   error
+}
+
+func (q *testQueue) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return nil, q.err
+	}
+	q.tasks = append(q.tasks, task)
+	return &asynq.TaskInfo{Type: task.Type(), Payload: task.Payload(), State: asynq.TaskStatePending}, nil
+}
+
+func (q *testQueue) Close() error { return nil }
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	roots := safepath.Roots
+	safepath.Roots = []string{os.TempDir()}
+	t.Cleanup(func() { safepath.Roots = roots })
+	cfg := config.Default()
+	cfg.Log.Requests = false
+	st := memstore.New()
+	queue := &testQueue{}
+	router := server.NewRouter(cfg, st, nil, queue, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			r = r.WithContext(auth.WithUser(r.Context(), auth.System))
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return &testAPI{t: t, srv: srv, st: st, queue: queue}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends body as JSON, unless it is nil, with the header name/value
+// pairs given.
+func (a *testAPI) do(method, path string, body interface{}, header ...string) *response {
+	a.t.Helper()
+	var r io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		r = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, a.srv.URL+"/api"+path, r)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: raw}
+}
+
+// expect fails the test unless the response has status, and decodes the
+// body into v when v is not nil.
+func (r *response) expect(t *testing.T, status int, v interface{}) *response {
+	t.Helper()
+	if r.status != status {
+		t.Fatalf("status %d, want %d: %s", r.status, status, r.body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(r.body, v); err != nil {
+			t.Fatalf("decoding %s: %v", r.body, err)
+		}
+	}
+	return r
+}
+
+// errorCode returns the code of an error response.
+func (r *response) errorCode(t *testing.T) string {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatalf("decoding %s: %v", r.body, err)
+	}
+	return body.Error.Code
+}
+
+// page is the list envelope.
+type page[T any] struct {
+	Items      []T     `json:"items"`
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
@@ -1,70 +1,396 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
 	"3d-library/internal/models"
-	"encoding/json"
+	"3d-library/internal/safepath"
+	"3d-library/internal/store"
+	"errors"
+	"fmt"
 	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
+	"path/filepath"
+	"strings"
 )
 
 type LibraryHandler struct {
-	db *sqlx.DB
+	store store.Store
 }
 
-func NewLibraryHandler(db *sqlx.DB) *LibraryHandler {
-	return &LibraryHandler{db: db}
+func NewLibraryHandler(st store.Store) *LibraryHandler {
+	return &LibraryHandler{store: st}
 }
 
+// List returns the libraries the user can see.
 func (h *LibraryHandler) List(w http.ResponseWriter, r *http.Request) {
-	var libraries []models.Library
-	err := h.db.Select(&libraries, "SELECT * FROM libraries ORDER BY created_at DESC")
+	libraries, err := h.store.Libraries().List(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(libraries)
+	a := auth.AccessFrom(r.Context())
+	visible := libraries[:0]
+	for _, l := range libraries {
+		if a.Can(l.ID, auth.Viewer) {
+			visible = append(visible, l)
+		}
+	}
+	writeJSON(w, 200, visible)
 }
 
 func (h *LibraryHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var library models.Library
-	err := h.db.Get(&library, "SELECT * FROM libraries WHERE id = $1", id)
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, id, auth.Viewer, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+	library, err := h.store.Libraries().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "library")
+		return
+	}
+	setETag(w, library.UpdatedAt)
+	writeJSON(w, 200, library)
+}
+
+// Update renames a library or changes its path, storage or quota. Moving the
+// path rewrites the stored model and file paths under it in the same
+// transaction.
+func (h *LibraryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req struct {
+		Name    *string         `json:"name"`
+		Path    *string         `json:"path"`
+		Storage *string         `json:"storage"`
+		QuotaMB optional[int64] `json:"quota_mb"`
+	}
+	if err := decodeStrict(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, id, auth.Admin, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+	// Paths reach outside the library, and library admins could otherwise
+	// lift their own quota.
+	if req.Path != nil || req.QuotaMB.Set {
+		if err := requireSiteAdmin(r); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	library, err := h.store.Libraries().Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		writeLookupError(w, err, "library")
+		return
+	}
+	if !ifMatch(r, library.UpdatedAt) {
+		writeError(w, preconditionFailed("library"))
+		return
+	}
+	before := *library
+
+	v := &validation{}
+	changed := false
+	if req.Name != nil {
+		name, err := validName(req.Name)
+		v.add("name", err)
+		library.Name, changed = name, true
+	}
+	oldPath := library.Path
+	if req.Path != nil {
+		path, err := h.validPath(*req.Path)
+		v.add("path", err)
+		if err == nil && path != oldPath {
+			library.Path, changed = path, true
+		}
+	}
+	if req.Storage != nil {
+		v.check(*req.Storage == "local", "storage", "must be local")
+		library.Storage, changed = *req.Storage, true
+	}
+	if req.QuotaMB.Set {
+		v.check(req.QuotaMB.Value == nil || *req.QuotaMB.Value >= 0, "quota_mb", "must not be negative")
+		library.QuotaMB, changed = req.QuotaMB.Value, true
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(library)
+
+	if changed {
+		err := h.store.InTx(r.Context(), func(tx store.Store) error {
+			if err := tx.Libraries().Update(r.Context(), library); err != nil {
+				return err
+			}
+			if library.Path == oldPath {
+				return nil
+			}
+			return tx.Models().RewritePaths(r.Context(), library.ID, oldPath, library.Path)
+		})
+		if err != nil {
+			writeUpdateError(w, err, "library")
+			return
+		}
+		record(r, h.store, audit.Update, "library", library.ID, before, library)
+	}
+	setETag(w, library.UpdatedAt)
+	writeJSON(w, 200, library)
+}
+
+// validPath cleans path and checks that it is an existing absolute
+// directory inside one of the allowed roots, wherever its symlinks lead.
+func (h *LibraryHandler) validPath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("is required")
+	}
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("must be absolute")
+	}
+	_, err := safepath.Root(path)
+	if errors.Is(err, safepath.ErrNoRoots) {
+		return "", fmt.Errorf("cannot be checked until libraries.allowed_roots is set")
+	}
+	if errors.Is(err, safepath.ErrOutsideRoots) {
+		return "", fmt.Errorf("must be inside one of the allowed library roots")
+	}
+	if err != nil {
+		return "", fmt.Errorf("must be an existing directory")
+	}
+	return path, nil
 }
 
+// Create adds a library. Only site administrators may, as the path reaches
+// outside every existing library.
 func (h *LibraryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if err := requireSiteAdmin(r); err != nil {
+		writeError(w, err)
+		return
+	}
 	var library models.Library
-	if err := json.NewDecoder(r.Body).Decode(&library); err != nil {
-		http.Error(w, err.Error(), 400)
+	if err := decodeJSON(r, &library); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	err := h.db.QueryRow(
-		"INSERT INTO libraries (name, path, storage) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at",
-		library.Name, library.Path, library.Storage,
-	).Scan(&library.ID, &library.CreatedAt, &library.UpdatedAt)
+	v := &validation{}
+	name, err := validName(&library.Name)
+	v.add("name", err)
+	path, err := h.validPath(library.Path)
+	v.add("path", err)
+	if library.Storage == "" {
+		library.Storage = "local"
+	}
+	v.check(library.Storage == "local", "storage", "must be local")
+	v.check(library.QuotaMB == nil || *library.QuotaMB >= 0, "quota_mb", "must not be negative")
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	library.Name, library.Path = name, path
 
-	if err != nil {
-		http.Error(w, err.Error(), 500)
+	if err := h.store.Libraries().Create(r.Context(), &library); err != nil {
+		writeError(w, err)
 		return
 	}
+	record(r, h.store, audit.Create, "library", library.ID, nil, library)
 
-	w.WriteHeader(201)
-	json.NewEncoder(w).Encode(library)
+	writeJSON(w, 201, library)
 }
 
+// Delete moves the library to the trash with its models and files.
 func (h *LibraryHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	_, err := h.db.Exec("DELETE FROM libraries WHERE id = $1", id)
+	id, err := idParam(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
+	if err := checkLibrary(r, id, auth.Admin, "library"); gone(err) {
+		w.WriteHeader(204)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	library, err := h.store.Libraries().Get(r.Context(), id)
+	if err == nil {
+		err = h.store.Libraries().Delete(r.Context(), id)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if err == nil {
+		record(r, h.store, audit.Delete, "library", id, library, nil)
+	}
 	w.WriteHeader(204)
 }
+
+// Restore brings the library back from the trash with the models and files
+// deleted along with it.
+func (h *LibraryHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// Roles in the library outlive it in the trash.
+	if err := checkLibrary(r, id, auth.Admin, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+	library, err := h.store.Libraries().Restore(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "library")
+		return
+	}
+	record(r, h.store, audit.Restore, "library", library.ID, nil, library)
+	writeJSON(w, 200, library)
+}
+
+// ListMembers returns the users given a role in the library. Site
+// administrators are admins everywhere and are not listed.
+func (h *LibraryHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, id, auth.Admin, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+	list, err := h.store.Members().ListForLibrary(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, list)
+}
+
+// SetMember gives a user, named by username, a role in the library,
+// replacing any role they had.
+func (h *LibraryHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, id, auth.Admin, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v := &validation{}
+	v.check(strings.TrimSpace(req.Username) != "", "username", "is required")
+	v.check(auth.Role(req.Role).Valid(), "role", "must be viewer, contributor, editor or admin")
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := h.store.Users().GetByName(r.Context(), strings.TrimSpace(req.Username))
+	if errors.Is(err, store.ErrNotFound) {
+		v.check(false, "username", "no such user")
+		writeError(w, v.err())
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.checkNotSelf(r, user.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	before, err := h.member(r, id, user.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	member := &models.LibraryMember{LibraryID: id, UserID: user.ID, Role: req.Role}
+	if err := h.store.Members().Set(r.Context(), member); err != nil {
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Add, "library", id, before, member)
+	writeJSON(w, 200, member)
+}
+
+func (h *LibraryHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := idParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, id, auth.Admin, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.checkNotSelf(r, userID); err != nil {
+		writeError(w, err)
+		return
+	}
+	before, err := h.member(r, id, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Members().Remove(r.Context(), id, userID); err != nil {
+		writeLookupError(w, err, "member")
+		return
+	}
+	record(r, h.store, audit.Remove, "library", id, before, nil)
+	w.WriteHeader(204)
+}
+
+// member returns the user's membership of the library, or nil, for the
+// audit log.
+func (h *LibraryHandler) member(r *http.Request, libraryID, userID int64) (*models.LibraryMember, error) {
+	list, err := h.store.Members().ListForLibrary(r.Context(), libraryID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		if m.UserID == userID {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkNotSelf stops library admins changing their own role, which could
+// leave a library without one. Site administrators keep their access
+// either way.
+func (h *LibraryHandler) checkNotSelf(r *http.Request, userID int64) error {
+	user := auth.UserFrom(r.Context())
+	if user != nil && user.ID == userID && !user.Admin {
+		return forbidden("you cannot change your own role")
+	}
+	return nil
+}
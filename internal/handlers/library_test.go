@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"3d-library/internal/models"
+	"fmt"
+	"testing"
+)
+
+func createLibrary(t *testing.T, a *testAPI, name string) models.Library {
+	t.Helper()
+	var library models.Library
+	a.do("POST", "/libraries", map[string]string{"name": name, "path": t.TempDir()}).expect(t, 201, &library)
+	return library
+}
+
+func TestLibraryCRUD(t *testing.T) {
+	a := newTestAPI(t)
+
+	library := createLibrary(t, a, "Terrain")
+	if library.ID == 0 || library.Name != "Terrain" || library.Storage != "local" {
+		t.Fatalf("created %+v", library)
+	}
+
+	var list []models.Library
+	a.do("GET", "/libraries", nil).expect(t, 200, &list)
+	if len(list) != 1 || list[0].ID != library.ID {
+		t.Fatalf("listed %+v", list)
+	}
+
+	path := fmt.Sprintf("/libraries/%d", library.ID)
+	resp := a.do("GET", path, nil).expect(t, 200, nil)
+	etag := resp.header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	var renamed models.Library
+	a.do("PATCH", path, map[string]string{"name": "Scenery"}, "If-Match", etag).expect(t, 200, &renamed)
+	if renamed.Name != "Scenery" || renamed.Path != library.Path {
+		t.Fatalf("renamed to %+v", renamed)
+	}
+	resp = a.do("PATCH", path, map[string]string{"name": "Terrain"}, "If-Match", etag).expect(t, 412, nil)
+	if code := resp.errorCode(t); code != "precondition_failed" {
+		t.Errorf("stale update: code %q", code)
+	}
+
+	a.do("DELETE", path, nil).expect(t, 204, nil)
+	a.do("GET", path, nil).expect(t, 404, nil)
+	a.do("DELETE", path, nil).expect(t, 204, nil)
+}
+
+func TestLibraryValidation(t *testing.T) {
+	a := newTestAPI(t)
+
+	resp := a.do("POST", "/libraries", map[string]string{"name": " ", "path": "relative"}).expect(t, 422, nil)
+	if code := resp.errorCode(t); code != "validation_failed" {
+		t.Errorf("code %q", code)
+	}
+	a.do("POST", "/libraries", map[string]string{"name": "Gone", "path": t.TempDir() + "/missing"}).expect(t, 422, nil)
+
+	a.do("GET", "/libraries/999", nil).expect(t, 404, nil)
+	a.do("PATCH", "/libraries/999", map[string]string{"name": "x"}).expect(t, 404, nil)
+	a.do("GET", "/libraries/abc", nil).expect(t, 400, nil)
+}
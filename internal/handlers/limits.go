@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/ratelimit"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BodyLimits caps request bodies: Routes by "METHOD pattern", with the
+// pattern as chi matches it such as "POST /api/bulk", and every other route
+// by Default.
+type BodyLimits struct {
+	Default int64
+	Routes  map[string]int64
+}
+
+// For returns the cap on bodies sent to route.
+func (l BodyLimits) For(route string) int64 {
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// LimitBody applies limits to the requests router serves. A body that says
+// it is too large is refused before it is read, and one that turns out too
+// large fails the handler reading it.
+func LimitBody(router chi.Routes, limits BodyLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := limits.Default
+			rctx := chi.NewRouteContext()
+			if router.Match(rctx, r.Method, r.URL.Path) {
+				limit = limits.For(r.Method + " " + rctx.RoutePattern())
+			}
+			if r.ContentLength > limit {
+				writeError(w, bodyTooLarge(limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bodyTooLarge(limit int64) *APIError {
+	if limit%(1<<20) == 0 {
+		return tooLarge("request body is larger than %d MB", limit>>20)
+	}
+	return tooLarge("request body is larger than %d KB", limit>>10)
+}
+
+// RateLimits are the rates RateLimiter allows. Search, Download and Scan
+// count the requests to those endpoints again, on top of User and IP.
+type RateLimits struct {
+	User     ratelimit.Rate
+	IP       ratelimit.Rate
+	Search   ratelimit.Rate
+	Download ratelimit.Rate
+	Scan     ratelimit.Rate
+}
+
+// RateLimiter answers 429 with a Retry-After header to clients over their
+// rates. With a nil Limiter, as when the go3d command runs the handlers in
+// process, it lets everything through. When the counts cannot be reached
+// requests are let through too, rather than taking the API down with them.
+type RateLimiter struct {
+	limiter  ratelimit.Limiter
+	rates    RateLimits
+	clientIP func(*http.Request) string
+}
+
+func NewRateLimiter(limiter ratelimit.Limiter, rates RateLimits, clientIP func(*http.Request) string) *RateLimiter {
+	return &RateLimiter{limiter: limiter, rates: rates, clientIP: clientIP}
+}
+
+// PerIP counts every request against its client address. It goes before
+// Authenticate, so guessing tokens and passwords counts too.
+func (h *RateLimiter) PerIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.allow(w, r, "ip:"+h.clientIP(r), h.rates.IP) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// PerUser counts every request by a signed-in user against them.
+func (h *RateLimiter) PerUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := auth.UserFrom(r.Context()); user != nil {
+			if !h.allow(w, r, "user:"+strconv.FormatInt(user.ID, 10), h.rates.User) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *RateLimiter) Search(next http.Handler) http.Handler {
+	return h.bucket("search", h.rates.Search, next)
+}
+
+func (h *RateLimiter) Download(next http.Handler) http.Handler {
+	return h.bucket("download", h.rates.Download, next)
+}
+
+func (h *RateLimiter) Scan(next http.Handler) http.Handler {
+	return h.bucket("scan", h.rates.Scan, next)
+}
+
+// bucket counts requests per user, or per client address for anonymous
+// ones such as share link downloads.
+func (h *RateLimiter) bucket(name string, rate ratelimit.Rate, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := name + ":ip:" + h.clientIP(r)
+		if user := auth.UserFrom(r.Context()); user != nil {
+			key = name + ":user:" + strconv.FormatInt(user.ID, 10)
+		}
+		if h.allow(w, r, key, rate) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow counts the request against key, answering 429 and returning false
+// once it is over rate.
+func (h *RateLimiter) allow(w http.ResponseWriter, r *http.Request, key string, rate ratelimit.Rate) bool {
+	if h.limiter == nil || rate.Requests <= 0 {
+		return true
+	}
+	ok, retry, err := h.limiter.Allow(r.Context(), key, rate)
+	if err != nil {
+		log.Printf("Rate limit for %s: %v", key, err)
+		return true
+	}
+	if ok {
+		return true
+	}
+	seconds := int(math.Ceil(retry.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, &APIError{Status: 429, Code: "rate_limited", Message: fmt.Sprintf("too many requests; try again in %d seconds", seconds)})
+	return false
+}
@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"3d-library/internal/config"
+	"3d-library/internal/ratelimit"
+	"3d-library/internal/server"
+	"3d-library/internal/store/memstore"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a time that only moves when told to.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// limitedAPI serves the full router with the in-memory limiter on a fake
+// clock. Proxies in 10.0.0.0/8 are trusted and name their user in
+// X-Forwarded-User.
+type limitedAPI struct {
+	t      *testing.T
+	router http.Handler
+	clock  *clock
+}
+
+func newLimitedAPI(t *testing.T, limits config.Limits) *limitedAPI {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Log.Requests = false
+	cfg.Limits = limits
+	cfg.Auth.Proxy.TrustedProxies = []string{"10.0.0.0/8"}
+	cfg.Auth.Proxy.Header = "X-Forwarded-User"
+	c := &clock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	limiter := ratelimit.NewMemory()
+	limiter.Now = c.Now
+	return &limitedAPI{t: t, router: server.NewRouter(cfg, memstore.New(), nil, nil, limiter), clock: c}
+}
+
+// from sends a request from the client address ip with the header
+// name/value pairs given.
+func (a *limitedAPI) from(ip, method, path string, body io.Reader, header ...string) *response {
+	a.t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	req.RemoteAddr = ip + ":40000"
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return &response{status: w.Code, header: w.Header(), body: w.Body.Bytes()}
+}
+
+// limited fails the test unless the response is a 429 asking the client to
+// wait retryAfter seconds.
+func (r *response) limited(t *testing.T, retryAfter string) {
+	t.Helper()
+	r.expect(t, 429, nil)
+	if code := r.errorCode(t); code != "rate_limited" {
+		t.Errorf("code %q", code)
+	}
+	if got := r.header.Get("Retry-After"); got != retryAfter {
+		t.Errorf("Retry-After %q, want %q", got, retryAfter)
+	}
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	a := newLimitedAPI(t, config.Limits{IPPerMinute: 2, MaxBodyKB: 1024})
+
+	for i := 0; i < 2; i++ {
+		a.from("192.0.2.1", "GET", "/api/auth/methods", nil).expect(t, 200, nil)
+	}
+	a.from("192.0.2.1", "GET", "/api/auth/methods", nil).limited(t, "60")
+	a.from("192.0.2.2", "GET", "/api/auth/methods", nil).expect(t, 200, nil)
+
+	a.clock.Advance(20 * time.Second)
+	a.from("192.0.2.1", "GET", "/api/auth/methods", nil).limited(t, "40")
+	a.clock.Advance(40 * time.Second)
+	a.from("192.0.2.1", "GET", "/api/auth/methods", nil).expect(t, 200, nil)
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	a := newLimitedAPI(t, config.Limits{UserPerMinute: 2, MaxBodyKB: 1024})
+	me := func(user, client string) *response {
+		return a.from("10.0.0.1", "GET", "/api/auth/me", nil, "X-Forwarded-User", user, "X-Forwarded-For", client)
+	}
+
+	me("alice", "198.51.100.1").expect(t, 200, nil)
+	me("alice", "198.51.100.2").expect(t, 200, nil)
+	// The count follows the user to another address.
+	me("alice", "198.51.100.3").limited(t, "60")
+	me("bob", "198.51.100.1").expect(t, 200, nil)
+
+	// Anonymous requests are not counted per user.
+	for i := 0; i < 5; i++ {
+		a.from("198.51.100.1", "GET", "/api/auth/methods", nil).expect(t, 200, nil)
+	}
+
+	a.clock.Advance(time.Minute)
+	me("alice", "198.51.100.1").expect(t, 200, nil)
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	a := newLimitedAPI(t, config.Limits{IPPerMinute: 1, MaxBodyKB: 1024})
+	via := func(remote, forwardedFor string) *response {
+		return a.from(remote, "GET", "/api/auth/methods", nil, "X-Forwarded-For", forwardedFor)
+	}
+
+	// Behind a trusted proxy each client has its own count.
+	via("10.0.0.1", "198.51.100.1").expect(t, 200, nil)
+	via("10.0.0.1", "198.51.100.2").expect(t, 200, nil)
+	via("10.0.0.1", "198.51.100.1").limited(t, "60")
+	// Through a second trusted proxy, and with a made-up address put in
+	// front by the client, it is still the same client.
+	via("10.0.0.2", "198.51.100.1, 10.0.0.1").limited(t, "60")
+	via("10.0.0.1", "203.0.113.9, 198.51.100.1").limited(t, "60")
+
+	// Other clients cannot pick the address they are counted under.
+	via("192.0.2.50", "198.51.100.3").expect(t, 200, nil)
+	via("192.0.2.50", "198.51.100.4").limited(t, "60")
+	via("10.0.0.1", "198.51.100.3").expect(t, 200, nil)
+}
+
+func TestBodyLimits(t *testing.T) {
+	a := newLimitedAPI(t, config.Limits{MaxBodyKB: 1, BodyKB: []string{"POST /api/bulk=4"}})
+	big := `{"name": "` + strings.Repeat("x", 2<<10) + `"}`
+
+	resp := a.from("192.0.2.1", "POST", "/api/libraries", strings.NewReader(big)).expect(t, 413, nil)
+	if code := resp.errorCode(t); code != "too_large" {
+		t.Errorf("code %q", code)
+	}
+	if !strings.Contains(string(resp.body), "larger than 1 KB") {
+		t.Errorf("body %s", resp.body)
+	}
+
+	// Without a length the body is cut off while the handler reads it.
+	resp = a.from("192.0.2.1", "POST", "/api/auth/login", io.MultiReader(strings.NewReader(big))).expect(t, 413, nil)
+	if code := resp.errorCode(t); code != "too_large" {
+		t.Errorf("unsized body: code %q", code)
+	}
+
+	// A route with its own cap lets the same body through to the handler.
+	if resp := a.from("192.0.2.1", "POST", "/api/bulk", strings.NewReader(big)); resp.status == 413 {
+		t.Errorf("POST /api/bulk under its own cap: %d %s", resp.status, resp.body)
+	}
+}
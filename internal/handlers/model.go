@@ -1,100 +1,283 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/jobs"
 	"3d-library/internal/models"
-	"encoding/json"
+	"3d-library/internal/store"
+	"3d-library/internal/thumbnail"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 )
 
 type ModelHandler struct {
-	db *sqlx.DB
+	store store.Store
+	db    *sqlx.DB
 }
 
-func NewModelHandler(db *sqlx.DB) *ModelHandler {
-	return &ModelHandler{db: db}
+func NewModelHandler(st store.Store, db *sqlx.DB) *ModelHandler {
+	return &ModelHandler{store: st, db: db}
 }
 
 func (h *ModelHandler) List(w http.ResponseWriter, r *http.Request) {
-	libraryID := r.URL.Query().Get("library_id")
-	
-	var modelsList []models.Model
-	var err error
-	
-	if libraryID != "" {
-		err = h.db.Select(&modelsList, "SELECT * FROM models WHERE library_id = $1 ORDER BY created_at DESC", libraryID)
-	} else {
-		err = h.db.Select(&modelsList, "SELECT * FROM models ORDER BY created_at DESC LIMIT 100")
-	}
-	
+	p, err := parseListQuery(r, store.ModelSorts, "created", true)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(modelsList)
+
+	filter := store.ModelFilter{LibraryIDs: visibleLibraries(r)}
+	if v := r.URL.Query().Get("library_id"); v != "" {
+		filter.LibraryID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, badRequest("library_id must be an integer"))
+			return
+		}
+	}
+	if q := r.URL.Query().Get("q"); q != "" {
+		filter.Query, err = parseSearch(q)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	page, err := h.store.Models().List(r.Context(), filter, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, pageOf(page))
 }
 
 func (h *ModelHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var model models.Model
-	err := h.db.Get(&model, "SELECT * FROM models WHERE id = $1", id)
+	model, err := loadModel(r, h.store, "id", auth.Viewer)
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(model)
+	setETag(w, model.UpdatedAt)
+	writeJSON(w, 200, model)
+}
+
+func (h *ModelHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          *string          `json:"name"`
+		Description   optional[string] `json:"description"`
+		PreviewFileID optional[int64]  `json:"preview_file_id"`
+	}
+	if err := decodeStrict(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	model, err := loadModel(r, h.store, "id", auth.Editor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ifMatch(r, model.UpdatedAt) {
+		writeError(w, preconditionFailed("model"))
+		return
+	}
+	before := *model
+
+	v := &validation{}
+	changed := false
+	if req.Name != nil {
+		name, err := validName(req.Name)
+		v.add("name", err)
+		model.Name, changed = name, true
+	}
+	if req.Description.Set {
+		model.Description, changed = req.Description.Value, true
+	}
+	if req.PreviewFileID.Set {
+		if req.PreviewFileID.Value != nil {
+			v.check(h.ownsFile(r, model.ID, *req.PreviewFileID.Value), "preview_file_id", "must be a file of this model")
+		}
+		model.PreviewFileID, changed = req.PreviewFileID.Value, true
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if changed {
+		if err := h.store.Models().Update(r.Context(), model); err != nil {
+			writeUpdateError(w, err, "model")
+			return
+		}
+		record(r, h.store, audit.Update, "model", model.ID, before, model)
+	}
+	setETag(w, model.UpdatedAt)
+	writeJSON(w, 200, model)
+}
+
+// ownsFile reports whether fileID is one of the model's files.
+func (h *ModelHandler) ownsFile(r *http.Request, modelID, fileID int64) bool {
+	file, err := h.store.Files().Get(r.Context(), fileID)
+	return err == nil && file.ModelID == modelID
 }
 
 func (h *ModelHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var model models.Model
-	if err := json.NewDecoder(r.Body).Decode(&model); err != nil {
-		http.Error(w, err.Error(), 400)
+	if err := decodeJSON(r, &model); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	// Use ON CONFLICT to handle duplicates
-	err := h.db.QueryRow(`
-		INSERT INTO models (library_id, name, path, description) 
-		VALUES ($1, $2, $3, $4) 
-		ON CONFLICT (library_id, path) DO UPDATE 
-		SET name = EXCLUDED.name, description = EXCLUDED.description, updated_at = NOW()
-		RETURNING id, created_at, updated_at
-	`, model.LibraryID, model.Name, model.Path, model.Description).Scan(&model.ID, &model.CreatedAt, &model.UpdatedAt)
+	v := &validation{}
+	v.check(model.LibraryID > 0, "library_id", "is required")
+	name, err := validName(&model.Name)
+	v.add("name", err)
+	v.check(strings.TrimSpace(model.Path) != "", "path", "is required")
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	model.Name = name
+	if err := checkLibrary(r, model.LibraryID, auth.Contributor, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
 
-	if err != nil {
-		http.Error(w, err.Error(), 500)
+	if err := h.store.Models().Create(r.Context(), &model); err != nil {
+		writeError(w, err)
 		return
 	}
+	record(r, h.store, audit.Create, "model", model.ID, nil, model)
 
-	w.WriteHeader(201)
-	json.NewEncoder(w).Encode(model)
+	writeJSON(w, 201, model)
 }
 
+// Delete moves the model to the trash with its files. Its tags and
+// collections are kept for a restore.
 func (h *ModelHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	_, err := h.db.Exec("DELETE FROM models WHERE id = $1", id)
+	model, err := loadModel(r, h.store, "id", auth.Editor)
+	if gone(err) {
+		w.WriteHeader(204)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
+	err = h.store.Models().Delete(r.Context(), model.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if err == nil {
+		record(r, h.store, audit.Delete, "model", model.ID, model, nil)
+	}
 	w.WriteHeader(204)
 }
 
+// Restore brings the model back from the trash with the files deleted along
+// with it.
+func (h *ModelHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var model *models.Model
+	err = h.store.InTx(r.Context(), func(tx store.Store) error {
+		if model, err = tx.Models().Restore(r.Context(), id); err != nil {
+			return err
+		}
+		// Models in the trash cannot be loaded, so access is checked
+		// after restoring and undoes it when denied.
+		return checkLibrary(r, model.LibraryID, auth.Editor, "model")
+	})
+	if err != nil {
+		writeLookupError(w, err, "model")
+		return
+	}
+	record(r, h.store, audit.Restore, "model", model.ID, nil, model)
+	writeJSON(w, 200, model)
+}
+
 func (h *ModelHandler) SetPreview(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
 	var req struct {
-		FileID *int `json:"file_id"`
+		FileID *int64 `json:"file_id"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	model, err := loadModel(r, h.store, "id", auth.Editor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id := model.ID
+	if req.FileID != nil {
+		v := &validation{}
+		v.check(h.ownsFile(r, id, *req.FileID), "file_id", "must be a file of this model")
+		if err := v.err(); err != nil {
+			writeError(w, err)
+			return
+		}
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), 400)
+	if err := h.store.Models().SetPreview(r.Context(), id, req.FileID); err != nil {
+		writeLookupError(w, err, "model")
 		return
 	}
-	_, err := h.db.Exec("UPDATE models SET preview_file_id = $1 WHERE id = $2", req.FileID, id)
+	record(r, h.store, audit.Update, "model", id, previewOf{model.PreviewFileID}, previewOf{req.FileID})
+	w.WriteHeader(204)
+}
+
+func (h *ModelHandler) RecordPrint(w http.ResponseWriter, r *http.Request) {
+	model, err := loadModel(r, h.store, "id", auth.Contributor)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
+	if err := h.store.Models().RecordPrint(r.Context(), model.ID); err != nil {
+		writeLookupError(w, err, "model")
+		return
+	}
+	record(r, h.store, audit.Update, "model", model.ID, printsOf{model.PrintCount}, printsOf{model.PrintCount + 1})
 	w.WriteHeader(204)
 }
+
+// previewOf and printsOf are the parts of a model that SetPreview and
+// RecordPrint change, as the audit log shows them.
+type previewOf struct {
+	PreviewFileID *int64 `json:"preview_file_id"`
+}
+
+type printsOf struct {
+	PrintCount int `json:"print_count"`
+}
+
+// Thumbnail serves the server-rendered thumbnail, rendering it first if it
+// is not cached yet.
+func (h *ModelHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	model, err := loadModel(r, h.store, "id", auth.Viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id := model.ID
+	path := thumbnail.Path(id)
+	if _, err := os.Stat(path); err != nil {
+		if err := jobs.UpdateThumbnail(h.db, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			writeError(w, notFound("thumbnail"))
+			return
+		}
+	}
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"3d-library/internal/models"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func createModel(t *testing.T, a *testAPI, libraryID int64, name string) models.Model {
+	t.Helper()
+	var model models.Model
+	a.do("POST", "/models", map[string]interface{}{"library_id": libraryID, "name": name, "path": "/" + name}).expect(t, 201, &model)
+	return model
+}
+
+func TestModelCRUD(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Minis")
+
+	model := createModel(t, a, library.ID, "dragon")
+	if model.ID == 0 || model.LibraryID != library.ID || model.Name != "dragon" {
+		t.Fatalf("created %+v", model)
+	}
+
+	path := fmt.Sprintf("/models/%d", model.ID)
+	var got models.Model
+	resp := a.do("GET", path, nil).expect(t, 200, &got)
+	if got.ID != model.ID {
+		t.Fatalf("got %+v", got)
+	}
+	etag := resp.header.Get("ETag")
+
+	var updated models.Model
+	resp = a.do("PATCH", path, map[string]interface{}{"name": "wyrm", "description": "big"}, "If-Match", etag).expect(t, 200, &updated)
+	if updated.Name != "wyrm" || updated.Description == nil || *updated.Description != "big" {
+		t.Fatalf("updated to %+v", updated)
+	}
+	if resp.header.Get("ETag") == etag {
+		t.Error("ETag did not change")
+	}
+	a.do("PATCH", path, map[string]interface{}{"name": "dragon"}, "If-Match", etag).expect(t, 412, nil)
+
+	// Recording a print changes the model, so its old ETag goes stale.
+	etag = a.do("GET", path, nil).expect(t, 200, nil).header.Get("ETag")
+	a.do("POST", path+"/prints", nil).expect(t, 204, nil)
+	a.do("PATCH", path, map[string]interface{}{"name": "dragon"}, "If-Match", etag).expect(t, 412, nil)
+
+	resp = a.do("PATCH", path, map[string]interface{}{"colour": "red"}).expect(t, 400, nil)
+	if code := resp.errorCode(t); code != "invalid_json" {
+		t.Errorf("unknown field: code %q", code)
+	}
+
+	a.do("DELETE", path, nil).expect(t, 204, nil)
+	a.do("GET", path, nil).expect(t, 404, nil)
+	a.do("PATCH", path, map[string]interface{}{"name": "x"}).expect(t, 404, nil)
+	a.do("DELETE", path, nil).expect(t, 204, nil)
+
+	var restored models.Model
+	a.do("POST", path+"/restore", nil).expect(t, 200, &restored)
+	if restored.Name != "wyrm" {
+		t.Errorf("restored %+v", restored)
+	}
+}
+
+func TestModelCreateErrors(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Minis")
+
+	// Administrators reach the store, whose foreign key refuses it.
+	a.do("POST", "/models", map[string]interface{}{"library_id": 999, "name": "x", "path": "/x"}).expect(t, 409, nil)
+	a.do("POST", "/models", map[string]interface{}{"library_id": library.ID, "name": "", "path": ""}).expect(t, 422, nil)
+	a.do("GET", "/models/999", nil).expect(t, 404, nil)
+}
+
+func TestModelPaging(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Minis")
+	names := []string{"e", "b", "d", "a", "c"}
+	for _, name := range names {
+		createModel(t, a, library.ID, name)
+	}
+
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(names) {
+			t.Fatal("paging does not end")
+		}
+		q := url.Values{"limit": {"2"}, "sort": {"name"}, "order": {"asc"}}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		var p page[models.Model]
+		a.do("GET", "/models?"+q.Encode(), nil).expect(t, 200, &p)
+		// Only the first page is counted.
+		if cursor == "" && (p.Total == nil || *p.Total != len(names)) {
+			t.Errorf("first page total %v, want %d", p.Total, len(names))
+		}
+		if cursor != "" && p.Total != nil {
+			t.Errorf("total %d on a later page", *p.Total)
+		}
+		if len(p.Items) > 2 {
+			t.Fatalf("page of %d, limit 2", len(p.Items))
+		}
+		for _, m := range p.Items {
+			seen = append(seen, m.Name)
+		}
+		if p.NextCursor == nil {
+			break
+		}
+		cursor = *p.NextCursor
+	}
+	if fmt.Sprint(seen) != "[a b c d e]" {
+		t.Errorf("paged through %v", seen)
+	}
+
+	a.do("GET", "/models?cursor=garbage", nil).expect(t, 400, nil)
+	a.do("GET", "/models?sort=colour", nil).expect(t, 400, nil)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"3d-library/internal/store"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Page is the envelope returned by every list endpoint. NextCursor is nil on
+// the last page; pass it back as ?cursor= to fetch the following one. Total
+// is only counted on the first page and is null on the pages after it.
+type Page struct {
+	Items      interface{} `json:"items"`
+	Total      *int        `json:"total"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// parseListQuery reads limit, sort, order and cursor. Pages are keyset based
+// on (sort, id), so deep pages cost the same as the first one. sorts lists
+// the accepted sort names.
+func parseListQuery(r *http.Request, sorts []string, defaultSort string, defaultDesc bool) (store.PageRequest, error) {
+	params := r.URL.Query()
+	p := store.PageRequest{Limit: defaultPageSize, Sort: defaultSort, Desc: defaultDesc}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, badRequest("limit must be a positive integer")
+		}
+		if n > maxPageSize {
+			n = maxPageSize
+		}
+		p.Limit = n
+	}
+
+	if v := params.Get("sort"); v != "" {
+		p.Sort = v
+	}
+	known := false
+	for _, name := range sorts {
+		known = known || name == p.Sort
+	}
+	if !known {
+		names := append([]string(nil), sorts...)
+		sort.Strings(names)
+		return p, badRequest("sort must be one of: %s", strings.Join(names, ", "))
+	}
+
+	switch strings.ToLower(params.Get("order")) {
+	case "":
+	case "asc":
+		p.Desc = false
+	case "desc":
+		p.Desc = true
+	default:
+		return p, badRequest("order must be asc or desc")
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return p, badRequest("invalid cursor")
+		}
+		var c store.Cursor
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return p, badRequest("invalid cursor")
+		}
+		p.After = &c
+	}
+	return p, nil
+}
+
+// pageOf builds the envelope for a page from the store.
+func pageOf[T any](p *store.Page[T]) Page {
+	page := Page{Items: p.Items, Total: p.Total}
+	if p.Next != nil {
+		raw, _ := json.Marshal(p.Next)
+		next := base64.RawURLEncoding.EncodeToString(raw)
+		page.NextCursor = &next
+	}
+	return page
+}
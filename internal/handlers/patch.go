@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// optional distinguishes a JSON field that was left out from one explicitly
+// set to null, which PATCH needs for nullable columns.
+type optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (o *optional[T]) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if bytes.Equal(b, []byte("null")) {
+		o.Value = nil
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
+
+// ETags are derived from updated_at, so any write that bumps it invalidates
+// copies clients are holding.
+func etag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d"`, updatedAt.UnixNano())
+}
+
+func setETag(w http.ResponseWriter, updatedAt time.Time) {
+	w.Header().Set("ETag", etag(updatedAt))
+}
+
+// ifMatch reports whether the If-Match header allows overwriting a row last
+// updated at updatedAt. Requests without the header are not checked.
+func ifMatch(r *http.Request, updatedAt time.Time) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || header == "*" {
+		return true
+	}
+	current := etag(updatedAt)
+	for _, v := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(v), "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+func validName(name *string) (string, error) {
+	v := strings.TrimSpace(*name)
+	if v == "" {
+		return "", fmt.Errorf("must not be empty")
+	}
+	if len(v) > 255 {
+		return "", fmt.Errorf("must be at most 255 characters")
+	}
+	return v, nil
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"3d-library/internal/database"
+	"3d-library/internal/store"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// APIError is the error body every handler returns:
+//
+//	{"error": {"code": "not_found", "message": "model not found"}}
+//
+// Fields is set for validation errors and maps request fields to problems.
+type APIError struct {
+	Status  int               `json:"-"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func notFound(entity string) *APIError {
+	return &APIError{Status: 404, Code: "not_found", Message: entity + " not found"}
+}
+
+func badRequest(format string, args ...interface{}) *APIError {
+	return &APIError{Status: 400, Code: "bad_request", Message: fmt.Sprintf(format, args...)}
+}
+
+// invalidRequest is a validation failure that is not tied to a single field.
+func invalidRequest(format string, args ...interface{}) *APIError {
+	return &APIError{Status: 422, Code: "validation_failed", Message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...interface{}) *APIError {
+	return &APIError{Status: 409, Code: "conflict", Message: fmt.Sprintf(format, args...)}
+}
+
+func tooLarge(format string, args ...interface{}) *APIError {
+	return &APIError{Status: 413, Code: "too_large", Message: fmt.Sprintf(format, args...)}
+}
+
+func preconditionFailed(entity string) *APIError {
+	return &APIError{Status: 412, Code: "precondition_failed", Message: entity + " was modified since it was read"}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.Status >= 500 {
+		log.Printf("API error: %v", err)
+	}
+	writeJSON(w, apiErr.Status, map[string]*APIError{"error": apiErr})
+}
+
+// writeLookupError reports a missing row as a 404 for the named entity and
+// anything else as usual.
+func writeLookupError(w http.ResponseWriter, err error, entity string) {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, store.ErrNotFound) {
+		err = notFound(entity)
+	}
+	writeError(w, err)
+}
+
+// writeUpdateError is writeLookupError for conditional updates, reporting a
+// lost race as a failed precondition.
+func writeUpdateError(w http.ResponseWriter, err error, entity string) {
+	if errors.Is(err, store.ErrStale) {
+		err = preconditionFailed(entity)
+	}
+	writeLookupError(w, err, entity)
+}
+
+// NotFound and MethodNotAllowed give unknown API routes the same envelope.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &APIError{Status: 404, Code: "not_found", Message: "no such endpoint"})
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &APIError{Status: 405, Code: "method_not_allowed", Message: r.Method + " is not allowed here"})
+}
+
+// toAPIError maps errors to responses. Store and database constraint errors
+// become 4xx since they are caused by the request, not the server.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, store.ErrNotFound):
+		return &APIError{Status: 404, Code: "not_found", Message: "not found"}
+	case errors.Is(err, store.ErrStale):
+		return &APIError{Status: 412, Code: "precondition_failed", Message: err.Error()}
+	case errors.Is(err, store.ErrDuplicate):
+		return &APIError{Status: 409, Code: "already_exists", Message: err.Error()}
+	case errors.Is(err, store.ErrReference):
+		return &APIError{Status: 409, Code: "invalid_reference", Message: err.Error()}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return &APIError{Status: 409, Code: "already_exists", Message: constraintMessage(pqErr, "already exists")}
+		case "23503":
+			return &APIError{Status: 409, Code: "invalid_reference", Message: constraintMessage(pqErr, "references a missing or in-use row")}
+		case "23502":
+			return &APIError{Status: 400, Code: "missing_field", Message: fmt.Sprintf("%s is required", pqErr.Column),
+				Fields: map[string]string{pqErr.Column: "is required"}}
+		case "23514", "22001", "22003":
+			return &APIError{Status: 400, Code: "invalid_value", Message: pqErr.Message}
+		case "22P02":
+			return &APIError{Status: 400, Code: "invalid_id", Message: "invalid identifier"}
+		}
+	}
+	if code, msg, ok := database.SQLiteError(err); ok {
+		switch code {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return &APIError{Status: 409, Code: "already_exists", Message: msg}
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return &APIError{Status: 409, Code: "invalid_reference", Message: "references a missing or in-use row"}
+		case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+			column := msg[strings.LastIndex(msg, ".")+1:]
+			return &APIError{Status: 400, Code: "missing_field", Message: fmt.Sprintf("%s is required", column),
+				Fields: map[string]string{column: "is required"}}
+		case sqlite3.SQLITE_CONSTRAINT_CHECK:
+			return &APIError{Status: 400, Code: "invalid_value", Message: msg}
+		}
+	}
+	return &APIError{Status: 500, Code: "internal", Message: "internal server error"}
+}
+
+func constraintMessage(err *pq.Error, what string) string {
+	if err.Detail != "" {
+		return err.Detail
+	}
+	if err.Table != "" {
+		return fmt.Sprintf("%s %s", err.Table, what)
+	}
+	return what
+}
+
+// parseMultipart parses a multipart body of at most limit bytes.
+func parseMultipart(w http.ResponseWriter, r *http.Request, limit int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	err := r.ParseMultipartForm(32 << 20)
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return bodyTooLarge(limit)
+	}
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	return nil
+}
+
+// idParam reads a numeric URL parameter.
+func idParam(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, &APIError{Status: 400, Code: "invalid_id", Message: fmt.Sprintf("%s must be a positive integer", name)}
+	}
+	return id, nil
+}
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	return decodeError(json.NewDecoder(r.Body).Decode(v))
+}
+
+// decodeStrict is decodeJSON that also rejects unknown fields, used by PATCH
+// so typos are not silently ignored.
+func decodeStrict(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return decodeError(dec.Decode(v))
+}
+
+// decodeError explains why a JSON body could not be read. A body cut off
+// by LimitBody is too large rather than malformed.
+func decodeError(err error) error {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return bodyTooLarge(maxBytes.Limit)
+	}
+	if err != nil {
+		return &APIError{Status: 400, Code: "invalid_json", Message: err.Error()}
+	}
+	return nil
+}
+
+// validation collects per-field problems with a request body.
+type validation struct {
+	fields map[string]string
+}
+
+func (v *validation) check(ok bool, field, message string) {
+	if ok {
+		return
+	}
+	if v.fields == nil {
+		v.fields = make(map[string]string)
+	}
+	if _, seen := v.fields[field]; !seen {
+		v.fields[field] = message
+	}
+}
+
+func (v *validation) add(field string, err error) {
+	if err != nil {
+		v.check(false, field, err.Error())
+	}
+}
+
+func (v *validation) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &APIError{Status: 422, Code: "validation_failed", Message: "request has invalid fields", Fields: v.fields}
+}
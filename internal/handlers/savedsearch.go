@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/models"
+	"3d-library/internal/search"
+	"3d-library/internal/store"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// SavedSearchHandler serves the signed-in user's own saved searches; other
+// users' searches are not found.
+type SavedSearchHandler struct {
+	store store.Store
+}
+
+func NewSavedSearchHandler(st store.Store) *SavedSearchHandler {
+	return &SavedSearchHandler{store: st}
+}
+
+func (h *SavedSearchHandler) List(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	searches, err := h.store.SavedSearches().List(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, searches)
+}
+
+func (h *SavedSearchHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	saved, err := h.store.SavedSearches().Get(r.Context(), user.ID, id)
+	if err != nil {
+		writeLookupError(w, err, "saved search")
+		return
+	}
+	writeJSON(w, 200, saved)
+}
+
+func validateSavedSearch(saved *models.SavedSearch) error {
+	v := &validation{}
+	name, err := validName(&saved.Name)
+	v.add("name", err)
+	if strings.TrimSpace(saved.Query) == "" {
+		v.check(false, "query", "must not be empty")
+	} else {
+		_, err := search.Parse(saved.Query)
+		v.add("query", err)
+	}
+	saved.Name = name
+	return v.err()
+}
+
+func (h *SavedSearchHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	var saved models.SavedSearch
+	if err := decodeJSON(r, &saved); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := validateSavedSearch(&saved); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	saved.UserID = user.ID
+	if err := h.store.SavedSearches().Create(r.Context(), &saved); err != nil {
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Create, "saved_search", saved.ID, nil, saved)
+
+	writeJSON(w, 201, saved)
+}
+
+func (h *SavedSearchHandler) Update(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var saved models.SavedSearch
+	if err := decodeJSON(r, &saved); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := validateSavedSearch(&saved); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	before, err := h.store.SavedSearches().Get(r.Context(), user.ID, id)
+	if err != nil {
+		writeLookupError(w, err, "saved search")
+		return
+	}
+	saved.ID, saved.UserID = id, user.ID
+	if err := h.store.SavedSearches().Update(r.Context(), &saved); err != nil {
+		writeLookupError(w, err, "saved search")
+		return
+	}
+	record(r, h.store, audit.Update, "saved_search", id, before, saved)
+	writeJSON(w, 200, saved)
+}
+
+func (h *SavedSearchHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	before, err := h.store.SavedSearches().Get(r.Context(), user.ID, id)
+	if err == nil {
+		err = h.store.SavedSearches().Delete(r.Context(), user.ID, id)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if err == nil {
+		record(r, h.store, audit.Delete, "saved_search", id, before, nil)
+	}
+	w.WriteHeader(204)
+}
+
+// Results runs the saved query and returns a page of matching models.
+func (h *SavedSearchHandler) Results(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := parseListQuery(r, store.ModelSorts, "created", true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	saved, err := h.store.SavedSearches().Get(r.Context(), user.ID, id)
+	if err != nil {
+		writeLookupError(w, err, "saved search")
+		return
+	}
+
+	q, err := parseSearch(saved.Query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := h.store.Models().List(r.Context(), store.ModelFilter{Query: q, LibraryIDs: visibleLibraries(r)}, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, pageOf(page))
+}
@@ -1,49 +1,66 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
 	"3d-library/internal/jobs"
-	"3d-library/internal/models"
-	"encoding/json"
+	"3d-library/internal/store"
+	"errors"
 	"net/http"
+	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/hibiken/asynq"
-	"github.com/jmoiron/sqlx"
 )
 
+// A library can only have one scan queued or running. The lock lapses after
+// scanLockTTL in case a scan dies without finishing.
+const scanLockTTL = 6 * time.Hour
+
 type ScanHandler struct {
-	db     *sqlx.DB
-	client *asynq.Client
+	store  store.Store
+	client jobs.Queue
 }
 
-func NewScanHandler(db *sqlx.DB, client *asynq.Client) *ScanHandler {
-	return &ScanHandler{db: db, client: client}
+func NewScanHandler(st store.Store, client jobs.Queue) *ScanHandler {
+	return &ScanHandler{store: st, client: client}
 }
 
 func (h *ScanHandler) ScanLibrary(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	
-	var library models.Library
-	err := h.db.Get(&library, "SELECT * FROM libraries WHERE id = $1", id)
+	id, err := idParam(r, "id")
 	if err != nil {
-		http.Error(w, "Library not found", 404)
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, id, auth.Editor, "library"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	library, err := h.store.Libraries().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "library")
 		return
 	}
 
 	// Queue the scan job
 	task, err := jobs.NewScanLibraryTask(library.ID, library.Path)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
 
-	info, err := h.client.Enqueue(task)
+	info, err := h.client.Enqueue(task, asynq.Unique(scanLockTTL))
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		writeError(w, &APIError{Status: 409, Code: "already_queued", Message: "a scan of this library is already queued"})
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
+	record(r, h.store, audit.Scan, "library", library.ID, nil, map[string]string{"job_id": info.ID})
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, 200, map[string]interface{}{
 		"message": "Scan queued",
 		"job_id":  info.ID,
 	})
@@ -1,45 +1,189 @@
 package handlers
 
 import (
+	"3d-library/internal/auth"
+	"3d-library/internal/imagesim"
 	"3d-library/internal/models"
+	"3d-library/internal/search"
+	"3d-library/internal/store"
+	"3d-library/internal/thumbnail"
 	"encoding/json"
+	"errors"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"net/http"
+	"strconv"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type SearchHandler struct {
-	db *sqlx.DB
+	store         store.Store
+	db            *sqlx.DB
+	index         *imagesim.Index
+	maxImageBytes int64
 }
 
-func NewSearchHandler(db *sqlx.DB) *SearchHandler {
-	return &SearchHandler{db: db}
+func NewSearchHandler(st store.Store, db *sqlx.DB, maxImageBytes int64) *SearchHandler {
+	return &SearchHandler{store: st, db: db, index: &imagesim.Index{}, maxImageBytes: maxImageBytes}
 }
 
 func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
-		http.Error(w, "query parameter required", 400)
+		writeError(w, badRequest("q is required"))
 		return
 	}
 
-	var modelsList []models.Model
-	err := h.db.Select(&modelsList, `
-		SELECT DISTINCT m.* FROM models m
-		LEFT JOIN model_files mf ON m.id = mf.model_id
-		WHERE 
-			m.name ILIKE $1 OR 
-			m.description ILIKE $1 OR
-			m.path ILIKE $1 OR
-			mf.filename ILIKE $1
-		ORDER BY m.created_at DESC
-		LIMIT 100
-	`, "%"+query+"%")
+	p, err := parseListQuery(r, store.ModelSorts, "created", true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	q, err := parseSearch(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter := store.ModelFilter{Query: q, LibraryIDs: visibleLibraries(r)}
+
+	page, err := h.store.Models().List(r.Context(), filter, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	facets, err := h.store.Models().Facets(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, 200, struct {
+		Page
+		Facets *store.Facets `json:"facets"`
+	}{pageOf(page), facets})
+}
+
+// parseSearch parses a search query, reporting syntax errors as a bad
+// request.
+func parseSearch(q string) (*search.Query, error) {
+	parsed, err := search.Parse(q)
+	if err != nil {
+		return nil, badRequest("%s", err)
+	}
+	return parsed, nil
+}
 
+type ImageMatch struct {
+	Model models.Model `json:"model"`
+	Score float64      `json:"score"`
+}
+
+// SearchImage finds the models whose thumbnails look most like an uploaded
+// image (multipart field "image").
+func (h *SearchHandler) SearchImage(w http.ResponseWriter, r *http.Request) {
+	if err := parseMultipart(w, r, h.maxImageBytes); err != nil {
+		writeError(w, err)
+		return
+	}
+	file, _, err := r.FormFile("image")
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, badRequest("image is required"))
 		return
 	}
+	defer file.Close()
 
-	json.NewEncoder(w).Encode(modelsList)
+	img, err := thumbnail.Decode(file)
+	if errors.Is(err, thumbnail.ErrTooLarge) {
+		writeError(w, tooLarge("%s", err))
+		return
+	}
+	if err != nil {
+		writeError(w, badRequest("unsupported image: %s", err))
+		return
+	}
+
+	limit := 20
+	if v := r.FormValue("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	if err := h.refreshIndex(); err != nil {
+		writeError(w, err)
+		return
+	}
+	a := auth.AccessFrom(r.Context())
+	var keep func(imagesim.Entry) bool
+	if a.Libraries() != nil {
+		keep = func(e imagesim.Entry) bool { return a.Can(e.LibraryID, auth.Viewer) }
+	}
+	matches := h.index.Nearest(imagesim.Extract(img), limit, keep)
+
+	ids := make([]int64, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ModelID
+	}
+	byID, err := h.store.Models().GetMany(r.Context(), ids)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	results := make([]ImageMatch, 0, len(matches))
+	for _, m := range matches {
+		if model, ok := byID[m.ModelID]; ok && a.Can(model.LibraryID, auth.Viewer) {
+			results = append(results, ImageMatch{Model: model, Score: m.Score})
+		}
+	}
+	writeJSON(w, 200, results)
+}
+
+// refreshIndex reloads the in-memory image index when features have been
+// added, removed or recomputed since it was last loaded.
+func (h *SearchHandler) refreshIndex() error {
+	var state struct {
+		Count  int     `db:"count"`
+		Latest *string `db:"latest"`
+	}
+	err := h.db.Get(&state, "SELECT COUNT(*) AS count, CAST(MAX(computed_at) AS TEXT) AS latest FROM model_image_features")
+	if err != nil {
+		return err
+	}
+	version := strconv.Itoa(state.Count)
+	if state.Latest != nil {
+		version += "@" + *state.Latest
+	}
+	if version == h.index.Version() {
+		return nil
+	}
+
+	var rows []struct {
+		ModelID   int64  `db:"model_id"`
+		LibraryID int64  `db:"library_id"`
+		Hash      int64  `db:"phash"`
+		Color     string `db:"color"`
+		Edges     string `db:"edges"`
+	}
+	err = h.db.Select(&rows, `
+		SELECT f.model_id, m.library_id, f.phash, f.color, f.edges
+		FROM model_image_features f
+		JOIN models m ON m.id = f.model_id`)
+	if err != nil {
+		return err
+	}
+	entries := make([]imagesim.Entry, 0, len(rows))
+	for _, row := range rows {
+		e := imagesim.Entry{ModelID: row.ModelID, LibraryID: row.LibraryID, Features: imagesim.Features{Hash: uint64(row.Hash)}}
+		if json.Unmarshal([]byte(row.Color), &e.Features.Color) != nil || json.Unmarshal([]byte(row.Edges), &e.Features.Edges) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	h.index.Load(version, entries)
+	return nil
 }
@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// shareCookie remembers that the password of a share link was entered. It
+// is scoped to the link's path.
+const shareCookie = "go3d_share"
+
+// ShareHandler manages share links and serves what they share to people
+// without an account.
+type ShareHandler struct {
+	store     store.Store
+	files     *FileHandler
+	publicURL string
+}
+
+func NewShareHandler(st store.Store, files *FileHandler, publicURL string) *ShareHandler {
+	return &ShareHandler{store: st, files: files, publicURL: strings.TrimSuffix(publicURL, "/")}
+}
+
+// Share is a share link as the API shows it.
+type Share struct {
+	models.ShareLink
+	HasPassword bool `json:"has_password"`
+}
+
+// NewShare is a share link as returned once, when it is created.
+type NewShare struct {
+	Share
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+func shareOf(l models.ShareLink) Share {
+	return Share{ShareLink: l, HasPassword: l.PasswordHash != ""}
+}
+
+// List returns the caller's share links. Administrators may ask for
+// another user's with user_id, or everyone's with user_id=0.
+func (h *ShareHandler) List(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	userID := user.ID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			writeError(w, badRequest("user_id must be a user ID"))
+			return
+		}
+		userID = id
+		if userID != user.ID && !user.Admin {
+			writeError(w, forbidden("only administrators can list other users' share links"))
+			return
+		}
+	}
+	links, err := h.store.Shares().List(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	list := make([]Share, len(links))
+	for i, l := range links {
+		list[i] = shareOf(l)
+	}
+	writeJSON(w, 200, list)
+}
+
+func (h *ShareHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		ModelID        *int64 `json:"model_id"`
+		CollectionID   *int64 `json:"collection_id"`
+		ExpiresInHours *int   `json:"expires_in_hours"`
+		Password       string `json:"password"`
+		MaxDownloads   *int   `json:"max_downloads"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v := &validation{}
+	v.check((req.ModelID == nil) != (req.CollectionID == nil), "model_id", "set either model_id or collection_id")
+	v.check(req.ExpiresInHours == nil || *req.ExpiresInHours > 0, "expires_in_hours", "must be at least 1")
+	v.check(req.MaxDownloads == nil || *req.MaxDownloads > 0, "max_downloads", "must be at least 1")
+	if req.Password != "" {
+		v.add("password", validPassword(req.Password))
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.ModelID != nil {
+		model, err := h.store.Models().Get(r.Context(), *req.ModelID)
+		if err == nil {
+			err = checkLibrary(r, model.LibraryID, auth.Viewer, "model")
+		}
+		if err != nil {
+			writeLookupError(w, err, "model")
+			return
+		}
+	} else if _, err := h.store.Collections().Get(r.Context(), *req.CollectionID); err != nil {
+		writeLookupError(w, err, "collection")
+		return
+	}
+
+	secret, hash, err := auth.NewSecret(auth.SharePrefix)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	link := models.ShareLink{
+		UserID:       user.ID,
+		ModelID:      req.ModelID,
+		CollectionID: req.CollectionID,
+		Hash:         hash,
+		Prefix:       secret[:len(auth.SharePrefix)+4],
+		MaxDownloads: req.MaxDownloads,
+	}
+	if req.Password != "" {
+		if link.PasswordHash, err = auth.HashPassword(req.Password); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if req.ExpiresInHours != nil {
+		expires := time.Now().UTC().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expires
+	}
+	if err := h.store.Shares().Create(r.Context(), &link); err != nil {
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Create, "share_link", link.ID, nil, shareOf(link))
+	writeJSON(w, 201, NewShare{Share: shareOf(link), Token: secret, URL: h.publicURL + "/s/" + secret})
+}
+
+// Delete revokes a share link. Users revoke their own; administrators
+// any.
+func (h *ShareHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user, ok := account(w, r)
+	if !ok {
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	link, err := h.store.Shares().Get(r.Context(), id)
+	if err == nil && link.UserID != user.ID && !user.Admin {
+		err = store.ErrNotFound
+	}
+	if err == nil {
+		err = h.store.Shares().Delete(r.Context(), id)
+	}
+	if err != nil {
+		writeLookupError(w, err, "share link")
+		return
+	}
+	record(r, h.store, audit.Delete, "share_link", id, shareOf(*link), nil)
+	w.WriteHeader(204)
+}
+
+// resolve loads the link named in the URL along with the access of the user
+// who made it: a link never shows more than its creator can still see.
+func (h *ShareHandler) resolve(r *http.Request) (*models.ShareLink, *auth.Access, error) {
+	link, err := h.store.Shares().GetByHash(r.Context(), auth.HashSecret(chi.URLParam(r, "token")))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, notFound("share link")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return nil, nil, &APIError{Status: 410, Code: "expired", Message: "this share link has expired"}
+	}
+	owner, err := h.store.Users().Get(r.Context(), link.UserID)
+	if errors.Is(err, store.ErrNotFound) || err == nil && owner.Disabled {
+		return nil, nil, notFound("share link")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	access, err := auth.LoadAccess(r.Context(), h.store, owner, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return link, access, nil
+}
+
+// unlocked reports whether the request may see a link's contents: the link
+// has no password, or it came in the X-Share-Password header or was
+// entered in the page's form earlier.
+func unlocked(r *http.Request, link *models.ShareLink) bool {
+	if link.PasswordHash == "" {
+		return true
+	}
+	if password := r.Header.Get("X-Share-Password"); password != "" {
+		return auth.CheckPassword(link.PasswordHash, password) == nil
+	}
+	cookie, err := r.Cookie(shareCookie)
+	return err == nil && cookie.Value == unlockKey(link)
+}
+
+// unlockKey is the cookie value for a link whose password was entered. It
+// cannot be made without the stored hashes.
+func unlockKey(link *models.ShareLink) string {
+	return auth.HashSecret(link.Hash + ":" + link.PasswordHash)
+}
+
+type sharedModel struct {
+	models.Model
+	Files []models.ModelFile
+}
+
+// contents lists the shared models with their files.
+func (h *ShareHandler) contents(r *http.Request, link *models.ShareLink, access *auth.Access) (string, []sharedModel, error) {
+	ctx := r.Context()
+	var title string
+	var list []models.Model
+	if link.ModelID != nil {
+		model, err := h.store.Models().Get(ctx, *link.ModelID)
+		if err != nil {
+			return "", nil, err
+		}
+		if !access.Can(model.LibraryID, auth.Viewer) {
+			return "", nil, notFound("share link")
+		}
+		title, list = model.Name, []models.Model{*model}
+	} else {
+		collection, err := h.store.Collections().Get(ctx, *link.CollectionID)
+		if err != nil {
+			return "", nil, err
+		}
+		ids, err := h.collectionModels(r, collection, access)
+		if err != nil {
+			return "", nil, err
+		}
+		found, err := h.store.Models().GetMany(ctx, ids)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, m := range found {
+			list = append(list, m)
+		}
+		sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+		title = collection.Name
+	}
+
+	shared := make([]sharedModel, len(list))
+	for i, m := range list {
+		files, err := h.store.Files().AllByModel(ctx, m.ID)
+		if err != nil {
+			return "", nil, err
+		}
+		shared[i] = sharedModel{Model: m, Files: files}
+	}
+	return title, shared, nil
+}
+
+func (h *ShareHandler) collectionModels(r *http.Request, c *models.Collection, access *auth.Access) ([]int64, error) {
+	filter, err := collectionFilter(c)
+	if err != nil {
+		return nil, err
+	}
+	filter.LibraryIDs = access.Libraries()
+	return h.store.Models().IDs(r.Context(), filter)
+}
+
+// Page serves the read-only page of a share link, or its password form.
+// POSTing the form checks the password.
+func (h *ShareHandler) Page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	link, access, err := h.resolve(r)
+	if err != nil {
+		h.renderPage(w, toAPIError(err).Status, sharePage{Title: "Shared files", Error: toAPIError(err).Message})
+		return
+	}
+	page := sharePage{Title: "Shared files", Base: "/s/" + chi.URLParam(r, "token")}
+
+	if r.Method == http.MethodPost && link.PasswordHash != "" {
+		if auth.CheckPassword(link.PasswordHash, r.PostFormValue("password")) != nil {
+			page.Locked, page.Error = true, "wrong password"
+			h.renderPage(w, 401, page)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     shareCookie,
+			Value:    unlockKey(link),
+			Path:     page.Base,
+			HttpOnly: true,
+			Secure:   strings.HasPrefix(h.publicURL, "https://"),
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, page.Base, http.StatusSeeOther)
+		return
+	}
+	if !unlocked(r, link) {
+		page.Locked = true
+		h.renderPage(w, 200, page)
+		return
+	}
+
+	title, shared, err := h.contents(r, link, access)
+	if err != nil {
+		apiErr := toAPIError(err)
+		if apiErr.Status == 500 {
+			writeError(w, err)
+			return
+		}
+		h.renderPage(w, apiErr.Status, sharePage{Title: "Shared files", Error: "this share link no longer works"})
+		return
+	}
+	page.Title, page.Models, page.Expires = title, shared, link.ExpiresAt
+	if link.MaxDownloads != nil {
+		left := *link.MaxDownloads - link.Downloads
+		page.DownloadsLeft = &left
+	}
+	h.renderPage(w, 200, page)
+}
+
+// Download serves one of the shared files through FileHandler.Serve, with
+// the link creator's access. Each download counts against the link's
+// limit; requests resuming a download part way through do not.
+func (h *ShareHandler) Download(w http.ResponseWriter, r *http.Request) {
+	link, access, err := h.resolve(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !unlocked(r, link) {
+		writeError(w, unauthorized("this share link needs a password"))
+		return
+	}
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	file, err := h.store.Files().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "file")
+		return
+	}
+	if link.ModelID != nil {
+		if file.ModelID != *link.ModelID {
+			writeError(w, notFound("file"))
+			return
+		}
+	} else {
+		collection, err := h.store.Collections().Get(r.Context(), *link.CollectionID)
+		if err != nil {
+			writeLookupError(w, err, "file")
+			return
+		}
+		ids, err := h.collectionModels(r, collection, access)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !slices.Contains(ids, file.ModelID) {
+			writeError(w, notFound("file"))
+			return
+		}
+	}
+
+	rng := r.Header.Get("Range")
+	if r.Method == http.MethodGet && (rng == "" || strings.HasPrefix(rng, "bytes=0-")) {
+		err := h.store.Shares().CountDownload(r.Context(), link.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, &APIError{Status: 410, Code: "download_limit", Message: "this share link has no downloads left"})
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	h.files.Serve(w, r.WithContext(auth.WithAccess(r.Context(), access)))
+}
+
+type sharePage struct {
+	Title         string
+	Error         string
+	Base          string
+	Locked        bool
+	Models        []sharedModel
+	Expires       *time.Time
+	DownloadsLeft *int
+}
+
+func (h *ShareHandler) renderPage(w http.ResponseWriter, status int, page sharePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	sharePageTemplate.Execute(w, page)
+}
+
+var sharePageTemplate = template.Must(template.New("share").Funcs(template.FuncMap{
+	"size": func(n int64) string {
+		const unit = 1024
+		if n < unit {
+			return fmt.Sprintf("%d B", n)
+		}
+		div, exp := int64(unit), 0
+		for m := n / unit; m >= unit; m /= unit {
+			div *= unit
+			exp++
+		}
+		return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+	},
+	"date": func(t *time.Time) string { return t.UTC().Format("2 Jan 2006 15:04 MST") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}} · Go3D</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #111827; color: #e5e7eb; margin: 0; }
+main { max-width: 720px; margin: 40px auto; padding: 0 20px; }
+a { color: #60a5fa; }
+section { background: #1f2937; border-radius: 8px; padding: 12px 20px; margin: 16px 0; }
+ul { padding-left: 20px; }
+small, .note { color: #9ca3af; }
+.error { color: #f87171; }
+input, button { font: inherit; padding: 6px 10px; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+{{if .Locked}}
+<form method="post" action="{{.Base}}">
+<p><label>Password <input type="password" name="password" autocomplete="off" autofocus required></label>
+<button>Open</button></p>
+</form>
+{{else if .Models}}
+<p class="note">{{with .Expires}}Available until {{date .}}. {{end}}{{with .DownloadsLeft}}{{.}} downloads left.{{end}}</p>
+{{range .Models}}
+<section>
+<h2>{{.Name}}</h2>
+{{with .Description}}<p>{{.}}</p>{{end}}
+<ul>
+{{range .Files}}<li><a href="{{$.Base}}/files/{{.ID}}">{{.Filename}}</a> <small>{{size .Size}}</small></li>
+{{end}}</ul>
+</section>
+{{end}}
+{{else if not .Error}}
+<p class="note">Nothing is shared here at the moment.</p>
+{{end}}
+</main>
+</body>
+</html>
+`))
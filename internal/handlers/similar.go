@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/database"
+	"3d-library/internal/jobs"
+	"3d-library/internal/models"
+	"3d-library/internal/similarity"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxSimilarCandidates = 5000
+	ratioWindow          = 0.15
+)
+
+// Weights for combining the three signals. When a model has no shape
+// descriptor the remaining weights are rescaled.
+const (
+	shapeWeight = 0.5
+	tokenWeight = 0.3
+	tagWeight   = 0.2
+)
+
+type SimilarModel struct {
+	Model models.Model `json:"model"`
+	Score float64      `json:"score"`
+	Shape *float64     `json:"shape"`
+	Name  float64      `json:"name"`
+	Tags  float64      `json:"tags"`
+}
+
+type similarCandidate struct {
+	ID          int64    `db:"id"`
+	Name        string   `db:"name"`
+	Path        string   `db:"path"`
+	LibraryPath string   `db:"library_path"`
+	Histogram   *string  `db:"histogram"`
+	RatioMid    *float64 `db:"ratio_mid"`
+	RatioMin    *float64 `db:"ratio_min"`
+}
+
+func (c similarCandidate) descriptor() (similarity.Descriptor, bool) {
+	if c.Histogram == nil || c.RatioMid == nil || c.RatioMin == nil {
+		return similarity.Descriptor{}, false
+	}
+	var d similarity.Descriptor
+	if err := json.Unmarshal([]byte(*c.Histogram), &d.Histogram); err != nil {
+		return d, false
+	}
+	d.Ratios = [2]float64{*c.RatioMid, *c.RatioMin}
+	return d, true
+}
+
+func (c similarCandidate) tokens() []string {
+	rel := strings.TrimPrefix(c.Path, c.LibraryPath)
+	return similarity.Tokens(c.Name, rel)
+}
+
+const similarCandidateColumns = `
+	SELECT m.id, m.name, m.path, l.path AS library_path, d.histogram, d.ratio_mid, d.ratio_min
+	FROM models m
+	JOIN libraries l ON l.id = m.library_id
+	LEFT JOIN model_descriptors d ON d.model_id = m.id`
+
+// Similar ranks other models by shape, name/path tokens and shared tags.
+// Candidates are narrowed in SQL to models with similar proportions, a
+// shared tag or a shared name token before scoring.
+func (h *ModelHandler) Similar(w http.ResponseWriter, r *http.Request) {
+	model, err := loadModel(r, h.store, "id", auth.Viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id := model.ID
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	var target similarCandidate
+	if err := h.db.Get(&target, similarCandidateColumns+" WHERE m.id = $1", id); err != nil {
+		writeLookupError(w, err, "model")
+		return
+	}
+	targetDesc, hasShape := target.descriptor()
+	if !hasShape {
+		if err := jobs.UpdateDescriptor(h.db, id); err != nil {
+			log.Printf("Descriptor for model %d: %v", id, err)
+		}
+		h.db.Get(&target, similarCandidateColumns+" WHERE m.id = $1", id)
+		targetDesc, hasShape = target.descriptor()
+	}
+	targetTokens := target.tokens()
+
+	conds := []string{"EXISTS (SELECT 1 FROM model_tags mt WHERE mt.model_id = m.id AND mt.tag_id IN (SELECT tag_id FROM model_tags WHERE model_id = $1))"}
+	args := []interface{}{id}
+	if hasShape {
+		args = append(args,
+			targetDesc.Ratios[0]-ratioWindow, targetDesc.Ratios[0]+ratioWindow,
+			targetDesc.Ratios[1]-ratioWindow, targetDesc.Ratios[1]+ratioWindow)
+		conds = append(conds, "(d.ratio_mid BETWEEN $2 AND $3 AND d.ratio_min BETWEEN $4 AND $5)")
+	}
+	for i, tok := range targetTokens {
+		if i == 8 {
+			break
+		}
+		args = append(args, "%"+tok+"%")
+		p := fmt.Sprintf("$%d", len(args))
+		conds = append(conds, database.ILike(h.db, "m.name", p)+" OR "+database.ILike(h.db, "m.path", p))
+	}
+
+	where := "m.id <> $1 AND m.deleted_at IS NULL AND (" + strings.Join(conds, " OR ") + ")"
+	if libraries := visibleLibraries(r); libraries != nil {
+		where += " AND m.library_id IN (0"
+		for _, lib := range libraries {
+			args = append(args, lib)
+			where += fmt.Sprintf(", $%d", len(args))
+		}
+		where += ")"
+	}
+
+	var candidates []similarCandidate
+	err = h.db.Select(&candidates, similarCandidateColumns+`
+		WHERE `+where+`
+		LIMIT `+strconv.Itoa(maxSimilarCandidates), args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tags, err := h.store.Tags().IDsForModels(r.Context(), append([]int64{id}, candidateIDs(candidates)...))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	results := make([]SimilarModel, 0, len(candidates))
+	for _, c := range candidates {
+		res := SimilarModel{
+			Name: similarity.Jaccard(targetTokens, c.tokens()),
+			Tags: similarity.Jaccard(tags[id], tags[c.ID]),
+		}
+		weight := tokenWeight + tagWeight
+		res.Score = tokenWeight*res.Name + tagWeight*res.Tags
+		if desc, ok := c.descriptor(); ok && hasShape {
+			shape := similarity.ShapeScore(targetDesc, desc)
+			res.Shape = &shape
+			res.Score += shapeWeight * shape
+			weight += shapeWeight
+		}
+		res.Score /= weight
+		res.Model.ID = c.ID
+		results = append(results, res)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if err := h.fillModels(r, results); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, results)
+}
+
+func candidateIDs(candidates []similarCandidate) []int64 {
+	ids := make([]int64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func (h *ModelHandler) fillModels(r *http.Request, results []SimilarModel) error {
+	ids := make([]int64, len(results))
+	for i, res := range results {
+		ids[i] = res.Model.ID
+	}
+	byID, err := h.store.Models().GetMany(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Model = byID[results[i].Model.ID]
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/oidc"
+	"3d-library/internal/store"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// flowCookie carries an OIDC login from OIDCLogin to OIDCCallback.
+const flowCookie = "go3d_oidc"
+
+type loginFlow struct {
+	oidc.Flow
+	Return string `json:"return"`
+}
+
+// Methods tells the web UI which ways of signing in are on.
+func (h *AuthHandler) Methods(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]bool{
+		"password": true,
+		"oidc":     h.opts.OIDC != nil,
+		"proxy":    len(h.opts.ProxyNetworks) > 0,
+	})
+}
+
+// OIDCLogin sends the browser to the identity provider. The state, nonce
+// and PKCE verifier wait in a short-lived cookie for the callback.
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.opts.OIDC == nil {
+		writeError(w, &APIError{Status: 404, Code: "not_found", Message: "single sign-on is not configured"})
+		return
+	}
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	target, err := h.opts.OIDC.AuthURL(r.Context(), flow)
+	if err != nil {
+		writeError(w, &APIError{Status: 502, Code: "provider_error", Message: err.Error()})
+		return
+	}
+	value, _ := json.Marshal(loginFlow{Flow: *flow, Return: safeReturn(r.URL.Query().Get("return"))})
+	http.SetCookie(w, h.flowCookie(base64.RawURLEncoding.EncodeToString(value)))
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// OIDCCallback finishes a login: it checks the provider's answer, finds or
+// creates the user, applies their groups and starts a session. Failures go
+// back to the web UI as ?auth_error= since there is a browser, not an API
+// client, on the other end.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.opts.OIDC == nil {
+		writeError(w, &APIError{Status: 404, Code: "not_found", Message: "single sign-on is not configured"})
+		return
+	}
+	var flow loginFlow
+	cookie, err := r.Cookie(flowCookie)
+	if err == nil {
+		var value []byte
+		value, err = base64.RawURLEncoding.DecodeString(cookie.Value)
+		if err == nil {
+			err = json.Unmarshal(value, &flow)
+		}
+	}
+	http.SetCookie(w, h.flowCookie(""))
+	fail := func(message string) {
+		http.Redirect(w, r, "/?auth_error="+url.QueryEscape(message), http.StatusFound)
+	}
+	if err != nil {
+		fail("sign-in expired; please try again")
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		if d := q.Get("error_description"); d != "" {
+			e += ": " + d
+		}
+		fail("identity provider refused sign-in: " + e)
+		return
+	}
+	claims, err := h.opts.OIDC.Exchange(r.Context(), &flow.Flow, q.Get("state"), q.Get("code"))
+	if err != nil {
+		log.Printf("OIDC sign-in: %v", err)
+		fail("sign-in failed: " + err.Error())
+		return
+	}
+
+	user, err := h.oidcUser(r, claims)
+	if err != nil {
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			log.Printf("OIDC sign-in: %v", err)
+			err = errors.New("could not sign in; see the server log")
+		}
+		fail(err.Error())
+		return
+	}
+	if err := h.syncGroups(r, user, claims.Strings(h.opts.GroupsClaim)); err != nil {
+		log.Printf("OIDC sign-in: groups of %q: %v", user.Username, err)
+		fail("could not apply your groups; see the server log")
+		return
+	}
+	if err := h.startSession(w, r, user.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Users().RecordLogin(r.Context(), user.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, safeReturn(flow.Return), http.StatusFound)
+}
+
+// oidcUser finds the user the provider vouched for by issuer and subject,
+// creating them on their first sign-in.
+func (h *AuthHandler) oidcUser(r *http.Request, claims oidc.Claims) (*models.User, error) {
+	ctx := r.Context()
+	externalID := strings.TrimSuffix(claims.String("iss"), "/") + "#" + claims.String("sub")
+	user, err := h.store.Users().GetByExternalID(ctx, externalID)
+	if err == nil {
+		if user.Disabled {
+			return nil, forbidden("user is disabled")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	var username string
+	for _, claim := range []string{h.opts.UsernameClaim, "preferred_username", "email", "sub"} {
+		if name, err := validUsername(claims.String(claim)); err == nil {
+			username = name
+			break
+		}
+	}
+	if username == "" {
+		return nil, forbidden("the identity provider sent no usable username")
+	}
+
+	user, err = h.store.Users().GetByName(ctx, username)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		user = &models.User{Username: username, ExternalID: &externalID}
+		if err := h.store.Users().Create(ctx, user); err != nil {
+			return nil, err
+		}
+		record(asUser(r, user), h.store, audit.Create, "user", user.ID, nil, user)
+		return user, nil
+	case err != nil:
+		return nil, err
+	case !h.opts.LinkExisting || user.ExternalID != nil:
+		return nil, conflict("a user named %q already exists; ask an administrator to link it", username)
+	case user.Disabled:
+		return nil, forbidden("user is disabled")
+	}
+	before := *user
+	user.ExternalID = &externalID
+	if err := h.store.Users().Update(ctx, user); err != nil {
+		return nil, err
+	}
+	record(asUser(r, user), h.store, audit.Update, "user", user.ID, before, user)
+	return user, nil
+}
+
+func (h *AuthHandler) flowCookie(value string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     flowCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		HttpOnly: true,
+		Secure:   h.opts.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		c.MaxAge = -1
+	} else {
+		c.MaxAge = int((10 * time.Minute).Seconds())
+	}
+	return c
+}
+
+// safeReturn keeps post-login redirects on this server.
+func safeReturn(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// proxyUsername returns the user a trusted reverse proxy names in its
+// header, or "" when the request did not come from one.
+func (h *AuthHandler) proxyUsername(r *http.Request) string {
+	if ip := remoteIP(r); ip == nil || !h.trustedProxy(ip) {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(h.opts.ProxyHeader))
+}
+
+func (h *AuthHandler) trustedProxy(ip net.IP) bool {
+	for _, n := range h.opts.ProxyNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP is the address a request came from. Behind trusted proxies it is
+// the last X-Forwarded-For hop that is not one of them, since clients can
+// put anything at the front of that header.
+func (h *AuthHandler) ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if ip == nil {
+		return r.RemoteAddr
+	}
+	if h.trustedProxy(ip) {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			ip = hop
+			if !h.trustedProxy(hop) {
+				break
+			}
+		}
+	}
+	return ip.String()
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// proxyUser loads the user named by the proxy, creating them the first
+// time they are seen, and applies their groups.
+func (h *AuthHandler) proxyUser(w http.ResponseWriter, r *http.Request, name string) (*models.User, bool) {
+	ctx := r.Context()
+	user, err := h.store.Users().GetByName(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		username, verr := validUsername(name)
+		if verr != nil {
+			writeUnauthorized(w, unauthorized(fmt.Sprintf("proxy user name %v", verr)))
+			return nil, false
+		}
+		user = &models.User{Username: username}
+		err = h.store.Users().Create(ctx, user)
+		if err == nil {
+			record(asUser(r, user), h.store, audit.Create, "user", user.ID, nil, user)
+		} else if toAPIError(err).Code == "already_exists" {
+			// Another request for the same new user got there first.
+			user, err = h.store.Users().GetByName(ctx, username)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	if user.Disabled {
+		writeUnauthorized(w, unauthorized("user is disabled"))
+		return nil, false
+	}
+
+	var groups []string
+	for _, g := range strings.Split(r.Header.Get(h.opts.ProxyGroupsHeader), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	if err := h.syncGroups(r, user, groups); err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// syncGroups applies the groups an identity provider or proxy sent for the
+// user, recording what changed as changes the user made by signing in.
+func (h *AuthHandler) syncGroups(r *http.Request, user *models.User, groups []string) error {
+	if h.opts.Groups == nil {
+		return nil
+	}
+	before := *user
+	changes, err := h.opts.Groups.Sync(r.Context(), h.store, user, groups)
+	if err != nil {
+		return err
+	}
+	r = asUser(r, user)
+	if user.Admin != before.Admin {
+		record(r, h.store, audit.Update, "user", user.ID, before, user)
+	}
+	for _, c := range changes {
+		action := audit.Add
+		if c.After == nil {
+			action = audit.Remove
+		}
+		record(r, h.store, action, "library", c.LibraryID, c.Before, c.After)
+	}
+	return nil
+}
+
+// asUser is r acting as user, for changes made before the request has one.
+func asUser(r *http.Request, user *models.User) *http.Request {
+	return r.WithContext(auth.WithUser(r.Context(), user))
+}
@@ -0,0 +1,261 @@
+package handlers_test
+
+import (
+	"3d-library/internal/config"
+	"3d-library/internal/models"
+	"3d-library/internal/oidc"
+	"3d-library/internal/server"
+	"3d-library/internal/store/memstore"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// ssoTest runs the server against oidc.Mock. Members of "admins" become
+// site administrators and members of "printers" editors of library Minis.
+type ssoTest struct {
+	t       *testing.T
+	api     *httptest.Server
+	st      *memstore.Store
+	library models.Library
+	client  *http.Client
+}
+
+func newSSOTest(t *testing.T) *ssoTest {
+	t.Helper()
+	mock := &oidc.Mock{ClientID: "go3d", ClientSecret: "secret"}
+	idp := httptest.NewServer(mock)
+	t.Cleanup(idp.Close)
+	mock.Issuer = idp.URL
+
+	st := memstore.New()
+	library := models.Library{Name: "Minis", Path: t.TempDir(), Storage: "local"}
+	if err := st.Libraries().Create(context.Background(), &library); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ssoTest{t: t, st: st, library: library}
+	s.api = httptest.NewUnstartedServer(nil)
+	cfg := config.Default()
+	cfg.Log.Requests = false
+	cfg.Server.PublicURL = "http://" + s.api.Listener.Addr().String()
+	cfg.Auth.OIDC.Issuer = idp.URL
+	cfg.Auth.OIDC.ClientID = mock.ClientID
+	cfg.Auth.OIDC.ClientSecret = mock.ClientSecret
+	cfg.Auth.OIDC.RedirectURL = cfg.Server.PublicURL + "/api/auth/oidc/callback"
+	cfg.Auth.AdminGroups = []string{"admins"}
+	cfg.Auth.GroupRoles = []string{"printers=Minis:editor"}
+	s.api.Config.Handler = server.NewRouter(cfg, st, nil, nil, nil)
+	s.api.Start()
+	t.Cleanup(s.api.Close)
+
+	s.client = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	return s
+}
+
+// redirect requests rawURL with cookies and expects a redirect, returning
+// its target and the cookies set.
+func (s *ssoTest) redirect(method, rawURL string, form url.Values, cookies []*http.Cookie) (*url.URL, []*http.Cookie) {
+	s.t.Helper()
+	req, err := http.NewRequest(method, rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		s.t.Fatalf("%s %s: status %d, want 302", method, rawURL, resp.StatusCode)
+	}
+	// Kept as sent, so that redirects back to this server stay relative.
+	target, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return target, resp.Cookies()
+}
+
+// start begins a login and signs in at the provider as username with
+// groups. It returns the provider's redirect to the callback and the flow
+// cookie.
+func (s *ssoTest) start(returnTo, username, groups string) (*url.URL, *http.Cookie) {
+	s.t.Helper()
+	authorize, cookies := s.redirect("GET", s.api.URL+"/api/auth/oidc/login?return="+url.QueryEscape(returnTo), nil, nil)
+	if len(cookies) != 1 {
+		s.t.Fatalf("login set %d cookies, want the flow cookie", len(cookies))
+	}
+	callback, _ := s.redirect("POST", authorize.String(), url.Values{"username": {username}, "groups": {groups}}, nil)
+	return callback, cookies[0]
+}
+
+// finish completes the login at callback and returns where the browser is
+// sent and the cookies set.
+func (s *ssoTest) finish(callback *url.URL, flow *http.Cookie) (*url.URL, []*http.Cookie) {
+	s.t.Helper()
+	return s.redirect("GET", callback.String(), nil, []*http.Cookie{flow})
+}
+
+func (s *ssoTest) login(returnTo, username, groups string) (*url.URL, []*http.Cookie) {
+	s.t.Helper()
+	return s.finish(s.start(returnTo, username, groups))
+}
+
+// me returns the user the cookies sign in as.
+func (s *ssoTest) me(cookies []*http.Cookie) *models.User {
+	s.t.Helper()
+	req, _ := http.NewRequest("GET", s.api.URL+"/api/auth/me", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		s.t.Fatalf("me: status %d", resp.StatusCode)
+	}
+	var user models.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		s.t.Fatal(err)
+	}
+	return &user
+}
+
+// editFlow rewrites a field of the flow in the cookie, as a tampered or
+// mixed-up browser would send it.
+func editFlow(t *testing.T, c *http.Cookie, field, value string) *http.Cookie {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flow map[string]interface{}
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := flow[field]; !ok {
+		t.Fatalf("flow cookie has no %q: %s", field, raw)
+	}
+	flow[field] = value
+	raw, _ = json.Marshal(flow)
+	edited := *c
+	edited.Value = base64.RawURLEncoding.EncodeToString(raw)
+	return &edited
+}
+
+// authError returns the error the callback sent the browser back with.
+func authError(t *testing.T, target *url.URL) string {
+	t.Helper()
+	if target.Path != "/" || target.Query().Get("auth_error") == "" {
+		t.Fatalf("redirected to %s, want /?auth_error=", target)
+	}
+	return target.Query().Get("auth_error")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	s := newSSOTest(t)
+
+	target, cookies := s.login("/models/7", "dana", "admins,printers")
+	if target.String() != "/models/7" {
+		t.Errorf("returned to %s, want /models/7", target)
+	}
+	user := s.me(cookies)
+	if user.Username != "dana" || !user.Admin {
+		t.Fatalf("signed in as %+v, want admin dana", user)
+	}
+	if user.ExternalID == nil || !strings.HasSuffix(*user.ExternalID, "#mock-dana") {
+		t.Errorf("external ID %v", user.ExternalID)
+	}
+	roles, err := s.st.Members().ForUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 || roles[0].LibraryID != s.library.ID || roles[0].Role != "editor" {
+		t.Fatalf("roles %+v, want editor of Minis", roles)
+	}
+
+	// Leaving the groups takes the rights away on the next sign-in.
+	_, cookies = s.login("/", "dana", "")
+	if again := s.me(cookies); again.ID != user.ID || again.Admin {
+		t.Errorf("second sign-in as %+v, want the same user without admin", again)
+	}
+	roles, err = s.st.Members().ForUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 0 {
+		t.Errorf("roles %+v, want none", roles)
+	}
+}
+
+func TestOIDCReturnStaysOnServer(t *testing.T) {
+	s := newSSOTest(t)
+	for _, returnTo := range []string{"//evil.example/x", "/\\evil.example", "https://evil.example/", "models"} {
+		target, _ := s.login(returnTo, "erin", "")
+		if target.String() != "/" {
+			t.Errorf("return %q redirected to %s, want /", returnTo, target)
+		}
+	}
+}
+
+func TestOIDCCallbackRefused(t *testing.T) {
+	s := newSSOTest(t)
+
+	t.Run("state mismatch", func(t *testing.T) {
+		callback, flow := s.start("/", "frank", "")
+		q := callback.Query()
+		q.Set("state", "forged")
+		callback.RawQuery = q.Encode()
+		target, cookies := s.finish(callback, flow)
+		if msg := authError(t, target); !strings.Contains(msg, "state") {
+			t.Errorf("error %q does not mention the state", msg)
+		}
+		for _, c := range cookies {
+			if c.Name != "go3d_oidc" {
+				t.Errorf("set cookie %s", c.Name)
+			}
+		}
+	})
+
+	t.Run("PKCE verifier", func(t *testing.T) {
+		callback, flow := s.start("/", "frank", "")
+		target, _ := s.finish(callback, editFlow(t, flow, "verifier", "not-the-verifier"))
+		if msg := authError(t, target); !strings.Contains(msg, "code_verifier") {
+			t.Errorf("error %q does not mention the verifier", msg)
+		}
+	})
+
+	t.Run("nonce", func(t *testing.T) {
+		callback, flow := s.start("/", "frank", "")
+		target, _ := s.finish(callback, editFlow(t, flow, "nonce", "not-the-nonce"))
+		if msg := authError(t, target); !strings.Contains(msg, "nonce") {
+			t.Errorf("error %q does not mention the nonce", msg)
+		}
+	})
+
+	t.Run("no flow cookie", func(t *testing.T) {
+		callback, _ := s.start("/", "frank", "")
+		target, _ := s.redirect("GET", callback.String(), nil, nil)
+		authError(t, target)
+	})
+
+	if _, err := s.st.Users().GetByName(context.Background(), "frank"); err == nil {
+		t.Error("a refused sign-in created the user")
+	}
+}
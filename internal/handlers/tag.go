@@ -1,78 +1,204 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
 	"3d-library/internal/models"
-	"encoding/json"
+	"3d-library/internal/store"
+	"errors"
 	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 )
 
 type TagHandler struct {
-	db *sqlx.DB
+	store store.Store
 }
 
-func NewTagHandler(db *sqlx.DB) *TagHandler {
-	return &TagHandler{db: db}
+func NewTagHandler(st store.Store) *TagHandler {
+	return &TagHandler{store: st}
 }
 
 func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
-	var tags []models.Tag
-	err := h.db.Select(&tags, "SELECT * FROM tags ORDER BY name")
+	p, err := parseListQuery(r, store.TagSorts, "name", false)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tags, err := h.store.Tags().List(r.Context(), r.URL.Query().Get("q"), p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, pageOf(tags))
+}
+
+func (h *TagHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tag, err := h.store.Tags().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "tag")
+		return
+	}
+	setETag(w, tag.UpdatedAt)
+	writeJSON(w, 200, tag)
+}
+
+// Update renames a tag everywhere, so only site administrators may.
+func (h *TagHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := requireSiteAdmin(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	var req struct {
+		Name *string `json:"name"`
+	}
+	if err := decodeStrict(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tag, err := h.store.Tags().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "tag")
+		return
+	}
+	if !ifMatch(r, tag.UpdatedAt) {
+		writeError(w, preconditionFailed("tag"))
+		return
+	}
+	before := *tag
+
+	v := &validation{}
+	changed := false
+	if req.Name != nil {
+		name, err := validName(req.Name)
+		v.add("name", err)
+		tag.Name, changed = name, true
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if changed {
+		if err := h.store.Tags().Update(r.Context(), tag); err != nil {
+			writeUpdateError(w, err, "tag")
+			return
+		}
+		record(r, h.store, audit.Update, "tag", tag.ID, before, tag)
+	}
+	setETag(w, tag.UpdatedAt)
+	writeJSON(w, 200, tag)
+}
+
+// Delete removes a tag from every model, so only site administrators may.
+func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := requireSiteAdmin(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	tag, err := h.store.Tags().Get(r.Context(), id)
+	if err == nil {
+		err = h.store.Tags().Delete(r.Context(), id)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if err == nil {
+		record(r, h.store, audit.Delete, "tag", id, tag, nil)
+	}
+	w.WriteHeader(204)
+}
+
+func (h *TagHandler) RemoveFromModel(w http.ResponseWriter, r *http.Request) {
+	tagID, err := idParam(r, "tagID")
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(tags)
+	model, err := loadModel(r, h.store, "id", auth.Contributor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tag, err := h.store.Tags().Get(r.Context(), tagID)
+	if errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(204)
+		return
+	}
+	if err == nil {
+		err = h.store.Tags().Detach(r.Context(), model.ID, tagID)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Untag, "model", model.ID, tag, nil)
+	w.WriteHeader(204)
 }
 
 func (h *TagHandler) AddToModel(w http.ResponseWriter, r *http.Request) {
-	modelID := chi.URLParam(r, "id")
 	var req struct {
 		Tag string `json:"tag"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), 400)
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
-
-	// Get or create tag
-	var tagID int64
-	err := h.db.QueryRow(
-		"INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id",
-		req.Tag,
-	).Scan(&tagID)
+	model, err := loadModel(r, h.store, "id", auth.Contributor)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
+		return
+	}
+	v := &validation{}
+	name, err := validName(&req.Tag)
+	v.add("tag", err)
+	if err := v.err(); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	// Link to model
-	_, err = h.db.Exec(
-		"INSERT INTO model_tags (model_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-		modelID, tagID,
-	)
+	var tag *models.Tag
+	err = h.store.InTx(r.Context(), func(tx store.Store) error {
+		tag, err = tx.Tags().Ensure(r.Context(), name)
+		if err != nil {
+			return err
+		}
+		return tx.Tags().Attach(r.Context(), model.ID, tag.ID)
+	})
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
+	record(r, h.store, audit.Tag, "model", model.ID, nil, tag)
 
 	w.WriteHeader(204)
 }
 
 func (h *TagHandler) GetModelTags(w http.ResponseWriter, r *http.Request) {
-	modelID := chi.URLParam(r, "id")
-	var tags []models.Tag
-	err := h.db.Select(&tags, `
-		SELECT t.* FROM tags t
-		JOIN model_tags mt ON t.id = mt.tag_id
-		WHERE mt.model_id = $1
-		ORDER BY t.name
-	`, modelID)
+	model, err := loadModel(r, h.store, "id", auth.Viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tags, err := h.store.Tags().ForModel(r.Context(), model.ID)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(tags)
+	writeJSON(w, 200, tags)
 }
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"net/http"
+)
+
+type TrashHandler struct {
+	store store.Store
+}
+
+func NewTrashHandler(st store.Store) *TrashHandler {
+	return &TrashHandler{store: st}
+}
+
+// List returns what the user could restore: libraries they administer, and
+// models and files in libraries where they are editors. Each goes until
+// restored or purged when the retention period ends.
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store.Trash().List(r.Context(), store.TrashFilter{LibraryIDs: visibleLibraries(r)})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	a := auth.AccessFrom(r.Context())
+	modelIDs := make([]int64, len(items.Files))
+	for i, f := range items.Files {
+		modelIDs[i] = f.ModelID
+	}
+	// Files in the trash belong to models outside it.
+	owners, err := h.store.Models().GetMany(r.Context(), modelIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	shown := &store.TrashItems{Libraries: []models.Library{}, Models: []models.Model{}, Files: []models.ModelFile{}}
+	for _, l := range items.Libraries {
+		if a.Can(l.ID, auth.Admin) {
+			shown.Libraries = append(shown.Libraries, l)
+		}
+	}
+	for _, m := range items.Models {
+		if a.Can(m.LibraryID, auth.Editor) {
+			shown.Models = append(shown.Models, m)
+		}
+	}
+	for _, f := range items.Files {
+		if m, ok := owners[f.ModelID]; ok && a.Can(m.LibraryID, auth.Editor) {
+			shown.Files = append(shown.Files, f)
+		}
+	}
+	writeJSON(w, 200, shown)
+}
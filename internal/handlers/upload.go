@@ -1,212 +1,466 @@
 package handlers
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/jobs"
+	"3d-library/internal/models"
+	"3d-library/internal/safepath"
+	"3d-library/internal/scanner"
+	"3d-library/internal/store"
 	"archive/zip"
+	"compress/flate"
+	"context"
 	"crypto/sha256"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 )
 
 type UploadHandler struct {
-	db *sqlx.DB
+	store  store.Store
+	db     *sqlx.DB
+	limits UploadLimits
 }
 
-func NewUploadHandler(db *sqlx.DB) *UploadHandler {
-	return &UploadHandler{db: db}
+// UploadLimits caps what one upload may send and write. A zero UserQuota
+// is no quota.
+type UploadLimits struct {
+	// MaxBytes caps the request body and MaxExtract what is written, with
+	// ZIP archives unpacked.
+	MaxBytes   int64
+	MaxExtract int64
+	// MaxFiles counts ZIP entries rather than the archives.
+	MaxFiles int
+	// MaxRatio caps how many times its compressed size a ZIP entry may
+	// unpack to, and MaxFolderDepth how many folders deep it may be.
+	MaxRatio       int64
+	MaxFolderDepth int
+	UserQuota      int64
 }
 
+func NewUploadHandler(st store.Store, db *sqlx.DB, limits UploadLimits) *UploadHandler {
+	return &UploadHandler{store: st, db: db, limits: limits}
+}
+
+// Upload writes files into a model folder, creating the model if needed.
+// The files are unpacked into the library's upload folder first and only
+// moved into place once every limit has held; if recording them fails or
+// leaves the library or the user over quota, they are moved back out and
+// any file they replaced is put back.
 func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
-	libraryID := chi.URLParam(r, "id")
-	
-	var library struct {
-		ID   int64  `db:"id"`
-		Path string `db:"path"`
-	}
-	err := h.db.Get(&library, "SELECT id, path FROM libraries WHERE id = $1", libraryID)
+	libraryID, err := idParam(r, "id")
 	if err != nil {
-		http.Error(w, "Library not found", 404)
+		writeError(w, err)
+		return
+	}
+	if err := checkLibrary(r, libraryID, auth.Contributor, "library"); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	err = r.ParseMultipartForm(100 << 20)
+	library, err := h.store.Libraries().Get(r.Context(), libraryID)
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeLookupError(w, err, "library")
 		return
 	}
 
-	modelName := r.FormValue("model_name")
-	if modelName == "" {
-		http.Error(w, "model_name required", 400)
+	if err := parseMultipart(w, r, h.limits.MaxBytes); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	modelPath := filepath.Join(library.Path, modelName)
-	os.MkdirAll(modelPath, 0755)
-
 	files := r.MultipartForm.File["files[]"]
 	if len(files) == 0 {
 		files = r.MultipartForm.File["files"]
 	}
-	if len(files) == 0 {
-		http.Error(w, "No files uploaded", 400)
-		return
-	}
 
-	var modelID int64
-	err = h.db.QueryRow(
-		"INSERT INTO models (library_id, name, path) VALUES ($1, $2, $3) ON CONFLICT (library_id, path) DO UPDATE SET name = $2 RETURNING id",
-		library.ID, modelName, modelPath,
-	).Scan(&modelID)
-	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
+	v := &validation{}
+	modelName := r.FormValue("model_name")
+	modelName, err = validName(&modelName)
+	if err == nil {
+		modelName, err = safepath.Name(modelName)
 	}
+	v.add("model_name", err)
+	v.check(len(files) > 0, "files", "at least one file is required")
 
-	uploaded := []string{}
+	// Every name, and the sizes the archives claim, are checked before
+	// anything is written.
+	parts := make([]uploadPart, 0, len(files))
+	count, size := 0, int64(0)
 	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
 			continue
 		}
 		defer file.Close()
-
+		part := uploadPart{file: file, name: fileHeader.Filename}
 		if strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".zip") {
-			tmpZip := filepath.Join(os.TempDir(), fileHeader.Filename)
-			tmpFile, err := os.Create(tmpZip)
+			part.zip, err = zip.NewReader(file, fileHeader.Size)
 			if err != nil {
-				log.Printf("Error creating temp file: %v", err)
+				v.add("files", fmt.Errorf("%q is not a valid ZIP archive", fileHeader.Filename))
 				continue
 			}
-			io.Copy(tmpFile, file)
-			tmpFile.Close()
-
-			extracted, err := h.extractZip(tmpZip, modelPath, modelID)
+			for _, f := range part.zip.File {
+				if f.FileInfo().IsDir() || hiddenEntry(f.Name) {
+					continue
+				}
+				name, err := safepath.Clean(f.Name)
+				if err != nil {
+					v.add("files", fmt.Errorf("%s: entry %q: %v", fileHeader.Filename, f.Name, err))
+					continue
+				}
+				// Archives inside an archive are refused rather than stored,
+				// so nothing the server writes can be unpacked again.
+				if strings.HasSuffix(strings.ToLower(name), ".zip") {
+					v.add("files", fmt.Errorf("%s: entry %q is a ZIP archive; archives inside archives are not accepted", fileHeader.Filename, f.Name))
+				}
+				if strings.Count(name, string(filepath.Separator)) > h.limits.MaxFolderDepth {
+					v.add("files", fmt.Errorf("%s: entry %q is more than %d folders deep", fileHeader.Filename, f.Name, h.limits.MaxFolderDepth))
+				}
+				if f.UncompressedSize64 > f.CompressedSize64*uint64(h.limits.MaxRatio) && f.UncompressedSize64 > minRatioBytes {
+					writeError(w, h.ratioError(fileHeader.Filename, f.Name))
+					return
+				}
+				count++
+				size += int64(min(f.UncompressedSize64, 1<<62))
+			}
+		} else {
+			part.name, err = safepath.Name(fileHeader.Filename)
 			if err != nil {
-				log.Printf("Error extracting ZIP: %v", err)
-			} else {
-				uploaded = append(uploaded, extracted...)
+				v.add("files", fmt.Errorf("%q %v", fileHeader.Filename, err))
 			}
-			os.Remove(tmpZip)
-			continue
+			count++
+			size += fileHeader.Size
 		}
+		parts = append(parts, part)
+	}
+	if count > h.limits.MaxFiles {
+		writeError(w, tooLarge("upload has %d files; the most allowed is %d", count, h.limits.MaxFiles))
+		return
+	}
+	if size > h.limits.MaxExtract {
+		writeError(w, h.extractError())
+		return
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
 
-		destPath := filepath.Join(modelPath, fileHeader.Filename)
-		destFile, err := os.Create(destPath)
-		if err != nil {
-			continue
+	stage, err := safepath.Stage(library.Path)
+	if err != nil {
+		writeError(w, libraryRootError(library, err))
+		return
+	}
+	defer func() {
+		if err := os.RemoveAll(filepath.Join(library.Path, stage)); err != nil {
+			log.Printf("Removing upload folder %s: %v", stage, err)
 		}
+	}()
+	staged, err := h.stage(library, stage, parts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-		hash := sha256.New()
-		size, _ := io.Copy(io.MultiWriter(destFile, hash), file)
-		destFile.Close()
+	var uploader *int64
+	if user := auth.UserFrom(r.Context()); user != nil && user.ID != 0 {
+		uploader = &user.ID
+	}
+	created := newFolders(library, modelName, staged)
+	var moves []fileMove
+	var modelID int64
+	err = h.store.InTx(r.Context(), func(tx store.Store) error {
+		model := models.Model{LibraryID: library.ID, Name: modelName, Path: filepath.Join(library.Path, modelName)}
+		if err := tx.Models().Ensure(r.Context(), &model); err != nil {
+			return err
+		}
+		if model.DeletedAt != nil {
+			return conflict("model %q is in the trash; restore it or choose another name", modelName)
+		}
+		modelID = model.ID
 
-		digest := fmt.Sprintf("%x", hash.Sum(nil))
+		for _, f := range staged {
+			rel := filepath.Join(modelName, f.rel)
+			// A file being replaced waits in the upload folder until the
+			// upload is recorded.
+			if _, err := os.Lstat(filepath.Join(library.Path, rel)); err == nil {
+				if err := safepath.Move(library.Path, rel, f.stage+".old"); err != nil {
+					return libraryRootError(library, err)
+				}
+				moves = append(moves, fileMove{rel, f.stage + ".old"})
+			}
+			if err := safepath.Move(library.Path, f.stage, rel); err != nil {
+				return libraryRootError(library, err)
+			}
+			moves = append(moves, fileMove{f.stage, rel})
 
-		_, err = h.db.Exec(
-			"INSERT INTO model_files (model_id, filename, path, size, digest) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (path) DO UPDATE SET size = EXCLUDED.size, digest = EXCLUDED.digest",
-			modelID, fileHeader.Filename, destPath, size, digest,
-		)
-		if err != nil {
-			log.Printf("Error saving file to DB: %v", err)
-		} else {
-			uploaded = append(uploaded, fileHeader.Filename)
+			path := filepath.Join(library.Path, rel)
+			width, depth, height := scanner.Measure(path)
+			err := saveFile(r.Context(), tx, library, &models.ModelFile{
+				ModelID:    modelID,
+				Filename:   filepath.Base(f.rel),
+				Path:       path,
+				Size:       f.size,
+				Digest:     &f.digest,
+				Width:      width,
+				Depth:      depth,
+				Height:     height,
+				UploadedBy: uploader,
+			})
+			if err != nil {
+				return err
+			}
 		}
+		return h.checkQuotas(r.Context(), tx, library, uploader)
+	})
+	if err != nil {
+		undoMoves(library, moves, created)
+		writeError(w, err)
+		return
 	}
 
-	h.setDefaultPreview(modelID)
+	uploaded := make([]string, len(staged))
+	for i, f := range staged {
+		uploaded[i] = f.name
+	}
+	store.ChooseDefaultPreview(r.Context(), h.store, modelID)
+	h.store.Models().RefreshStats(r.Context(), modelID)
+	if err := jobs.UpdateDescriptor(h.db, modelID); err != nil {
+		log.Printf("Descriptor for model %d: %v", modelID, err)
+	}
+	if err := jobs.UpdateThumbnail(h.db, modelID); err != nil {
+		log.Printf("Thumbnail for model %d: %v", modelID, err)
+	}
+	record(r, h.store, audit.Upload, "model", modelID, nil, map[string]interface{}{
+		"library_id": library.ID,
+		"name":       modelName,
+		"files":      uploaded,
+	})
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, 200, map[string]interface{}{
 		"uploaded": uploaded,
 		"count":    len(uploaded),
 	})
 }
 
-func (h *UploadHandler) extractZip(zipPath, destDir string, modelID int64) ([]string, error) {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
+// uploadPart is one file of an upload, with the archive when it is a ZIP.
+type uploadPart struct {
+	file multipart.File
+	name string
+	zip  *zip.Reader
+}
 
-	extracted := []string{}
-	for _, f := range r.File {
-		if f.FileInfo().IsDir() || strings.HasPrefix(filepath.Base(f.Name), ".") {
-			continue
+// hiddenEntry reports whether a ZIP entry is, or is inside, a dotfile such
+// as .DS_Store, which uploads leave out.
+func hiddenEntry(name string) bool {
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
 		}
+	}
+	return false
+}
+
+// libraryRootError explains why files cannot be written to the library.
+func libraryRootError(library *models.Library, err error) error {
+	if errors.Is(err, safepath.ErrOutsideRoots) {
+		return forbidden(fmt.Sprintf("library %q is outside the allowed library roots", library.Name))
+	}
+	return err
+}
 
-		fpath := filepath.Join(destDir, f.Name)
-		os.MkdirAll(filepath.Dir(fpath), 0755)
+// minRatioBytes is how large a ZIP entry may unpack to whatever its
+// compression ratio, so small files of zeros and the like still upload.
+const minRatioBytes = 1 << 20
 
-		outFile, err := os.Create(fpath)
+// stagedFile is an uploaded file written to the upload folder, waiting to
+// be moved to rel below the model folder.
+type stagedFile struct {
+	stage  string // relative to the library root
+	rel    string
+	name   string // as sent, for the response
+	size   int64
+	digest string
+}
+
+// fileMove is a rename done while recording an upload, from and to
+// relative to the library root, to be undone if recording fails.
+type fileMove struct {
+	from, to string
+}
+
+// stage writes the upload's files, with ZIP archives unpacked, into the
+// folder dir below the library root. It stops as soon as the upload grows
+// past MaxExtract or an entry past MaxRatio, whatever the archive claimed.
+func (h *UploadHandler) stage(library *models.Library, dir string, parts []uploadPart) ([]stagedFile, error) {
+	staged := []stagedFile{}
+	var written int64
+	write := func(src io.Reader, rel, name string, limit int64, limitErr error) error {
+		f := stagedFile{stage: filepath.Join(dir, strconv.Itoa(len(staged))), rel: rel, name: name}
+		out, err := safepath.Create(library.Path, f.stage)
 		if err != nil {
-			log.Printf("Error creating file %s: %v", fpath, err)
-			continue
+			return libraryRootError(library, err)
+		}
+		hash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(src, limit+1))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
 		}
-
-		rc, err := f.Open()
 		if err != nil {
-			outFile.Close()
-			log.Printf("Error opening ZIP entry %s: %v", f.Name, err)
+			return err
+		}
+		if n > limit {
+			return limitErr
+		}
+		f.size, f.digest = n, fmt.Sprintf("%x", hash.Sum(nil))
+		written += n
+		staged = append(staged, f)
+		return nil
+	}
+
+	for _, part := range parts {
+		if part.zip == nil {
+			if err := write(part.file, part.name, part.name, h.limits.MaxExtract-written, h.extractError()); err != nil {
+				return nil, err
+			}
 			continue
 		}
+		for _, f := range part.zip.File {
+			if f.FileInfo().IsDir() || hiddenEntry(f.Name) {
+				continue
+			}
+			rel, err := safepath.Clean(f.Name)
+			if err != nil {
+				return nil, err
+			}
+			limit, limitErr := h.limits.MaxExtract-written, h.extractError()
+			if byRatio := max(int64(min(f.CompressedSize64, 1<<40))*h.limits.MaxRatio, minRatioBytes); byRatio < limit {
+				limit, limitErr = byRatio, h.ratioError(part.name, f.Name)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, badRequest("%s: entry %q: %v", part.name, f.Name, err)
+			}
+			err = write(rc, rel, f.Name, limit, limitErr)
+			rc.Close()
+			if damagedArchive(err) {
+				return nil, badRequest("%s: entry %q: %v", part.name, f.Name, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return staged, nil
+}
 
-		hash := sha256.New()
-		size, _ := io.Copy(io.MultiWriter(outFile, hash), rc)
-		outFile.Close()
-		rc.Close()
+func (h *UploadHandler) extractError() error {
+	return tooLarge("upload unpacks to more than %d MB", h.limits.MaxExtract>>20)
+}
+
+func (h *UploadHandler) ratioError(archive, entry string) error {
+	return tooLarge("%s: entry %q unpacks to more than %d times its compressed size", archive, entry, h.limits.MaxRatio)
+}
 
-		digest := fmt.Sprintf("%x", hash.Sum(nil))
+// damagedArchive reports whether err came from reading a broken ZIP entry
+// rather than from writing it out.
+func damagedArchive(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &corrupt)
+}
 
-		_, err = h.db.Exec(
-			"INSERT INTO model_files (model_id, filename, path, size, digest) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (path) DO UPDATE SET size = EXCLUDED.size, digest = EXCLUDED.digest",
-			modelID, filepath.Base(f.Name), fpath, size, digest,
-		)
+// checkQuotas fails once the library's files, or the files the uploader
+// has uploaded, take more than their quota. Files in the trash count until
+// they are purged.
+func (h *UploadHandler) checkQuotas(ctx context.Context, st store.Store, library *models.Library, uploader *int64) error {
+	if library.QuotaMB != nil {
+		used, err := st.Libraries().Usage(ctx, library.ID)
 		if err != nil {
-			log.Printf("Error saving %s to DB: %v", f.Name, err)
-		} else {
-			extracted = append(extracted, f.Name)
+			return err
+		}
+		if used > *library.QuotaMB<<20 {
+			return &APIError{Status: 413, Code: "quota_exceeded", Message: fmt.Sprintf("library %q would be over its %d MB quota", library.Name, *library.QuotaMB)}
 		}
 	}
+	if uploader != nil && h.limits.UserQuota > 0 {
+		used, err := st.Users().Usage(ctx, *uploader)
+		if err != nil {
+			return err
+		}
+		if used > h.limits.UserQuota {
+			return &APIError{Status: 413, Code: "quota_exceeded", Message: fmt.Sprintf("your uploads would be over the %d MB quota", h.limits.UserQuota>>20)}
+		}
+	}
+	return nil
+}
 
-	return extracted, nil
+// newFolders returns the folders, relative to the library root, that
+// moving the staged files into the model folder will create, deepest
+// first.
+func newFolders(library *models.Library, modelName string, staged []stagedFile) []string {
+	seen := map[string]bool{}
+	folders := []string{}
+	for _, f := range staged {
+		for dir := filepath.Dir(filepath.Join(modelName, f.rel)); dir != "." && !seen[dir]; dir = filepath.Dir(dir) {
+			seen[dir] = true
+			if _, err := os.Lstat(filepath.Join(library.Path, dir)); err != nil {
+				folders = append(folders, dir)
+			}
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool { return len(folders[i]) > len(folders[j]) })
+	return folders
 }
 
-func (h *UploadHandler) setDefaultPreview(modelID int64) {
-	var files []struct {
-		ID       int64  `db:"id"`
-		Filename string `db:"filename"`
+// undoMoves puts files back where they were before a failed upload, in
+// reverse order so replaced files come back last, and removes the folders
+// the upload created.
+func undoMoves(library *models.Library, moves []fileMove, folders []string) {
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := safepath.Move(library.Path, moves[i].to, moves[i].from); err != nil {
+			log.Printf("Undoing upload to %s: %v", moves[i].to, err)
+		}
 	}
-	h.db.Select(&files, "SELECT id, filename FROM model_files WHERE model_id = $1", modelID)
-	
-	var previewID *int64
-	for _, f := range files {
-		ext := strings.ToLower(filepath.Ext(f.Filename))
-		if ext == ".png" || ext == ".jpg" || ext == ".jpeg" {
-			previewID = &f.ID
-			break
+	for _, dir := range folders {
+		if err := safepath.Remove(library.Path, filepath.Join(library.Path, dir)); err != nil {
+			log.Printf("Undoing upload folder %s: %v", dir, err)
 		}
 	}
-	
-	if previewID == nil {
-		for _, f := range files {
-			ext := strings.ToLower(filepath.Ext(f.Filename))
-			if ext == ".stl" || ext == ".obj" || ext == ".3mf" {
-				previewID = &f.ID
-				break
-			}
+}
+
+// saveFile records an uploaded file, replacing the row for the same path.
+// A replaced file in the trash comes back, since it was written again, and
+// scans stop skipping it if it was forgotten.
+func saveFile(ctx context.Context, st store.Store, library *models.Library, f *models.ModelFile) error {
+	format := scanner.Format(f.Path)
+	f.Format = &format
+	if err := st.Files().Upsert(ctx, f); err != nil {
+		return err
+	}
+	if f.DeletedAt != nil {
+		if _, err := st.Files().Restore(ctx, f.ID); err != nil {
+			return err
+		}
+		// A copy in the library trash is no longer what the row describes.
+		if err := st.Files().SetTrashPath(ctx, f.ID, nil); err != nil {
+			return err
 		}
 	}
-	
-	if previewID != nil {
-		h.db.Exec("UPDATE models SET preview_file_id = $1 WHERE id = $2", previewID, modelID)
+	if rel, err := safepath.Rel(library.Path, f.Path); err == nil {
+		return st.Files().Unignore(ctx, library.ID, rel)
 	}
+	return nil
 }
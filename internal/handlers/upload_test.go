@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipOf returns a ZIP archive holding the named entries, each with a little
+// content.
+func zipOf(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(w, "solid %s\nendsolid\n", name)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// upload sends one file to a library's upload endpoint.
+func (a *testAPI) upload(libraryID int64, modelName, filename string, content []byte) *response {
+	a.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("model_name", modelName)
+	fw, err := mw.CreateFormFile("files", filename)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	fw.Write(content)
+	if err := mw.Close(); err != nil {
+		a.t.Fatal(err)
+	}
+	resp, err := http.Post(fmt.Sprintf("%s/api/libraries/%d/upload", a.srv.URL, libraryID), mw.FormDataContentType(), &body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: raw}
+}
+
+func TestUploadRefusesNestedArchives(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Uploads")
+
+	for _, inner := range []string{"inner.zip", "parts/MORE.ZIP"} {
+		resp := a.upload(library.ID, "Nested", "outer.zip", zipOf(t, "base.stl", inner)).expect(t, 422, nil)
+		if code := resp.errorCode(t); code != "validation_failed" {
+			t.Errorf("%s: code %q", inner, code)
+		}
+		if !strings.Contains(string(resp.body), "archives inside archives") {
+			t.Errorf("%s: %s", inner, resp.body)
+		}
+	}
+
+	entries, err := os.ReadDir(library.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("refused uploads left %d entries in the library", len(entries))
+	}
+}
+
+func TestUploadFolderDepth(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Uploads")
+
+	deep := strings.Repeat("d/", 11) + "part.stl"
+	resp := a.upload(library.ID, "Deep", "deep.zip", zipOf(t, deep)).expect(t, 422, nil)
+	if !strings.Contains(string(resp.body), "more than 10 folders deep") {
+		t.Errorf("%s", resp.body)
+	}
+}
+
+func TestUploadRefusesZipSlip(t *testing.T) {
+	a := newTestAPI(t)
+	library := createLibrary(t, a, "Uploads")
+
+	for _, entry := range []string{"../../x", `..\..\x`, "parts/../../../x", "/tmp/x"} {
+		resp := a.upload(library.ID, "Slip", "slip.zip", zipOf(t, "base.stl", entry)).expect(t, 422, nil)
+		if code := resp.errorCode(t); code != "validation_failed" {
+			t.Errorf("%s: code %q", entry, code)
+		}
+	}
+
+	// The entries lead from the model folder to beside the library.
+	if _, err := os.Stat(filepath.Join(filepath.Dir(library.Path), "x")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file written beside the library: %v", err)
+	}
+	entries, err := os.ReadDir(library.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("refused uploads left %d entries in the library", len(entries))
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// UserHandler manages accounts. Every route is for administrators only.
+type UserHandler struct {
+	store store.Store
+}
+
+func NewUserHandler(st store.Store) *UserHandler {
+	return &UserHandler{store: st}
+}
+
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	users, err := h.store.Users().List(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, users)
+}
+
+func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Admin    bool   `json:"admin"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v := &validation{}
+	username, err := validUsername(req.Username)
+	v.add("username", err)
+	v.add("password", validPassword(req.Password))
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	user := &models.User{Username: username, PasswordHash: hash, Admin: req.Admin}
+	if err := h.store.Users().Create(r.Context(), user); err != nil {
+		if toAPIError(err).Code == "already_exists" {
+			err = &APIError{Status: 409, Code: "already_exists", Message: fmt.Sprintf("username %q is taken", username),
+				Fields: map[string]string{"username": "is taken"}}
+		}
+		writeError(w, err)
+		return
+	}
+	record(r, h.store, audit.Create, "user", user.ID, nil, user)
+	setETag(w, user.UpdatedAt)
+	writeJSON(w, 201, user)
+}
+
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := h.store.Users().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "user")
+		return
+	}
+	setETag(w, user.UpdatedAt)
+	writeJSON(w, 200, user)
+}
+
+// Update resets a password, grants or removes admin rights, or disables an
+// account. Disabling or a new password signs the user out everywhere;
+// disabled users' API tokens stop working too.
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req struct {
+		Password *string `json:"password"`
+		Admin    *bool   `json:"admin"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := decodeStrict(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.store.Users().Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err, "user")
+		return
+	}
+	if !ifMatch(r, user.UpdatedAt) {
+		writeError(w, preconditionFailed("user"))
+		return
+	}
+	before := *user
+
+	self := auth.UserFrom(r.Context()).ID == user.ID
+	v := &validation{}
+	signOut := false
+	if req.Password != nil {
+		if err := validPassword(*req.Password); err != nil {
+			v.add("password", err)
+		} else if user.PasswordHash, err = auth.HashPassword(*req.Password); err != nil {
+			writeError(w, err)
+			return
+		}
+		signOut = true
+	}
+	if req.Admin != nil {
+		v.check(*req.Admin || !self, "admin", "you cannot remove your own admin rights")
+		user.Admin = *req.Admin
+	}
+	if req.Disabled != nil {
+		v.check(!*req.Disabled || !self, "disabled", "you cannot disable yourself")
+		signOut = signOut || *req.Disabled && !user.Disabled
+		user.Disabled = *req.Disabled
+	}
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.Password != nil || req.Admin != nil || req.Disabled != nil {
+		if err := h.store.Users().Update(r.Context(), user); err != nil {
+			writeUpdateError(w, err, "user")
+			return
+		}
+		record(r, h.store, audit.Update, "user", user.ID, before, userChange{*user, req.Password != nil})
+	}
+	if signOut {
+		if err := h.store.Sessions().DeleteForUser(r.Context(), user.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	setETag(w, user.UpdatedAt)
+	writeJSON(w, 200, user)
+}
+
+func validUsername(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("must not be empty")
+	}
+	if len(name) > 64 {
+		return "", fmt.Errorf("must be at most 64 characters")
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("._@-", c)) {
+			return "", fmt.Errorf("may only contain letters, digits and . _ @ -")
+		}
+	}
+	return name, nil
+}
+
+// validPassword enforces a minimum length. bcrypt ignores anything past 72
+// bytes, so longer passwords are refused rather than silently truncated.
+func validPassword(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("must be at least 8 characters")
+	}
+	if len(password) > 72 {
+		return fmt.Errorf("must be at most 72 bytes")
+	}
+	return nil
+}
+
+// userChange is a user as the audit log shows an update. Password hashes
+// are never logged, only that the password changed.
+type userChange struct {
+	models.User
+	PasswordChanged bool `json:"password_changed,omitempty"`
+}
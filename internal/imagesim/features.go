@@ -0,0 +1,155 @@
+package imagesim
+
+import (
+	"3d-library/internal/thumbnail"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	workSize   = 128
+	hashSize   = 32
+	colorBins  = 4 // per channel
+	edgeBins   = 8
+	edgeGrid   = 2
+	edgeThresh = 0.1
+)
+
+// Features describes an image for similarity search: a DCT perceptual hash
+// for overall structure, an RGB histogram, and a histogram of edge
+// orientations per image quadrant for silhouette and detail.
+type Features struct {
+	Hash  uint64    `json:"hash"`
+	Color []float64 `json:"color"`
+	Edges []float64 `json:"edges"`
+}
+
+func Extract(img image.Image) Features {
+	src := thumbnail.Fit(img, workSize)
+	gray := make([]float64, workSize*workSize)
+	color := make([]float64, colorBins*colorBins*colorBins)
+
+	for y := 0; y < workSize; y++ {
+		for x := 0; x < workSize; x++ {
+			i := src.PixOffset(x, y)
+			r, g, b := src.Pix[i], src.Pix[i+1], src.Pix[i+2]
+			gray[y*workSize+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 255
+			q := func(c uint8) int { return int(c) * colorBins / 256 }
+			color[(q(r)*colorBins+q(g))*colorBins+q(b)]++
+		}
+	}
+	normalize(color)
+
+	return Features{
+		Hash:  perceptualHash(gray),
+		Color: color,
+		Edges: edgeHistogram(gray),
+	}
+}
+
+// Similarity returns 1 for identical features and 0 for nothing in common.
+func Similarity(a, b Features) float64 {
+	hash := float64(bits.OnesCount64(a.Hash^b.Hash)) / 64
+	return 1 - (0.5*hash + 0.2*l1(a.Color, b.Color)/2 + 0.3*l1(a.Edges, b.Edges)/2)
+}
+
+func perceptualHash(gray []float64) uint64 {
+	// Downsample to 32x32 and take the low 8x8 DCT frequencies.
+	step := workSize / hashSize
+	small := make([]float64, hashSize*hashSize)
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			sum := 0.0
+			for dy := 0; dy < step; dy++ {
+				for dx := 0; dx < step; dx++ {
+					sum += gray[(y*step+dy)*workSize+x*step+dx]
+				}
+			}
+			small[y*hashSize+x] = sum / float64(step*step)
+		}
+	}
+
+	coeffs := make([]float64, 0, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < hashSize; y++ {
+				cy := math.Cos(float64(2*y+1) * float64(v) * math.Pi / (2 * hashSize))
+				for x := 0; x < hashSize; x++ {
+					sum += small[y*hashSize+x] * math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*hashSize)) * cy
+				}
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	// The DC term only reflects average brightness, so leave it out of
+	// the median.
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func edgeHistogram(gray []float64) []float64 {
+	hist := make([]float64, edgeGrid*edgeGrid*edgeBins)
+	cell := workSize / edgeGrid
+	at := func(x, y int) float64 { return gray[y*workSize+x] }
+
+	for y := 1; y < workSize-1; y++ {
+		for x := 1; x < workSize-1; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			mag := math.Hypot(gx, gy)
+			if mag < edgeThresh {
+				continue
+			}
+			// Orientation modulo pi: an edge and its reverse are the same.
+			angle := math.Atan2(gy, gx)
+			if angle < 0 {
+				angle += math.Pi
+			}
+			bin := int(angle / math.Pi * edgeBins)
+			if bin >= edgeBins {
+				bin = edgeBins - 1
+			}
+			region := (y/cell)*edgeGrid + x/cell
+			hist[region*edgeBins+bin] += mag
+		}
+	}
+	normalize(hist)
+	return hist
+}
+
+func normalize(v []float64) {
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	if sum == 0 {
+		return
+	}
+	for i := range v {
+		v[i] /= sum
+	}
+}
+
+func l1(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 2
+	}
+	d := 0.0
+	for i := range a {
+		d += math.Abs(a[i] - b[i])
+	}
+	return d
+}
@@ -0,0 +1,58 @@
+package imagesim
+
+import (
+	"sort"
+	"sync"
+)
+
+type Entry struct {
+	ModelID   int64
+	LibraryID int64
+	Features  Features
+}
+
+type Match struct {
+	ModelID int64
+	Score   float64
+}
+
+// Index holds image features in memory for brute-force nearest neighbour
+// search. Version identifies the data it was loaded from so callers can tell
+// when it needs reloading.
+type Index struct {
+	mu      sync.RWMutex
+	entries []Entry
+	version string
+}
+
+func (ix *Index) Version() string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.version
+}
+
+func (ix *Index) Load(version string, entries []Entry) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.entries = entries
+	ix.version = version
+}
+
+// Nearest returns the limit best matches for f among the entries keep
+// accepts, or among all entries when keep is nil.
+func (ix *Index) Nearest(f Features, limit int, keep func(Entry) bool) []Match {
+	ix.mu.RLock()
+	matches := make([]Match, 0, len(ix.entries))
+	for _, e := range ix.entries {
+		if keep == nil || keep(e) {
+			matches = append(matches, Match{ModelID: e.ModelID, Score: Similarity(f, e.Features)})
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
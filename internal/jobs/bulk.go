@@ -0,0 +1,395 @@
+package jobs
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/safepath"
+	"3d-library/internal/store"
+	"3d-library/internal/thumbnail"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	BulkAddTags              = "add_tags"
+	BulkRemoveTags           = "remove_tags"
+	BulkAddToCollection      = "add_to_collection"
+	BulkRemoveFromCollection = "remove_from_collection"
+	BulkMoveLibrary          = "move_library"
+	BulkDelete               = "delete"
+	BulkRegeneratePreviews   = "regenerate_previews"
+)
+
+type BulkRequest struct {
+	Operation    string   `json:"operation"`
+	ModelIDs     []int64  `json:"model_ids"`
+	Tags         []string `json:"tags,omitempty"`
+	CollectionID int64    `json:"collection_id,omitempty"`
+	LibraryID    int64    `json:"library_id,omitempty"`
+}
+
+type BulkResult struct {
+	ModelID int64  `json:"model_id"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (req *BulkRequest) Validate() error {
+	if len(req.ModelIDs) == 0 {
+		return fmt.Errorf("no models selected")
+	}
+	switch req.Operation {
+	case BulkAddTags, BulkRemoveTags:
+		if len(req.Tags) == 0 {
+			return fmt.Errorf("%s needs tags", req.Operation)
+		}
+		for _, t := range req.Tags {
+			if strings.TrimSpace(t) == "" {
+				return fmt.Errorf("tags must not be empty")
+			}
+		}
+	case BulkAddToCollection, BulkRemoveFromCollection:
+		if req.CollectionID == 0 {
+			return fmt.Errorf("%s needs collection_id", req.Operation)
+		}
+	case BulkMoveLibrary:
+		if req.LibraryID == 0 {
+			return fmt.Errorf("%s needs library_id", req.Operation)
+		}
+	case BulkDelete, BulkRegeneratePreviews:
+	default:
+		return fmt.Errorf("unknown operation %q", req.Operation)
+	}
+	return nil
+}
+
+// Role is the library role the operation needs for each model.
+func (req *BulkRequest) Role() auth.Role {
+	switch req.Operation {
+	case BulkAddTags, BulkRemoveTags, BulkAddToCollection, BulkRemoveFromCollection:
+		return auth.Contributor
+	}
+	return auth.Editor
+}
+
+type BulkPayload struct {
+	JobID int64 `json:"job_id"`
+}
+
+func NewBulkTask(jobID int64) (*asynq.Task, error) {
+	payload, err := json.Marshal(BulkPayload{JobID: jobID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeBulk, payload), nil
+}
+
+func HandleBulkTask(ctx context.Context, t *asynq.Task, st store.Store, db *sqlx.DB) error {
+	var p BulkPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return err
+	}
+
+	job, err := st.BulkJobs().Get(ctx, p.JobID)
+	if err != nil {
+		return err
+	}
+	var req BulkRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return err
+	}
+
+	if err := st.BulkJobs().Start(ctx, job.ID); err != nil {
+		return err
+	}
+	access, err := bulkAccess(ctx, st, job.UserID)
+	var results []BulkResult
+	if err == nil {
+		// The changes are logged as made by whoever queued the job.
+		ctx = auth.WithClientIP(auth.WithUser(ctx, access.User), job.SourceIP)
+		results, err = RunBulk(ctx, st, db, &req, access)
+	}
+	if err != nil {
+		if failErr := st.BulkJobs().Fail(ctx, job.ID, err.Error()); failErr != nil {
+			log.Printf("Bulk job %d: %v", job.ID, failErr)
+		}
+		return err
+	}
+	out, _ := json.Marshal(results)
+	log.Printf("Bulk job %d: %s on %d models", job.ID, req.Operation, len(req.ModelIDs))
+	return st.BulkJobs().Finish(ctx, job.ID, string(out))
+}
+
+// bulkAccess is the access of the user who queued a job, checked again when
+// it runs. Jobs without a user were queued by the system.
+func bulkAccess(ctx context.Context, st store.Store, userID *int64) (*auth.Access, error) {
+	if userID == nil {
+		return auth.NewAccess(auth.System, nil, false), nil
+	}
+	user, err := st.Users().Get(ctx, *userID)
+	if err != nil {
+		return nil, fmt.Errorf("user %d: %w", *userID, err)
+	}
+	if user.Disabled {
+		return nil, fmt.Errorf("user %q is disabled", user.Username)
+	}
+	return auth.LoadAccess(ctx, st, user, false)
+}
+
+// RunBulk applies the operation to every model in one transaction, skipping
+// the models a may not change. Each item runs in a nested transaction so a
+// failing model is reported and skipped without aborting the rest. Changes
+// go in the audit log as made by the user in ctx.
+func RunBulk(ctx context.Context, st store.Store, db *sqlx.DB, req *BulkRequest, a *auth.Access) ([]BulkResult, error) {
+	check := func(item store.Store, id int64) error {
+		return checkModel(ctx, item, a, id, req.Role())
+	}
+	if req.Operation == BulkRegeneratePreviews {
+		return regeneratePreviews(ctx, st, db, req.ModelIDs, check), nil
+	}
+
+	var results []BulkResult
+	undo := func() {}
+	err := st.InTx(ctx, func(tx store.Store) error {
+		op, opUndo, err := bulkOperation(ctx, tx, req, a)
+		if err != nil {
+			return err
+		}
+		undo = opUndo
+
+		results = make([]BulkResult, 0, len(req.ModelIDs))
+		for _, id := range req.ModelIDs {
+			res := BulkResult{ModelID: id, OK: true}
+			err := tx.InTx(ctx, func(item store.Store) error {
+				if err := check(item, id); err != nil {
+					return err
+				}
+				return op(item, id)
+			})
+			if err != nil {
+				res.OK, res.Error = false, err.Error()
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return results, nil
+}
+
+// errModelNotFound is how a missing model is reported in bulk results.
+var errModelNotFound = errors.New("model not found")
+
+func getModel(ctx context.Context, st store.Store, id int64) (*models.Model, error) {
+	m, err := st.Models().Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errModelNotFound
+	}
+	return m, err
+}
+
+// checkModel checks that a has role in the model's library. Models in
+// libraries a cannot see are reported as missing.
+func checkModel(ctx context.Context, st store.Store, a *auth.Access, id int64, role auth.Role) error {
+	m, err := getModel(ctx, st, id)
+	if err != nil {
+		return err
+	}
+	if a.Can(m.LibraryID, role) {
+		return nil
+	}
+	if a.Can(m.LibraryID, auth.Viewer) {
+		return fmt.Errorf("you need the %s role in this model's library", role)
+	}
+	return errModelNotFound
+}
+
+// bulkOperation returns the per-model step for a request, and an undo for
+// any filesystem changes should the transaction fail to commit.
+func bulkOperation(ctx context.Context, tx store.Store, req *BulkRequest, a *auth.Access) (func(store.Store, int64) error, func(), error) {
+	noUndo := func() {}
+
+	switch req.Operation {
+	case BulkAddTags, BulkRemoveTags:
+		var tags []*models.Tag
+		for _, name := range req.Tags {
+			tag, err := tx.Tags().Ensure(ctx, strings.TrimSpace(name))
+			if err != nil {
+				return nil, nil, err
+			}
+			tags = append(tags, tag)
+		}
+		return func(item store.Store, modelID int64) error {
+			if _, err := getModel(ctx, item, modelID); err != nil {
+				return err
+			}
+			for _, tag := range tags {
+				var err error
+				if req.Operation == BulkRemoveTags {
+					err = item.Tags().Detach(ctx, modelID, tag.ID)
+					if err == nil {
+						err = audit.Record(ctx, item, audit.Untag, "model", modelID, tag, nil)
+					}
+				} else {
+					err = item.Tags().Attach(ctx, modelID, tag.ID)
+					if err == nil {
+						err = audit.Record(ctx, item, audit.Tag, "model", modelID, nil, tag)
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}, noUndo, nil
+
+	case BulkAddToCollection, BulkRemoveFromCollection:
+		collection, err := tx.Collections().Get(ctx, req.CollectionID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("collection %d not found", req.CollectionID)
+		}
+		if !a.Owns(collection.UserID) {
+			return nil, nil, fmt.Errorf("only the owner of collection %d or an administrator can change it", req.CollectionID)
+		}
+		if collection.Query != nil {
+			return nil, nil, fmt.Errorf("smart collection members come from its query")
+		}
+		return func(item store.Store, modelID int64) error {
+			if _, err := getModel(ctx, item, modelID); err != nil {
+				return err
+			}
+			ref := map[string]int64{"model_id": modelID}
+			if req.Operation == BulkRemoveFromCollection {
+				if err := item.Collections().RemoveModel(ctx, req.CollectionID, modelID); err != nil {
+					return err
+				}
+				return audit.Record(ctx, item, audit.Remove, "collection", req.CollectionID, ref, nil)
+			}
+			if err := item.Collections().AddModel(ctx, req.CollectionID, modelID); err != nil {
+				return err
+			}
+			return audit.Record(ctx, item, audit.Add, "collection", req.CollectionID, nil, ref)
+		}, noUndo, nil
+
+	case BulkMoveLibrary:
+		return moveLibraryOperation(ctx, tx, req.LibraryID, a)
+
+	case BulkDelete:
+		return func(item store.Store, modelID int64) error {
+			model, err := getModel(ctx, item, modelID)
+			if err != nil {
+				return err
+			}
+			if err := item.Models().Delete(ctx, modelID); err != nil {
+				return err
+			}
+			return audit.Record(ctx, item, audit.Delete, "model", modelID, model, nil)
+		}, noUndo, nil
+	}
+	return nil, nil, fmt.Errorf("unknown operation %q", req.Operation)
+}
+
+// moveLibraryOperation moves each model's folder into the root of the target
+// library and rewrites the stored paths. Moving models in needs the
+// contributor role in the target library.
+func moveLibraryOperation(ctx context.Context, tx store.Store, libraryID int64, a *auth.Access) (func(store.Store, int64) error, func(), error) {
+	library, err := tx.Libraries().Get(ctx, libraryID)
+	if err != nil || !a.Can(libraryID, auth.Viewer) {
+		return nil, nil, fmt.Errorf("library %d not found", libraryID)
+	}
+	if !a.Can(libraryID, auth.Contributor) {
+		return nil, nil, fmt.Errorf("you need the %s role in library %d", auth.Contributor, libraryID)
+	}
+	root := library.Path
+
+	type move struct{ from, to string }
+	var moved []move
+	undo := func() {
+		for i := len(moved) - 1; i >= 0; i-- {
+			if err := os.Rename(moved[i].to, moved[i].from); err != nil {
+				log.Printf("Bulk move: could not restore %s: %v", moved[i].from, err)
+			}
+		}
+	}
+
+	return func(item store.Store, modelID int64) error {
+		model, err := getModel(ctx, item, modelID)
+		if err != nil {
+			return err
+		}
+		if model.LibraryID == libraryID {
+			return nil
+		}
+
+		// Only a folder below both library roots can move; a model at the
+		// top of its library is the library itself.
+		from, err := item.Libraries().Get(ctx, model.LibraryID)
+		if err != nil {
+			return err
+		}
+		if _, err := safepath.Within(from.Path, model.Path); err != nil {
+			return fmt.Errorf("%s: %w", model.Path, err)
+		}
+		dest := filepath.Join(root, filepath.Base(model.Path))
+		if _, err := safepath.Within(root, dest); err != nil {
+			return fmt.Errorf("%s: %w", dest, err)
+		}
+		if _, err := os.Lstat(dest); err == nil {
+			return fmt.Errorf("%s already exists", dest)
+		}
+
+		if err := item.Models().Move(ctx, modelID, libraryID, dest); err != nil {
+			return err
+		}
+		after := *model
+		after.LibraryID, after.Path = libraryID, dest
+		if err := audit.Record(ctx, item, audit.Update, "model", modelID, model, after); err != nil {
+			return err
+		}
+		if err := os.Rename(model.Path, dest); err != nil {
+			return err
+		}
+		moved = append(moved, move{model.Path, dest})
+		return nil
+	}, undo, nil
+}
+
+// regeneratePreviews re-picks the default preview and forces the thumbnail
+// and shape descriptor to be rebuilt. It runs outside a transaction since it
+// is mostly file work and every step is idempotent.
+func regeneratePreviews(ctx context.Context, st store.Store, db *sqlx.DB, modelIDs []int64, check func(store.Store, int64) error) []BulkResult {
+	results := make([]BulkResult, 0, len(modelIDs))
+	for _, id := range modelIDs {
+		res := BulkResult{ModelID: id, OK: true}
+		if err := check(st, id); err != nil {
+			res.OK, res.Error = false, err.Error()
+			results = append(results, res)
+			continue
+		}
+
+		store.ChooseDefaultPreview(ctx, st, id)
+		db.Exec("DELETE FROM model_image_features WHERE model_id = $1", id)
+		db.Exec("DELETE FROM model_descriptors WHERE model_id = $1", id)
+		os.Remove(thumbnail.Path(id))
+		if err := UpdateThumbnail(db, id); err != nil {
+			res.OK, res.Error = false, err.Error()
+		} else if err := UpdateDescriptor(db, id); err != nil {
+			res.OK, res.Error = false, err.Error()
+		}
+		results = append(results, res)
+	}
+	return results
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"3d-library/internal/mesh"
+	"3d-library/internal/safepath"
+	"3d-library/internal/similarity"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UpdateDescriptor computes the shape descriptor for a model's primary mesh:
+// the preview file when it is a mesh, otherwise the largest mesh file. It is
+// skipped when the stored descriptor was built from the same file contents.
+func UpdateDescriptor(db *sqlx.DB, modelID int64) error {
+	var file struct {
+		ID     int64   `db:"id"`
+		Path   string  `db:"path"`
+		Digest *string `db:"digest"`
+		Root   string  `db:"root"`
+	}
+	err := db.Get(&file, `
+		SELECT mf.id, mf.path, mf.digest, l.path AS root FROM model_files mf
+		JOIN models m ON m.id = mf.model_id
+		JOIN libraries l ON l.id = m.library_id
+		WHERE mf.model_id = $1 AND mf.deleted_at IS NULL AND mf.format IN ('stl', 'obj', '3mf')
+		ORDER BY (mf.id = m.preview_file_id) DESC, mf.size DESC
+		LIMIT 1
+	`, modelID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored *string
+	err = db.Get(&stored, "SELECT digest FROM model_descriptors WHERE model_id = $1 AND file_id = $2", modelID, file.ID)
+	if err == nil && stored != nil && file.Digest != nil && *stored == *file.Digest {
+		return nil
+	}
+
+	f, err := safepath.Open(file.Root, file.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m, err := mesh.Read(f, file.Path)
+	if err != nil {
+		return err
+	}
+	desc := similarity.Describe(m)
+	hist, err := json.Marshal(desc.Histogram)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO model_descriptors (model_id, file_id, digest, histogram, ratio_mid, ratio_min)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (model_id) DO UPDATE SET
+			file_id = EXCLUDED.file_id, digest = EXCLUDED.digest, histogram = EXCLUDED.histogram,
+			ratio_mid = EXCLUDED.ratio_mid, ratio_min = EXCLUDED.ratio_min, computed_at = NOW()
+	`, modelID, file.ID, file.Digest, string(hist), desc.Ratios[0], desc.Ratios[1])
+	return err
+}
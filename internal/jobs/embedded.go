@@ -0,0 +1,328 @@
+package jobs
+
+import (
+	"3d-library/internal/database"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jmoiron/sqlx"
+)
+
+// Defaults match asynq's so a task behaves the same on either queue.
+const (
+	defaultMaxRetry = 25
+	defaultTimeout  = 30 * time.Minute
+	// leaseGrace is how long past its timeout an active task may run before
+	// another worker assumes its process died and takes it over.
+	leaseGrace = time.Minute
+	pollEvery  = 2 * time.Second
+	// archiveRetention is how long archived tasks are kept, counted from
+	// their last run.
+	archiveRetention = 90 * 24 * time.Hour
+	pruneEvery       = time.Hour
+)
+
+// Embedded is a Queue that keeps tasks in the job_queue table and runs them
+// inside the process with Run. It honours asynq's MaxRetry, Queue, Timeout,
+// Deadline, Unique, ProcessAt, ProcessIn and TaskID options. Successful
+// tasks are deleted; tasks out of retries stay behind as "archived" for
+// archiveRetention.
+type Embedded struct {
+	db   *sqlx.DB
+	wake chan struct{}
+}
+
+func NewEmbedded(db *sqlx.DB) *Embedded {
+	return &Embedded{db: db, wake: make(chan struct{}, 1)}
+}
+
+type queuedTask struct {
+	ID             string     `db:"id"`
+	Queue          string     `db:"queue"`
+	Type           string     `db:"type"`
+	Payload        []byte     `db:"payload"`
+	State          string     `db:"state"`
+	MaxRetry       int        `db:"max_retry"`
+	Retried        int        `db:"retried"`
+	TimeoutSeconds int64      `db:"timeout_seconds"`
+	Deadline       *time.Time `db:"deadline"`
+	RunAt          time.Time  `db:"run_at"`
+	LeaseUntil     *time.Time `db:"lease_until"`
+	UniqueKey      *string    `db:"unique_key"`
+	UniqueUntil    *time.Time `db:"unique_until"`
+	LastError      *string    `db:"last_error"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+// deadline is when a run starting at start must finish.
+func (t *queuedTask) deadline(start time.Time) time.Time {
+	if t.TimeoutSeconds == 0 {
+		return *t.Deadline
+	}
+	d := start.Add(time.Duration(t.TimeoutSeconds) * time.Second)
+	if t.Deadline != nil && t.Deadline.Before(d) {
+		return *t.Deadline
+	}
+	return d
+}
+
+func (t *queuedTask) info() *asynq.TaskInfo {
+	info := &asynq.TaskInfo{
+		ID:            t.ID,
+		Queue:         t.Queue,
+		Type:          t.Type,
+		Payload:       t.Payload,
+		State:         asynq.TaskStatePending,
+		MaxRetry:      t.MaxRetry,
+		Retried:       t.Retried,
+		Timeout:       time.Duration(t.TimeoutSeconds) * time.Second,
+		NextProcessAt: t.RunAt,
+	}
+	if t.RunAt.After(time.Now()) {
+		info.State = asynq.TaskStateScheduled
+	}
+	if t.Deadline != nil {
+		info.Deadline = *t.Deadline
+	}
+	return info
+}
+
+func (q *Embedded) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	now := time.Now().UTC()
+	t := &queuedTask{
+		Queue:    "default",
+		Type:     task.Type(),
+		Payload:  task.Payload(),
+		MaxRetry: defaultMaxRetry,
+		RunAt:    now,
+	}
+	if t.Payload == nil {
+		t.Payload = []byte{}
+	}
+	var timeout, unique time.Duration
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.MaxRetryOpt:
+			t.MaxRetry = opt.Value().(int)
+		case asynq.QueueOpt:
+			t.Queue = opt.Value().(string)
+		case asynq.TimeoutOpt:
+			timeout = opt.Value().(time.Duration)
+		case asynq.DeadlineOpt:
+			d := opt.Value().(time.Time).UTC()
+			t.Deadline = &d
+		case asynq.UniqueOpt:
+			unique = opt.Value().(time.Duration)
+		case asynq.ProcessAtOpt:
+			t.RunAt = opt.Value().(time.Time).UTC()
+		case asynq.ProcessInOpt:
+			t.RunAt = now.Add(opt.Value().(time.Duration))
+		case asynq.TaskIDOpt:
+			t.ID = opt.Value().(string)
+		case asynq.RetentionOpt:
+			// Completed tasks are not kept.
+		default:
+			return nil, fmt.Errorf("embedded queue: unsupported option %s", opt)
+		}
+	}
+	if t.MaxRetry < 0 {
+		t.MaxRetry = 0
+	}
+	if timeout == 0 && t.Deadline == nil {
+		timeout = defaultTimeout
+	}
+	t.TimeoutSeconds = int64(timeout / time.Second)
+	if t.ID == "" {
+		t.ID = newTaskID()
+	}
+
+	tx, err := q.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.Get(&n, "SELECT COUNT(*) FROM job_queue WHERE id = $1", t.ID); err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, asynq.ErrTaskIDConflict
+	}
+	if unique > 0 {
+		// Like asynq, uniqueness covers the queue, type and payload, and
+		// lasts until the task succeeds or the TTL runs out.
+		sum := sha256.Sum256([]byte(t.Queue + "\x00" + t.Type + "\x00" + string(t.Payload)))
+		key := hex.EncodeToString(sum[:])
+		until := now.Add(unique)
+		t.UniqueKey, t.UniqueUntil = &key, &until
+		if _, err := tx.Exec("UPDATE job_queue SET unique_key = NULL WHERE unique_key = $1 AND unique_until <= $2", key, now); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO job_queue (id, queue, type, payload, max_retry, timeout_seconds, deadline, run_at, unique_key, unique_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT DO NOTHING
+	`, t.ID, t.Queue, t.Type, t.Payload, t.MaxRetry, t.TimeoutSeconds, t.Deadline, t.RunAt, t.UniqueKey, t.UniqueUntil)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, asynq.ErrDuplicateTask
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if !t.RunAt.After(now) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return t.info(), nil
+}
+
+// Close does nothing; the database belongs to the caller.
+func (q *Embedded) Close() error { return nil }
+
+// Run processes due tasks with up to concurrency running at once until ctx
+// is cancelled, then waits for the running ones to finish. Several
+// processes may Run against the same database.
+func (q *Embedded) Run(ctx context.Context, h asynq.Handler, concurrency int) {
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var pruned time.Time
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		t, err := q.claim()
+		if err != nil || t == nil {
+			<-slots
+			if err != nil {
+				log.Printf("Job queue: %v", err)
+			}
+			if time.Since(pruned) >= pruneEvery {
+				if err := q.prune(); err != nil {
+					log.Printf("Job queue: %v", err)
+				}
+				pruned = time.Now()
+			}
+			select {
+			case <-q.wake:
+			case <-time.After(pollEvery):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			q.process(h, t)
+		}()
+	}
+}
+
+// claim leases the next due task, or a task whose worker went away, and
+// returns nil when there is none.
+func (q *Embedded) claim() (*queuedTask, error) {
+	tx, err := q.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	lock := " FOR UPDATE SKIP LOCKED"
+	if database.IsSQLite(q.db) {
+		// SQLite transactions here take the write lock when they begin.
+		lock = ""
+	}
+	now := time.Now().UTC()
+	var t queuedTask
+	err = tx.Get(&t, `
+		SELECT * FROM job_queue
+		WHERE (state = 'pending' AND run_at <= $1) OR (state = 'active' AND lease_until < $1)
+		ORDER BY run_at, id LIMIT 1`+lock, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Retries are counted here and only here: a task claimed again either
+	// failed, which left an error, or its worker died mid-run.
+	if t.State == "active" || t.LastError != nil {
+		t.Retried++
+	}
+	lease := t.deadline(now).Add(leaseGrace)
+	if _, err := tx.Exec("UPDATE job_queue SET state = 'active', retried = $2, lease_until = $3 WHERE id = $1", t.ID, t.Retried, lease); err != nil {
+		return nil, err
+	}
+	return &t, tx.Commit()
+}
+
+func (q *Embedded) process(h asynq.Handler, t *queuedTask) {
+	ctx, cancel := context.WithDeadline(context.Background(), t.deadline(time.Now().UTC()))
+	defer cancel()
+	task := asynq.NewTask(t.Type, t.Payload)
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return h.ProcessTask(ctx, task)
+	}()
+
+	var dbErr error
+	switch {
+	case err == nil:
+		_, dbErr = q.db.Exec("DELETE FROM job_queue WHERE id = $1", t.ID)
+	case errors.Is(err, asynq.SkipRetry) || t.Retried >= t.MaxRetry:
+		log.Printf("Job %s (%s) failed for good: %v", t.ID, t.Type, err)
+		_, dbErr = q.db.Exec("UPDATE job_queue SET state = 'archived', lease_until = NULL, last_error = $2 WHERE id = $1", t.ID, err.Error())
+	default:
+		log.Printf("Job %s (%s) failed, will retry: %v", t.ID, t.Type, err)
+		runAt := time.Now().UTC().Add(asynq.DefaultRetryDelayFunc(t.Retried, err, task))
+		_, dbErr = q.db.Exec("UPDATE job_queue SET state = 'pending', run_at = $2, lease_until = NULL, last_error = $3 WHERE id = $1",
+			t.ID, runAt, err.Error())
+	}
+	if dbErr != nil {
+		log.Printf("Job %s: %v", t.ID, dbErr)
+	}
+}
+
+// prune deletes archived tasks whose last run is older than
+// archiveRetention.
+func (q *Embedded) prune() error {
+	_, err := q.db.Exec("DELETE FROM job_queue WHERE state = 'archived' AND run_at < $1", time.Now().UTC().Add(-archiveRetention))
+	return err
+}
+
+func newTaskID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"3d-library/internal/database"
+	"3d-library/internal/migrate"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+func newTestQueue(t *testing.T) *Embedded {
+	t.Helper()
+	db, err := database.Connect("sqlite://" + filepath.Join(t.TempDir(), "queue.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	return NewEmbedded(db)
+}
+
+var failing = asynq.HandlerFunc(func(context.Context, *asynq.Task) error {
+	return errors.New("broken")
+})
+
+// task reads the task back from the table.
+func (q *Embedded) task(t *testing.T, id string) *queuedTask {
+	t.Helper()
+	var task queuedTask
+	if err := q.db.Get(&task, "SELECT * FROM job_queue WHERE id = $1", id); err != nil {
+		t.Fatal(err)
+	}
+	return &task
+}
+
+// due makes the task's retry due now instead of after the backoff.
+func (q *Embedded) due(t *testing.T, id string) {
+	t.Helper()
+	if _, err := q.db.Exec("UPDATE job_queue SET run_at = $2 WHERE id = $1", id, time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedRetries(t *testing.T) {
+	q := newTestQueue(t)
+	info, err := q.Enqueue(asynq.NewTask("test:fail", nil), asynq.MaxRetry(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := 0
+	for ; runs < 10; runs++ {
+		task, err := q.claim()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task == nil {
+			break
+		}
+		if task.Retried != runs {
+			t.Errorf("run %d claimed with retried %d", runs+1, task.Retried)
+		}
+		q.process(failing, task)
+		q.due(t, info.ID)
+	}
+	if runs != 3 {
+		t.Errorf("ran %d times, want 3 with MaxRetry(2)", runs)
+	}
+	if task := q.task(t, info.ID); task.State != "archived" || task.Retried != 2 {
+		t.Errorf("ended %s with retried %d, want archived with 2", task.State, task.Retried)
+	}
+}
+
+func TestEmbeddedReclaimCountsOnce(t *testing.T) {
+	q := newTestQueue(t)
+	info, err := q.Enqueue(asynq.NewTask("test:fail", nil), asynq.MaxRetry(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first worker dies mid-run, so its lease runs out.
+	if task, err := q.claim(); err != nil || task == nil {
+		t.Fatalf("claim: %v, %v", task, err)
+	}
+	if _, err := q.db.Exec("UPDATE job_queue SET lease_until = $2 WHERE id = $1", info.ID, time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := q.claim()
+	if err != nil || task == nil {
+		t.Fatalf("reclaim: %v, %v", task, err)
+	}
+	if task.Retried != 1 {
+		t.Errorf("reclaimed with retried %d, want 1", task.Retried)
+	}
+	q.process(failing, task)
+	if got := q.task(t, info.ID); got.State != "pending" || got.Retried != 1 {
+		t.Errorf("after failing: %s with retried %d, want pending with 1", got.State, got.Retried)
+	}
+}
+
+func TestEmbeddedPrune(t *testing.T) {
+	q := newTestQueue(t)
+	now := time.Now().UTC()
+	for id, row := range map[string]struct {
+		state string
+		runAt time.Time
+	}{
+		"old-archived": {"archived", now.Add(-archiveRetention - time.Hour)},
+		"new-archived": {"archived", now.Add(-time.Hour)},
+		"old-pending":  {"pending", now.Add(-archiveRetention - time.Hour)},
+	} {
+		_, err := q.db.Exec("INSERT INTO job_queue (id, type, payload, state, max_retry, run_at) VALUES ($1, 'test', $2, $3, 0, $4)",
+			id, []byte{}, row.state, row.runAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.prune(); err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	if err := q.db.Select(&left, "SELECT id FROM job_queue ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0] != "new-archived" || left[1] != "old-pending" {
+		t.Errorf("left %v, want new-archived and old-pending", left)
+	}
+}
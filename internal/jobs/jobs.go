@@ -1,12 +1,16 @@
 package jobs
 
 import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/safepath"
 	"3d-library/internal/scanner"
+	"3d-library/internal/store"
 	"context"
 	"encoding/json"
 	"log"
 	"path/filepath"
-	"strings"
 
 	"github.com/hibiken/asynq"
 	"github.com/jmoiron/sqlx"
@@ -14,6 +18,7 @@ import (
 
 const (
 	TypeScanLibrary = "library:scan"
+	TypeBulk        = "models:bulk"
 )
 
 type ScanLibraryPayload struct {
@@ -29,54 +34,37 @@ func NewScanLibraryTask(libraryID int64, path string) (*asynq.Task, error) {
 	return asynq.NewTask(TypeScanLibrary, payload), nil
 }
 
-func setDefaultPreview(db *sqlx.DB, modelID int64) {
-	var files []struct {
-		ID       int64  `db:"id"`
-		Filename string `db:"filename"`
-	}
-	db.Select(&files, "SELECT id, filename FROM model_files WHERE model_id = $1", modelID)
-	
-	var previewID *int64
-	for _, f := range files {
-		ext := strings.ToLower(filepath.Ext(f.Filename))
-		if ext == ".png" || ext == ".jpg" || ext == ".jpeg" {
-			previewID = &f.ID
-			break
-		}
-	}
-	
-	if previewID == nil {
-		for _, f := range files {
-			ext := strings.ToLower(filepath.Ext(f.Filename))
-			if ext == ".stl" || ext == ".obj" || ext == ".3mf" {
-				previewID = &f.ID
-				break
-			}
-		}
-	}
-	
-	if previewID != nil {
-		db.Exec("UPDATE models SET preview_file_id = $1 WHERE id = $2", previewID, modelID)
-	}
-}
-
-func HandleScanLibraryTask(ctx context.Context, t *asynq.Task, db *sqlx.DB) error {
+func HandleScanLibraryTask(ctx context.Context, t *asynq.Task, st store.Store, db *sqlx.DB) error {
 	var p ScanLibraryPayload
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
 		return err
 	}
 
 	log.Printf("Scanning library %d at %s", p.LibraryID, p.Path)
+	if _, err := safepath.Root(p.Path); err != nil {
+		return err
+	}
 
 	s := scanner.New(p.Path)
 	files, err := s.Scan()
 	if err != nil {
 		return err
 	}
+	ignored, err := st.Files().Ignored(ctx, p.LibraryID)
+	if err != nil {
+		return err
+	}
+	skip := make(map[string]bool, len(ignored))
+	for _, path := range ignored {
+		skip[path] = true
+	}
 
-	// Group files by directory
+	// Group files by directory, leaving out forgotten ones
 	modelDirs := make(map[string][]scanner.FileInfo)
 	for _, file := range files {
+		if rel, err := filepath.Rel(p.Path, file.Path); err == nil && skip[rel] {
+			continue
+		}
 		dir := filepath.Dir(file.Path)
 		modelDirs[dir] = append(modelDirs[dir], file)
 	}
@@ -85,46 +73,71 @@ func HandleScanLibraryTask(ctx context.Context, t *asynq.Task, db *sqlx.DB) erro
 	for modelPath, dirFiles := range modelDirs {
 		modelName := filepath.Base(modelPath)
 		
-		var modelID int64
-		err := db.QueryRow(`
-			INSERT INTO models (library_id, name, path) 
-			VALUES ($1, $2, $3) 
-			ON CONFLICT (library_id, path) DO UPDATE 
-			SET updated_at = NOW() 
-			RETURNING id
-		`, p.LibraryID, modelName, modelPath).Scan(&modelID)
-		
-		if err != nil {
+		model := models.Model{LibraryID: p.LibraryID, Name: modelName, Path: modelPath}
+		if err := st.Models().Ensure(ctx, &model); err != nil {
+			continue
+		}
+		// Deleted models stay in the trash until restored.
+		if model.DeletedAt != nil {
 			continue
 		}
+		modelID := model.ID
 
 		for _, file := range dirFiles {
-			_, err = db.Exec(`
-				INSERT INTO model_files (model_id, filename, path, size, mime_type, digest) 
-				VALUES ($1, $2, $3, $4, $5, $6) 
-				ON CONFLICT (path) DO UPDATE SET size = $4, digest = $6
-			`, modelID, filepath.Base(file.Path), file.Path, file.Size, file.MimeType, file.Digest)
-			
-			if err == nil {
+			f := models.ModelFile{
+				ModelID:  modelID,
+				Filename: filepath.Base(file.Path),
+				Path:     file.Path,
+				Size:     file.Size,
+				MimeType: &file.MimeType,
+				Digest:   &file.Digest,
+				Format:   &file.Format,
+				Width:    file.Width,
+				Depth:    file.Depth,
+				Height:   file.Height,
+			}
+			if err := st.Files().Upsert(ctx, &f); err == nil {
 				added++
 			}
 		}
-		
-		setDefaultPreview(db, modelID)
+
+		store.ChooseDefaultPreview(ctx, st, modelID)
+		st.Models().RefreshStats(ctx, modelID)
+		if err := UpdateDescriptor(db, modelID); err != nil {
+			log.Printf("Descriptor for model %d: %v", modelID, err)
+		}
+		if err := UpdateThumbnail(db, modelID); err != nil {
+			log.Printf("Thumbnail for model %d: %v", modelID, err)
+		}
 	}
 
 	log.Printf("Scan complete: %d files scanned, %d models, %d files added", len(files), len(modelDirs), added)
+	// Scans run as the system, whoever queued them.
+	err = audit.Record(auth.WithUser(ctx, auth.System), st, audit.Scan, "library", p.LibraryID, nil, map[string]int{
+		"files":       len(files),
+		"models":      len(modelDirs),
+		"files_added": added,
+	})
+	if err != nil {
+		log.Printf("Audit log: scan of library %d: %v", p.LibraryID, err)
+	}
 	return nil
 }
 
-func NewClient() *asynq.Client {
-	return asynq.NewClient(asynq.RedisClientOpt{Addr: "localhost:6379"})
+func NewClient(redisAddr string) *asynq.Client {
+	return asynq.NewClient(asynq.RedisClientOpt{Addr: redisAddr})
 }
 
-func NewServer(db *sqlx.DB) *asynq.ServeMux {
+func NewServer(st store.Store, db *sqlx.DB) *asynq.ServeMux {
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(TypeScanLibrary, func(ctx context.Context, t *asynq.Task) error {
-		return HandleScanLibraryTask(ctx, t, db)
+		return HandleScanLibraryTask(ctx, t, st, db)
+	})
+	mux.HandleFunc(TypeBulk, func(ctx context.Context, t *asynq.Task) error {
+		return HandleBulkTask(ctx, t, st, db)
+	})
+	mux.HandleFunc(TypePurgeTrash, func(ctx context.Context, t *asynq.Task) error {
+		return HandlePurgeTrashTask(ctx, t, st)
 	})
 	return mux
 }
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"net"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Queue accepts tasks for background processing. *asynq.Client implements
+// it, and so does Embedded for installs without Redis.
+type Queue interface {
+	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+	Close() error
+}
+
+// UseEmbedded reports whether to run jobs through Embedded rather than the
+// Redis at redisAddr. mode "redis" or "embedded" decides; when it is empty,
+// the embedded queue is used if Redis does not accept a connection.
+func UseEmbedded(mode, redisAddr string) bool {
+	switch mode {
+	case "redis":
+		return false
+	case "embedded":
+		return true
+	}
+	conn, err := net.DialTimeout("tcp", redisAddr, time.Second)
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"3d-library/internal/imagesim"
+	"3d-library/internal/mesh"
+	"3d-library/internal/safepath"
+	"3d-library/internal/thumbnail"
+	"database/sql"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UpdateThumbnail renders the model's thumbnail and stores its image search
+// features. The source is the preview file, else an image, else the largest
+// mesh. Nothing is done when the thumbnail exists and the source is unchanged.
+func UpdateThumbnail(db *sqlx.DB, modelID int64) error {
+	var file struct {
+		ID     int64   `db:"id"`
+		Path   string  `db:"path"`
+		Digest *string `db:"digest"`
+		Format string  `db:"format"`
+		Root   string  `db:"root"`
+	}
+	err := db.Get(&file, `
+		SELECT mf.id, mf.path, mf.digest, mf.format, l.path AS root FROM model_files mf
+		JOIN models m ON m.id = mf.model_id
+		JOIN libraries l ON l.id = m.library_id
+		WHERE mf.model_id = $1 AND mf.deleted_at IS NULL AND mf.format IN ('png', 'jpg', 'jpeg', 'stl', 'obj', '3mf')
+		ORDER BY (mf.id = m.preview_file_id) DESC, mf.format IN ('png', 'jpg', 'jpeg') DESC, mf.size DESC
+		LIMIT 1
+	`, modelID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored *string
+	err = db.Get(&stored, "SELECT source_digest FROM model_image_features WHERE model_id = $1 AND source_file_id = $2", modelID, file.ID)
+	if err == nil && stored != nil && file.Digest != nil && *stored == *file.Digest {
+		if _, statErr := os.Stat(thumbnail.Path(modelID)); statErr == nil {
+			return nil
+		}
+	}
+
+	// The file is opened where its symlinks lead, which must be inside the
+	// library, so a link swapped in afterwards is not followed.
+	f, err := safepath.Open(file.Root, file.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var thumb image.Image
+	if mesh.Supported(file.Path) {
+		m, err := mesh.Read(f, file.Path)
+		if err != nil {
+			return err
+		}
+		thumb = thumbnail.Render(m, thumbnail.Size)
+	} else {
+		src, err := thumbnail.Decode(f)
+		if err != nil {
+			return err
+		}
+		thumb = thumbnail.Fit(src, thumbnail.Size)
+	}
+
+	if err := thumbnail.Save(modelID, thumb); err != nil {
+		return err
+	}
+
+	features := imagesim.Extract(thumb)
+	color, _ := json.Marshal(features.Color)
+	edges, _ := json.Marshal(features.Edges)
+	_, err = db.Exec(`
+		INSERT INTO model_image_features (model_id, source_file_id, source_digest, phash, color, edges)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (model_id) DO UPDATE SET
+			source_file_id = EXCLUDED.source_file_id, source_digest = EXCLUDED.source_digest,
+			phash = EXCLUDED.phash, color = EXCLUDED.color, edges = EXCLUDED.edges, computed_at = NOW()
+	`, modelID, file.ID, file.Digest, int64(features.Hash), string(color), string(edges))
+	return err
+}
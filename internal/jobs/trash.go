@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"3d-library/internal/audit"
+	"3d-library/internal/auth"
+	"3d-library/internal/models"
+	"3d-library/internal/safepath"
+	"3d-library/internal/store"
+	"3d-library/internal/thumbnail"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const TypePurgeTrash = "trash:purge"
+
+// purgeEvery is how often the web server queues a purge.
+const purgeEvery = time.Hour
+
+type PurgeTrashPayload struct {
+	RetentionDays int `json:"retention_days"`
+	// RemoveFiles also deletes the files of purged libraries and models
+	// from disk. Copies in a library's .trash folder always go.
+	RemoveFiles bool `json:"remove_files"`
+}
+
+func NewPurgeTrashTask(retentionDays int, removeFiles bool) (*asynq.Task, error) {
+	payload, err := json.Marshal(PurgeTrashPayload{RetentionDays: retentionDays, RemoveFiles: removeFiles})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypePurgeTrash, payload), nil
+}
+
+// SchedulePurges queues a purge of the trash now and every hour until ctx
+// is done. Several servers may run it; the queue keeps one purge at a time.
+// A retention of 0 keeps the trash forever and schedules nothing.
+func SchedulePurges(ctx context.Context, q Queue, retentionDays int, removeFiles bool) {
+	if retentionDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(purgeEvery)
+	defer ticker.Stop()
+	for {
+		task, err := NewPurgeTrashTask(retentionDays, removeFiles)
+		if err == nil {
+			_, err = q.Enqueue(task, asynq.Unique(purgeEvery))
+		}
+		if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+			log.Printf("Trash purge: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// HandlePurgeTrashTask removes for good what has been in the trash longer
+// than the retention period. Each library, model and file goes in its own
+// transaction, so a failure leaves the rest purged and the task retries.
+func HandlePurgeTrashTask(ctx context.Context, t *asynq.Task, st store.Store) error {
+	var p PurgeTrashPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return err
+	}
+	// Purges run as the system, like scans.
+	ctx = auth.WithUser(ctx, auth.System)
+	cutoff := time.Now().UTC().AddDate(0, 0, -p.RetentionDays)
+	items, err := st.Trash().List(ctx, store.TrashFilter{Before: cutoff})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	purged := map[string]int{}
+	purge := func(entity string, id int64, before interface{}, fn func(tx store.Store) ([]models.ModelFile, error)) ([]models.ModelFile, bool) {
+		var files []models.ModelFile
+		err := st.InTx(ctx, func(tx store.Store) error {
+			var err error
+			if files, err = fn(tx); err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, audit.Purge, entity, id, before, nil)
+		})
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				errs = append(errs, err)
+			}
+			return nil, false
+		}
+		purged[entity]++
+		return files, true
+	}
+
+	for _, l := range items.Libraries {
+		files, ok := purge("library", l.ID, l, func(tx store.Store) ([]models.ModelFile, error) {
+			return tx.Libraries().Purge(ctx, l.ID)
+		})
+		if ok {
+			removeThumbnails(files)
+			removeFiles(l.Path, files, p.RemoveFiles)
+		}
+	}
+	for _, m := range items.Models {
+		root := ""
+		if l, err := st.Libraries().Get(ctx, m.LibraryID); err == nil {
+			root = l.Path
+		}
+		files, ok := purge("model", m.ID, m, func(tx store.Store) ([]models.ModelFile, error) {
+			return tx.Models().Purge(ctx, m.ID)
+		})
+		if ok {
+			os.Remove(thumbnail.Path(m.ID))
+			removeFiles(root, files, p.RemoveFiles)
+		}
+	}
+	for _, f := range items.Files {
+		root := ""
+		if m, err := st.Models().Get(ctx, f.ModelID); err == nil {
+			if l, err := st.Libraries().Get(ctx, m.LibraryID); err == nil {
+				root = l.Path
+			}
+		}
+		files, ok := purge("file", f.ID, f, func(tx store.Store) ([]models.ModelFile, error) {
+			file, err := tx.Files().Purge(ctx, f.ID)
+			if err != nil {
+				return nil, err
+			}
+			return []models.ModelFile{*file}, nil
+		})
+		if ok {
+			// A file on its own was either forgotten, and stays on disk, or
+			// deleted from disk into the library trash.
+			removeFiles(root, files, false)
+		}
+	}
+
+	log.Printf("Trash purge: %d libraries, %d models and %d files deleted before %s",
+		purged["library"], purged["model"], purged["file"], cutoff.Format(time.DateOnly))
+	return errors.Join(errs...)
+}
+
+// removeFiles deletes the copies of purged files in the library trash,
+// and with all the files themselves, then the folders they leave empty.
+// Nothing outside root is touched, and nothing at all when root is unknown.
+func removeFiles(root string, files []models.ModelFile, all bool) {
+	if root == "" {
+		return
+	}
+	dirs := map[string]bool{}
+	remove := func(path string) {
+		if err := safepath.Remove(root, path); err != nil {
+			log.Printf("Trash purge: %s: %v", path, err)
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for _, f := range files {
+		if f.TrashPath != nil {
+			remove(*f.TrashPath)
+		} else if all {
+			remove(f.Path)
+		}
+	}
+	for dir := range dirs {
+		// Fails, as it should, while anything is left inside, and for
+		// the library root itself.
+		safepath.Remove(root, dir)
+	}
+}
+
+// removeThumbnails drops the cached thumbnails of the files' models.
+func removeThumbnails(files []models.ModelFile) {
+	done := map[int64]bool{}
+	for _, f := range files {
+		if !done[f.ModelID] {
+			done[f.ModelID] = true
+			os.Remove(thumbnail.Path(f.ModelID))
+		}
+	}
+}
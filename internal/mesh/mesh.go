@@ -0,0 +1,93 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Vec3 [3]float64
+
+type Triangle [3]Vec3
+
+type Mesh struct {
+	Triangles []Triangle
+}
+
+// Load parses an STL, OBJ or 3MF file. Coordinates are returned as stored in
+// the file, which for all three formats is millimetres by convention.
+func Load(path string) (*Mesh, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, path)
+}
+
+// Read parses an open STL, OBJ or 3MF file, with the format taken from
+// name's extension.
+func Read(f *os.File, name string) (*Mesh, error) {
+	var m *Mesh
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".stl":
+		m, err = readSTL(f)
+	case ".obj":
+		m, err = readOBJ(f)
+	case ".3mf":
+		info, statErr := f.Stat()
+		if statErr != nil {
+			return nil, statErr
+		}
+		m, err = read3MF(f, info.Size())
+	default:
+		return nil, fmt.Errorf("unsupported mesh format: %s", filepath.Ext(name))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Triangles) == 0 {
+		return nil, fmt.Errorf("%s: mesh has no triangles", filepath.Base(name))
+	}
+	return m, nil
+}
+
+func Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".stl", ".obj", ".3mf":
+		return true
+	}
+	return false
+}
+
+func (m *Mesh) Bounds() (min, max Vec3) {
+	min = Vec3{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max = Vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, t := range m.Triangles {
+		for _, v := range t {
+			for i := 0; i < 3; i++ {
+				min[i] = math.Min(min[i], v[i])
+				max[i] = math.Max(max[i], v[i])
+			}
+		}
+	}
+	return min, max
+}
+
+// Dimensions returns the size of the axis-aligned bounding box as
+// width (X), depth (Y) and height (Z).
+func (m *Mesh) Dimensions() Vec3 {
+	min, max := m.Bounds()
+	return Vec3{max[0] - min[0], max[1] - min[1], max[2] - min[2]}
+}
+
+func (a Vec3) Sub(b Vec3) Vec3 {
+	return Vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a Vec3) Len() float64 {
+	return math.Sqrt(a[0]*a[0] + a[1]*a[1] + a[2]*a[2])
+}
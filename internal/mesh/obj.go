@@ -0,0 +1,65 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func readOBJ(r io.Reader) (*Mesh, error) {
+	m := &Mesh{}
+	var verts []Vec3
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("obj: malformed vertex line")
+			}
+			var v Vec3
+			for c := 0; c < 3; c++ {
+				f, err := strconv.ParseFloat(fields[c+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("obj: %w", err)
+				}
+				v[c] = f
+			}
+			verts = append(verts, v)
+		case "f":
+			var idx []int
+			for _, ref := range fields[1:] {
+				// Faces may be written as v, v/vt, v//vn or v/vt/vn.
+				s := ref
+				if i := strings.IndexByte(ref, '/'); i >= 0 {
+					s = ref[:i]
+				}
+				i, err := strconv.Atoi(s)
+				if err != nil {
+					return nil, fmt.Errorf("obj: %w", err)
+				}
+				if i < 0 {
+					i = len(verts) + i
+				} else {
+					i--
+				}
+				if i < 0 || i >= len(verts) {
+					return nil, fmt.Errorf("obj: face references missing vertex %s", ref)
+				}
+				idx = append(idx, i)
+			}
+			// Fan-triangulate polygons.
+			for k := 1; k+1 < len(idx); k++ {
+				m.Triangles = append(m.Triangles, Triangle{verts[idx[0]], verts[idx[k]], verts[idx[k+1]]})
+			}
+		}
+	}
+	return m, sc.Err()
+}
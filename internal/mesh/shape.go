@@ -0,0 +1,69 @@
+package mesh
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ShapeDistribution returns the D2 shape distribution of the mesh: a
+// histogram of distances between random point pairs on the surface,
+// normalised by the bounding box diagonal so it does not depend on scale.
+// Sampling is seeded, so the same mesh always gives the same histogram.
+func (m *Mesh) ShapeDistribution(bins, samples int) []float64 {
+	hist := make([]float64, bins)
+	if len(m.Triangles) == 0 {
+		return hist
+	}
+
+	// Sample triangles proportionally to their area.
+	cum := make([]float64, len(m.Triangles))
+	total := 0.0
+	for i, t := range m.Triangles {
+		total += triangleArea(t)
+		cum[i] = total
+	}
+	min, max := m.Bounds()
+	diag := max.Sub(min).Len()
+	if total == 0 || diag == 0 {
+		return hist
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	point := func() Vec3 {
+		i := sort.SearchFloat64s(cum, rng.Float64()*total)
+		if i >= len(m.Triangles) {
+			i = len(m.Triangles) - 1
+		}
+		t := m.Triangles[i]
+		r1, r2 := math.Sqrt(rng.Float64()), rng.Float64()
+		var p Vec3
+		for c := 0; c < 3; c++ {
+			p[c] = (1-r1)*t[0][c] + r1*(1-r2)*t[1][c] + r1*r2*t[2][c]
+		}
+		return p
+	}
+
+	for i := 0; i < samples; i++ {
+		d := point().Sub(point()).Len() / diag
+		b := int(d * float64(bins))
+		if b >= bins {
+			b = bins - 1
+		}
+		hist[b]++
+	}
+	for i := range hist {
+		hist[i] /= float64(samples)
+	}
+	return hist
+}
+
+func triangleArea(t Triangle) float64 {
+	a, b := t[1].Sub(t[0]), t[2].Sub(t[0])
+	cross := Vec3{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+	return cross.Len() / 2
+}
@@ -0,0 +1,102 @@
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func readSTL(r io.Reader) (*Mesh, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	header, err := br.Peek(84)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Many binary exporters also start the header with "solid", so trust the
+	// triangle count when it matches the remaining data.
+	if len(header) == 84 {
+		count := binary.LittleEndian.Uint32(header[80:84])
+		if !bytes.HasPrefix(bytes.TrimSpace(header), []byte("solid")) || looksBinary(header, count) {
+			return readBinarySTL(br, count)
+		}
+	}
+	return readASCIISTL(br)
+}
+
+func looksBinary(header []byte, count uint32) bool {
+	for _, b := range header[:80] {
+		if b == 0 {
+			return true
+		}
+	}
+	return count > 0 && !bytes.Contains(header, []byte("facet"))
+}
+
+func readBinarySTL(r io.Reader, count uint32) (*Mesh, error) {
+	if _, err := io.CopyN(io.Discard, r, 84); err != nil {
+		return nil, err
+	}
+
+	m := &Mesh{Triangles: make([]Triangle, 0, count)}
+	buf := make([]byte, 50)
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		var t Triangle
+		for v := 0; v < 3; v++ {
+			for c := 0; c < 3; c++ {
+				off := 12 + v*12 + c*4
+				t[v][c] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[off : off+4])))
+			}
+		}
+		m.Triangles = append(m.Triangles, t)
+	}
+	return m, nil
+}
+
+func readASCIISTL(r io.Reader) (*Mesh, error) {
+	m := &Mesh{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var t Triangle
+	n := 0
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "vertex":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("stl: malformed vertex line")
+			}
+			if n < 3 {
+				for c := 0; c < 3; c++ {
+					v, err := strconv.ParseFloat(fields[c+1], 64)
+					if err != nil {
+						return nil, fmt.Errorf("stl: %w", err)
+					}
+					t[n][c] = v
+				}
+			}
+			n++
+		case "endfacet":
+			if n >= 3 {
+				m.Triangles = append(m.Triangles, t)
+			}
+			n = 0
+		}
+	}
+	return m, sc.Err()
+}
@@ -0,0 +1,102 @@
+package mesh
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var unitScale = map[string]float64{
+	"micron":     0.001,
+	"millimeter": 1,
+	"centimeter": 10,
+	"inch":       25.4,
+	"foot":       304.8,
+	"meter":      1000,
+}
+
+func read3MF(r io.ReaderAt, size int64) (*Mesh, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("3mf: %w", err)
+	}
+
+	m := &Mesh{}
+	for _, f := range zr.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".model") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("3mf: %w", err)
+		}
+		err = decode3MFModel(rc, m)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// decode3MFModel streams the model XML so large meshes do not have to be
+// unmarshalled into an intermediate tree first.
+func decode3MFModel(r io.Reader, m *Mesh) error {
+	dec := xml.NewDecoder(r)
+	scale := 1.0
+	var verts []Vec3
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("3mf: %w", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch el.Name.Local {
+		case "model":
+			if s, ok := unitScale[attr(el, "unit")]; ok {
+				scale = s
+			}
+		case "mesh":
+			verts = verts[:0]
+		case "vertex":
+			var v Vec3
+			for c, name := range []string{"x", "y", "z"} {
+				f, err := strconv.ParseFloat(attr(el, name), 64)
+				if err != nil {
+					return fmt.Errorf("3mf: bad vertex %s: %w", name, err)
+				}
+				v[c] = f * scale
+			}
+			verts = append(verts, v)
+		case "triangle":
+			var t Triangle
+			for k, name := range []string{"v1", "v2", "v3"} {
+				i, err := strconv.Atoi(attr(el, name))
+				if err != nil || i < 0 || i >= len(verts) {
+					return fmt.Errorf("3mf: bad triangle index %s", name)
+				}
+				t[k] = verts[i]
+			}
+			m.Triangles = append(m.Triangles, t)
+		}
+	}
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
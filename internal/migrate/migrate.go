@@ -0,0 +1,382 @@
+// Package migrate applies the embedded schema migrations. It reads goose
+// annotations and keeps goose's goose_db_version table, so databases
+// migrated with the goose CLI carry on from where they are.
+package migrate
+
+import (
+	"3d-library/internal/database"
+	"3d-library/migrations"
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNoHistory means the database has tables but no goose_db_version, so
+// which migrations it has is unknown.
+var ErrNoHistory = errors.New("database has tables but no migration history")
+
+// Migration is one numbered file such as 009_preview_file.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	up      []string
+	down    []string
+}
+
+// Status is a migration and whether it has been applied. Migrations the
+// database has but this build does not know about have an empty Name.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load returns the migrations for db's dialect in version order.
+func Load(db *sqlx.DB) ([]Migration, error) {
+	dir := "."
+	if database.IsSQLite(db) {
+		dir = "sqlite"
+	}
+	entries, err := fs.ReadDir(migrations.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		num, _, _ := strings.Cut(e.Name(), "_")
+		version, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", e.Name())
+		}
+		src, err := fs.ReadFile(migrations.FS, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, err := parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		m.Version, m.Name = version, strings.TrimSuffix(e.Name(), ".sql")
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s share a version", list[i-1].Name, list[i].Name)
+		}
+	}
+	return list, nil
+}
+
+// parse splits a goose file into its Up and Down statements. Statements end
+// at a line ending in ";" unless they sit between StatementBegin and
+// StatementEnd.
+func parse(src string) (Migration, error) {
+	var m Migration
+	var section *[]string
+	var stmt strings.Builder
+	inBlock := false
+
+	flush := func() {
+		if s := strings.TrimSpace(stmt.String()); s != "" && section != nil {
+			*section = append(*section, s)
+		}
+		stmt.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "-- +goose ") {
+			switch strings.TrimPrefix(trimmed, "-- +goose ") {
+			case "Up":
+				flush()
+				section = &m.up
+			case "Down":
+				flush()
+				section = &m.down
+			case "StatementBegin":
+				flush()
+				inBlock = true
+			case "StatementEnd":
+				inBlock = false
+				flush()
+			default:
+				return m, fmt.Errorf("unsupported annotation %q", trimmed)
+			}
+			continue
+		}
+		if section == nil || (!inBlock && (trimmed == "" || strings.HasPrefix(trimmed, "--"))) {
+			continue
+		}
+		stmt.WriteString(line)
+		stmt.WriteByte('\n')
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	if inBlock {
+		return m, errors.New("StatementBegin without StatementEnd")
+	}
+	flush()
+	if m.up == nil && m.down == nil {
+		return m, errors.New("no -- +goose Up section")
+	}
+	return m, scanner.Err()
+}
+
+// Up applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied.
+func Up(db *sqlx.DB) ([]Migration, error) {
+	all, applied, err := state(db, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNewer(all, applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range all {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := run(db, m.Version, m.up, true); err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration and returns it, or
+// nil when none is applied.
+func Down(db *sqlx.DB) (*Migration, error) {
+	all, applied, err := state(db, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNewer(all, applied); err != nil {
+		return nil, err
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		m := all[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := run(db, m.Version, m.down, false); err != nil {
+			return nil, fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+		return &m, nil
+	}
+	return nil, nil
+}
+
+// Force records version and everything before it as applied without
+// running anything, for databases created by hand whose schema is known to
+// match that version.
+func Force(db *sqlx.DB, version int64) error {
+	all, applied, err := state(db, true)
+	if errors.Is(err, ErrNoHistory) {
+		if err := createTable(db); err != nil {
+			return err
+		}
+		all, applied, err = state(db, true)
+	}
+	if err != nil {
+		return err
+	}
+	known := false
+	for _, m := range all {
+		if m.Version == version {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+	for _, m := range all {
+		if _, ok := applied[m.Version]; m.Version <= version && !ok {
+			if err := run(db, m.Version, nil, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// List reports every known migration, plus any newer ones recorded in the
+// database, in version order.
+func List(db *sqlx.DB) ([]Status, error) {
+	all, applied, err := state(db, false)
+	if err != nil {
+		return nil, err
+	}
+	var list []Status
+	for _, m := range all {
+		s := Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			s.Applied, s.AppliedAt = true, at
+			delete(applied, m.Version)
+		}
+		list = append(list, s)
+	}
+	for v, at := range applied {
+		list = append(list, Status{Version: v, Applied: true, AppliedAt: at})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Check verifies that this build understands the database. It fails when
+// the schema is newer than the newest embedded migration, and returns the
+// number of migrations still to apply.
+func Check(db *sqlx.DB) (pending int, err error) {
+	all, applied, err := state(db, false)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkNewer(all, applied); err != nil {
+		return 0, err
+	}
+	for _, m := range all {
+		if _, ok := applied[m.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func checkNewer(all []Migration, applied map[int64]*time.Time) error {
+	var latest int64
+	if len(all) > 0 {
+		latest = all[len(all)-1].Version
+	}
+	for v := range applied {
+		if v > latest {
+			return fmt.Errorf("database schema is at version %d but this build only knows up to %d; upgrade Go3D or restore a matching backup", v, latest)
+		}
+	}
+	return nil
+}
+
+// state loads the migrations and the applied versions with when they were
+// applied. With create, a missing version table is created on an empty
+// database; a database with tables but no history is ErrNoHistory.
+func state(db *sqlx.DB, create bool) ([]Migration, map[int64]*time.Time, error) {
+	all, err := Load(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := map[int64]*time.Time{}
+	exists, err := tableExists(db, "goose_db_version")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		hasSchema, err := tableExists(db, "models")
+		if err != nil {
+			return nil, nil, err
+		}
+		if hasSchema {
+			return nil, nil, fmt.Errorf("%w; if the schema matches migration N, run \"migrate force N\"", ErrNoHistory)
+		}
+		if !create {
+			return all, applied, nil
+		}
+		if err := createTable(db); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var rows []struct {
+		Version int64      `db:"version_id"`
+		Tstamp  *time.Time `db:"tstamp"`
+	}
+	if err := db.Select(&rows, "SELECT version_id, tstamp FROM goose_db_version WHERE is_applied AND version_id > 0"); err != nil {
+		return nil, nil, err
+	}
+	for _, r := range rows {
+		applied[r.Version] = r.Tstamp
+	}
+	return all, applied, nil
+}
+
+// createTable creates goose_db_version as goose itself would.
+func createTable(db *sqlx.DB) error {
+	ddl := `CREATE TABLE goose_db_version (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(id)
+	)`
+	if database.IsSQLite(db) {
+		ddl = `CREATE TABLE goose_db_version (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			version_id INTEGER NOT NULL,
+			is_applied INTEGER NOT NULL,
+			tstamp TIMESTAMP DEFAULT (datetime('now'))
+		)`
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(ddl); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, $1)", true); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// run executes stmts and records version as applied or, going down, not.
+func run(db *sqlx.DB, version int64, stmts []string, up bool) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, s := range stmts {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	if up {
+		_, err = tx.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, $2)", version, true)
+	} else {
+		_, err = tx.Exec("DELETE FROM goose_db_version WHERE version_id = $1", version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func tableExists(db *sqlx.DB, name string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	if database.IsSQLite(db) {
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1"
+	}
+	var n int
+	err := db.Get(&n, query, name)
+	return n > 0, err
+}
@@ -9,37 +9,173 @@ type Library struct {
 	Storage   string    `db:"storage" json:"storage"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// DeletedAt is set while the library is in the trash.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// QuotaMB caps the space the library's files take; nil is no cap.
+	QuotaMB *int64 `db:"quota_mb" json:"quota_mb"`
 }
 
 type Model struct {
-	ID            int64     `db:"id" json:"id"`
-	LibraryID     int64     `db:"library_id" json:"library_id"`
-	Name          string    `db:"name" json:"name"`
-	Path          string    `db:"path" json:"path"`
-	Description   *string   `db:"description" json:"description"`
-	PreviewFileID *int64    `db:"preview_file_id" json:"preview_file_id"`
-	CreatedAt     time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	ID            int64      `db:"id" json:"id"`
+	LibraryID     int64      `db:"library_id" json:"library_id"`
+	Name          string     `db:"name" json:"name"`
+	Path          string     `db:"path" json:"path"`
+	Description   *string    `db:"description" json:"description"`
+	PreviewFileID *int64     `db:"preview_file_id" json:"preview_file_id"`
+	TotalSize     int64      `db:"total_size" json:"total_size"`
+	Width         *float64   `db:"width" json:"width"`
+	Depth         *float64   `db:"depth" json:"depth"`
+	Height        *float64   `db:"height" json:"height"`
+	PrintCount    int        `db:"print_count" json:"print_count"`
+	LastPrintedAt *time.Time `db:"last_printed_at" json:"last_printed_at"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt     *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 type ModelFile struct {
+	ID        int64      `db:"id" json:"id"`
+	ModelID   int64      `db:"model_id" json:"model_id"`
+	Filename  string     `db:"filename" json:"filename"`
+	Path      string     `db:"path" json:"path"`
+	Size      int64      `db:"size" json:"size"`
+	MimeType  *string    `db:"mime_type" json:"mime_type"`
+	Digest    *string    `db:"digest" json:"digest"`
+	Format    *string    `db:"format" json:"format"`
+	Width     *float64   `db:"width" json:"width"`
+	Depth     *float64   `db:"depth" json:"depth"`
+	Height    *float64   `db:"height" json:"height"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// TrashPath is where the file went in its library's .trash folder when
+	// it was deleted from disk.
+	TrashPath *string `db:"trash_path" json:"trash_path,omitempty"`
+	// UploadedBy is the user who uploaded the file; scanned files have
+	// none.
+	UploadedBy *int64 `db:"uploaded_by" json:"uploaded_by,omitempty"`
+}
+
+// Collection is a manual list of models, or a smart collection when Query is
+// set, in which case members are whatever the search query matches.
+type Collection struct {
 	ID        int64     `db:"id" json:"id"`
-	ModelID   int64     `db:"model_id" json:"model_id"`
-	Filename  string    `db:"filename" json:"filename"`
-	Path      string    `db:"path" json:"path"`
-	Size      int64     `db:"size" json:"size"`
-	MimeType  *string   `db:"mime_type" json:"mime_type"`
-	Digest    *string   `db:"digest" json:"digest"`
+	Name      string    `db:"name" json:"name"`
+	Query     *string   `db:"query" json:"query"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// UserID is the owner, nil for collections older than owners.
+	UserID *int64 `db:"user_id" json:"user_id"`
 }
 
-type Collection struct {
+type SavedSearch struct {
 	ID        int64     `db:"id" json:"id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
 	Name      string    `db:"name" json:"name"`
+	Query     string    `db:"query" json:"query"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
 type Tag struct {
-	ID   int64  `db:"id" json:"id"`
-	Name string `db:"name" json:"name"`
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type BulkJob struct {
+	ID         int64      `db:"id" json:"id"`
+	Operation  string     `db:"operation" json:"operation"`
+	Request    string     `db:"request" json:"-"`
+	Status     string     `db:"status" json:"status"`
+	Total      int        `db:"total" json:"total"`
+	Results    *string    `db:"results" json:"-"`
+	Error      *string    `db:"error" json:"error"`
+	UserID     *int64     `db:"user_id" json:"user_id"`
+	SourceIP   string     `db:"source_ip" json:"-"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at"`
+}
+
+type User struct {
+	ID           int64  `db:"id" json:"id"`
+	Username     string `db:"username" json:"username"`
+	PasswordHash string `db:"password_hash" json:"-"`
+	Admin        bool   `db:"admin" json:"admin"`
+	Disabled     bool   `db:"disabled" json:"disabled"`
+	// ExternalID is set for users who sign in through an identity
+	// provider.
+	ExternalID  *string    `db:"external_id" json:"external_id"`
+	LastLoginAt *time.Time `db:"last_login_at" json:"last_login_at"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// Session is a signed-in browser. ID is the hash of the cookie value.
+type Session struct {
+	ID        string    `db:"id" json:"-"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// APIToken is a personal token for scripts. Only its hash is kept; Prefix
+// is the start of the token so people can tell theirs apart.
+type APIToken struct {
+	ID         int64      `db:"id" json:"id"`
+	UserID     int64      `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name"`
+	Hash       string     `db:"token_hash" json:"-"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// ShareLink gives anyone holding its secret read-only access to a model or,
+// when CollectionID is set instead, to a collection.
+type ShareLink struct {
+	ID           int64      `db:"id" json:"id"`
+	UserID       int64      `db:"user_id" json:"user_id"`
+	ModelID      *int64     `db:"model_id" json:"model_id"`
+	CollectionID *int64     `db:"collection_id" json:"collection_id"`
+	Hash         string     `db:"token_hash" json:"-"`
+	Prefix       string     `db:"prefix" json:"prefix"`
+	PasswordHash string     `db:"password_hash" json:"-"`
+	ExpiresAt    *time.Time `db:"expires_at" json:"expires_at"`
+	MaxDownloads *int       `db:"max_downloads" json:"max_downloads"`
+	Downloads    int        `db:"downloads" json:"downloads"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// LibraryMember gives a user a role in one library: viewer, contributor,
+// editor or admin. Username is filled in when listing.
+type LibraryMember struct {
+	LibraryID int64     `db:"library_id" json:"library_id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Username  string    `db:"username" json:"username"`
+	Role      string    `db:"role" json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AuditEntry records one change: who made it, from where, and the entity
+// as JSON before and after. Actor is the username at the time, kept in case
+// the user is later removed.
+type AuditEntry struct {
+	ID        int64     `db:"id" json:"id"`
+	ActorID   *int64    `db:"actor_id" json:"actor_id"`
+	Actor     string    `db:"actor" json:"actor"`
+	Action    string    `db:"action" json:"action"`
+	Entity    string    `db:"entity" json:"entity"`
+	EntityID  *int64    `db:"entity_id" json:"entity_id"`
+	Before    *JSON     `db:"before" json:"before"`
+	After     *JSON     `db:"after" json:"after"`
+	SourceIP  string    `db:"source_ip" json:"source_ip"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// JSON is a JSON document kept as text, and written out as is.
+type JSON string
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	return []byte(j), nil
 }
@@ -0,0 +1,257 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far the provider's clock may be off from ours.
+const clockSkew = 2 * time.Minute
+
+// keySet is the provider's signing keys by key ID.
+type keySet struct {
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verify checks the ID token's signature, issuer, audience, expiry and
+// nonce, and returns its claims.
+func (p *Provider) verify(ctx context.Context, raw, nonce string) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("signature is not base64url")
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if strings.TrimSuffix(claims.String("iss"), "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("issued by %q, not %q", claims.String("iss"), p.cfg.Issuer)
+	}
+	aud := claims.Strings("aud")
+	if !contains(aud, p.cfg.ClientID) {
+		return nil, fmt.Errorf("not issued for client %q", p.cfg.ClientID)
+	}
+	if azp := claims.String("azp"); len(aud) > 1 && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("authorized party is %q, not %q", azp, p.cfg.ClientID)
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return nil, errors.New("issued in the future")
+	}
+	if claims.String("nonce") != nonce {
+		return nil, errors.New("nonce does not match")
+	}
+	if claims.String("sub") == "" {
+		return nil, errors.New("no subject")
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, out interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("not base64url")
+	}
+	return json.Unmarshal(b, out)
+}
+
+func checkSignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	if len(alg) == 5 {
+		switch alg[2:] {
+		case "256":
+			hash = crypto.SHA256
+		case "384":
+			hash = crypto.SHA384
+		case "512":
+			hash = crypto.SHA512
+		}
+	}
+	if hash == 0 {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			if rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil {
+				return nil
+			}
+		case "PS":
+			if rsa.VerifyPSS(k, hash, digest, sig, nil) == nil {
+				return nil
+			}
+		default:
+			return fmt.Errorf("algorithm %s does not match the RSA key", alg)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return fmt.Errorf("algorithm %s does not match the EC key", alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if ecdsa.Verify(k, digest, r, s) {
+			return nil
+		}
+	}
+	return errors.New("bad signature")
+}
+
+// key returns the signing key with the given ID, fetching the key set
+// again when it is unknown, as happens after the provider rotates keys.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+	if keys != nil {
+		if k := keys.find(kid); k != nil {
+			return k, nil
+		}
+		if time.Since(keys.fetched) < time.Minute {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("signing keys: %w", err)
+	}
+	keys = &keySet{keys: map[string]crypto.PublicKey{}, fetched: time.Now()}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys.keys[k.Kid] = pub
+		}
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	if k := keys.find(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// find looks a key up by ID. Tokens without a key ID match a set holding a
+// single key.
+func (ks *keySet) find(kid string) crypto.PublicKey {
+	if k, ok := ks.keys[kid]; ok {
+		return k
+	}
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k
+		}
+	}
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	num := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("bad key parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := num(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := num(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("bad RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := num(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := num(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
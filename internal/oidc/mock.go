@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mock is a minimal OpenID Connect provider for trying out and testing
+// single sign-on without a real identity provider. It signs anyone in:
+// as User when that is set, otherwise as whoever is typed into its login
+// form.
+type Mock struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// User and Groups sign every login in straight away, for scripts.
+	User   string
+	Groups []string
+
+	once  sync.Once
+	key   *rsa.PrivateKey
+	kid   string
+	mu    sync.Mutex
+	codes map[string]mockGrant
+	users map[string]mockGrant
+}
+
+type mockGrant struct {
+	username    string
+	groups      []string
+	nonce       string
+	challenge   string
+	redirectURI string
+	expires     time.Time
+}
+
+func (m *Mock) init() {
+	m.once.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		m.key = key
+		// A fresh key ID each run, so a restarted mock is not mistaken
+		// for the old one by clients caching its keys.
+		m.kid = mockSecret()[:8]
+		m.Issuer = strings.TrimSuffix(m.Issuer, "/")
+		m.codes = map[string]mockGrant{}
+		m.users = map[string]mockGrant{}
+	})
+}
+
+func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.init()
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeMockJSON(w, 200, map[string]interface{}{
+			"issuer":                                m.Issuer,
+			"authorization_endpoint":                m.Issuer + "/authorize",
+			"token_endpoint":                        m.Issuer + "/token",
+			"userinfo_endpoint":                     m.Issuer + "/userinfo",
+			"jwks_uri":                              m.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	case "/userinfo":
+		m.userinfo(w, r)
+	case "/jwks":
+		pub := m.key.PublicKey
+		writeMockJSON(w, 200, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "use": "sig", "alg": "RS256", "kid": m.kid,
+			"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var mockLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<title>Mock identity provider</title>
+<h1>Mock identity provider</h1>
+<form method="post">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}<p><label>Username <input name="username" autofocus required></label>
+<p><label>Groups <input name="groups" placeholder="comma separated"></label>
+<p><button>Sign in</button>
+</form>`))
+
+func (m *Mock) authorize(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	q := r.Form
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "redirect_uri is required", 400)
+		return
+	}
+	if q.Get("client_id") != m.ClientID || q.Get("response_type") != "code" {
+		http.Error(w, "unknown client_id or response_type is not code", 400)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", 400)
+		return
+	}
+
+	username, groups := m.User, m.Groups
+	if username == "" {
+		if r.Method != http.MethodPost || strings.TrimSpace(q.Get("username")) == "" {
+			params := url.Values{}
+			for _, k := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+				params.Set(k, q.Get(k))
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			mockLoginPage.Execute(w, map[string]interface{}{"Params": params})
+			return
+		}
+		username, groups = strings.TrimSpace(q.Get("username")), nil
+		for _, g := range strings.Split(q.Get("groups"), ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				groups = append(groups, g)
+			}
+		}
+	}
+
+	code := mockSecret()
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		username: username, groups: groups, nonce: q.Get("nonce"),
+		challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri"),
+		expires: time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	back := redirect.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *Mock) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", 405)
+		return
+	}
+	r.ParseForm()
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != m.ClientID || m.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(m.ClientSecret)) != 1 {
+		writeMockJSON(w, 401, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	m.mu.Lock()
+	grant, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		writeMockJSON(w, 400, map[string]string{"error": "unsupported_grant_type"})
+		return
+	case !ok || time.Now().After(grant.expires) || r.PostForm.Get("redirect_uri") != grant.redirectURI:
+		writeMockJSON(w, 400, map[string]string{"error": "invalid_grant"})
+		return
+	case base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge:
+		writeMockJSON(w, 400, map[string]string{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+		return
+	}
+
+	now := time.Now()
+	claims := grant.claims()
+	claims["iss"] = m.Issuer
+	claims["aud"] = m.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	if grant.nonce != "" {
+		claims["nonce"] = grant.nonce
+	}
+	idToken, err := m.sign(claims)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	access := mockSecret()
+	m.mu.Lock()
+	grant.expires = now.Add(5 * time.Minute)
+	m.users[access] = grant
+	m.mu.Unlock()
+	writeMockJSON(w, 200, map[string]interface{}{
+		"access_token": access, "token_type": "Bearer", "expires_in": 300, "id_token": idToken,
+	})
+}
+
+func (m *Mock) userinfo(w http.ResponseWriter, r *http.Request) {
+	access := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	m.mu.Lock()
+	grant, ok := m.users[access]
+	m.mu.Unlock()
+	if !ok || time.Now().After(grant.expires) {
+		writeMockJSON(w, 401, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeMockJSON(w, 200, grant.claims())
+}
+
+func (g mockGrant) claims() map[string]interface{} {
+	groups := g.groups
+	if groups == nil {
+		groups = []string{}
+	}
+	return map[string]interface{}{
+		"sub":                "mock-" + g.username,
+		"preferred_username": g.username,
+		"name":               g.username,
+		"email":              g.username + "@example.com",
+		"groups":             groups,
+	}
+}
+
+func (m *Mock) sign(claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": m.kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func mockSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeMockJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
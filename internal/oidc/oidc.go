@@ -0,0 +1,270 @@
+// Package oidc signs users in through an OpenID Connect provider with the
+// authorization code flow and PKCE. It needs only the provider's issuer
+// URL: endpoints and signing keys are discovered and cached.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Issuer   string
+	ClientID string
+	// ClientSecret is empty for public clients, which rely on PKCE alone.
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider talks to one OpenID Connect provider.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+type discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+func New(cfg Config) *Provider {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Flow is what a login must remember between AuthURL and Exchange, kept
+// by the browser that started it.
+type Flow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// NewFlow picks a random state, nonce and PKCE verifier.
+func NewFlow() (*Flow, error) {
+	var f Flow
+	for _, s := range []*string{&f.State, &f.Nonce, &f.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		*s = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &f, nil
+}
+
+// AuthURL is where to send the browser to sign in.
+func (p *Provider) AuthURL(ctx context.Context, f *Flow) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(f.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {f.State},
+		"nonce":                 {f.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Claims are the verified ID token claims, merged with the userinfo
+// response when the provider has one.
+type Claims map[string]interface{}
+
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings reads a claim holding a list of strings, or a single string.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// Exchange trades the code from the provider's redirect for tokens and
+// returns the verified claims. It checks the state first.
+func (p *Provider) Exchange(ctx context.Context, f *Flow, state, code string) (Claims, error) {
+	if state == "" || state != f.State {
+		return nil, errors.New("login state does not match; start again")
+	}
+	if code == "" {
+		return nil, errors.New("provider returned no code")
+	}
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {f.Verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	var tokens struct {
+		IDToken     string `json:"id_token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.do(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token exchange: provider returned no ID token")
+	}
+
+	claims, err := p.verify(ctx, tokens.IDToken, f.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("ID token: %w", err)
+	}
+	if d.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if err := p.userinfo(ctx, d.UserinfoEndpoint, tokens.AccessToken, claims); err != nil {
+			return nil, fmt.Errorf("userinfo: %w", err)
+		}
+	}
+	return claims, nil
+}
+
+// userinfo adds the claims the ID token left out, such as groups with some
+// providers.
+func (p *Provider) userinfo(ctx context.Context, endpoint, accessToken string, claims Claims) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	var info Claims
+	if err := p.do(req, &info); err != nil {
+		return err
+	}
+	if info.String("sub") != claims.String("sub") {
+		return errors.New("subject does not match the ID token")
+	}
+	for k, v := range info {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+	return nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	if err := p.do(req, &d); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery: provider says its issuer is %q, not %q", d.Issuer, p.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery: provider is missing the authorization, token or JWKS endpoint")
+	}
+	if len(d.CodeChallengeMethods) > 0 && !contains(d.CodeChallengeMethods, "S256") {
+		return nil, errors.New("discovery: provider does not support PKCE with S256")
+	}
+	p.discovery = &d
+	return &d, nil
+}
+
+// do sends req and decodes a JSON response, turning OAuth error bodies and
+// other failures into errors.
+func (p *Provider) do(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			if oauthErr.Description != "" {
+				return fmt.Errorf("%s: %s", oauthErr.Error, oauthErr.Description)
+			}
+			return errors.New(oauthErr.Error)
+		}
+		return fmt.Errorf("%s returned %s", req.URL.Redacted(), resp.Status)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
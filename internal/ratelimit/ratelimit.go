@@ -0,0 +1,113 @@
+// Package ratelimit counts requests in fixed windows, in Redis when the
+// server has one so that every server process shares the counts, or in
+// memory.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate is how many requests are allowed per period. A Rate of zero
+// requests allows any number.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Limiter counts a request against key and reports whether it is within
+// rate, and if not how long until the window ends.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error)
+	Close() error
+}
+
+// Memory keeps the counts of a single process.
+type Memory struct {
+	// Now tells the time; tests replace it.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]window
+	swept   time.Time
+}
+
+type window struct {
+	count int
+	ends  time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{Now: time.Now, windows: make(map[string]window)}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	if rate.Requests <= 0 {
+		return true, 0, nil
+	}
+	now := m.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Windows of clients that went quiet are dropped now and then.
+	if now.Sub(m.swept) > time.Minute {
+		for k, w := range m.windows {
+			if !now.Before(w.ends) {
+				delete(m.windows, k)
+			}
+		}
+		m.swept = now
+	}
+	w := m.windows[key]
+	if !now.Before(w.ends) {
+		w = window{ends: now.Add(rate.Per)}
+	}
+	w.count++
+	m.windows[key] = w
+	if w.count > rate.Requests {
+		return false, w.ends.Sub(now), nil
+	}
+	return true, 0, nil
+}
+
+func (m *Memory) Close() error { return nil }
+
+// Redis keeps the counts in Redis, under keys that expire with their
+// window.
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(addr string) *Redis {
+	return &Redis{client: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+// count increments a window and returns the count with the milliseconds
+// it has left, starting the window on its first request.
+var count = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {n, ttl}
+`)
+
+func (r *Redis) Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	if rate.Requests <= 0 {
+		return true, 0, nil
+	}
+	res, err := count.Run(ctx, r.client, []string{"go3d:ratelimit:" + key}, rate.Per.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if res[0] > int64(rate.Requests) {
+		return false, time.Duration(res[1]) * time.Millisecond, nil
+	}
+	return true, 0, nil
+}
+
+func (r *Redis) Close() error { return r.client.Close() }
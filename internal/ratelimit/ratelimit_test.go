@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a time that only moves when told to.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newMemory() (*Memory, *clock) {
+	c := &clock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	m := NewMemory()
+	m.Now = c.Now
+	return m, c
+}
+
+func allow(t *testing.T, m *Memory, key string, rate Rate) (bool, time.Duration) {
+	t.Helper()
+	ok, retry, err := m.Allow(context.Background(), key, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok, retry
+}
+
+func TestMemoryWindow(t *testing.T) {
+	m, c := newMemory()
+	rate := Rate{Requests: 3, Per: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := allow(t, m, "ip:192.0.2.1", rate); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+		c.Advance(10 * time.Second)
+	}
+	ok, retry := allow(t, m, "ip:192.0.2.1", rate)
+	if ok {
+		t.Fatal("fourth request allowed")
+	}
+	// The window started with the first request, 30 seconds ago.
+	if retry != 30*time.Second {
+		t.Errorf("retry after %v, want 30s", retry)
+	}
+
+	// Other keys have their own counts.
+	if ok, _ := allow(t, m, "ip:192.0.2.2", rate); !ok {
+		t.Error("another key refused")
+	}
+
+	c.Advance(29 * time.Second)
+	if ok, retry := allow(t, m, "ip:192.0.2.1", rate); ok || retry != time.Second {
+		t.Errorf("just before the window ends: allowed %v, retry %v", ok, retry)
+	}
+	c.Advance(time.Second)
+	if ok, _ := allow(t, m, "ip:192.0.2.1", rate); !ok {
+		t.Error("refused in a new window")
+	}
+}
+
+func TestMemoryUnlimited(t *testing.T) {
+	m, _ := newMemory()
+	for i := 0; i < 100; i++ {
+		if ok, _ := allow(t, m, "user:1", Rate{}); !ok {
+			t.Fatalf("request %d refused with no rate", i+1)
+		}
+	}
+	if len(m.windows) != 0 {
+		t.Errorf("counted %d windows with no rate", len(m.windows))
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	m, c := newMemory()
+	allow(t, m, "ip:192.0.2.1", Rate{Requests: 1, Per: time.Second})
+	allow(t, m, "ip:192.0.2.2", Rate{Requests: 1, Per: time.Hour})
+
+	c.Advance(2 * time.Minute)
+	allow(t, m, "ip:192.0.2.3", Rate{Requests: 1, Per: time.Second})
+	if _, ok := m.windows["ip:192.0.2.1"]; ok {
+		t.Error("ended window kept")
+	}
+	if _, ok := m.windows["ip:192.0.2.2"]; !ok {
+		t.Error("open window dropped")
+	}
+}
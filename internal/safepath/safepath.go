@@ -0,0 +1,354 @@
+// Package safepath keeps file operations on a library inside its root
+// directory, and library roots inside the configured Roots, however the
+// paths stored in the database or sent by clients were built. Every read
+// and write of library files goes through it.
+package safepath
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// TrashDir is the folder under a library root that files deleted from disk
+// are moved to. Scans skip it.
+const TrashDir = ".trash"
+
+// UploadsDir is the folder under a library root that uploads are written to
+// before they are moved into place. Scans skip it too.
+const UploadsDir = ".uploads"
+
+// Roots lists the directories libraries may be in, along with what is
+// below them. Empty allows none. server.Setup sets it from the
+// configuration, and server.DefaultRoots to the existing libraries when
+// that is empty.
+var Roots []string
+
+var (
+	ErrOutside      = errors.New("path is outside the library")
+	ErrOutsideRoots = errors.New("path is outside the allowed library roots")
+	// ErrNoRoots is ErrOutsideRoots when Roots is empty.
+	ErrNoRoots = fmt.Errorf("%w: none are set", ErrOutsideRoots)
+)
+
+// Root checks that root is an existing directory inside Roots, with
+// symlinks resolved, and returns the resolved path.
+func Root(root string) (string, error) {
+	if !filepath.IsAbs(root) {
+		return "", ErrOutsideRoots
+	}
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(real); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", root)
+	}
+	if len(Roots) == 0 {
+		return "", ErrNoRoots
+	}
+	for _, allowed := range Roots {
+		allowed, err := filepath.EvalSymlinks(allowed)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(allowed, real); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return real, nil
+		}
+	}
+	return "", ErrOutsideRoots
+}
+
+// Name cleans a single file or folder name sent by a client. The errors
+// read as validation messages.
+func Name(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("is required")
+	case strings.ContainsAny(name, `/\`):
+		return "", errors.New("must not contain path separators")
+	case strings.HasPrefix(name, "."):
+		return "", errors.New("must not start with a dot")
+	case strings.ContainsFunc(name, unicode.IsControl):
+		return "", errors.New("must not contain control characters")
+	}
+	return name, nil
+}
+
+// Clean turns a relative path from a client, such as a ZIP entry name,
+// into one that stays below whatever it is joined to. Either slash
+// separates folders, and every part must pass Name.
+func Clean(rel string) (string, error) {
+	if strings.HasPrefix(rel, "/") || strings.HasPrefix(rel, `\`) || filepath.VolumeName(rel) != "" || driveLetter(rel) {
+		return "", errors.New("must be relative")
+	}
+	var parts []string
+	for _, part := range strings.FieldsFunc(rel, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == "." {
+			continue
+		}
+		if part == ".." {
+			return "", errors.New("must not lead out of its folder")
+		}
+		name, err := Name(part)
+		if err != nil {
+			return "", fmt.Errorf("%q %v", part, err)
+		}
+		parts = append(parts, name)
+	}
+	if len(parts) == 0 {
+		return "", errors.New("is required")
+	}
+	return filepath.Join(parts...), nil
+}
+
+// Rel returns path relative to root, failing with ErrOutside unless path is
+// absolute and lies below root. It only looks at the names; use Within
+// before touching the file.
+func Rel(root, path string) (string, error) {
+	if !filepath.IsAbs(path) || !filepath.IsAbs(root) {
+		return "", ErrOutside
+	}
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrOutside
+	}
+	return rel, nil
+}
+
+// Within is Rel with the symlinks in root and in path's folders resolved,
+// so a link cannot lead outside the library. path itself may be a link,
+// since it is what gets moved or removed rather than its target.
+func Within(root, path string) (string, error) {
+	if _, err := Rel(root, path); err != nil {
+		return "", err
+	}
+	realRoot, err := Root(root)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(filepath.Clean(path)))
+	if err != nil {
+		return "", err
+	}
+	return Rel(realRoot, filepath.Join(dir, filepath.Base(path)))
+}
+
+// Trash moves the file at path into root's TrashDir, at the same place
+// relative to root, and returns where it went. A file already there of the
+// same name is kept by numbering the new one.
+func Trash(root, path string) (string, error) {
+	rel, err := Within(root, path)
+	if err != nil {
+		return "", err
+	}
+	if inTrash(rel) {
+		return "", fmt.Errorf("%s is already in the library trash", path)
+	}
+	realRoot, err := Root(root)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(realRoot, TrashDir, rel)
+	if err := mkdirIn(realRoot, filepath.Dir(filepath.Join(TrashDir, rel))); err != nil {
+		return "", err
+	}
+	for n := 1; exists(dest); n++ {
+		dest = fmt.Sprintf("%s.%d", filepath.Join(realRoot, TrashDir, rel), n)
+	}
+	if err := os.Rename(filepath.Join(realRoot, rel), dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// Untrash moves a file Trash put at trashed back to path. It fails with
+// os.ErrExist rather than overwrite a file there.
+func Untrash(root, trashed, path string) error {
+	rel, err := Within(root, trashed)
+	if err != nil {
+		return err
+	}
+	if !inTrash(rel) {
+		return fmt.Errorf("%s is not in the library trash", trashed)
+	}
+	dest, err := Rel(root, path)
+	if err != nil {
+		return err
+	}
+	realRoot, err := Root(root)
+	if err != nil {
+		return err
+	}
+	if err := mkdirIn(realRoot, filepath.Dir(dest)); err != nil {
+		return err
+	}
+	path = filepath.Join(realRoot, dest)
+	if exists(path) {
+		return fmt.Errorf("%s: %w", path, os.ErrExist)
+	}
+	return os.Rename(trashed, path)
+}
+
+// Resolve follows every symlink in path and returns where it leads, if that
+// is inside root.
+func Resolve(root, path string) (string, error) {
+	if _, err := Rel(root, path); err != nil {
+		return "", err
+	}
+	realRoot, err := Root(root)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := Rel(realRoot, real); err != nil {
+		return "", err
+	}
+	return real, nil
+}
+
+// Open opens the file at path for reading if it is inside root.
+func Open(root, path string) (*os.File, error) {
+	real, err := Resolve(root, path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(real)
+}
+
+// Mkdir creates the folders of rel, as returned by Clean, below root and
+// returns the path they make.
+func Mkdir(root, rel string) (string, error) {
+	realRoot, err := Root(root)
+	if err != nil {
+		return "", err
+	}
+	if _, err := Rel(realRoot, filepath.Join(realRoot, rel)); err != nil {
+		return "", err
+	}
+	if err := mkdirIn(realRoot, rel); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// Create opens the file at rel, as returned by Clean, below root for
+// writing, creating its folders and truncating what is there. It will not
+// write through a symlink.
+func Create(root, rel string) (*os.File, error) {
+	realRoot, err := Root(root)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(realRoot, rel)
+	if _, err := Rel(realRoot, path); err != nil {
+		return nil, err
+	}
+	if err := mkdirIn(realRoot, filepath.Dir(rel)); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil && !info.Mode().IsRegular() {
+		return nil, ErrOutside
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// Stage creates an empty folder in root's UploadsDir for an upload to
+// write to, and returns it relative to root.
+func Stage(root string) (string, error) {
+	realRoot, err := Root(root)
+	if err != nil {
+		return "", err
+	}
+	if err := mkdirIn(realRoot, UploadsDir); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(filepath.Join(realRoot, UploadsDir), "upload-")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(realRoot, dir)
+}
+
+// Move renames the file at from to to, both relative to root as Clean
+// returns them, creating to's folders. It replaces a regular file at to
+// but will not move onto anything else.
+func Move(root, from, to string) error {
+	realRoot, err := Root(root)
+	if err != nil {
+		return err
+	}
+	for _, rel := range []string{from, to} {
+		if _, err := Rel(realRoot, filepath.Join(realRoot, rel)); err != nil {
+			return err
+		}
+	}
+	if err := mkdirIn(realRoot, filepath.Dir(to)); err != nil {
+		return err
+	}
+	dest := filepath.Join(realRoot, to)
+	if info, err := os.Lstat(dest); err == nil && !info.Mode().IsRegular() {
+		return ErrOutside
+	}
+	return os.Rename(filepath.Join(realRoot, from), dest)
+}
+
+// Remove deletes the file, or empty folder, at path if it is inside root. A
+// missing file is not an error.
+func Remove(root, path string) error {
+	if _, err := Within(root, path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// mkdirIn creates the folders of rel below root one at a time, failing
+// with ErrOutside where one is a symlink rather than following it out.
+func mkdirIn(root, rel string) error {
+	dir := root
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if name == "." {
+			continue
+		}
+		dir = filepath.Join(dir, name)
+		if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return ErrOutside
+		}
+	}
+	return nil
+}
+
+// driveLetter reports whether rel starts like C:, which VolumeName only
+// catches on Windows but a ZIP made there may hold anywhere.
+func driveLetter(rel string) bool {
+	return len(rel) >= 2 && rel[1] == ':' && ('a' <= rel[0] && rel[0] <= 'z' || 'A' <= rel[0] && rel[0] <= 'Z')
+}
+
+func inTrash(rel string) bool {
+	return rel == TrashDir || strings.HasPrefix(rel, TrashDir+string(filepath.Separator))
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,238 @@
+package safepath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setRoots sets Roots for the length of the test.
+func setRoots(t *testing.T, roots ...string) {
+	t.Helper()
+	old := Roots
+	Roots = roots
+	t.Cleanup(func() { Roots = old })
+}
+
+func mkdir(t *testing.T, path string) string {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// layout makes a library folder and a folder outside it, with a file in
+// each, under a fresh temporary directory.
+func layout(t *testing.T) (lib, outside string) {
+	t.Helper()
+	dir := t.TempDir()
+	setRoots(t, dir)
+	lib = mkdir(t, filepath.Join(dir, "lib"))
+	outside = mkdir(t, filepath.Join(dir, "outside"))
+	writeFile(t, filepath.Join(lib, "model.stl"), "inside")
+	writeFile(t, filepath.Join(outside, "secret"), "outside")
+	return lib, outside
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		rel, want string
+	}{
+		{"model.stl", "model.stl"},
+		{"parts/arm.stl", filepath.Join("parts", "arm.stl")},
+		{`parts\arm.stl`, filepath.Join("parts", "arm.stl")},
+		{"./parts//arm.stl", filepath.Join("parts", "arm.stl")},
+	}
+	for _, tt := range tests {
+		got, err := Clean(tt.rel)
+		if err != nil || got != tt.want {
+			t.Errorf("Clean(%q) = %q, %v; want %q", tt.rel, got, err, tt.want)
+		}
+	}
+
+	for _, rel := range []string{
+		"",
+		".",
+		"..",
+		"../x",
+		"../../x",
+		"parts/../../x",
+		`parts\..\..\x`,
+		"/etc/passwd",
+		`\Windows\x`,
+		"C:/x",
+		`c:\x`,
+		"C:x",
+		".hidden",
+		"parts/.git/config",
+		"bad\x00name",
+	} {
+		if got, err := Clean(rel); err == nil {
+			t.Errorf("Clean(%q) = %q, want an error", rel, got)
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	dir := t.TempDir()
+	allowed := mkdir(t, filepath.Join(dir, "allowed"))
+	lib := mkdir(t, filepath.Join(allowed, "lib"))
+	other := mkdir(t, filepath.Join(dir, "other"))
+	// A link inside the allowed root that leads out of it.
+	symlink(t, other, filepath.Join(allowed, "escape"))
+	// A root configured through a link.
+	symlink(t, allowed, filepath.Join(dir, "allowed-link"))
+
+	setRoots(t, allowed)
+	if got, err := Root(lib); err != nil || got != lib {
+		t.Errorf("Root(%s) = %q, %v", lib, got, err)
+	}
+	for _, root := range []string{other, filepath.Join(allowed, "escape"), dir} {
+		if _, err := Root(root); !errors.Is(err, ErrOutsideRoots) {
+			t.Errorf("Root(%s): got %v, want ErrOutsideRoots", root, err)
+		}
+	}
+	if _, err := Root("allowed/lib"); !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("relative root: got %v, want ErrOutsideRoots", err)
+	}
+	if _, err := Root(filepath.Join(lib, "missing")); err == nil {
+		t.Error("missing root accepted")
+	}
+
+	setRoots(t)
+	if _, err := Root(lib); !errors.Is(err, ErrNoRoots) || !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("Root(%s) with no roots: got %v, want ErrNoRoots", lib, err)
+	}
+
+	setRoots(t, filepath.Join(dir, "allowed-link"))
+	if got, err := Root(lib); err != nil || got != lib {
+		t.Errorf("Root(%s) under a linked root = %q, %v", lib, got, err)
+	}
+	if _, err := Root(other); !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("Root(%s) under a linked root: got %v, want ErrOutsideRoots", other, err)
+	}
+}
+
+func TestWithinSymlinkedFolder(t *testing.T) {
+	lib, outside := layout(t)
+	symlink(t, outside, filepath.Join(lib, "link"))
+	symlink(t, filepath.Join(outside, "secret"), filepath.Join(lib, "secret.stl"))
+
+	through := filepath.Join(lib, "link", "secret")
+	if _, err := Within(lib, through); !errors.Is(err, ErrOutside) {
+		t.Errorf("Within through a linked folder: got %v, want ErrOutside", err)
+	}
+	if _, err := Resolve(lib, through); !errors.Is(err, ErrOutside) {
+		t.Errorf("Resolve through a linked folder: got %v, want ErrOutside", err)
+	}
+	if _, err := Open(lib, through); !errors.Is(err, ErrOutside) {
+		t.Errorf("Open through a linked folder: got %v, want ErrOutside", err)
+	}
+
+	// The link itself is inside and may be moved or removed, but not
+	// followed.
+	link := filepath.Join(lib, "secret.stl")
+	if rel, err := Within(lib, link); err != nil || rel != "secret.stl" {
+		t.Errorf("Within(link) = %q, %v", rel, err)
+	}
+	if _, err := Resolve(lib, link); !errors.Is(err, ErrOutside) {
+		t.Errorf("Resolve(link): got %v, want ErrOutside", err)
+	}
+
+	for _, path := range []string{filepath.Join(lib, "..", "outside", "secret"), lib, "model.stl"} {
+		if _, err := Within(lib, path); !errors.Is(err, ErrOutside) {
+			t.Errorf("Within(%s): got %v, want ErrOutside", path, err)
+		}
+	}
+	if real, err := Resolve(lib, filepath.Join(lib, "model.stl")); err != nil || real != filepath.Join(lib, "model.stl") {
+		t.Errorf("Resolve(model.stl) = %q, %v", real, err)
+	}
+}
+
+func TestCreateRefusesSymlinks(t *testing.T) {
+	lib, outside := layout(t)
+	symlink(t, filepath.Join(outside, "secret"), filepath.Join(lib, "secret.stl"))
+	symlink(t, outside, filepath.Join(lib, "link"))
+
+	if _, err := Create(lib, "secret.stl"); !errors.Is(err, ErrOutside) {
+		t.Errorf("Create onto a link: got %v, want ErrOutside", err)
+	}
+	if _, err := Create(lib, filepath.Join("link", "new.stl")); !errors.Is(err, ErrOutside) {
+		t.Errorf("Create through a linked folder: got %v, want ErrOutside", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "secret")); string(data) != "outside" {
+		t.Errorf("file outside the library now holds %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.stl")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file created outside the library: %v", err)
+	}
+
+	f, err := Create(lib, filepath.Join("parts", "arm.stl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := os.Stat(filepath.Join(lib, "parts", "arm.stl")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMoveRefusesSymlinks(t *testing.T) {
+	lib, outside := layout(t)
+	symlink(t, filepath.Join(outside, "secret"), filepath.Join(lib, "secret.stl"))
+	symlink(t, outside, filepath.Join(lib, "link"))
+
+	if err := Move(lib, "model.stl", "secret.stl"); !errors.Is(err, ErrOutside) {
+		t.Errorf("Move onto a link: got %v, want ErrOutside", err)
+	}
+	if err := Move(lib, "model.stl", filepath.Join("link", "model.stl")); !errors.Is(err, ErrOutside) {
+		t.Errorf("Move through a linked folder: got %v, want ErrOutside", err)
+	}
+	if err := Move(lib, "model.stl", filepath.Join("..", "outside", "model.stl")); !errors.Is(err, ErrOutside) {
+		t.Errorf("Move out of the library: got %v, want ErrOutside", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "secret")); string(data) != "outside" {
+		t.Errorf("file outside the library now holds %q", data)
+	}
+
+	if err := Move(lib, "model.stl", filepath.Join("parts", "model.stl")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(lib, "parts", "model.stl")); string(data) != "inside" {
+		t.Errorf("moved file holds %q", data)
+	}
+}
+
+func TestMkdirInRefusesSymlinks(t *testing.T) {
+	lib, outside := layout(t)
+	symlink(t, outside, filepath.Join(lib, "link"))
+
+	if err := mkdirIn(lib, filepath.Join("link", "sub")); !errors.Is(err, ErrOutside) {
+		t.Errorf("mkdirIn through a link: got %v, want ErrOutside", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "sub")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("folder created outside the library: %v", err)
+	}
+	if err := mkdirIn(lib, filepath.Join("model.stl", "sub")); err == nil {
+		t.Error("mkdirIn below a file succeeded")
+	}
+	if err := mkdirIn(lib, filepath.Join("a", "b")); err != nil {
+		t.Error(err)
+	}
+}
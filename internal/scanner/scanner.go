@@ -1,6 +1,8 @@
 package scanner
 
 import (
+	"3d-library/internal/mesh"
+	"3d-library/internal/safepath"
 	"crypto/sha256"
 	"fmt"
 	"io"
@@ -14,6 +16,10 @@ type FileInfo struct {
 	Size     int64
 	Digest   string
 	MimeType string
+	Format   string
+	Width    *float64
+	Depth    *float64
+	Height   *float64
 }
 
 type Scanner struct {
@@ -33,6 +39,11 @@ func (s *Scanner) Scan() ([]FileInfo, error) {
 		}
 
 		if info.IsDir() {
+			// Files deleted from disk wait in the library trash, and
+			// uploads in progress in their own folder.
+			if path == filepath.Join(s.rootPath, safepath.TrashDir) || path == filepath.Join(s.rootPath, safepath.UploadsDir) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -42,17 +53,32 @@ func (s *Scanner) Scan() ([]FileInfo, error) {
 			return nil
 		}
 
+		// Symlinked files count only when they lead somewhere inside
+		// the library.
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := safepath.Resolve(s.rootPath, path)
+			if err != nil {
+				return nil
+			}
+			if info, err = os.Stat(target); err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+		}
+
 		digest, err := calculateDigest(path)
 		if err != nil {
 			return err
 		}
 
-		files = append(files, FileInfo{
+		fi := FileInfo{
 			Path:     path,
 			Size:     info.Size(),
 			Digest:   digest,
 			MimeType: getMimeType(ext),
-		})
+			Format:   Format(path),
+		}
+		fi.Width, fi.Depth, fi.Height = Measure(path)
+		files = append(files, fi)
 
 		return nil
 	})
@@ -95,3 +121,22 @@ func calculateDigest(path string) (string, error) {
 
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+// Format returns the lowercased extension without the dot, e.g. "stl".
+func Format(path string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
+
+// Measure returns the bounding box dimensions of a mesh file in millimetres,
+// or nils when the file is not a mesh or cannot be parsed.
+func Measure(path string) (width, depth, height *float64) {
+	if !mesh.Supported(path) {
+		return nil, nil, nil
+	}
+	m, err := mesh.Load(path)
+	if err != nil {
+		return nil, nil, nil
+	}
+	d := m.Dimensions()
+	return &d[0], &d[1], &d[2]
+}
@@ -0,0 +1,201 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Term is a single filter from a query string such as `tag:dragon`,
+// `-tag:broken`, `height:>100mm` or a bare word.
+type Term struct {
+	Field  string
+	Op     string
+	Value  string
+	Num    float64
+	Max    float64
+	Negate bool
+}
+
+type Query struct {
+	Terms []Term
+}
+
+var fieldAliases = map[string]string{
+	"tag":        "tag",
+	"tags":       "tag",
+	"fmt":        "format",
+	"format":     "format",
+	"ext":        "format",
+	"size":       "size",
+	"width":      "width",
+	"depth":      "depth",
+	"height":     "height",
+	"library":    "library",
+	"lib":        "library",
+	"collection": "collection",
+	"col":        "collection",
+	"printed":    "printed",
+	"name":       "name",
+	"path":       "path",
+}
+
+var sizeUnits = map[string]float64{
+	"":   1,
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+var lengthUnits = map[string]float64{
+	"":   1,
+	"mm": 1,
+	"cm": 10,
+	"m":  1000,
+	"in": 25.4,
+}
+
+// Parse turns a query like `tag:dragon fmt:3mf size:<50MB -tag:broken` into
+// a list of terms. Values may be double-quoted to include spaces.
+func Parse(s string) (*Query, error) {
+	q := &Query{}
+	for _, tok := range tokenize(s) {
+		t := Term{Field: "text", Op: "=", Value: tok}
+		if strings.HasPrefix(tok, "-") && len(tok) > 1 {
+			t.Negate = true
+			tok = tok[1:]
+			t.Value = tok
+		}
+
+		if i := strings.IndexByte(tok, ':'); i > 0 {
+			field, ok := fieldAliases[strings.ToLower(tok[:i])]
+			if !ok {
+				return nil, fmt.Errorf("unknown filter %q", tok[:i])
+			}
+			t.Field = field
+			t.Value = unquote(tok[i+1:])
+			if t.Value == "" {
+				return nil, fmt.Errorf("filter %q needs a value", tok[:i])
+			}
+		} else {
+			t.Value = unquote(tok)
+		}
+
+		if err := t.parseValue(); err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, t)
+	}
+	return q, nil
+}
+
+func (t *Term) parseValue() error {
+	switch t.Field {
+	case "size":
+		return t.parseNumber(sizeUnits)
+	case "width", "depth", "height":
+		return t.parseNumber(lengthUnits)
+	case "format":
+		t.Value = strings.TrimPrefix(strings.ToLower(t.Value), ".")
+	case "printed":
+		switch strings.ToLower(t.Value) {
+		case "yes", "true", "1":
+			t.Value = "yes"
+		case "no", "false", "0":
+			t.Value = "no"
+		default:
+			return fmt.Errorf("printed must be yes or no")
+		}
+	}
+	return nil
+}
+
+// parseNumber accepts `50MB`, `<50MB`, `>=100mm` and ranges like `10..20mm`.
+func (t *Term) parseNumber(units map[string]float64) error {
+	v := strings.ToLower(t.Value)
+	if lo, hi, ok := strings.Cut(v, ".."); ok {
+		if _, unit := splitUnit(lo); unit == "" {
+			_, hiUnit := splitUnit(hi)
+			lo += hiUnit
+		}
+		min, err := parseQuantity(lo, units)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Field, err)
+		}
+		max, err := parseQuantity(hi, units)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Field, err)
+		}
+		t.Op, t.Num, t.Max = "..", min, max
+		return nil
+	}
+
+	t.Op = "="
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(v, op) {
+			t.Op = op
+			v = v[len(op):]
+			break
+		}
+	}
+	n, err := parseQuantity(v, units)
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.Field, err)
+	}
+	t.Num = n
+	return nil
+}
+
+func parseQuantity(s string, units map[string]float64) (float64, error) {
+	num, unit := splitUnit(s)
+	scale, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", unit)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", num)
+	}
+	return n * scale, nil
+}
+
+func splitUnit(s string) (num, unit string) {
+	i := strings.IndexFunc(s, unicode.IsLetter)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return strings.Trim(s, `"`)
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Term
+	}{
+		{"", nil},
+		{"dragon", []Term{{Field: "text", Op: "=", Value: "dragon"}}},
+		{"-broken", []Term{{Field: "text", Op: "=", Value: "broken", Negate: true}}},
+		{"-", []Term{{Field: "text", Op: "=", Value: "-"}}},
+		{`"big dragon"`, []Term{{Field: "text", Op: "=", Value: "big dragon"}}},
+		{`-"big dragon"`, []Term{{Field: "text", Op: "=", Value: "big dragon", Negate: true}}},
+		{"tag:dragon -TAGS:broken", []Term{
+			{Field: "tag", Op: "=", Value: "dragon"},
+			{Field: "tag", Op: "=", Value: "broken", Negate: true},
+		}},
+		{`tag:"red dragon" name:knight`, []Term{
+			{Field: "tag", Op: "=", Value: "red dragon"},
+			{Field: "name", Op: "=", Value: "knight"},
+		}},
+		{"fmt:.STL ext:3mf", []Term{
+			{Field: "format", Op: "=", Value: "stl"},
+			{Field: "format", Op: "=", Value: "3mf"},
+		}},
+		{"lib:Minis col:Favourites path:orcs", []Term{
+			{Field: "library", Op: "=", Value: "Minis"},
+			{Field: "collection", Op: "=", Value: "Favourites"},
+			{Field: "path", Op: "=", Value: "orcs"},
+		}},
+		{"printed:true -printed:0", []Term{
+			{Field: "printed", Op: "=", Value: "yes"},
+			{Field: "printed", Op: "=", Value: "no", Negate: true},
+		}},
+		{"size:<50MB", []Term{{Field: "size", Op: "<", Value: "<50MB", Num: 50 << 20}}},
+		{"size:>=1gb", []Term{{Field: "size", Op: ">=", Value: ">=1gb", Num: 1 << 30}}},
+		{"size:2048", []Term{{Field: "size", Op: "=", Value: "2048", Num: 2048}}},
+		{"size:1..2kb", []Term{{Field: "size", Op: "..", Value: "1..2kb", Num: 1 << 10, Max: 2 << 10}}},
+		{"height:>100mm", []Term{{Field: "height", Op: ">", Value: ">100mm", Num: 100}}},
+		{"height:<=5cm", []Term{{Field: "height", Op: "<=", Value: "<=5cm", Num: 50}}},
+		{"width:2in", []Term{{Field: "width", Op: "=", Value: "2in", Num: 50.8}}},
+		{"depth:10..20cm", []Term{{Field: "depth", Op: "..", Value: "10..20cm", Num: 100, Max: 200}}},
+		{"depth:5mm..1m", []Term{{Field: "depth", Op: "..", Value: "5mm..1m", Num: 5, Max: 1000}}},
+		{"-height:>1m", []Term{{Field: "height", Op: ">", Value: ">1m", Num: 1000, Negate: true}}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(q.Terms, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.query, q.Terms, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		"colour:red",
+		"tag:",
+		`tag:""`,
+		"size:5xb",
+		"size:<big",
+		"height:10..tall",
+		"width:3ft",
+		"printed:maybe",
+	} {
+		if q, err := Parse(query); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", query, q.Terms)
+		}
+	}
+}
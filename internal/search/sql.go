@@ -0,0 +1,112 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var numericColumns = map[string]string{
+	"size":   "m.total_size",
+	"width":  "m.width",
+	"depth":  "m.depth",
+	"height": "m.height",
+}
+
+// SQL renders the query as a WHERE condition over `models m`. Placeholders
+// are numbered starting after the given args, which are returned extended
+// with the query's own values.
+func (q *Query) SQL(args []interface{}) (string, []interface{}) {
+	return q.render(&builder{args: args})
+}
+
+// SQLite is SQL for SQLite, where free text is matched through the
+// model_search FTS5 table instead of ILIKE.
+func (q *Query) SQLite(args []interface{}) (string, []interface{}) {
+	return q.render(&builder{args: args, sqlite: true})
+}
+
+func (q *Query) render(b *builder) (string, []interface{}) {
+	if len(q.Terms) == 0 {
+		return "TRUE", b.args
+	}
+
+	conds := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		cond := b.term(t)
+		if t.Negate {
+			cond = "NOT " + cond
+		}
+		conds = append(conds, cond)
+	}
+	return strings.Join(conds, " AND "), b.args
+}
+
+type builder struct {
+	args   []interface{}
+	sqlite bool
+}
+
+func (b *builder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *builder) term(t Term) string {
+	switch t.Field {
+	case "tag":
+		return fmt.Sprintf(`EXISTS (SELECT 1 FROM model_tags mt JOIN tags t ON t.id = mt.tag_id
+			WHERE mt.model_id = m.id AND LOWER(t.name) = LOWER(%s))`, b.arg(t.Value))
+	case "format":
+		return fmt.Sprintf(`EXISTS (SELECT 1 FROM model_files mf WHERE mf.model_id = m.id AND mf.deleted_at IS NULL AND mf.format = %s)`, b.arg(t.Value))
+	case "library":
+		return fmt.Sprintf(`EXISTS (SELECT 1 FROM libraries l WHERE l.id = m.library_id AND LOWER(l.name) = LOWER(%s))`, b.arg(t.Value))
+	case "collection":
+		return fmt.Sprintf(`EXISTS (SELECT 1 FROM model_collections mc JOIN collections c ON c.id = mc.collection_id
+			WHERE mc.model_id = m.id AND LOWER(c.name) = LOWER(%s))`, b.arg(t.Value))
+	case "printed":
+		if t.Value == "yes" {
+			return "(m.print_count > 0)"
+		}
+		return "(m.print_count = 0)"
+	case "name":
+		return "(" + b.like("m.name", b.arg(contains(t.Value))) + ")"
+	case "path":
+		return "(" + b.like("m.path", b.arg(contains(t.Value))) + ")"
+	case "size", "width", "depth", "height":
+		col := numericColumns[t.Field]
+		if t.Op == ".." {
+			return fmt.Sprintf("(%s BETWEEN %s AND %s)", col, b.arg(t.Num), b.arg(t.Max))
+		}
+		return fmt.Sprintf("(%s %s %s)", col, t.Op, b.arg(t.Num))
+	default:
+		// Trigrams cannot match fewer than three characters.
+		if b.sqlite && utf8.RuneCountInString(t.Value) >= 3 {
+			return fmt.Sprintf("(m.id IN (SELECT rowid FROM model_search WHERE model_search MATCH %s))", b.arg(phrase(t.Value)))
+		}
+		// description is nullable, and NULL would make a negated word drop
+		// every model without one.
+		p := b.arg(contains(t.Value))
+		return fmt.Sprintf(`(%s OR %s OR %s OR
+			EXISTS (SELECT 1 FROM model_files mf WHERE mf.model_id = m.id AND mf.deleted_at IS NULL AND %s))`,
+			b.like("m.name", p), b.like("COALESCE(m.description, '')", p), b.like("m.path", p), b.like("mf.filename", p))
+	}
+}
+
+// like is a case-insensitive match of column against a contains() pattern.
+func (b *builder) like(column, p string) string {
+	if b.sqlite {
+		return column + " LIKE " + p + ` ESCAPE '\'`
+	}
+	return column + " ILIKE " + p
+}
+
+// phrase quotes s as a single FTS5 phrase so operators in it are literal.
+func phrase(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func contains(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
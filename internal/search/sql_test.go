@@ -0,0 +1,103 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// squash collapses runs of white space so conditions spread over several
+// lines compare as one.
+func squash(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func TestSQL(t *testing.T) {
+	textPG := `(m.name ILIKE $2 OR COALESCE(m.description, '') ILIKE $2 OR m.path ILIKE $2 OR ` +
+		`EXISTS (SELECT 1 FROM model_files mf WHERE mf.model_id = m.id AND mf.deleted_at IS NULL AND mf.filename ILIKE $2))`
+	textSQLite := `(m.name LIKE $2 ESCAPE '\' OR COALESCE(m.description, '') LIKE $2 ESCAPE '\' OR m.path LIKE $2 ESCAPE '\' OR ` +
+		`EXISTS (SELECT 1 FROM model_files mf WHERE mf.model_id = m.id AND mf.deleted_at IS NULL AND mf.filename LIKE $2 ESCAPE '\'))`
+	fts := `(m.id IN (SELECT rowid FROM model_search WHERE model_search MATCH $2))`
+
+	tests := []struct {
+		query      string
+		pg, sqlite string
+		args       []interface{}
+		sqliteArgs []interface{}
+	}{
+		{query: "", pg: "TRUE", sqlite: "TRUE"},
+		{
+			query: "dragon", pg: textPG, sqlite: fts,
+			args: []interface{}{"%dragon%"}, sqliteArgs: []interface{}{`"dragon"`},
+		},
+		{
+			// Negated words must keep models without a description.
+			query: "-broken", pg: "NOT " + textPG, sqlite: "NOT " + fts,
+			args: []interface{}{"%broken%"}, sqliteArgs: []interface{}{`"broken"`},
+		},
+		{
+			// Under three characters SQLite falls back to LIKE.
+			query: "-ab", pg: "NOT " + textPG, sqlite: "NOT " + textSQLite,
+			args: []interface{}{"%ab%"},
+		},
+		{
+			query: `"50%_off"`, pg: textPG, sqlite: fts,
+			args: []interface{}{`%50\%\_off%`}, sqliteArgs: []interface{}{`"50%_off"`},
+		},
+		{
+			query: `"say ""hi"""`, pg: textPG, sqlite: fts,
+			args: []interface{}{`%say ""hi""%`}, sqliteArgs: []interface{}{`"say """"hi"""""`},
+		},
+		{
+			query: "name:orc", pg: "(m.name ILIKE $2)", sqlite: `(m.name LIKE $2 ESCAPE '\')`,
+			args: []interface{}{"%orc%"},
+		},
+		{
+			query: "size:<50MB", pg: "(m.total_size < $2)", sqlite: "(m.total_size < $2)",
+			args: []interface{}{float64(50 << 20)},
+		},
+		{
+			query: "-height:10..20cm", pg: "NOT (m.height BETWEEN $2 AND $3)", sqlite: "NOT (m.height BETWEEN $2 AND $3)",
+			args: []interface{}{float64(100), float64(200)},
+		},
+		{
+			query: "printed:no", pg: "(m.print_count = 0)", sqlite: "(m.print_count = 0)",
+		},
+		{
+			query: "tag:dragon fmt:stl",
+			pg: `EXISTS (SELECT 1 FROM model_tags mt JOIN tags t ON t.id = mt.tag_id WHERE mt.model_id = m.id AND LOWER(t.name) = LOWER($2)) AND ` +
+				`EXISTS (SELECT 1 FROM model_files mf WHERE mf.model_id = m.id AND mf.deleted_at IS NULL AND mf.format = $3)`,
+			args: []interface{}{"dragon", "stl"},
+		},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		if tt.sqlite == "" {
+			tt.sqlite = tt.pg
+		}
+		if tt.sqliteArgs == nil {
+			tt.sqliteArgs = tt.args
+		}
+		for _, dialect := range []struct {
+			name   string
+			render func([]interface{}) (string, []interface{})
+			want   string
+			args   []interface{}
+		}{
+			{"SQL", q.SQL, tt.pg, tt.args},
+			{"SQLite", q.SQLite, tt.sqlite, tt.sqliteArgs},
+		} {
+			// Placeholders continue after the arguments passed in.
+			cond, args := dialect.render([]interface{}{int64(7)})
+			if squash(cond) != squash(dialect.want) {
+				t.Errorf("%s for %q:\n got %s\nwant %s", dialect.name, tt.query, squash(cond), squash(dialect.want))
+			}
+			if want := append([]interface{}{int64(7)}, dialect.args...); !reflect.DeepEqual(args, want) {
+				t.Errorf("%s args for %q = %v, want %v", dialect.name, tt.query, args, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"3d-library/internal/api"
+	"3d-library/internal/auth"
+	"3d-library/internal/config"
+	"3d-library/internal/handlers"
+	"3d-library/internal/jobs"
+	"3d-library/internal/oidc"
+	"3d-library/internal/ratelimit"
+	"3d-library/internal/store"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewRouter wires every handler. Handlers only touch st, db and jobQueue
+// while serving, so the router can be built with nil values to inspect its
+// routes. A nil limiter turns rate limiting off.
+func NewRouter(cfg *config.Config, st store.Store, db *sqlx.DB, jobQueue jobs.Queue, limiter ratelimit.Limiter) *chi.Mux {
+	// Uploads and image searches keep their own caps unless limits.body_kb
+	// names them.
+	bodyLimits := handlers.BodyLimits{Default: cfg.MaxBodyBytes(), Routes: map[string]int64{
+		"POST /api/libraries/{id}/upload": cfg.MaxUploadBytes(),
+		"POST /api/search/image":          cfg.MaxImageBytes(),
+	}}
+	for route, limit := range cfg.BodyLimits() {
+		bodyLimits.Routes[route] = limit
+	}
+
+	// Initialize handlers
+	libraryHandler := handlers.NewLibraryHandler(st)
+	modelHandler := handlers.NewModelHandler(st, db)
+	collectionHandler := handlers.NewCollectionHandler(st)
+	tagHandler := handlers.NewTagHandler(st)
+	fileHandler := handlers.NewFileHandler(st)
+	scanHandler := handlers.NewScanHandler(st, jobQueue)
+	searchHandler := handlers.NewSearchHandler(st, db, bodyLimits.For("POST /api/search/image"))
+	uploadHandler := handlers.NewUploadHandler(st, db, handlers.UploadLimits{
+		MaxBytes:       bodyLimits.For("POST /api/libraries/{id}/upload"),
+		MaxExtract:     cfg.Uploads.MaxExtractMB << 20,
+		MaxFiles:       cfg.Uploads.MaxFiles,
+		MaxRatio:       cfg.Uploads.MaxRatio,
+		MaxFolderDepth: cfg.Uploads.MaxFolderDepth,
+		UserQuota:      cfg.Uploads.UserQuotaMB << 20,
+	})
+	savedSearchHandler := handlers.NewSavedSearchHandler(st)
+	bulkHandler := handlers.NewBulkHandler(st, db, jobQueue)
+	userHandler := handlers.NewUserHandler(st)
+	shareHandler := handlers.NewShareHandler(st, fileHandler, cfg.Server.PublicURL)
+	auditHandler := handlers.NewAuditHandler(st)
+	trashHandler := handlers.NewTrashHandler(st)
+	authHandler := handlers.NewAuthHandler(st, authOptions(cfg))
+	limits := handlers.NewRateLimiter(limiter, rateLimits(cfg), authHandler.ClientIP)
+
+	// Setup router
+	r := chi.NewRouter()
+	if cfg.Log.Requests {
+		r.Use(middleware.Logger)
+	}
+	r.Use(middleware.Recoverer)
+
+	r.Use(cors(cfg.Server.CORSOrigins))
+	r.Use(handlers.LimitBody(r, bodyLimits))
+
+	// Serve static files
+	fileServer := http.FileServer(http.Dir("./web/static"))
+	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
+
+	// Serve index.html at root
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "./web/static/index.html")
+	})
+
+	// Share links, for people without an account
+	r.Group(func(r chi.Router) {
+		r.Use(limits.PerIP)
+		r.Get("/s/{token}", shareHandler.Page)
+		r.Post("/s/{token}", shareHandler.Page)
+		r.With(limits.Download).Get("/s/{token}/files/{id}", shareHandler.Download)
+	})
+
+	// API routes
+	r.Route("/api", func(r chi.Router) {
+		r.Use(limits.PerIP)
+		r.Use(authHandler.Authenticate)
+		r.Use(limits.PerUser)
+		r.NotFound(handlers.NotFound)
+		r.MethodNotAllowed(handlers.MethodNotAllowed)
+
+		r.Get("/openapi.json", api.Handler)
+		r.Post("/auth/login", authHandler.Login)
+		r.Post("/auth/logout", authHandler.Logout)
+		r.Get("/auth/me", authHandler.Me)
+		r.Get("/auth/methods", authHandler.Methods)
+		r.Get("/auth/oidc/login", authHandler.OIDCLogin)
+		r.Get("/auth/oidc/callback", authHandler.OIDCCallback)
+
+		// Users and the audit log
+		r.Group(func(r chi.Router) {
+			r.Use(authHandler.RequireAdmin)
+			r.Get("/users", userHandler.List)
+			r.Post("/users", userHandler.Create)
+			r.Get("/users/{id}", userHandler.Get)
+			r.Patch("/users/{id}", userHandler.Update)
+
+			// Audit log
+			r.Get("/audit", auditHandler.List)
+			r.Get("/audit/export", auditHandler.Export)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(authHandler.RequireUser)
+
+			r.Post("/auth/password", authHandler.ChangePassword)
+			r.Get("/auth/tokens", authHandler.ListTokens)
+			r.Post("/auth/tokens", authHandler.CreateToken)
+			r.Delete("/auth/tokens/{id}", authHandler.DeleteToken)
+			r.Get("/shares", shareHandler.List)
+			r.Post("/shares", shareHandler.Create)
+			r.Delete("/shares/{id}", shareHandler.Delete)
+
+			// Libraries
+			r.Get("/libraries", libraryHandler.List)
+			r.Post("/libraries", libraryHandler.Create)
+			r.Get("/libraries/{id}", libraryHandler.Get)
+			r.Patch("/libraries/{id}", libraryHandler.Update)
+			r.Delete("/libraries/{id}", libraryHandler.Delete)
+			r.Post("/libraries/{id}/restore", libraryHandler.Restore)
+			r.With(limits.Scan).Post("/libraries/{id}/scan", scanHandler.ScanLibrary)
+			r.Post("/libraries/{id}/upload", uploadHandler.Upload)
+			r.Get("/libraries/{id}/members", libraryHandler.ListMembers)
+			r.Post("/libraries/{id}/members", libraryHandler.SetMember)
+			r.Delete("/libraries/{id}/members/{userID}", libraryHandler.RemoveMember)
+
+			// Models
+			r.Get("/models", modelHandler.List)
+			r.Post("/models", modelHandler.Create)
+			r.Get("/models/{id}", modelHandler.Get)
+			r.Patch("/models/{id}", modelHandler.Update)
+			r.Delete("/models/{id}", modelHandler.Delete)
+			r.Post("/models/{id}/restore", modelHandler.Restore)
+			r.Get("/models/{id}/files", fileHandler.GetModelFiles)
+			r.Post("/models/{id}/preview", modelHandler.SetPreview)
+			r.Post("/models/{id}/prints", modelHandler.RecordPrint)
+			r.With(limits.Search).Get("/models/{id}/similar", modelHandler.Similar)
+			r.Get("/models/{id}/thumbnail", modelHandler.Thumbnail)
+			r.Post("/models/{id}/tags", tagHandler.AddToModel)
+			r.Get("/models/{id}/tags", tagHandler.GetModelTags)
+			r.Delete("/models/{id}/tags/{tagID}", tagHandler.RemoveFromModel)
+
+			// Collections
+			r.Get("/collections", collectionHandler.List)
+			r.Post("/collections", collectionHandler.Create)
+			r.Get("/collections/{id}", collectionHandler.Get)
+			r.Patch("/collections/{id}", collectionHandler.Update)
+			r.Delete("/collections/{id}", collectionHandler.Delete)
+			r.Get("/collections/{id}/models", collectionHandler.GetModels)
+			r.Post("/collections/{id}/models", collectionHandler.AddModel)
+			r.Delete("/collections/{id}/models/{modelID}", collectionHandler.RemoveModel)
+			r.Put("/collections/{id}/query", collectionHandler.SetQuery)
+
+			// Files
+			r.Get("/files/{id}", fileHandler.Get)
+			r.With(limits.Download).Get("/files/{id}/download", fileHandler.Serve)
+			r.Delete("/files/{id}", fileHandler.Delete)
+			r.Post("/files/{id}/restore", fileHandler.Restore)
+
+			// Trash
+			r.Get("/trash", trashHandler.List)
+
+			// Tags
+			r.Get("/tags", tagHandler.List)
+			r.Get("/tags/{id}", tagHandler.Get)
+			r.Patch("/tags/{id}", tagHandler.Update)
+			r.Delete("/tags/{id}", tagHandler.Delete)
+
+			// Bulk operations
+			r.Post("/bulk", bulkHandler.Run)
+			r.Get("/bulk/{id}", bulkHandler.Get)
+
+			// Search
+			r.With(limits.Search).Get("/search", searchHandler.Search)
+			r.With(limits.Search).Post("/search/image", searchHandler.SearchImage)
+
+			// Saved searches
+			r.Get("/searches", savedSearchHandler.List)
+			r.Post("/searches", savedSearchHandler.Create)
+			r.Get("/searches/{id}", savedSearchHandler.Get)
+			r.Put("/searches/{id}", savedSearchHandler.Update)
+			r.Delete("/searches/{id}", savedSearchHandler.Delete)
+			r.With(limits.Search).Get("/searches/{id}/results", savedSearchHandler.Results)
+		})
+	})
+
+	return r
+}
+
+// rateLimits turns the limits settings into the rate limiter's rates.
+func rateLimits(cfg *config.Config) handlers.RateLimits {
+	l := cfg.Limits
+	perMinute := func(n int) ratelimit.Rate { return ratelimit.Rate{Requests: n, Per: time.Minute} }
+	return handlers.RateLimits{
+		User:     perMinute(l.UserPerMinute),
+		IP:       perMinute(l.IPPerMinute),
+		Search:   perMinute(l.SearchPerMinute),
+		Download: perMinute(l.DownloadPerMinute),
+		Scan:     ratelimit.Rate{Requests: l.ScansPerHour, Per: time.Hour},
+	}
+}
+
+// authOptions turns the validated auth settings into the handler's options.
+func authOptions(cfg *config.Config) handlers.AuthOptions {
+	a := cfg.Auth
+	opts := handlers.AuthOptions{
+		AnonymousRead:     a.AnonymousRead,
+		SessionTTL:        time.Duration(a.SessionHours) * time.Hour,
+		SecureCookies:     strings.HasPrefix(cfg.Server.PublicURL, "https://"),
+		TrustedOrigins:    cfg.Server.CORSOrigins,
+		UsernameClaim:     a.OIDC.UsernameClaim,
+		GroupsClaim:       a.OIDC.GroupsClaim,
+		LinkExisting:      a.OIDC.LinkExisting,
+		ProxyHeader:       a.Proxy.Header,
+		ProxyGroupsHeader: a.Proxy.GroupsHeader,
+	}
+	if a.OIDC.Issuer != "" {
+		opts.OIDC = oidc.New(oidc.Config{
+			Issuer:       a.OIDC.Issuer,
+			ClientID:     a.OIDC.ClientID,
+			ClientSecret: a.OIDC.ClientSecret,
+			RedirectURL:  a.OIDC.RedirectURL,
+			Scopes:       a.OIDC.Scopes,
+		})
+	}
+	for _, proxy := range a.Proxy.TrustedProxies {
+		if n, err := config.ParseNetwork(proxy); err == nil {
+			opts.ProxyNetworks = append(opts.ProxyNetworks, n)
+		}
+	}
+	if len(a.AdminGroups) > 0 || len(a.GroupRoles) > 0 {
+		opts.Groups = &auth.Groups{Admins: a.AdminGroups}
+		for _, rule := range a.GroupRoles {
+			if r, err := auth.ParseGroupRule(rule); err == nil {
+				opts.Groups.Rules = append(opts.Groups.Rules, r)
+			}
+		}
+	}
+	return opts
+}
+
+// cors lets pages from the given origins call the API with the user's
+// session. Other origins get no CORS headers, so browsers keep their pages
+// from reading responses.
+func cors(origins []string) func(http.Handler) http.Handler {
+	allowed := map[string]bool{}
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match")
+				w.Header().Set("Access-Control-Expose-Headers", "ETag")
+			}
+			if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package server
+
+import (
+	"3d-library/internal/api"
+	"3d-library/internal/config"
+	"testing"
+)
+
+func TestRoutesMatchDocument(t *testing.T) {
+	if err := api.CheckRoutes(NewRouter(config.Default(), nil, nil, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
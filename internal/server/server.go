@@ -0,0 +1,208 @@
+// Package server starts the web server and the job worker from a loaded
+// configuration. cmd/go3d and the older cmd/web and cmd/worker binaries all
+// run through it.
+package server
+
+import (
+	"3d-library/internal/api"
+	"3d-library/internal/config"
+	"3d-library/internal/database"
+	"3d-library/internal/jobs"
+	"3d-library/internal/migrate"
+	"3d-library/internal/ratelimit"
+	"3d-library/internal/safepath"
+	"3d-library/internal/store/sqlstore"
+	"3d-library/internal/thumbnail"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jmoiron/sqlx"
+)
+
+// Setup applies the logging, thumbnail and library root settings, which live
+// in package variables, and connects to the database.
+func Setup(cfg *config.Config) (*sqlx.DB, error) {
+	cfg.Log.Setup()
+	thumbnail.CacheDir = cfg.Thumbnails.Dir
+	safepath.Roots = cfg.Libraries.AllowedRoots
+	return database.Connect(cfg.Database.URL)
+}
+
+// DefaultRoots confines libraries to the folders of the ones in the database
+// when libraries.allowed_roots is empty, so file access stays bounded
+// without configuration. With no libraries either, none can be created
+// until allowed_roots is set. It needs the libraries table, so it runs
+// after migrations.
+func DefaultRoots(cfg *config.Config, db *sqlx.DB) error {
+	if len(cfg.Libraries.AllowedRoots) > 0 {
+		return nil
+	}
+	var paths []string
+	if err := db.Select(&paths, "SELECT DISTINCT path FROM libraries ORDER BY path"); err != nil {
+		return err
+	}
+	safepath.Roots = paths
+	return nil
+}
+
+// NewQueue returns the Redis job queue, or the embedded one when the
+// configuration asks for it or Redis does not answer.
+func NewQueue(cfg *config.Config, db *sqlx.DB) jobs.Queue {
+	if jobs.UseEmbedded(cfg.Jobs.Queue, cfg.Redis.Addr) {
+		return jobs.NewEmbedded(db)
+	}
+	return jobs.NewClient(cfg.Redis.Addr)
+}
+
+// NewLimiter returns the rate limit counts: in Redis when the configuration
+// asks for it or Redis answers, so every server shares them, and in memory
+// otherwise.
+func NewLimiter(cfg *config.Config) ratelimit.Limiter {
+	switch cfg.Limits.RateStore {
+	case "memory":
+		return ratelimit.NewMemory()
+	case "redis":
+		return ratelimit.NewRedis(cfg.Redis.Addr)
+	}
+	if jobs.UseEmbedded("", cfg.Redis.Addr) {
+		return ratelimit.NewMemory()
+	}
+	return ratelimit.NewRedis(cfg.Redis.Addr)
+}
+
+// Serve runs the web server until it fails or ctx is done, then waits for
+// requests in flight. With the embedded queue it also runs the background
+// jobs.
+func Serve(ctx context.Context, cfg *config.Config) error {
+	db, err := Setup(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	log.Println("✓ Connected to database")
+
+	if err := MigrateOnStart(db, cfg.Database.AutoMigrate); err != nil {
+		return err
+	}
+	if err := DefaultRoots(cfg, db); err != nil {
+		return err
+	}
+
+	st := sqlstore.New(db)
+	jobQueue := NewQueue(cfg, db)
+	defer jobQueue.Close()
+	embedded, isEmbedded := jobQueue.(*jobs.Embedded)
+	if isEmbedded {
+		go embedded.Run(ctx, jobs.NewServer(st, db), cfg.Jobs.Concurrency)
+		log.Println("✓ Running background jobs in-process")
+	} else {
+		log.Println("✓ Connected to Redis")
+	}
+
+	go jobs.SchedulePurges(ctx, jobQueue, cfg.Trash.RetentionDays, cfg.Trash.PurgeFiles)
+
+	limiter := NewLimiter(cfg)
+	defer limiter.Close()
+	if _, inRedis := limiter.(*ratelimit.Redis); inRedis {
+		log.Println("✓ Counting rate limits in Redis")
+	}
+
+	r := NewRouter(cfg, st, db, jobQueue, limiter)
+	if err := api.CheckRoutes(r); err != nil {
+		return err
+	}
+
+	if n, err := st.Users().Count(ctx); err != nil {
+		return err
+	} else if n == 0 {
+		log.Println("warning: no users yet; create an administrator with \"go3d user create -admin NAME\"")
+	}
+	if cfg.Auth.AnonymousRead {
+		log.Println("warning: anonymous read access is on; anyone who can reach the server can browse and download")
+	}
+	if len(safepath.Roots) == 0 {
+		log.Println("warning: libraries.allowed_roots is empty and there are no libraries yet; set it to create the first library")
+	} else if len(cfg.Libraries.AllowedRoots) == 0 {
+		log.Printf("✓ Libraries confined to the %d existing library folders; set libraries.allowed_roots to allow others", len(safepath.Roots))
+	}
+
+	log.Printf("✓ Server listening on %s", cfg.Addr())
+	log.Printf("✓ UI available at %s", cfg.Server.PublicURL)
+	if !isEmbedded {
+		log.Println("✓ Start worker with: go3d worker")
+	}
+	srv := &http.Server{Addr: cfg.Addr(), Handler: r}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// Work processes background jobs until ctx is done.
+func Work(ctx context.Context, cfg *config.Config) error {
+	db, err := Setup(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	log.Println("✓ Worker connected to database")
+
+	// The web server applies migrations; the worker only makes sure it
+	// understands the schema.
+	pending, err := migrate.Check(db)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		log.Printf("warning: %d migrations pending; start the web server or run \"go3d migrate up\"", pending)
+	}
+	if err := DefaultRoots(cfg, db); err != nil {
+		return err
+	}
+
+	mux := jobs.NewServer(sqlstore.New(db), db)
+
+	if jobs.UseEmbedded(cfg.Jobs.Queue, cfg.Redis.Addr) {
+		// The web server already runs these jobs; a separate worker only
+		// adds capacity.
+		log.Println("✓ Worker started, processing jobs from the database...")
+		jobs.NewEmbedded(db).Run(ctx, mux, cfg.Jobs.Concurrency)
+		return nil
+	}
+
+	srv := asynq.NewServer(
+		asynq.RedisClientOpt{Addr: cfg.Redis.Addr},
+		asynq.Config{Concurrency: cfg.Jobs.Concurrency},
+	)
+
+	log.Println("✓ Worker started, processing jobs...")
+	return srv.Run(mux)
+}
+
+// MigrateOnStart brings the schema up to date, or with auto off only warns
+// about pending migrations. Either way it fails against a schema newer than
+// this build.
+func MigrateOnStart(db *sqlx.DB, auto bool) error {
+	if !auto {
+		pending, err := migrate.Check(db)
+		if pending > 0 {
+			log.Printf("warning: %d migrations pending; run \"go3d migrate up\"", pending)
+		}
+		return err
+	}
+	done, err := migrate.Up(db)
+	for _, m := range done {
+		log.Println("✓ Applied migration", m.Name)
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"3d-library/internal/auth"
+	"3d-library/internal/config"
+	"3d-library/internal/database"
+	"3d-library/internal/migrate"
+	"3d-library/internal/safepath"
+	"3d-library/internal/store/sqlstore"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRoots(t *testing.T) {
+	defer func(roots []string) { safepath.Roots = roots }(safepath.Roots)
+
+	db, err := database.Connect("sqlite://" + filepath.Join(t.TempDir(), "roots.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrate.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	inside, outside := t.TempDir(), t.TempDir()
+	if _, err := db.Exec("INSERT INTO libraries (name, path) VALUES ('Minis', $1)", inside); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	safepath.Roots = nil
+	if err := DefaultRoots(cfg, db); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(safepath.Roots, []string{inside}) {
+		t.Fatalf("roots %v, want the library's folder", safepath.Roots)
+	}
+	if _, err := safepath.Root(outside); err != safepath.ErrOutsideRoots {
+		t.Errorf("a folder outside every library: %v", err)
+	}
+
+	cfg.Libraries.AllowedRoots = []string{outside}
+	safepath.Roots = cfg.Libraries.AllowedRoots
+	if err := DefaultRoots(cfg, db); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(safepath.Roots, []string{outside}) {
+		t.Errorf("roots %v, want the configured ones kept", safepath.Roots)
+	}
+}
+
+func TestNoRootsRefusesLibraries(t *testing.T) {
+	defer func(roots []string) { safepath.Roots = roots }(safepath.Roots)
+
+	db, err := database.Connect("sqlite://" + filepath.Join(t.TempDir(), "roots.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrate.Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh install: no libraries and no allowed_roots.
+	cfg := config.Default()
+	cfg.Log.Requests = false
+	safepath.Roots = nil
+	if err := DefaultRoots(cfg, db); err != nil {
+		t.Fatal(err)
+	}
+	if len(safepath.Roots) != 0 {
+		t.Fatalf("roots %v, want none", safepath.Roots)
+	}
+	dir := t.TempDir()
+	if _, err := safepath.Root(dir); !errors.Is(err, safepath.ErrNoRoots) {
+		t.Errorf("Root with no roots: %v", err)
+	}
+
+	router := NewRouter(cfg, sqlstore.New(db), db, nil, nil)
+	create := func() *httptest.ResponseRecorder {
+		body := strings.NewReader(fmt.Sprintf(`{"name": "Minis", "path": %q}`, dir))
+		req := httptest.NewRequest("POST", "/api/libraries", body)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(auth.WithUser(req.Context(), auth.System))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := create(); w.Code != 422 || !strings.Contains(w.Body.String(), "libraries.allowed_roots") {
+		t.Fatalf("creating a library with no roots: %d %s", w.Code, w.Body)
+	}
+
+	cfg.Libraries.AllowedRoots = []string{filepath.Dir(dir)}
+	safepath.Roots = cfg.Libraries.AllowedRoots
+	if w := create(); w.Code != 201 {
+		t.Fatalf("creating a library inside allowed_roots: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package similarity
+
+import (
+	"3d-library/internal/mesh"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	HistogramBins    = 32
+	HistogramSamples = 20000
+)
+
+// Descriptor is the geometric fingerprint of a model's primary mesh.
+// Ratios are the middle and smallest bounding box sides divided by the
+// largest, so a flat base and a tall pillar are far apart regardless of size.
+type Descriptor struct {
+	Histogram []float64
+	Ratios    [2]float64
+}
+
+func Describe(m *mesh.Mesh) Descriptor {
+	d := m.Dimensions()
+	dims := []float64{d[0], d[1], d[2]}
+	sort.Sort(sort.Reverse(sort.Float64Slice(dims)))
+
+	desc := Descriptor{Histogram: m.ShapeDistribution(HistogramBins, HistogramSamples)}
+	if dims[0] > 0 {
+		desc.Ratios = [2]float64{dims[1] / dims[0], dims[2] / dims[0]}
+	}
+	return desc
+}
+
+// ShapeScore compares two descriptors, returning 1 for identical shapes and
+// 0 for completely different ones.
+func ShapeScore(a, b Descriptor) float64 {
+	if len(a.Histogram) != len(b.Histogram) || len(a.Histogram) == 0 {
+		return 0
+	}
+	l1 := 0.0
+	for i := range a.Histogram {
+		l1 += math.Abs(a.Histogram[i] - b.Histogram[i])
+	}
+	hist := 1 - l1/2
+	ratio := 1 - (math.Abs(a.Ratios[0]-b.Ratios[0])+math.Abs(a.Ratios[1]-b.Ratios[1]))/2
+	return 0.7*hist + 0.3*ratio
+}
+
+var stopwords = map[string]bool{
+	"stl": true, "obj": true, "3mf": true, "the": true, "and": true,
+	"for": true, "v1": true, "v2": true, "v3": true, "final": true,
+}
+
+// Tokens splits names and paths into lowercase words, also breaking on
+// camelCase and letter/digit boundaries, e.g. "HingeV2_left" gives
+// hinge, v, 2, left minus stopwords and single characters.
+func Tokens(parts ...string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, part := range parts {
+		for _, word := range splitWords(part) {
+			w := strings.ToLower(word)
+			if len(w) < 2 || stopwords[w] || seen[w] {
+				continue
+			}
+			seen[w] = true
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+func splitWords(s string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	var prev rune
+	for _, r := range s {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev),
+			unicode.IsDigit(r) != unicode.IsDigit(prev) && len(cur) > 0:
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
+
+func Jaccard[T comparable](a, b []T) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	set := make(map[T]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+	inter := 0
+	union := len(set)
+	for _, v := range b {
+		if set[v] {
+			inter++
+			delete(set, v)
+		} else {
+			union++
+		}
+	}
+	return float64(inter) / float64(union)
+}
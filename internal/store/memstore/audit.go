@@ -0,0 +1,39 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"strings"
+)
+
+type audit struct{ s *Store }
+
+func (r audit) Append(ctx context.Context, e *models.AuditEntry) error {
+	defer r.s.lock()()
+	d := r.s.d
+	e.ID = d.id()
+	e.CreatedAt = now()
+	d.audit = append(d.audit, *e)
+	return nil
+}
+
+func (r audit) List(ctx context.Context, f store.AuditFilter, p store.PageRequest) (*store.Page[models.AuditEntry], error) {
+	defer r.s.lock()()
+	list := []models.AuditEntry{}
+	for _, e := range r.s.d.audit {
+		switch {
+		case f.Actor != "" && !strings.EqualFold(e.Actor, f.Actor),
+			f.Action != "" && e.Action != f.Action,
+			f.Entity != "" && e.Entity != f.Entity,
+			f.EntityID != 0 && (e.EntityID == nil || *e.EntityID != f.EntityID),
+			!f.Since.IsZero() && e.CreatedAt.Before(f.Since),
+			!f.Until.IsZero() && !e.CreatedAt.Before(f.Until):
+			continue
+		}
+		list = append(list, e)
+	}
+	return page(list, p, store.AuditSorts, func(e models.AuditEntry, sort string) (interface{}, int64) {
+		return e.CreatedAt, e.ID
+	})
+}
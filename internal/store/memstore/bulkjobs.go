@@ -0,0 +1,63 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+)
+
+type bulkJobs struct{ s *Store }
+
+func (r bulkJobs) Get(ctx context.Context, id int64) (*models.BulkJob, error) {
+	defer r.s.lock()()
+	j, ok := r.s.d.bulkJobs[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &j, nil
+}
+
+func (r bulkJobs) Create(ctx context.Context, j *models.BulkJob) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if j.UserID != nil {
+		if _, ok := d.users[*j.UserID]; !ok {
+			return missing("user_id", *j.UserID, "users")
+		}
+	}
+	j.ID = d.id()
+	j.Status = "queued"
+	j.Results, j.Error, j.FinishedAt = nil, nil, nil
+	j.CreatedAt = now()
+	d.bulkJobs[j.ID] = *j
+	return nil
+}
+
+func (r bulkJobs) Start(ctx context.Context, id int64) error {
+	return r.update(id, func(j *models.BulkJob) { j.Status = "running" })
+}
+
+func (r bulkJobs) Finish(ctx context.Context, id int64, results string) error {
+	return r.update(id, func(j *models.BulkJob) {
+		t := now()
+		j.Status, j.Results, j.FinishedAt = "done", &results, &t
+	})
+}
+
+func (r bulkJobs) Fail(ctx context.Context, id int64, reason string) error {
+	return r.update(id, func(j *models.BulkJob) {
+		t := now()
+		j.Status, j.Error, j.FinishedAt = "failed", &reason, &t
+	})
+}
+
+func (r bulkJobs) update(id int64, fn func(j *models.BulkJob)) error {
+	defer r.s.lock()()
+	j, ok := r.s.d.bulkJobs[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	fn(&j)
+	r.s.d.bulkJobs[id] = j
+	return nil
+}
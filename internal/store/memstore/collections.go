@@ -0,0 +1,112 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+)
+
+type collections struct{ s *Store }
+
+func (r collections) List(ctx context.Context, name string, p store.PageRequest) (*store.Page[models.Collection], error) {
+	defer r.s.lock()()
+	list := []models.Collection{}
+	for _, c := range r.s.d.collections {
+		if containsFold(c.Name, name) {
+			list = append(list, c)
+		}
+	}
+	return page(list, p, store.CollectionSorts, func(c models.Collection, sort string) (interface{}, int64) {
+		if sort == "name" {
+			return c.Name, c.ID
+		}
+		return c.CreatedAt, c.ID
+	})
+}
+
+func (r collections) Get(ctx context.Context, id int64) (*models.Collection, error) {
+	defer r.s.lock()()
+	c, ok := r.s.d.collections[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &c, nil
+}
+
+func (r collections) Create(ctx context.Context, c *models.Collection) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if err := d.uniqueCollectionName(0, c.Name); err != nil {
+		return err
+	}
+	c.ID = d.id()
+	c.CreatedAt = now()
+	c.UpdatedAt = c.CreatedAt
+	d.collections[c.ID] = *c
+	return nil
+}
+
+func (r collections) Update(ctx context.Context, c *models.Collection) error {
+	defer r.s.lock()()
+	d := r.s.d
+	stored, ok := d.collections[c.ID]
+	if err := checkStale(ok, stored.UpdatedAt, c.UpdatedAt); err != nil {
+		return err
+	}
+	if err := d.uniqueCollectionName(c.ID, c.Name); err != nil {
+		return err
+	}
+	stored.Name, stored.Query = c.Name, c.Query
+	stored.UpdatedAt = now()
+	d.collections[c.ID] = stored
+	*c = stored
+	return nil
+}
+
+func (r collections) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.collections[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(d.collections, id)
+	for l := range d.modelCollections {
+		if l.otherID == id {
+			delete(d.modelCollections, l)
+		}
+	}
+	for _, s := range d.shares {
+		if s.CollectionID != nil && *s.CollectionID == id {
+			delete(d.shares, s.ID)
+		}
+	}
+	return nil
+}
+
+func (r collections) AddModel(ctx context.Context, collectionID, modelID int64) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.models[modelID]; !ok {
+		return missing("model_id", modelID, "models")
+	}
+	if _, ok := d.collections[collectionID]; !ok {
+		return missing("collection_id", collectionID, "collections")
+	}
+	d.modelCollections[link{modelID, collectionID}] = true
+	return nil
+}
+
+func (r collections) RemoveModel(ctx context.Context, collectionID, modelID int64) error {
+	defer r.s.lock()()
+	delete(r.s.d.modelCollections, link{modelID, collectionID})
+	return nil
+}
+
+func (d *data) uniqueCollectionName(id int64, name string) error {
+	for _, c := range d.collections {
+		if c.ID != id && c.Name == name {
+			return duplicate("name", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+)
+
+type files struct{ s *Store }
+
+func (r files) Get(ctx context.Context, id int64) (*models.ModelFile, error) {
+	defer r.s.lock()()
+	f, ok := r.s.d.files[id]
+	if !ok || f.DeletedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	return &f, nil
+}
+
+func (r files) ListByModel(ctx context.Context, modelID int64, p store.PageRequest) (*store.Page[models.ModelFile], error) {
+	defer r.s.lock()()
+	return page(r.s.d.filesOf(modelID), p, store.FileSorts, func(f models.ModelFile, sort string) (interface{}, int64) {
+		switch sort {
+		case "created":
+			return f.CreatedAt, f.ID
+		case "size":
+			return f.Size, f.ID
+		}
+		return f.Filename, f.ID
+	})
+}
+
+func (r files) AllByModel(ctx context.Context, modelID int64) ([]models.ModelFile, error) {
+	defer r.s.lock()()
+	return r.s.d.filesOf(modelID), nil
+}
+
+func (r files) Upsert(ctx context.Context, f *models.ModelFile) error {
+	defer r.s.lock()()
+	d := r.s.d
+	for _, stored := range d.files {
+		if stored.Path != f.Path {
+			continue
+		}
+		stored.Size, stored.Digest, stored.Format = f.Size, f.Digest, f.Format
+		stored.Width, stored.Depth, stored.Height = f.Width, f.Depth, f.Height
+		if f.MimeType != nil {
+			stored.MimeType = f.MimeType
+		}
+		if f.UploadedBy != nil {
+			stored.UploadedBy = f.UploadedBy
+		}
+		d.files[stored.ID] = stored
+		*f = stored
+		return nil
+	}
+	if _, ok := d.models[f.ModelID]; !ok {
+		return missing("model_id", f.ModelID, "models")
+	}
+	f.ID = d.id()
+	f.CreatedAt = now()
+	d.files[f.ID] = *f
+	return nil
+}
+
+func (r files) Delete(ctx context.Context, id int64) (*models.ModelFile, error) {
+	defer r.s.lock()()
+	f, ok := r.s.d.files[id]
+	if !ok || f.DeletedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	t := now()
+	f.DeletedAt = &t
+	r.s.d.files[id] = f
+	return &f, nil
+}
+
+func (r files) Restore(ctx context.Context, id int64) (*models.ModelFile, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	f, ok := d.files[id]
+	if !ok || f.DeletedAt == nil {
+		return nil, store.ErrNotFound
+	}
+	if d.models[f.ModelID].DeletedAt != nil {
+		return nil, &store.Error{Kind: store.ErrReference, Detail: "The file's model is in the trash."}
+	}
+	f.DeletedAt = nil
+	d.files[id] = f
+	return &f, nil
+}
+
+func (r files) Purge(ctx context.Context, id int64) (*models.ModelFile, error) {
+	defer r.s.lock()()
+	f, ok := r.s.d.files[id]
+	if !ok || f.DeletedAt == nil {
+		return nil, store.ErrNotFound
+	}
+	delete(r.s.d.files, id)
+	for _, m := range r.s.d.models {
+		if m.PreviewFileID != nil && *m.PreviewFileID == id {
+			m.PreviewFileID = nil
+			r.s.d.models[m.ID] = m
+		}
+	}
+	return &f, nil
+}
+
+func (r files) SetTrashPath(ctx context.Context, id int64, path *string) error {
+	defer r.s.lock()()
+	f, ok := r.s.d.files[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	f.TrashPath = path
+	r.s.d.files[id] = f
+	return nil
+}
+
+func (r files) Ignore(ctx context.Context, libraryID int64, path string) error {
+	defer r.s.lock()()
+	if _, ok := r.s.d.libraries[libraryID]; !ok {
+		return missing("library_id", libraryID, "libraries")
+	}
+	r.s.d.ignores[ignore{libraryID, path}] = true
+	return nil
+}
+
+func (r files) Unignore(ctx context.Context, libraryID int64, path string) error {
+	defer r.s.lock()()
+	delete(r.s.d.ignores, ignore{libraryID, path})
+	return nil
+}
+
+func (r files) Ignored(ctx context.Context, libraryID int64) ([]string, error) {
+	defer r.s.lock()()
+	list := []string{}
+	for key := range r.s.d.ignores {
+		if key.libraryID == libraryID {
+			list = append(list, key.path)
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// filesOf returns a model's files outside the trash ordered by id.
+func (d *data) filesOf(modelID int64) []models.ModelFile {
+	list := []models.ModelFile{}
+	for _, f := range d.files {
+		if f.ModelID == modelID && f.DeletedAt == nil {
+			list = append(list, f)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
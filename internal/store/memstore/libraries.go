@@ -0,0 +1,151 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+)
+
+type libraries struct{ s *Store }
+
+func (r libraries) List(ctx context.Context) ([]models.Library, error) {
+	defer r.s.lock()()
+	list := []models.Library{}
+	for _, l := range r.s.d.libraries {
+		if l.DeletedAt == nil {
+			list = append(list, l)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (r libraries) Get(ctx context.Context, id int64) (*models.Library, error) {
+	defer r.s.lock()()
+	l, ok := r.s.d.libraries[id]
+	if !ok || l.DeletedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	return &l, nil
+}
+
+func (r libraries) Create(ctx context.Context, l *models.Library) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if err := d.uniqueLibraryPath(0, l.Path); err != nil {
+		return err
+	}
+	if l.Storage == "" {
+		l.Storage = "local"
+	}
+	l.ID = d.id()
+	l.CreatedAt = now()
+	l.UpdatedAt = l.CreatedAt
+	d.libraries[l.ID] = *l
+	return nil
+}
+
+func (r libraries) Update(ctx context.Context, l *models.Library) error {
+	defer r.s.lock()()
+	d := r.s.d
+	stored, ok := d.libraries[l.ID]
+	if err := checkStale(ok, stored.UpdatedAt, l.UpdatedAt); err != nil {
+		return err
+	}
+	if err := d.uniqueLibraryPath(l.ID, l.Path); err != nil {
+		return err
+	}
+	stored.Name, stored.Path, stored.Storage, stored.QuotaMB = l.Name, l.Path, l.Storage, l.QuotaMB
+	stored.UpdatedAt = now()
+	d.libraries[l.ID] = stored
+	*l = stored
+	return nil
+}
+
+func (r libraries) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	d := r.s.d
+	l, ok := d.libraries[id]
+	if !ok || l.DeletedAt != nil {
+		return store.ErrNotFound
+	}
+	t := now()
+	l.DeletedAt = &t
+	d.libraries[id] = l
+	for _, m := range d.models {
+		if m.LibraryID == id && m.DeletedAt == nil {
+			d.trashModel(m, t)
+		}
+	}
+	return nil
+}
+
+func (r libraries) Restore(ctx context.Context, id int64) (*models.Library, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	l, ok := d.libraries[id]
+	if !ok || l.DeletedAt == nil {
+		return nil, store.ErrNotFound
+	}
+	for _, m := range d.models {
+		if m.LibraryID == id && m.DeletedAt != nil && m.DeletedAt.Equal(*l.DeletedAt) {
+			d.restoreModel(m)
+		}
+	}
+	l.DeletedAt = nil
+	d.libraries[id] = l
+	return &l, nil
+}
+
+func (r libraries) Purge(ctx context.Context, id int64) ([]models.ModelFile, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	if l, ok := d.libraries[id]; !ok || l.DeletedAt == nil {
+		return nil, store.ErrNotFound
+	}
+	delete(d.libraries, id)
+	for key := range d.members {
+		if key.libraryID == id {
+			delete(d.members, key)
+		}
+	}
+	for key := range d.ignores {
+		if key.libraryID == id {
+			delete(d.ignores, key)
+		}
+	}
+	list := []models.ModelFile{}
+	for _, m := range d.models {
+		if m.LibraryID == id {
+			list = append(list, d.deleteModel(m.ID)...)
+		}
+	}
+	return list, nil
+}
+
+func (r libraries) Usage(ctx context.Context, id int64) (int64, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	var n int64
+	for _, f := range d.files {
+		if m, ok := d.models[f.ModelID]; ok && m.LibraryID == id {
+			n += f.Size
+		}
+	}
+	return n, nil
+}
+
+func (d *data) uniqueLibraryPath(id int64, path string) error {
+	for _, l := range d.libraries {
+		if l.ID != id && l.Path == path {
+			return duplicate("path", path)
+		}
+	}
+	return nil
+}
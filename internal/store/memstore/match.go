@@ -0,0 +1,118 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/search"
+	"3d-library/internal/store"
+	"slices"
+	"strings"
+)
+
+// filter returns the models matching f.
+func (d *data) filter(f store.ModelFilter) []models.Model {
+	list := []models.Model{}
+	for _, m := range d.models {
+		if m.DeletedAt != nil {
+			continue
+		}
+		if f.LibraryID != 0 && m.LibraryID != f.LibraryID {
+			continue
+		}
+		if f.LibraryIDs != nil && !slices.Contains(f.LibraryIDs, m.LibraryID) {
+			continue
+		}
+		if f.CollectionID != 0 && !d.modelCollections[link{m.ID, f.CollectionID}] {
+			continue
+		}
+		if f.Query != nil && !d.matches(m, f.Query) {
+			continue
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+// matches evaluates a query the way search.Query.SQL does. A numeric term
+// over a missing dimension matches neither itself nor its negation, as
+// comparisons with NULL do.
+func (d *data) matches(m models.Model, q *search.Query) bool {
+	for _, t := range q.Terms {
+		ok, known := d.term(m, t)
+		if !known {
+			return false
+		}
+		if ok == t.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *data) term(m models.Model, t search.Term) (ok, known bool) {
+	switch t.Field {
+	case "tag":
+		for l := range d.modelTags {
+			if l.modelID == m.ID && strings.EqualFold(d.tags[l.otherID].Name, t.Value) {
+				return true, true
+			}
+		}
+		return false, true
+	case "format":
+		for _, f := range d.filesOf(m.ID) {
+			if f.Format != nil && *f.Format == t.Value {
+				return true, true
+			}
+		}
+		return false, true
+	case "library":
+		lib, found := d.libraries[m.LibraryID]
+		return found && strings.EqualFold(lib.Name, t.Value), true
+	case "collection":
+		for l := range d.modelCollections {
+			if l.modelID == m.ID && strings.EqualFold(d.collections[l.otherID].Name, t.Value) {
+				return true, true
+			}
+		}
+		return false, true
+	case "printed":
+		return (m.PrintCount > 0) == (t.Value == "yes"), true
+	case "name":
+		return containsFold(m.Name, t.Value), true
+	case "path":
+		return containsFold(m.Path, t.Value), true
+	case "size":
+		return compareNum(float64(m.TotalSize), t), true
+	case "width", "depth", "height":
+		v := map[string]*float64{"width": m.Width, "depth": m.Depth, "height": m.Height}[t.Field]
+		if v == nil {
+			return false, false
+		}
+		return compareNum(*v, t), true
+	}
+	if containsFold(m.Name, t.Value) || containsFold(m.Path, t.Value) ||
+		(m.Description != nil && containsFold(*m.Description, t.Value)) {
+		return true, true
+	}
+	for _, f := range d.filesOf(m.ID) {
+		if containsFold(f.Filename, t.Value) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func compareNum(v float64, t search.Term) bool {
+	switch t.Op {
+	case "..":
+		return v >= t.Num && v <= t.Max
+	case "<":
+		return v < t.Num
+	case "<=":
+		return v <= t.Num
+	case ">":
+		return v > t.Num
+	case ">=":
+		return v >= t.Num
+	}
+	return v == t.Num
+}
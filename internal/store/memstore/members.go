@@ -0,0 +1,77 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+	"strings"
+)
+
+type membership struct {
+	libraryID int64
+	userID    int64
+}
+
+type members struct{ s *Store }
+
+func (r members) ListForLibrary(ctx context.Context, libraryID int64) ([]models.LibraryMember, error) {
+	defer r.s.lock()()
+	list := r.s.d.membersWhere(func(m models.LibraryMember) bool { return m.LibraryID == libraryID })
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Username) < strings.ToLower(list[j].Username)
+	})
+	return list, nil
+}
+
+func (r members) ForUser(ctx context.Context, userID int64) ([]models.LibraryMember, error) {
+	defer r.s.lock()()
+	list := r.s.d.membersWhere(func(m models.LibraryMember) bool { return m.UserID == userID })
+	sort.Slice(list, func(i, j int) bool { return list[i].LibraryID < list[j].LibraryID })
+	return list, nil
+}
+
+// membersWhere returns the matching members with their current usernames.
+func (d *data) membersWhere(match func(models.LibraryMember) bool) []models.LibraryMember {
+	list := []models.LibraryMember{}
+	for _, m := range d.members {
+		if match(m) {
+			m.Username = d.users[m.UserID].Username
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+func (r members) Set(ctx context.Context, m *models.LibraryMember) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.libraries[m.LibraryID]; !ok {
+		return missing("library_id", m.LibraryID, "libraries")
+	}
+	user, ok := d.users[m.UserID]
+	if !ok {
+		return missing("user_id", m.UserID, "users")
+	}
+	key := membership{m.LibraryID, m.UserID}
+	stored, ok := d.members[key]
+	if !ok {
+		stored = models.LibraryMember{LibraryID: m.LibraryID, UserID: m.UserID, CreatedAt: now()}
+	}
+	stored.Role = m.Role
+	stored.UpdatedAt = now()
+	d.members[key] = stored
+	*m = stored
+	m.Username = user.Username
+	return nil
+}
+
+func (r members) Remove(ctx context.Context, libraryID, userID int64) error {
+	defer r.s.lock()()
+	key := membership{libraryID, userID}
+	if _, ok := r.s.d.members[key]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.s.d.members, key)
+	return nil
+}
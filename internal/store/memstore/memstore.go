@@ -0,0 +1,169 @@
+// Package memstore implements store.Store in memory, for tests and for
+// running without a database. It enforces the same unique keys, references
+// and cascades as the SQL schema.
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu *sync.Mutex
+	d  *data
+	// inTx is set on the Store handed to an InTx callback, which already
+	// holds mu.
+	inTx bool
+}
+
+// ignore is a path, relative to the library root, that scans skip.
+type ignore struct {
+	libraryID int64
+	path      string
+}
+
+// link is a (model, tag) or (model, collection) pair.
+type link struct {
+	modelID int64
+	otherID int64
+}
+
+type data struct {
+	nextID           int64
+	libraries        map[int64]models.Library
+	models           map[int64]models.Model
+	files            map[int64]models.ModelFile
+	tags             map[int64]models.Tag
+	collections      map[int64]models.Collection
+	savedSearches    map[int64]models.SavedSearch
+	modelTags        map[link]bool
+	modelCollections map[link]bool
+	users            map[int64]models.User
+	sessions         map[string]models.Session
+	tokens           map[int64]models.APIToken
+	members          map[membership]models.LibraryMember
+	shares           map[int64]models.ShareLink
+	ignores          map[ignore]bool
+	bulkJobs         map[int64]models.BulkJob
+	audit            []models.AuditEntry
+}
+
+func New() *Store {
+	return &Store{mu: &sync.Mutex{}, d: &data{
+		libraries:        map[int64]models.Library{},
+		models:           map[int64]models.Model{},
+		files:            map[int64]models.ModelFile{},
+		tags:             map[int64]models.Tag{},
+		collections:      map[int64]models.Collection{},
+		savedSearches:    map[int64]models.SavedSearch{},
+		modelTags:        map[link]bool{},
+		modelCollections: map[link]bool{},
+		users:            map[int64]models.User{},
+		sessions:         map[string]models.Session{},
+		tokens:           map[int64]models.APIToken{},
+		members:          map[membership]models.LibraryMember{},
+		shares:           map[int64]models.ShareLink{},
+		ignores:          map[ignore]bool{},
+		bulkJobs:         map[int64]models.BulkJob{},
+	}}
+}
+
+func (s *Store) Libraries() store.Libraries         { return libraries{s} }
+func (s *Store) Models() store.Models               { return modelRepo{s} }
+func (s *Store) Files() store.Files                 { return files{s} }
+func (s *Store) Tags() store.Tags                   { return tags{s} }
+func (s *Store) Collections() store.Collections     { return collections{s} }
+func (s *Store) SavedSearches() store.SavedSearches { return savedSearches{s} }
+func (s *Store) Users() store.Users                 { return users{s} }
+func (s *Store) Sessions() store.Sessions           { return sessions{s} }
+func (s *Store) Tokens() store.Tokens               { return tokens{s} }
+func (s *Store) Members() store.Members             { return members{s} }
+func (s *Store) Shares() store.Shares               { return shares{s} }
+func (s *Store) Audit() store.Audit                 { return audit{s} }
+func (s *Store) Trash() store.Trash                 { return trash{s} }
+func (s *Store) BulkJobs() store.BulkJobs           { return bulkJobs{s} }
+
+// InTx runs fn against a copy of the data and keeps the copy if fn
+// succeeds. Transactions are serialised, so fn must only use the Store it
+// is given.
+func (s *Store) InTx(ctx context.Context, fn func(tx store.Store) error) error {
+	unlock := s.lock()
+	defer unlock()
+	tx := &Store{mu: s.mu, d: s.d.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	*s.d = *tx.d
+	return nil
+}
+
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (d *data) clone() *data {
+	return &data{
+		nextID:           d.nextID,
+		libraries:        cloneMap(d.libraries),
+		models:           cloneMap(d.models),
+		files:            cloneMap(d.files),
+		tags:             cloneMap(d.tags),
+		collections:      cloneMap(d.collections),
+		savedSearches:    cloneMap(d.savedSearches),
+		modelTags:        cloneMap(d.modelTags),
+		modelCollections: cloneMap(d.modelCollections),
+		users:            cloneMap(d.users),
+		sessions:         cloneMap(d.sessions),
+		tokens:           cloneMap(d.tokens),
+		members:          cloneMap(d.members),
+		shares:           cloneMap(d.shares),
+		ignores:          cloneMap(d.ignores),
+		bulkJobs:         cloneMap(d.bulkJobs),
+		// Capping the capacity makes the transaction append to a copy.
+		audit: d.audit[:len(d.audit):len(d.audit)],
+	}
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (d *data) id() int64 {
+	d.nextID++
+	return d.nextID
+}
+
+func now() time.Time {
+	return time.Now().UTC().Round(0)
+}
+
+func duplicate(key string, value interface{}) error {
+	return &store.Error{Kind: store.ErrDuplicate, Detail: fmt.Sprintf("Key (%s)=(%v) already exists.", key, value)}
+}
+
+func missing(key string, value interface{}, table string) error {
+	return &store.Error{Kind: store.ErrReference, Detail: fmt.Sprintf("Key (%s)=(%v) is not present in table %q.", key, value, table)}
+}
+
+// checkStale is the updated_at compare of the SQL stores' updates.
+func checkStale(exists bool, stored, read time.Time) error {
+	if !exists {
+		return store.ErrNotFound
+	}
+	if !stored.Equal(read) {
+		return store.ErrStale
+	}
+	return nil
+}
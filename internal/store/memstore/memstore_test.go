@@ -0,0 +1,11 @@
+package memstore
+
+import (
+	"3d-library/internal/store"
+	"3d-library/internal/store/storetest"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return New() })
+}
@@ -0,0 +1,386 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type modelRepo struct{ s *Store }
+
+func (r modelRepo) Get(ctx context.Context, id int64) (*models.Model, error) {
+	defer r.s.lock()()
+	m, ok := r.s.d.models[id]
+	if !ok || m.DeletedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	return &m, nil
+}
+
+func (r modelRepo) GetMany(ctx context.Context, ids []int64) (map[int64]models.Model, error) {
+	defer r.s.lock()()
+	byID := make(map[int64]models.Model, len(ids))
+	for _, id := range ids {
+		if m, ok := r.s.d.models[id]; ok && m.DeletedAt == nil {
+			byID[id] = m
+		}
+	}
+	return byID, nil
+}
+
+func (r modelRepo) List(ctx context.Context, f store.ModelFilter, p store.PageRequest) (*store.Page[models.Model], error) {
+	defer r.s.lock()()
+	return page(r.s.d.filter(f), p, store.ModelSorts, func(m models.Model, sort string) (interface{}, int64) {
+		switch sort {
+		case "name":
+			return m.Name, m.ID
+		case "updated":
+			return m.UpdatedAt, m.ID
+		case "size":
+			return m.TotalSize, m.ID
+		case "prints":
+			return int64(m.PrintCount), m.ID
+		}
+		return m.CreatedAt, m.ID
+	})
+}
+
+func (r modelRepo) IDs(ctx context.Context, f store.ModelFilter) ([]int64, error) {
+	defer r.s.lock()()
+	ids := []int64{}
+	for _, m := range r.s.d.filter(f) {
+		ids = append(ids, m.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (r modelRepo) Facets(ctx context.Context, f store.ModelFilter) (*store.Facets, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	tags, formats, libs, cols := counter{}, counter{}, counter{}, counter{}
+	for _, m := range d.filter(f) {
+		for l := range d.modelTags {
+			if l.modelID == m.ID {
+				tags.add(d.tags[l.otherID].Name)
+			}
+		}
+		seen := map[string]bool{}
+		for _, file := range d.filesOf(m.ID) {
+			if file.Format != nil && !seen[*file.Format] {
+				seen[*file.Format] = true
+				formats.add(*file.Format)
+			}
+		}
+		if lib, ok := d.libraries[m.LibraryID]; ok {
+			libs.add(lib.Name)
+		}
+		for l := range d.modelCollections {
+			if l.modelID == m.ID {
+				cols.add(d.collections[l.otherID].Name)
+			}
+		}
+	}
+	return &store.Facets{
+		Tags:        tags.top(),
+		Formats:     formats.top(),
+		Libraries:   libs.top(),
+		Collections: cols.top(),
+	}, nil
+}
+
+type counter map[string]int
+
+func (c counter) add(value string) {
+	c[value]++
+}
+
+// top orders values like the SQL facets: most models first, then by value.
+func (c counter) top() []store.FacetCount {
+	list := []store.FacetCount{}
+	for v, n := range c {
+		list = append(list, store.FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > store.MaxFacetValues {
+		list = list[:store.MaxFacetValues]
+	}
+	return list
+}
+
+func (r modelRepo) Create(ctx context.Context, m *models.Model) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if stored, ok := d.modelAt(m.LibraryID, m.Path); ok {
+		if stored.DeletedAt != nil {
+			return &store.Error{Kind: store.ErrDuplicate, Detail: fmt.Sprintf("The model at %s is in the trash.", m.Path)}
+		}
+		stored.Name, stored.Description = m.Name, m.Description
+		stored.UpdatedAt = now()
+		d.models[stored.ID] = stored
+		*m = stored
+		return nil
+	}
+	return d.insertModel(m)
+}
+
+func (r modelRepo) Ensure(ctx context.Context, m *models.Model) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if stored, ok := d.modelAt(m.LibraryID, m.Path); ok {
+		stored.UpdatedAt = now()
+		d.models[stored.ID] = stored
+		*m = stored
+		return nil
+	}
+	return d.insertModel(m)
+}
+
+func (d *data) insertModel(m *models.Model) error {
+	if _, ok := d.libraries[m.LibraryID]; !ok {
+		return missing("library_id", m.LibraryID, "libraries")
+	}
+	*m = models.Model{
+		ID:          d.id(),
+		LibraryID:   m.LibraryID,
+		Name:        m.Name,
+		Path:        m.Path,
+		Description: m.Description,
+		CreatedAt:   now(),
+	}
+	m.UpdatedAt = m.CreatedAt
+	d.models[m.ID] = *m
+	return nil
+}
+
+func (d *data) modelAt(libraryID int64, path string) (models.Model, bool) {
+	for _, m := range d.models {
+		if m.LibraryID == libraryID && m.Path == path {
+			return m, true
+		}
+	}
+	return models.Model{}, false
+}
+
+func (r modelRepo) Update(ctx context.Context, m *models.Model) error {
+	defer r.s.lock()()
+	d := r.s.d
+	stored, ok := d.models[m.ID]
+	if err := checkStale(ok, stored.UpdatedAt, m.UpdatedAt); err != nil {
+		return err
+	}
+	if err := d.placeModel(m.ID, m.LibraryID, m.Path); err != nil {
+		return err
+	}
+	stored.Name, stored.Description, stored.PreviewFileID = m.Name, m.Description, m.PreviewFileID
+	stored.LibraryID, stored.Path = m.LibraryID, m.Path
+	stored.UpdatedAt = now()
+	d.models[m.ID] = stored
+	*m = stored
+	return nil
+}
+
+// placeModel checks that model id may live at path in the library.
+func (d *data) placeModel(id, libraryID int64, path string) error {
+	if _, ok := d.libraries[libraryID]; !ok {
+		return missing("library_id", libraryID, "libraries")
+	}
+	if other, ok := d.modelAt(libraryID, path); ok && other.ID != id {
+		return duplicate("library_id, path", path)
+	}
+	return nil
+}
+
+func (r modelRepo) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	m, ok := r.s.d.models[id]
+	if !ok || m.DeletedAt != nil {
+		return store.ErrNotFound
+	}
+	r.s.d.trashModel(m, now())
+	return nil
+}
+
+// trashModel moves the model and its files to the trash at t.
+func (d *data) trashModel(m models.Model, t time.Time) {
+	m.DeletedAt = &t
+	d.models[m.ID] = m
+	for _, f := range d.filesOf(m.ID) {
+		f.DeletedAt = &t
+		d.files[f.ID] = f
+	}
+}
+
+// restoreModel brings back the model and the files trashed with it.
+func (d *data) restoreModel(m models.Model) {
+	for _, f := range d.files {
+		if f.ModelID == m.ID && f.DeletedAt != nil && f.DeletedAt.Equal(*m.DeletedAt) {
+			f.DeletedAt = nil
+			d.files[f.ID] = f
+		}
+	}
+	m.DeletedAt = nil
+	d.models[m.ID] = m
+}
+
+func (r modelRepo) Restore(ctx context.Context, id int64) (*models.Model, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	m, ok := d.models[id]
+	if !ok || m.DeletedAt == nil {
+		return nil, store.ErrNotFound
+	}
+	if d.libraries[m.LibraryID].DeletedAt != nil {
+		return nil, &store.Error{Kind: store.ErrReference, Detail: "The model's library is in the trash."}
+	}
+	d.restoreModel(m)
+	m = d.models[id]
+	return &m, nil
+}
+
+func (r modelRepo) Purge(ctx context.Context, id int64) ([]models.ModelFile, error) {
+	defer r.s.lock()()
+	if m, ok := r.s.d.models[id]; !ok || m.DeletedAt == nil {
+		return nil, store.ErrNotFound
+	}
+	return r.s.d.deleteModel(id), nil
+}
+
+// deleteModel removes the model for good with what refers to it, and
+// returns its files.
+func (d *data) deleteModel(id int64) []models.ModelFile {
+	delete(d.models, id)
+	list := []models.ModelFile{}
+	for _, f := range d.files {
+		if f.ModelID == id {
+			list = append(list, f)
+			delete(d.files, f.ID)
+		}
+	}
+	for l := range d.modelTags {
+		if l.modelID == id {
+			delete(d.modelTags, l)
+		}
+	}
+	for l := range d.modelCollections {
+		if l.modelID == id {
+			delete(d.modelCollections, l)
+		}
+	}
+	for _, s := range d.shares {
+		if s.ModelID != nil && *s.ModelID == id {
+			delete(d.shares, s.ID)
+		}
+	}
+	return list
+}
+
+func (r modelRepo) SetPreview(ctx context.Context, id int64, fileID *int64) error {
+	defer r.s.lock()()
+	m, ok := r.s.d.models[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	m.PreviewFileID = fileID
+	m.UpdatedAt = now()
+	r.s.d.models[id] = m
+	return nil
+}
+
+func (r modelRepo) RecordPrint(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	m, ok := r.s.d.models[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	t := now()
+	m.PrintCount++
+	m.LastPrintedAt = &t
+	m.UpdatedAt = t
+	r.s.d.models[id] = m
+	return nil
+}
+
+func (r modelRepo) RefreshStats(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	d := r.s.d
+	m, ok := d.models[id]
+	if !ok {
+		return nil
+	}
+	m.TotalSize, m.Width, m.Depth, m.Height = 0, nil, nil, nil
+	for _, f := range d.filesOf(id) {
+		m.TotalSize += f.Size
+		m.Width = maxOf(m.Width, f.Width)
+		m.Depth = maxOf(m.Depth, f.Depth)
+		m.Height = maxOf(m.Height, f.Height)
+	}
+	d.models[id] = m
+	return nil
+}
+
+// maxOf is SQL MAX over nullable values.
+func maxOf(a, b *float64) *float64 {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
+
+func (r modelRepo) Move(ctx context.Context, id, libraryID int64, path string) error {
+	defer r.s.lock()()
+	d := r.s.d
+	m, ok := d.models[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if err := d.placeModel(id, libraryID, path); err != nil {
+		return err
+	}
+	oldPath := m.Path
+	m.LibraryID, m.Path = libraryID, path
+	m.UpdatedAt = now()
+	d.models[id] = m
+	for _, f := range d.files {
+		if f.ModelID == id {
+			d.rewriteFile(f, oldPath, path)
+		}
+	}
+	return nil
+}
+
+func (r modelRepo) RewritePaths(ctx context.Context, libraryID int64, oldRoot, newRoot string) error {
+	defer r.s.lock()()
+	d := r.s.d
+	for _, m := range d.models {
+		if m.LibraryID != libraryID {
+			continue
+		}
+		if strings.HasPrefix(m.Path, oldRoot) {
+			m.Path = newRoot + m.Path[len(oldRoot):]
+			d.models[m.ID] = m
+		}
+	}
+	for _, f := range d.files {
+		if m, ok := d.models[f.ModelID]; ok && m.LibraryID == libraryID {
+			d.rewriteFile(f, oldRoot, newRoot)
+		}
+	}
+	return nil
+}
+
+func (d *data) rewriteFile(f models.ModelFile, oldPrefix, newPrefix string) {
+	if strings.HasPrefix(f.Path, oldPrefix) {
+		f.Path = newPrefix + f.Path[len(oldPrefix):]
+		d.files[f.ID] = f
+	}
+}
@@ -0,0 +1,112 @@
+package memstore
+
+import (
+	"3d-library/internal/store"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// page sorts rows by (sort value, id), skips those up to the cursor and
+// returns one page of them. key returns a row's value for the sort, as a
+// string, int64 or time.Time, and its id.
+func page[T any](rows []T, p store.PageRequest, sorts []string, key func(row T, sort string) (interface{}, int64)) (*store.Page[T], error) {
+	known := false
+	for _, name := range sorts {
+		known = known || name == p.Sort
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown sort %q", p.Sort)
+	}
+
+	var total *int
+	if p.After == nil {
+		n := len(rows)
+		total = &n
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		vi, idi := key(rows[i], p.Sort)
+		vj, idj := key(rows[j], p.Sort)
+		c := compare(vi, vj)
+		if c == 0 {
+			c = compare(idi, idj)
+		}
+		if p.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	kept := []T{}
+	for _, row := range rows {
+		if p.After != nil {
+			v, id := key(row, p.Sort)
+			c := compare(v, parseCursor(v, p.After.Value))
+			if c == 0 {
+				c = compare(id, p.After.ID)
+			}
+			if p.Desc {
+				c = -c
+			}
+			if c <= 0 {
+				continue
+			}
+		}
+		kept = append(kept, row)
+		if len(kept) > p.Limit {
+			break
+		}
+	}
+	return store.NewPage(p, kept, total, func(row T) (string, int64) {
+		v, id := key(row, p.Sort)
+		return formatCursor(v), id
+	}), nil
+}
+
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int64:
+		switch b := b.(int64); {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+// parseCursor reads a cursor value as the same type as like.
+func parseCursor(like interface{}, s string) interface{} {
+	switch like.(type) {
+	case int64:
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	case time.Time:
+		t, _ := time.Parse(time.RFC3339Nano, s)
+		return t
+	}
+	return s
+}
+
+func formatCursor(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return store.CursorTime(v)
+	}
+	return v.(string)
+}
+
+// containsFold is ILIKE '%sub%'.
+func containsFold(s, sub string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+}
@@ -0,0 +1,89 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+	"sort"
+)
+
+type savedSearches struct{ s *Store }
+
+func (r savedSearches) List(ctx context.Context, userID int64) ([]models.SavedSearch, error) {
+	defer r.s.lock()()
+	list := []models.SavedSearch{}
+	for _, s := range r.s.d.savedSearches {
+		if s.UserID == userID {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r savedSearches) Get(ctx context.Context, userID, id int64) (*models.SavedSearch, error) {
+	defer r.s.lock()()
+	s, ok := r.s.d.savedSearches[id]
+	if !ok || s.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	return &s, nil
+}
+
+func (r savedSearches) Create(ctx context.Context, s *models.SavedSearch) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.users[s.UserID]; !ok {
+		return missing("user_id", s.UserID, "users")
+	}
+	if err := r.checkName(s); err != nil {
+		return err
+	}
+	s.ID = d.id()
+	s.CreatedAt = now()
+	d.savedSearches[s.ID] = *s
+	return nil
+}
+
+func (r savedSearches) Update(ctx context.Context, s *models.SavedSearch) error {
+	defer r.s.lock()()
+	d := r.s.d
+	stored, ok := d.savedSearches[s.ID]
+	if !ok || stored.UserID != s.UserID {
+		return store.ErrNotFound
+	}
+	if err := r.checkName(s); err != nil {
+		return err
+	}
+	stored.Name = s.Name
+	stored.Query = s.Query
+	d.savedSearches[s.ID] = stored
+	*s = stored
+	return nil
+}
+
+func (r savedSearches) Delete(ctx context.Context, userID, id int64) error {
+	defer r.s.lock()()
+	s, ok := r.s.d.savedSearches[id]
+	if !ok || s.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(r.s.d.savedSearches, id)
+	return nil
+}
+
+// checkName is the (user_id, name) unique key.
+func (r savedSearches) checkName(s *models.SavedSearch) error {
+	for _, other := range r.s.d.savedSearches {
+		if other.ID != s.ID && other.UserID == s.UserID && other.Name == s.Name {
+			return duplicate("user_id, name", fmt.Sprintf("%d, %s", s.UserID, s.Name))
+		}
+	}
+	return nil
+}
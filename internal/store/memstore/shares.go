@@ -0,0 +1,94 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+)
+
+type shares struct{ s *Store }
+
+func (r shares) List(ctx context.Context, userID int64) ([]models.ShareLink, error) {
+	defer r.s.lock()()
+	list := []models.ShareLink{}
+	for _, l := range r.s.d.shares {
+		if userID == 0 || l.UserID == userID {
+			list = append(list, l)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (r shares) Get(ctx context.Context, id int64) (*models.ShareLink, error) {
+	defer r.s.lock()()
+	l, ok := r.s.d.shares[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &l, nil
+}
+
+func (r shares) GetByHash(ctx context.Context, hash string) (*models.ShareLink, error) {
+	defer r.s.lock()()
+	for _, l := range r.s.d.shares {
+		if l.Hash == hash {
+			return &l, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (r shares) Create(ctx context.Context, l *models.ShareLink) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.users[l.UserID]; !ok {
+		return missing("user_id", l.UserID, "users")
+	}
+	if l.ModelID != nil {
+		if _, ok := d.models[*l.ModelID]; !ok {
+			return missing("model_id", *l.ModelID, "models")
+		}
+	}
+	if l.CollectionID != nil {
+		if _, ok := d.collections[*l.CollectionID]; !ok {
+			return missing("collection_id", *l.CollectionID, "collections")
+		}
+	}
+	for _, other := range d.shares {
+		if other.Hash == l.Hash {
+			return duplicate("token_hash", l.Hash)
+		}
+	}
+	l.ID = d.id()
+	l.Downloads = 0
+	l.CreatedAt = now()
+	d.shares[l.ID] = *l
+	return nil
+}
+
+func (r shares) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	if _, ok := r.s.d.shares[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.s.d.shares, id)
+	return nil
+}
+
+func (r shares) CountDownload(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	l, ok := r.s.d.shares[id]
+	if !ok || l.MaxDownloads != nil && l.Downloads >= *l.MaxDownloads {
+		return store.ErrNotFound
+	}
+	l.Downloads++
+	r.s.d.shares[id] = l
+	return nil
+}
@@ -0,0 +1,126 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+)
+
+type tags struct{ s *Store }
+
+func (r tags) List(ctx context.Context, name string, p store.PageRequest) (*store.Page[models.Tag], error) {
+	defer r.s.lock()()
+	list := []models.Tag{}
+	for _, t := range r.s.d.tags {
+		if containsFold(t.Name, name) {
+			list = append(list, t)
+		}
+	}
+	return page(list, p, store.TagSorts, func(t models.Tag, sort string) (interface{}, int64) {
+		return t.Name, t.ID
+	})
+}
+
+func (r tags) Get(ctx context.Context, id int64) (*models.Tag, error) {
+	defer r.s.lock()()
+	t, ok := r.s.d.tags[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &t, nil
+}
+
+func (r tags) Ensure(ctx context.Context, name string) (*models.Tag, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	for _, t := range d.tags {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	t := models.Tag{ID: d.id(), Name: name, UpdatedAt: now()}
+	d.tags[t.ID] = t
+	return &t, nil
+}
+
+func (r tags) Update(ctx context.Context, t *models.Tag) error {
+	defer r.s.lock()()
+	d := r.s.d
+	stored, ok := d.tags[t.ID]
+	if err := checkStale(ok, stored.UpdatedAt, t.UpdatedAt); err != nil {
+		return err
+	}
+	for _, other := range d.tags {
+		if other.ID != t.ID && other.Name == t.Name {
+			return duplicate("name", t.Name)
+		}
+	}
+	stored.Name = t.Name
+	stored.UpdatedAt = now()
+	d.tags[t.ID] = stored
+	*t = stored
+	return nil
+}
+
+func (r tags) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.tags[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(d.tags, id)
+	for l := range d.modelTags {
+		if l.otherID == id {
+			delete(d.modelTags, l)
+		}
+	}
+	return nil
+}
+
+func (r tags) ForModel(ctx context.Context, modelID int64) ([]models.Tag, error) {
+	defer r.s.lock()()
+	list := []models.Tag{}
+	for l := range r.s.d.modelTags {
+		if l.modelID == modelID {
+			list = append(list, r.s.d.tags[l.otherID])
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (r tags) IDsForModels(ctx context.Context, modelIDs []int64) (map[int64][]int64, error) {
+	defer r.s.lock()()
+	byModel := make(map[int64][]int64, len(modelIDs))
+	for _, id := range modelIDs {
+		for l := range r.s.d.modelTags {
+			if l.modelID == id {
+				byModel[id] = append(byModel[id], l.otherID)
+			}
+		}
+		if ids := byModel[id]; ids != nil {
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		}
+	}
+	return byModel, nil
+}
+
+func (r tags) Attach(ctx context.Context, modelID, tagID int64) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.models[modelID]; !ok {
+		return missing("model_id", modelID, "models")
+	}
+	if _, ok := d.tags[tagID]; !ok {
+		return missing("tag_id", tagID, "tags")
+	}
+	d.modelTags[link{modelID, tagID}] = true
+	return nil
+}
+
+func (r tags) Detach(ctx context.Context, modelID, tagID int64) error {
+	defer r.s.lock()()
+	delete(r.s.d.modelTags, link{modelID, tagID})
+	return nil
+}
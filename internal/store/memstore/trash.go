@@ -0,0 +1,58 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"slices"
+	"sort"
+	"time"
+)
+
+type trash struct{ s *Store }
+
+func (r trash) List(ctx context.Context, f store.TrashFilter) (*store.TrashItems, error) {
+	defer r.s.lock()()
+	d := r.s.d
+	keep := func(libraryID int64, deletedAt *time.Time) bool {
+		return deletedAt != nil &&
+			(f.LibraryIDs == nil || slices.Contains(f.LibraryIDs, libraryID)) &&
+			(f.Before.IsZero() || deletedAt.Before(f.Before))
+	}
+	items := &store.TrashItems{Libraries: []models.Library{}, Models: []models.Model{}, Files: []models.ModelFile{}}
+	for _, l := range d.libraries {
+		if keep(l.ID, l.DeletedAt) {
+			items.Libraries = append(items.Libraries, l)
+		}
+	}
+	for _, m := range d.models {
+		if d.libraries[m.LibraryID].DeletedAt == nil && keep(m.LibraryID, m.DeletedAt) {
+			items.Models = append(items.Models, m)
+		}
+	}
+	for _, file := range d.files {
+		if m := d.models[file.ModelID]; m.DeletedAt == nil && keep(m.LibraryID, file.DeletedAt) {
+			items.Files = append(items.Files, file)
+		}
+	}
+	// Most recent first, like the SQL store.
+	newest := func(a, b *time.Time, aID, bID int64) bool {
+		if !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return aID > bID
+	}
+	sort.Slice(items.Libraries, func(i, j int) bool {
+		a, b := items.Libraries[i], items.Libraries[j]
+		return newest(a.DeletedAt, b.DeletedAt, a.ID, b.ID)
+	})
+	sort.Slice(items.Models, func(i, j int) bool {
+		a, b := items.Models[i], items.Models[j]
+		return newest(a.DeletedAt, b.DeletedAt, a.ID, b.ID)
+	})
+	sort.Slice(items.Files, func(i, j int) bool {
+		a, b := items.Files[i], items.Files[j]
+		return newest(a.DeletedAt, b.DeletedAt, a.ID, b.ID)
+	})
+	return items, nil
+}
@@ -0,0 +1,240 @@
+package memstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"sort"
+	"strings"
+)
+
+type users struct{ s *Store }
+
+func (r users) List(ctx context.Context) ([]models.User, error) {
+	defer r.s.lock()()
+	list := []models.User{}
+	for _, u := range r.s.d.users {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Username) < strings.ToLower(list[j].Username)
+	})
+	return list, nil
+}
+
+func (r users) Get(ctx context.Context, id int64) (*models.User, error) {
+	defer r.s.lock()()
+	u, ok := r.s.d.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r users) GetByName(ctx context.Context, username string) (*models.User, error) {
+	defer r.s.lock()()
+	for _, u := range r.s.d.users {
+		if strings.EqualFold(u.Username, username) {
+			return &u, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (r users) GetByExternalID(ctx context.Context, externalID string) (*models.User, error) {
+	defer r.s.lock()()
+	for _, u := range r.s.d.users {
+		if u.ExternalID != nil && *u.ExternalID == externalID {
+			return &u, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (r users) Count(ctx context.Context) (int, error) {
+	defer r.s.lock()()
+	return len(r.s.d.users), nil
+}
+
+func (r users) Create(ctx context.Context, u *models.User) error {
+	defer r.s.lock()()
+	d := r.s.d
+	for _, other := range d.users {
+		if strings.EqualFold(other.Username, u.Username) {
+			return duplicate("username", u.Username)
+		}
+		if u.ExternalID != nil && other.ExternalID != nil && *other.ExternalID == *u.ExternalID {
+			return duplicate("external_id", *u.ExternalID)
+		}
+	}
+	u.ID = d.id()
+	u.LastLoginAt = nil
+	u.CreatedAt = now()
+	u.UpdatedAt = u.CreatedAt
+	d.users[u.ID] = *u
+	return nil
+}
+
+func (r users) Update(ctx context.Context, u *models.User) error {
+	defer r.s.lock()()
+	d := r.s.d
+	stored, ok := d.users[u.ID]
+	if err := checkStale(ok, stored.UpdatedAt, u.UpdatedAt); err != nil {
+		return err
+	}
+	for id, other := range d.users {
+		if id != u.ID && u.ExternalID != nil && other.ExternalID != nil && *other.ExternalID == *u.ExternalID {
+			return duplicate("external_id", *u.ExternalID)
+		}
+	}
+	stored.PasswordHash, stored.Admin, stored.Disabled, stored.ExternalID = u.PasswordHash, u.Admin, u.Disabled, u.ExternalID
+	stored.UpdatedAt = now()
+	d.users[u.ID] = stored
+	*u = stored
+	return nil
+}
+
+func (r users) RecordLogin(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	u, ok := r.s.d.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	t := now()
+	u.LastLoginAt = &t
+	r.s.d.users[id] = u
+	return nil
+}
+
+func (r users) Usage(ctx context.Context, id int64) (int64, error) {
+	defer r.s.lock()()
+	var n int64
+	for _, f := range r.s.d.files {
+		if f.UploadedBy != nil && *f.UploadedBy == id {
+			n += f.Size
+		}
+	}
+	return n, nil
+}
+
+type sessions struct{ s *Store }
+
+func (r sessions) Create(ctx context.Context, sess *models.Session) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.users[sess.UserID]; !ok {
+		return missing("user_id", sess.UserID, "users")
+	}
+	if _, ok := d.sessions[sess.ID]; ok {
+		return duplicate("id", sess.ID)
+	}
+	t := now()
+	for id, other := range d.sessions {
+		if !other.ExpiresAt.After(t) {
+			delete(d.sessions, id)
+		}
+	}
+	sess.CreatedAt = t
+	d.sessions[sess.ID] = *sess
+	return nil
+}
+
+func (r sessions) Get(ctx context.Context, id string) (*models.Session, error) {
+	defer r.s.lock()()
+	sess, ok := r.s.d.sessions[id]
+	if !ok || !sess.ExpiresAt.After(now()) {
+		return nil, store.ErrNotFound
+	}
+	return &sess, nil
+}
+
+func (r sessions) Delete(ctx context.Context, id string) error {
+	defer r.s.lock()()
+	if _, ok := r.s.d.sessions[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.s.d.sessions, id)
+	return nil
+}
+
+func (r sessions) DeleteForUser(ctx context.Context, userID int64) error {
+	defer r.s.lock()()
+	for id, sess := range r.s.d.sessions {
+		if sess.UserID == userID {
+			delete(r.s.d.sessions, id)
+		}
+	}
+	return nil
+}
+
+type tokens struct{ s *Store }
+
+func (r tokens) ListForUser(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	defer r.s.lock()()
+	list := []models.APIToken{}
+	for _, t := range r.s.d.tokens {
+		if t.UserID == userID {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (r tokens) GetByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	defer r.s.lock()()
+	for _, t := range r.s.d.tokens {
+		if t.Hash == hash {
+			if t.ExpiresAt != nil && !t.ExpiresAt.After(now()) {
+				break
+			}
+			return &t, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (r tokens) Create(ctx context.Context, t *models.APIToken) error {
+	defer r.s.lock()()
+	d := r.s.d
+	if _, ok := d.users[t.UserID]; !ok {
+		return missing("user_id", t.UserID, "users")
+	}
+	for _, other := range d.tokens {
+		if other.Hash == t.Hash {
+			return duplicate("token_hash", t.Hash)
+		}
+	}
+	t.ID = d.id()
+	t.LastUsedAt = nil
+	t.CreatedAt = now()
+	d.tokens[t.ID] = *t
+	return nil
+}
+
+func (r tokens) Delete(ctx context.Context, userID, id int64) error {
+	defer r.s.lock()()
+	t, ok := r.s.d.tokens[id]
+	if !ok || t.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(r.s.d.tokens, id)
+	return nil
+}
+
+func (r tokens) Touch(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+	t, ok := r.s.d.tokens[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	used := now()
+	t.LastUsedAt = &used
+	r.s.d.tokens[id] = t
+	return nil
+}
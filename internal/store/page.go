@@ -0,0 +1,54 @@
+package store
+
+import "time"
+
+// Sort names accepted by each listing, in the order they are documented.
+var (
+	ModelSorts      = []string{"name", "created", "updated", "size", "prints"}
+	FileSorts       = []string{"name", "created", "size"}
+	TagSorts        = []string{"name"}
+	CollectionSorts = []string{"name", "created"}
+	AuditSorts      = []string{"created"}
+)
+
+// PageRequest asks for one page of a keyset-paginated listing ordered by
+// (Sort, id). After is the last row of the previous page.
+type PageRequest struct {
+	Limit int
+	Sort  string
+	Desc  bool
+	After *Cursor
+}
+
+// Cursor identifies a row by its sort value and id.
+type Cursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+type Page[T any] struct {
+	Items []T
+	// Total counts every row across the pages. It is only counted for the
+	// first page and is nil on pages after a cursor, which would otherwise
+	// pay for a full count each time.
+	Total *int
+	// Next is nil on the last page.
+	Next *Cursor
+}
+
+// NewPage trims the extra row fetched beyond the limit and sets Next from
+// the last row kept. key returns a row's sort value and id.
+func NewPage[T any](p PageRequest, items []T, total *int, key func(T) (string, int64)) *Page[T] {
+	page := &Page[T]{Items: items, Total: total}
+	if len(items) > p.Limit {
+		page.Items = items[:p.Limit]
+		v, id := key(page.Items[len(page.Items)-1])
+		page.Next = &Cursor{Value: v, ID: id}
+	}
+	return page
+}
+
+// CursorTime formats a timestamp sort value.
+func CursorTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"3d-library/internal/models"
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPreview picks the file to show for a model: the first image, else
+// the first mesh, else nil.
+func DefaultPreview(files []models.ModelFile) *int64 {
+	for _, exts := range [][]string{{".png", ".jpg", ".jpeg"}, {".stl", ".obj", ".3mf"}} {
+		for _, f := range files {
+			ext := strings.ToLower(filepath.Ext(f.Filename))
+			for _, want := range exts {
+				if ext == want {
+					id := f.ID
+					return &id
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ChooseDefaultPreview sets the model's preview to DefaultPreview of its
+// files. A model without usable files keeps its current preview.
+func ChooseDefaultPreview(ctx context.Context, s Store, modelID int64) error {
+	files, err := s.Files().AllByModel(ctx, modelID)
+	if err != nil {
+		return err
+	}
+	if id := DefaultPreview(files); id != nil {
+		return s.Models().SetPreview(ctx, modelID, id)
+	}
+	return nil
+}
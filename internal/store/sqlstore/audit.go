@@ -0,0 +1,64 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+	"strings"
+)
+
+type audit struct{ s *Store }
+
+func (r audit) Append(ctx context.Context, e *models.AuditEntry) error {
+	return r.s.get(ctx, e, `
+		INSERT INTO audit_log (actor_id, actor, action, entity, entity_id, before, after, source_ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *
+	`, e.ActorID, e.Actor, e.Action, e.Entity, e.EntityID, e.Before, e.After, e.SourceIP)
+}
+
+func (r audit) List(ctx context.Context, f store.AuditFilter, p store.PageRequest) (*store.Page[models.AuditEntry], error) {
+	if _, err := sortColumn(map[string]string{"created": "created_at"}, p); err != nil {
+		return nil, err
+	}
+	conds := []string{"TRUE"}
+	var args []interface{}
+	add := func(cond string, value interface{}) {
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Actor != "" {
+		add("LOWER(actor) = LOWER($%d)", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.Entity != "" {
+		add("entity = $%d", f.Entity)
+	}
+	if f.EntityID != 0 {
+		add("entity_id = $%d", f.EntityID)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until.UTC())
+	}
+	where := strings.Join(conds, " AND ")
+
+	total, err := r.s.count(ctx, p, "SELECT COUNT(*) FROM audit_log WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, pageArgs := r.s.keyset(p, "created_at", "id", args)
+	list := []models.AuditEntry{}
+	err = r.s.selectAll(ctx, &list, "SELECT * FROM audit_log WHERE "+where+" AND "+ks+" "+orderLimit(p, "created_at", "id"), pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewPage(p, list, total, func(e models.AuditEntry) (string, int64) {
+		return store.CursorTime(e.CreatedAt), e.ID
+	}), nil
+}
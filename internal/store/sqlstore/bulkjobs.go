@@ -0,0 +1,33 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"context"
+)
+
+type bulkJobs struct{ s *Store }
+
+func (r bulkJobs) Get(ctx context.Context, id int64) (*models.BulkJob, error) {
+	var j models.BulkJob
+	if err := r.s.get(ctx, &j, "SELECT * FROM bulk_jobs WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r bulkJobs) Create(ctx context.Context, j *models.BulkJob) error {
+	return r.s.get(ctx, j, "INSERT INTO bulk_jobs (operation, request, total, user_id, source_ip) VALUES ($1, $2, $3, $4, $5) RETURNING *",
+		j.Operation, j.Request, j.Total, j.UserID, j.SourceIP)
+}
+
+func (r bulkJobs) Start(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "UPDATE bulk_jobs SET status = 'running' WHERE id = $1", id)
+}
+
+func (r bulkJobs) Finish(ctx context.Context, id int64, results string) error {
+	return r.s.execOne(ctx, "UPDATE bulk_jobs SET status = 'done', results = $2, finished_at = NOW() WHERE id = $1", id, results)
+}
+
+func (r bulkJobs) Fail(ctx context.Context, id int64, reason string) error {
+	return r.s.execOne(ctx, "UPDATE bulk_jobs SET status = 'failed', error = $2, finished_at = NOW() WHERE id = $1", id, reason)
+}
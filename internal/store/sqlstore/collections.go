@@ -0,0 +1,72 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+)
+
+type collections struct{ s *Store }
+
+var collectionColumns = map[string]string{
+	"name":    "name",
+	"created": "created_at",
+}
+
+func (r collections) List(ctx context.Context, name string, p store.PageRequest) (*store.Page[models.Collection], error) {
+	col, err := sortColumn(collectionColumns, p)
+	if err != nil {
+		return nil, err
+	}
+	where, args := r.s.nameFilter(name)
+
+	total, err := r.s.count(ctx, p, "SELECT COUNT(*) FROM collections WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, pageArgs := r.s.keyset(p, col, "id", args)
+	list := []models.Collection{}
+	err = r.s.selectAll(ctx, &list, "SELECT * FROM collections WHERE "+where+" AND "+ks+" "+orderLimit(p, col, "id"), pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewPage(p, list, total, func(c models.Collection) (string, int64) {
+		if p.Sort == "name" {
+			return c.Name, c.ID
+		}
+		return store.CursorTime(c.CreatedAt), c.ID
+	}), nil
+}
+
+func (r collections) Get(ctx context.Context, id int64) (*models.Collection, error) {
+	var c models.Collection
+	if err := r.s.get(ctx, &c, "SELECT * FROM collections WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r collections) Create(ctx context.Context, c *models.Collection) error {
+	return r.s.get(ctx, c, "INSERT INTO collections (name, query, user_id) VALUES ($1, $2, $3) RETURNING *", c.Name, c.Query, c.UserID)
+}
+
+func (r collections) Update(ctx context.Context, c *models.Collection) error {
+	return updateRow(ctx, r.s, c, "collections", c.ID, c.UpdatedAt, "name = $3, query = $4", c.Name, c.Query)
+}
+
+func (r collections) Delete(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "DELETE FROM collections WHERE id = $1", id)
+}
+
+func (r collections) AddModel(ctx context.Context, collectionID, modelID int64) error {
+	_, err := r.s.exec(ctx,
+		"INSERT INTO model_collections (model_id, collection_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		modelID, collectionID)
+	return err
+}
+
+func (r collections) RemoveModel(ctx context.Context, collectionID, modelID int64) error {
+	_, err := r.s.exec(ctx, "DELETE FROM model_collections WHERE collection_id = $1 AND model_id = $2", collectionID, modelID)
+	return err
+}
@@ -0,0 +1,128 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"strconv"
+	"time"
+)
+
+type files struct{ s *Store }
+
+var fileColumns = map[string]string{
+	"name":    "filename",
+	"created": "created_at",
+	"size":    "size",
+}
+
+func (r files) Get(ctx context.Context, id int64) (*models.ModelFile, error) {
+	var f models.ModelFile
+	if err := r.s.get(ctx, &f, "SELECT * FROM model_files WHERE id = $1 AND deleted_at IS NULL", id); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r files) ListByModel(ctx context.Context, modelID int64, p store.PageRequest) (*store.Page[models.ModelFile], error) {
+	col, err := sortColumn(fileColumns, p)
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.s.count(ctx, p, "SELECT COUNT(*) FROM model_files WHERE model_id = $1 AND deleted_at IS NULL", modelID)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, args := r.s.keyset(p, col, "id", []interface{}{modelID})
+	list := []models.ModelFile{}
+	err = r.s.selectAll(ctx, &list, "SELECT * FROM model_files WHERE model_id = $1 AND deleted_at IS NULL AND "+ks+" "+orderLimit(p, col, "id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewPage(p, list, total, func(f models.ModelFile) (string, int64) {
+		switch p.Sort {
+		case "created":
+			return store.CursorTime(f.CreatedAt), f.ID
+		case "size":
+			return strconv.FormatInt(f.Size, 10), f.ID
+		}
+		return f.Filename, f.ID
+	}), nil
+}
+
+func (r files) AllByModel(ctx context.Context, modelID int64) ([]models.ModelFile, error) {
+	list := []models.ModelFile{}
+	err := r.s.selectAll(ctx, &list, "SELECT * FROM model_files WHERE model_id = $1 AND deleted_at IS NULL ORDER BY id", modelID)
+	return list, err
+}
+
+func (r files) Upsert(ctx context.Context, f *models.ModelFile) error {
+	return r.s.get(ctx, f, `
+		INSERT INTO model_files (model_id, filename, path, size, mime_type, digest, format, width, depth, height, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (path) DO UPDATE SET
+			size = EXCLUDED.size, mime_type = COALESCE(EXCLUDED.mime_type, model_files.mime_type),
+			digest = EXCLUDED.digest, format = EXCLUDED.format,
+			width = EXCLUDED.width, depth = EXCLUDED.depth, height = EXCLUDED.height,
+			uploaded_by = COALESCE(EXCLUDED.uploaded_by, model_files.uploaded_by)
+		RETURNING *
+	`, f.ModelID, f.Filename, f.Path, f.Size, f.MimeType, f.Digest, f.Format, f.Width, f.Depth, f.Height, f.UploadedBy)
+}
+
+func (r files) Delete(ctx context.Context, id int64) (*models.ModelFile, error) {
+	var f models.ModelFile
+	err := r.s.get(ctx, &f, "UPDATE model_files SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING *", id, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r files) Restore(ctx context.Context, id int64) (*models.ModelFile, error) {
+	var f models.ModelFile
+	err := r.s.inTx(ctx, func(s *Store) error {
+		var modelDeleted *time.Time
+		err := s.get(ctx, &modelDeleted, `SELECT m.deleted_at FROM model_files f JOIN models m ON m.id = f.model_id
+			WHERE f.id = $1 AND f.deleted_at IS NOT NULL`, id)
+		if err != nil {
+			return err
+		}
+		if modelDeleted != nil {
+			return &store.Error{Kind: store.ErrReference, Detail: "The file's model is in the trash."}
+		}
+		return s.get(ctx, &f, "UPDATE model_files SET deleted_at = NULL WHERE id = $1 RETURNING *", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r files) Purge(ctx context.Context, id int64) (*models.ModelFile, error) {
+	var f models.ModelFile
+	if err := r.s.get(ctx, &f, "DELETE FROM model_files WHERE id = $1 AND deleted_at IS NOT NULL RETURNING *", id); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r files) SetTrashPath(ctx context.Context, id int64, path *string) error {
+	return r.s.execOne(ctx, "UPDATE model_files SET trash_path = $2 WHERE id = $1", id, path)
+}
+
+func (r files) Ignore(ctx context.Context, libraryID int64, path string) error {
+	_, err := r.s.exec(ctx, "INSERT INTO scan_ignores (library_id, path) VALUES ($1, $2) ON CONFLICT DO NOTHING", libraryID, path)
+	return err
+}
+
+func (r files) Unignore(ctx context.Context, libraryID int64, path string) error {
+	_, err := r.s.exec(ctx, "DELETE FROM scan_ignores WHERE library_id = $1 AND path = $2", libraryID, path)
+	return err
+}
+
+func (r files) Ignored(ctx context.Context, libraryID int64) ([]string, error) {
+	list := []string{}
+	err := r.s.selectAll(ctx, &list, "SELECT path FROM scan_ignores WHERE library_id = $1 ORDER BY path", libraryID)
+	return list, err
+}
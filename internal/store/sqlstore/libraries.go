@@ -0,0 +1,97 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"context"
+	"time"
+)
+
+type libraries struct{ s *Store }
+
+func (r libraries) List(ctx context.Context) ([]models.Library, error) {
+	list := []models.Library{}
+	err := r.s.selectAll(ctx, &list, "SELECT * FROM libraries WHERE deleted_at IS NULL ORDER BY created_at DESC")
+	return list, err
+}
+
+func (r libraries) Get(ctx context.Context, id int64) (*models.Library, error) {
+	var l models.Library
+	if err := r.s.get(ctx, &l, "SELECT * FROM libraries WHERE id = $1 AND deleted_at IS NULL", id); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r libraries) Create(ctx context.Context, l *models.Library) error {
+	return r.s.get(ctx, l,
+		"INSERT INTO libraries (name, path, storage, quota_mb) VALUES ($1, $2, $3, $4) RETURNING *",
+		l.Name, l.Path, l.Storage, l.QuotaMB)
+}
+
+func (r libraries) Update(ctx context.Context, l *models.Library) error {
+	return updateRow(ctx, r.s, l, "libraries", l.ID, l.UpdatedAt,
+		"name = $3, path = $4, storage = $5, quota_mb = $6", l.Name, l.Path, l.Storage, l.QuotaMB)
+}
+
+func (r libraries) Delete(ctx context.Context, id int64) error {
+	return r.s.inTx(ctx, func(s *Store) error {
+		now := time.Now().UTC()
+		if err := s.execOne(ctx, "UPDATE libraries SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL", id, now); err != nil {
+			return err
+		}
+		_, err := s.exec(ctx, `UPDATE model_files SET deleted_at = $2 WHERE deleted_at IS NULL
+			AND model_id IN (SELECT id FROM models WHERE library_id = $1 AND deleted_at IS NULL)`, id, now)
+		if err != nil {
+			return err
+		}
+		_, err = s.exec(ctx, "UPDATE models SET deleted_at = $2 WHERE library_id = $1 AND deleted_at IS NULL", id, now)
+		return err
+	})
+}
+
+func (r libraries) Restore(ctx context.Context, id int64) (*models.Library, error) {
+	var l models.Library
+	err := r.s.inTx(ctx, func(s *Store) error {
+		// Models and files deleted along with the library share its
+		// deleted_at.
+		const deletedAt = "(SELECT deleted_at FROM libraries WHERE id = $1)"
+		_, err := s.exec(ctx, `UPDATE model_files SET deleted_at = NULL WHERE deleted_at = `+deletedAt+`
+			AND model_id IN (SELECT id FROM models WHERE library_id = $1 AND deleted_at = `+deletedAt+`)`, id)
+		if err != nil {
+			return err
+		}
+		if _, err := s.exec(ctx, "UPDATE models SET deleted_at = NULL WHERE library_id = $1 AND deleted_at = "+deletedAt, id); err != nil {
+			return err
+		}
+		return s.get(ctx, &l, "UPDATE libraries SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING *", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r libraries) Purge(ctx context.Context, id int64) ([]models.ModelFile, error) {
+	list := []models.ModelFile{}
+	err := r.s.inTx(ctx, func(s *Store) error {
+		// The cascade would remove the files too, but not return them.
+		err := s.selectAll(ctx, &list, `DELETE FROM model_files
+			WHERE model_id IN (SELECT m.id FROM models m JOIN libraries l ON l.id = m.library_id WHERE l.id = $1 AND l.deleted_at IS NOT NULL)
+			RETURNING *`, id)
+		if err != nil {
+			return err
+		}
+		return s.execOne(ctx, "DELETE FROM libraries WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r libraries) Usage(ctx context.Context, id int64) (int64, error) {
+	var n int64
+	err := r.s.get(ctx, &n, `SELECT COALESCE(SUM(f.size), 0) FROM model_files f
+		JOIN models m ON m.id = f.model_id WHERE m.library_id = $1`, id)
+	return n, err
+}
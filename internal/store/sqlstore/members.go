@@ -0,0 +1,39 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"context"
+)
+
+type members struct{ s *Store }
+
+const memberSelect = "SELECT lm.*, u.username FROM library_members lm JOIN users u ON u.id = lm.user_id"
+
+func (r members) ListForLibrary(ctx context.Context, libraryID int64) ([]models.LibraryMember, error) {
+	list := []models.LibraryMember{}
+	err := r.s.selectAll(ctx, &list, memberSelect+" WHERE lm.library_id = $1 ORDER BY LOWER(u.username)", libraryID)
+	return list, err
+}
+
+func (r members) ForUser(ctx context.Context, userID int64) ([]models.LibraryMember, error) {
+	list := []models.LibraryMember{}
+	err := r.s.selectAll(ctx, &list, memberSelect+" WHERE lm.user_id = $1 ORDER BY lm.library_id", userID)
+	return list, err
+}
+
+func (r members) Set(ctx context.Context, m *models.LibraryMember) error {
+	_, err := r.s.exec(ctx, `
+		INSERT INTO library_members (library_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (library_id, user_id) DO UPDATE
+		SET role = EXCLUDED.role, updated_at = NOW()
+	`, m.LibraryID, m.UserID, m.Role)
+	if err != nil {
+		return err
+	}
+	return r.s.get(ctx, m, memberSelect+" WHERE lm.library_id = $1 AND lm.user_id = $2", m.LibraryID, m.UserID)
+}
+
+func (r members) Remove(ctx context.Context, libraryID, userID int64) error {
+	return r.s.execOne(ctx, "DELETE FROM library_members WHERE library_id = $1 AND user_id = $2", libraryID, userID)
+}
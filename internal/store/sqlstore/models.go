@@ -0,0 +1,314 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type modelRepo struct{ s *Store }
+
+var modelColumns = map[string]string{
+	"name":    "m.name",
+	"created": "m.created_at",
+	"updated": "m.updated_at",
+	"size":    "m.total_size",
+	"prints":  "m.print_count",
+}
+
+func modelKey(sort string) func(models.Model) (string, int64) {
+	return func(m models.Model) (string, int64) {
+		switch sort {
+		case "name":
+			return m.Name, m.ID
+		case "updated":
+			return store.CursorTime(m.UpdatedAt), m.ID
+		case "size":
+			return strconv.FormatInt(m.TotalSize, 10), m.ID
+		case "prints":
+			return strconv.Itoa(m.PrintCount), m.ID
+		}
+		return store.CursorTime(m.CreatedAt), m.ID
+	}
+}
+
+// where renders the filter as a condition over `models m`. Models in the
+// trash never match.
+func (s *Store) where(f store.ModelFilter, args []interface{}) (string, []interface{}) {
+	conds := []string{"m.deleted_at IS NULL"}
+	if f.LibraryID != 0 {
+		args = append(args, f.LibraryID)
+		conds = append(conds, fmt.Sprintf("m.library_id = $%d", len(args)))
+	}
+	if f.LibraryIDs != nil {
+		conds = append(conds, inList("m.library_id", f.LibraryIDs, &args))
+	}
+	if f.CollectionID != 0 {
+		args = append(args, f.CollectionID)
+		conds = append(conds, fmt.Sprintf("EXISTS (SELECT 1 FROM model_collections mc WHERE mc.model_id = m.id AND mc.collection_id = $%d)", len(args)))
+	}
+	if f.Query != nil {
+		var cond string
+		if s.sqlite {
+			cond, args = f.Query.SQLite(args)
+		} else {
+			cond, args = f.Query.SQL(args)
+		}
+		conds = append(conds, cond)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// inList renders "column IN (...)" with a placeholder per id, or FALSE for
+// no ids.
+func inList(column string, ids []int64, args *[]interface{}) string {
+	if len(ids) == 0 {
+		return "FALSE"
+	}
+	marks := make([]string, len(ids))
+	for i, id := range ids {
+		*args = append(*args, id)
+		marks[i] = fmt.Sprintf("$%d", len(*args))
+	}
+	return column + " IN (" + strings.Join(marks, ", ") + ")"
+}
+
+func (r modelRepo) Get(ctx context.Context, id int64) (*models.Model, error) {
+	var m models.Model
+	if err := r.s.get(ctx, &m, "SELECT * FROM models WHERE id = $1 AND deleted_at IS NULL", id); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r modelRepo) GetMany(ctx context.Context, ids []int64) (map[int64]models.Model, error) {
+	byID := make(map[int64]models.Model, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+	query, args, err := sqlx.In("SELECT * FROM models WHERE id IN (?) AND deleted_at IS NULL", ids)
+	if err != nil {
+		return nil, err
+	}
+	var list []models.Model
+	if err := r.s.selectAll(ctx, &list, r.s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		byID[m.ID] = m
+	}
+	return byID, nil
+}
+
+func (r modelRepo) List(ctx context.Context, f store.ModelFilter, p store.PageRequest) (*store.Page[models.Model], error) {
+	col, err := sortColumn(modelColumns, p)
+	if err != nil {
+		return nil, err
+	}
+	cond, args := r.s.where(f, nil)
+
+	total, err := r.s.count(ctx, p, "SELECT COUNT(*) FROM models m WHERE "+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, pageArgs := r.s.keyset(p, col, "m.id", args)
+	list := []models.Model{}
+	err = r.s.selectAll(ctx, &list, "SELECT m.* FROM models m WHERE "+cond+" AND "+ks+" "+orderLimit(p, col, "m.id"), pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewPage(p, list, total, modelKey(p.Sort)), nil
+}
+
+func (r modelRepo) IDs(ctx context.Context, f store.ModelFilter) ([]int64, error) {
+	cond, args := r.s.where(f, nil)
+	ids := []int64{}
+	err := r.s.selectAll(ctx, &ids, "SELECT m.id FROM models m WHERE "+cond+" ORDER BY m.id", args...)
+	return ids, err
+}
+
+// Facet queries count distinct matching models per value. %s is replaced by
+// the id subquery for the filter.
+var facetQueries = map[string]string{
+	"tags": `
+		SELECT t.name AS value, COUNT(*) AS count FROM model_tags mt
+		JOIN tags t ON t.id = mt.tag_id
+		WHERE mt.model_id IN (%s)
+		GROUP BY t.name ORDER BY count DESC, value LIMIT %d`,
+	"formats": `
+		SELECT mf.format AS value, COUNT(DISTINCT mf.model_id) AS count FROM model_files mf
+		WHERE mf.format IS NOT NULL AND mf.deleted_at IS NULL AND mf.model_id IN (%s)
+		GROUP BY mf.format ORDER BY count DESC, value LIMIT %d`,
+	"libraries": `
+		SELECT l.name AS value, COUNT(*) AS count FROM models mm
+		JOIN libraries l ON l.id = mm.library_id
+		WHERE mm.id IN (%s)
+		GROUP BY l.name ORDER BY count DESC, value LIMIT %d`,
+	"collections": `
+		SELECT c.name AS value, COUNT(*) AS count FROM model_collections mc
+		JOIN collections c ON c.id = mc.collection_id
+		WHERE mc.model_id IN (%s)
+		GROUP BY c.name ORDER BY count DESC, value LIMIT %d`,
+}
+
+func (r modelRepo) Facets(ctx context.Context, f store.ModelFilter) (*store.Facets, error) {
+	cond, args := r.s.where(f, nil)
+	ids := "SELECT m.id FROM models m WHERE " + cond
+	facets := &store.Facets{}
+	targets := map[string]*[]store.FacetCount{
+		"tags":        &facets.Tags,
+		"formats":     &facets.Formats,
+		"libraries":   &facets.Libraries,
+		"collections": &facets.Collections,
+	}
+	for name, dest := range targets {
+		*dest = []store.FacetCount{}
+		if err := r.s.selectAll(ctx, dest, fmt.Sprintf(facetQueries[name], ids, store.MaxFacetValues), args...); err != nil {
+			return nil, err
+		}
+	}
+	return facets, nil
+}
+
+func (r modelRepo) Create(ctx context.Context, m *models.Model) error {
+	err := r.s.get(ctx, m, `
+		INSERT INTO models (library_id, name, path, description)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (library_id, path) DO UPDATE
+		SET name = EXCLUDED.name, description = EXCLUDED.description, updated_at = NOW()
+		WHERE models.deleted_at IS NULL
+		RETURNING *
+	`, m.LibraryID, m.Name, m.Path, m.Description)
+	if errors.Is(err, store.ErrNotFound) {
+		return trashedModel(m.Path)
+	}
+	return err
+}
+
+func trashedModel(path string) error {
+	return &store.Error{Kind: store.ErrDuplicate, Detail: fmt.Sprintf("The model at %s is in the trash.", path)}
+}
+
+func (r modelRepo) Ensure(ctx context.Context, m *models.Model) error {
+	return r.s.get(ctx, m, `
+		INSERT INTO models (library_id, name, path)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (library_id, path) DO UPDATE
+		SET updated_at = NOW()
+		RETURNING *
+	`, m.LibraryID, m.Name, m.Path)
+}
+
+func (r modelRepo) Update(ctx context.Context, m *models.Model) error {
+	return updateRow(ctx, r.s, m, "models", m.ID, m.UpdatedAt,
+		"name = $3, description = $4, preview_file_id = $5, library_id = $6, path = $7",
+		m.Name, m.Description, m.PreviewFileID, m.LibraryID, m.Path)
+}
+
+func (r modelRepo) Delete(ctx context.Context, id int64) error {
+	return r.s.inTx(ctx, func(s *Store) error {
+		now := time.Now().UTC()
+		if err := s.execOne(ctx, "UPDATE models SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL", id, now); err != nil {
+			return err
+		}
+		_, err := s.exec(ctx, "UPDATE model_files SET deleted_at = $2 WHERE model_id = $1 AND deleted_at IS NULL", id, now)
+		return err
+	})
+}
+
+func (r modelRepo) Restore(ctx context.Context, id int64) (*models.Model, error) {
+	var m models.Model
+	err := r.s.inTx(ctx, func(s *Store) error {
+		var libraryDeleted *time.Time
+		err := s.get(ctx, &libraryDeleted, `SELECT l.deleted_at FROM models m JOIN libraries l ON l.id = m.library_id
+			WHERE m.id = $1 AND m.deleted_at IS NOT NULL`, id)
+		if err != nil {
+			return err
+		}
+		if libraryDeleted != nil {
+			return &store.Error{Kind: store.ErrReference, Detail: "The model's library is in the trash."}
+		}
+		_, err = s.exec(ctx, "UPDATE model_files SET deleted_at = NULL WHERE model_id = $1 AND deleted_at = (SELECT deleted_at FROM models WHERE id = $1)", id)
+		if err != nil {
+			return err
+		}
+		return s.get(ctx, &m, "UPDATE models SET deleted_at = NULL WHERE id = $1 RETURNING *", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r modelRepo) Purge(ctx context.Context, id int64) ([]models.ModelFile, error) {
+	list := []models.ModelFile{}
+	err := r.s.inTx(ctx, func(s *Store) error {
+		err := s.selectAll(ctx, &list, `DELETE FROM model_files
+			WHERE model_id IN (SELECT id FROM models WHERE id = $1 AND deleted_at IS NOT NULL)
+			RETURNING *`, id)
+		if err != nil {
+			return err
+		}
+		return s.execOne(ctx, "DELETE FROM models WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r modelRepo) SetPreview(ctx context.Context, id int64, fileID *int64) error {
+	return r.s.execOne(ctx, "UPDATE models SET preview_file_id = $1, updated_at = NOW() WHERE id = $2", fileID, id)
+}
+
+func (r modelRepo) RecordPrint(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "UPDATE models SET print_count = print_count + 1, last_printed_at = NOW(), updated_at = NOW() WHERE id = $1", id)
+}
+
+func (r modelRepo) RefreshStats(ctx context.Context, id int64) error {
+	_, err := r.s.exec(ctx, `
+		UPDATE models SET
+			total_size = COALESCE((SELECT SUM(size) FROM model_files WHERE model_id = $1 AND deleted_at IS NULL), 0),
+			width = (SELECT MAX(width) FROM model_files WHERE model_id = $1 AND deleted_at IS NULL),
+			depth = (SELECT MAX(depth) FROM model_files WHERE model_id = $1 AND deleted_at IS NULL),
+			height = (SELECT MAX(height) FROM model_files WHERE model_id = $1 AND deleted_at IS NULL)
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (r modelRepo) Move(ctx context.Context, id, libraryID int64, path string) error {
+	var oldPath string
+	if err := r.s.get(ctx, &oldPath, "SELECT path FROM models WHERE id = $1", id); err != nil {
+		return err
+	}
+	if err := r.s.execOne(ctx, "UPDATE models SET library_id = $1, path = $2, updated_at = NOW() WHERE id = $3", libraryID, path, id); err != nil {
+		return err
+	}
+	_, err := r.s.exec(ctx, `UPDATE model_files SET path = CAST($1 AS TEXT) || SUBSTR(path, LENGTH(CAST($2 AS TEXT)) + 1)
+		WHERE model_id = $3 AND SUBSTR(path, 1, LENGTH(CAST($2 AS TEXT))) = $2`, path, oldPath, id)
+	return err
+}
+
+func (r modelRepo) RewritePaths(ctx context.Context, libraryID int64, oldRoot, newRoot string) error {
+	for _, table := range []string{"models", "model_files"} {
+		scope := "library_id = $3"
+		if table == "model_files" {
+			scope = "model_id IN (SELECT id FROM models WHERE library_id = $3)"
+		}
+		_, err := r.s.exec(ctx, `UPDATE `+table+` SET path = CAST($2 AS TEXT) || SUBSTR(path, LENGTH(CAST($1 AS TEXT)) + 1)
+			WHERE `+scope+` AND SUBSTR(path, 1, LENGTH(CAST($1 AS TEXT))) = $1`, oldRoot, newRoot, libraryID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
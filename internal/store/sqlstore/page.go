@@ -0,0 +1,62 @@
+package sqlstore
+
+import (
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// keyset returns the condition selecting rows after the cursor, or "TRUE"
+// on the first page, appending its values to args.
+func (s *Store) keyset(p store.PageRequest, column, idCol string, args []interface{}) (string, []interface{}) {
+	if p.After == nil {
+		return "TRUE", args
+	}
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	var value interface{} = p.After.Value
+	if s.sqlite && strings.HasSuffix(column, "_at") {
+		// SQLite compares timestamps as text in its own format, not the
+		// cursor's RFC 3339.
+		if t, err := time.Parse(time.RFC3339Nano, p.After.Value); err == nil {
+			value = t.UTC()
+		}
+	}
+	args = append(args, value, p.After.ID)
+	return fmt.Sprintf("(%s, %s) %s ($%d, $%d)", column, idCol, op, len(args)-1, len(args)), args
+}
+
+// orderLimit orders by (column, id) and fetches one extra row so NewPage
+// knows whether another page exists.
+func orderLimit(p store.PageRequest, column, idCol string) string {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT %d", column, dir, idCol, dir, p.Limit+1)
+}
+
+// count runs the COUNT(*) query for the first page's total. Pages after a
+// cursor have none.
+func (s *Store) count(ctx context.Context, p store.PageRequest, query string, args ...interface{}) (*int, error) {
+	if p.After != nil {
+		return nil, nil
+	}
+	var n int
+	if err := s.get(ctx, &n, query, args...); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func sortColumn(columns map[string]string, p store.PageRequest) (string, error) {
+	col, ok := columns[p.Sort]
+	if !ok {
+		return "", fmt.Errorf("unknown sort %q", p.Sort)
+	}
+	return col, nil
+}
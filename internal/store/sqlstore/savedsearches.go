@@ -0,0 +1,34 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"context"
+)
+
+type savedSearches struct{ s *Store }
+
+func (r savedSearches) List(ctx context.Context, userID int64) ([]models.SavedSearch, error) {
+	list := []models.SavedSearch{}
+	err := r.s.selectAll(ctx, &list, "SELECT * FROM saved_searches WHERE user_id = $1 ORDER BY name, id", userID)
+	return list, err
+}
+
+func (r savedSearches) Get(ctx context.Context, userID, id int64) (*models.SavedSearch, error) {
+	var s models.SavedSearch
+	if err := r.s.get(ctx, &s, "SELECT * FROM saved_searches WHERE id = $1 AND user_id = $2", id, userID); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r savedSearches) Create(ctx context.Context, s *models.SavedSearch) error {
+	return r.s.get(ctx, s, "INSERT INTO saved_searches (user_id, name, query) VALUES ($1, $2, $3) RETURNING *", s.UserID, s.Name, s.Query)
+}
+
+func (r savedSearches) Update(ctx context.Context, s *models.SavedSearch) error {
+	return r.s.get(ctx, s, "UPDATE saved_searches SET name = $3, query = $4 WHERE id = $1 AND user_id = $2 RETURNING *", s.ID, s.UserID, s.Name, s.Query)
+}
+
+func (r savedSearches) Delete(ctx context.Context, userID, id int64) error {
+	return r.s.execOne(ctx, "DELETE FROM saved_searches WHERE id = $1 AND user_id = $2", id, userID)
+}
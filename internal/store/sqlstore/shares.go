@@ -0,0 +1,48 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"context"
+)
+
+type shares struct{ s *Store }
+
+func (r shares) List(ctx context.Context, userID int64) ([]models.ShareLink, error) {
+	list := []models.ShareLink{}
+	err := r.s.selectAll(ctx, &list, "SELECT * FROM share_links WHERE $1 = 0 OR user_id = $1 ORDER BY created_at DESC, id DESC", userID)
+	return list, err
+}
+
+func (r shares) Get(ctx context.Context, id int64) (*models.ShareLink, error) {
+	var l models.ShareLink
+	if err := r.s.get(ctx, &l, "SELECT * FROM share_links WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r shares) GetByHash(ctx context.Context, hash string) (*models.ShareLink, error) {
+	var l models.ShareLink
+	if err := r.s.get(ctx, &l, "SELECT * FROM share_links WHERE token_hash = $1", hash); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r shares) Create(ctx context.Context, l *models.ShareLink) error {
+	return r.s.get(ctx, l, `
+		INSERT INTO share_links (user_id, model_id, collection_id, token_hash, prefix, password_hash, expires_at, max_downloads)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *
+	`, l.UserID, l.ModelID, l.CollectionID, l.Hash, l.Prefix, l.PasswordHash, l.ExpiresAt, l.MaxDownloads)
+}
+
+func (r shares) Delete(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "DELETE FROM share_links WHERE id = $1", id)
+}
+
+func (r shares) CountDownload(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, `
+		UPDATE share_links SET downloads = downloads + 1
+		WHERE id = $1 AND (max_downloads IS NULL OR downloads < max_downloads)
+	`, id)
+}
@@ -0,0 +1,151 @@
+// Package sqlstore implements store.Store on top of sqlx.
+package sqlstore
+
+import (
+	"3d-library/internal/database"
+	"3d-library/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type Store struct {
+	db *sqlx.DB
+	q  sqlx.ExtContext
+	tx *sqlx.Tx
+	// depth is the savepoint nesting level inside tx.
+	depth int
+	// sqlite switches the few queries that differ from Postgres.
+	sqlite bool
+}
+
+func New(db *sqlx.DB) *Store {
+	return &Store{db: db, q: db, sqlite: database.IsSQLite(db)}
+}
+
+func (s *Store) Libraries() store.Libraries         { return libraries{s} }
+func (s *Store) Models() store.Models               { return modelRepo{s} }
+func (s *Store) Files() store.Files                 { return files{s} }
+func (s *Store) Tags() store.Tags                   { return tags{s} }
+func (s *Store) Collections() store.Collections     { return collections{s} }
+func (s *Store) SavedSearches() store.SavedSearches { return savedSearches{s} }
+func (s *Store) Users() store.Users                 { return users{s} }
+func (s *Store) Sessions() store.Sessions           { return sessions{s} }
+func (s *Store) Tokens() store.Tokens               { return tokens{s} }
+func (s *Store) Members() store.Members             { return members{s} }
+func (s *Store) Shares() store.Shares               { return shares{s} }
+func (s *Store) Audit() store.Audit                 { return audit{s} }
+func (s *Store) Trash() store.Trash                 { return trash{s} }
+func (s *Store) BulkJobs() store.BulkJobs           { return bulkJobs{s} }
+
+func (s *Store) InTx(ctx context.Context, fn func(tx store.Store) error) error {
+	if s.tx == nil {
+		tx, err := s.db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(&Store{db: s.db, q: tx, tx: tx, sqlite: s.sqlite}); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	savepoint := fmt.Sprintf("sp_%d", s.depth+1)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+	if err := fn(&Store{db: s.db, q: s.tx, tx: s.tx, depth: s.depth + 1, sqlite: s.sqlite}); err != nil {
+		if _, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	return err
+}
+
+// inTx is InTx for repository methods that run several statements.
+func (s *Store) inTx(ctx context.Context, fn func(s *Store) error) error {
+	return s.InTx(ctx, func(tx store.Store) error {
+		return fn(tx.(*Store))
+	})
+}
+
+func (s *Store) get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return translate(sqlx.GetContext(ctx, s.q, dest, query, args...))
+}
+
+func (s *Store) selectAll(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return translate(sqlx.SelectContext(ctx, s.q, dest, query, args...))
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	res, err := s.q.ExecContext(ctx, query, args...)
+	return res, translate(err)
+}
+
+// execOne is exec for statements that must touch a row.
+func (s *Store) execOne(ctx context.Context, query string, args ...interface{}) error {
+	res, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// translate turns driver errors the caller can act on into store errors.
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return &store.Error{Kind: store.ErrDuplicate, Detail: pqErr.Detail}
+		case "23503":
+			return &store.Error{Kind: store.ErrReference, Detail: pqErr.Detail}
+		}
+	}
+	if code, msg, ok := database.SQLiteError(err); ok {
+		switch code {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return &store.Error{Kind: store.ErrDuplicate, Detail: msg}
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return &store.Error{Kind: store.ErrReference, Detail: msg}
+		}
+	}
+	return err
+}
+
+// updateRow runs "UPDATE table SET sets" only if updated_at still has the
+// value the caller read, and scans the new row into dest. $1 and $2 are the
+// id and updated_at; sets uses $3 onwards for args.
+func updateRow(ctx context.Context, s *Store, dest interface{}, table string, id int64, updatedAt time.Time, sets string, args ...interface{}) error {
+	all := append([]interface{}{id, updatedAt}, args...)
+	err := s.get(ctx, dest, "UPDATE "+table+" SET "+sets+", updated_at = NOW() WHERE id = $1 AND updated_at = $2 RETURNING *", all...)
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	var n int
+	if err := s.get(ctx, &n, "SELECT COUNT(*) FROM "+table+" WHERE id = $1", id); err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return store.ErrStale
+}
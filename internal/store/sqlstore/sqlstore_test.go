@@ -0,0 +1,26 @@
+package sqlstore
+
+import (
+	"3d-library/internal/database"
+	"3d-library/internal/migrate"
+	"3d-library/internal/store"
+	"3d-library/internal/store/storetest"
+	"path/filepath"
+	"testing"
+)
+
+// TestSQLite runs the shared store cases against a migrated SQLite file.
+func TestSQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		t.Helper()
+		db, err := database.Connect("sqlite://" + filepath.Join(t.TempDir(), "store.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if _, err := migrate.Up(db); err != nil {
+			t.Fatal(err)
+		}
+		return New(db)
+	})
+}
@@ -0,0 +1,117 @@
+package sqlstore
+
+import (
+	"3d-library/internal/database"
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type tags struct{ s *Store }
+
+var tagColumns = map[string]string{
+	"name": "name",
+}
+
+func (r tags) List(ctx context.Context, name string, p store.PageRequest) (*store.Page[models.Tag], error) {
+	col, err := sortColumn(tagColumns, p)
+	if err != nil {
+		return nil, err
+	}
+	where, args := r.s.nameFilter(name)
+
+	total, err := r.s.count(ctx, p, "SELECT COUNT(*) FROM tags WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, pageArgs := r.s.keyset(p, col, "id", args)
+	list := []models.Tag{}
+	err = r.s.selectAll(ctx, &list, "SELECT * FROM tags WHERE "+where+" AND "+ks+" "+orderLimit(p, col, "id"), pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewPage(p, list, total, func(t models.Tag) (string, int64) {
+		return t.Name, t.ID
+	}), nil
+}
+
+// nameFilter matches names containing name, case-insensitively.
+func (s *Store) nameFilter(name string) (string, []interface{}) {
+	if name == "" {
+		return "TRUE", nil
+	}
+	return database.ILike(s.db, "name", "$1"), []interface{}{"%" + name + "%"}
+}
+
+func (r tags) Get(ctx context.Context, id int64) (*models.Tag, error) {
+	var t models.Tag
+	if err := r.s.get(ctx, &t, "SELECT * FROM tags WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r tags) Ensure(ctx context.Context, name string) (*models.Tag, error) {
+	var t models.Tag
+	err := r.s.get(ctx, &t,
+		"INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING *",
+		name)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r tags) Update(ctx context.Context, t *models.Tag) error {
+	return updateRow(ctx, r.s, t, "tags", t.ID, t.UpdatedAt, "name = $3", t.Name)
+}
+
+func (r tags) Delete(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "DELETE FROM tags WHERE id = $1", id)
+}
+
+func (r tags) ForModel(ctx context.Context, modelID int64) ([]models.Tag, error) {
+	list := []models.Tag{}
+	err := r.s.selectAll(ctx, &list, `
+		SELECT t.* FROM tags t
+		JOIN model_tags mt ON t.id = mt.tag_id
+		WHERE mt.model_id = $1
+		ORDER BY t.name
+	`, modelID)
+	return list, err
+}
+
+func (r tags) IDsForModels(ctx context.Context, modelIDs []int64) (map[int64][]int64, error) {
+	byModel := make(map[int64][]int64, len(modelIDs))
+	if len(modelIDs) == 0 {
+		return byModel, nil
+	}
+	query, args, err := sqlx.In("SELECT model_id, tag_id FROM model_tags WHERE model_id IN (?) ORDER BY model_id, tag_id", modelIDs)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ModelID int64 `db:"model_id"`
+		TagID   int64 `db:"tag_id"`
+	}
+	if err := r.s.selectAll(ctx, &rows, r.s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		byModel[row.ModelID] = append(byModel[row.ModelID], row.TagID)
+	}
+	return byModel, nil
+}
+
+func (r tags) Attach(ctx context.Context, modelID, tagID int64) error {
+	_, err := r.s.exec(ctx, "INSERT INTO model_tags (model_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", modelID, tagID)
+	return err
+}
+
+func (r tags) Detach(ctx context.Context, modelID, tagID int64) error {
+	_, err := r.s.exec(ctx, "DELETE FROM model_tags WHERE model_id = $1 AND tag_id = $2", modelID, tagID)
+	return err
+}
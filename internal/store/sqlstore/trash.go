@@ -0,0 +1,47 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/store"
+	"context"
+	"fmt"
+)
+
+type trash struct{ s *Store }
+
+func (r trash) List(ctx context.Context, f store.TrashFilter) (*store.TrashItems, error) {
+	// where renders the filter over the table's alias, with the library
+	// joined as l.
+	where := func(table string) (string, []interface{}) {
+		var args []interface{}
+		cond := table + ".deleted_at IS NOT NULL"
+		if f.LibraryIDs != nil {
+			cond += " AND " + inList("l.id", f.LibraryIDs, &args)
+		}
+		if !f.Before.IsZero() {
+			args = append(args, f.Before.UTC())
+			cond += fmt.Sprintf(" AND %s.deleted_at < $%d", table, len(args))
+		}
+		return cond, args
+	}
+	items := &store.TrashItems{Libraries: []models.Library{}, Models: []models.Model{}, Files: []models.ModelFile{}}
+
+	cond, args := where("l")
+	err := r.s.selectAll(ctx, &items.Libraries, "SELECT l.* FROM libraries l WHERE "+cond+" ORDER BY l.deleted_at DESC, l.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	cond, args = where("m")
+	err = r.s.selectAll(ctx, &items.Models, `SELECT m.* FROM models m JOIN libraries l ON l.id = m.library_id
+		WHERE l.deleted_at IS NULL AND `+cond+" ORDER BY m.deleted_at DESC, m.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	cond, args = where("f")
+	err = r.s.selectAll(ctx, &items.Files, `SELECT f.* FROM model_files f JOIN models m ON m.id = f.model_id JOIN libraries l ON l.id = m.library_id
+		WHERE m.deleted_at IS NULL AND `+cond+" ORDER BY f.deleted_at DESC, f.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,126 @@
+package sqlstore
+
+import (
+	"3d-library/internal/models"
+	"context"
+	"time"
+)
+
+type users struct{ s *Store }
+
+func (r users) List(ctx context.Context) ([]models.User, error) {
+	list := []models.User{}
+	err := r.s.selectAll(ctx, &list, "SELECT * FROM users ORDER BY LOWER(username)")
+	return list, err
+}
+
+func (r users) Get(ctx context.Context, id int64) (*models.User, error) {
+	var u models.User
+	if err := r.s.get(ctx, &u, "SELECT * FROM users WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r users) GetByName(ctx context.Context, username string) (*models.User, error) {
+	var u models.User
+	if err := r.s.get(ctx, &u, "SELECT * FROM users WHERE LOWER(username) = LOWER($1)", username); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r users) GetByExternalID(ctx context.Context, externalID string) (*models.User, error) {
+	var u models.User
+	if err := r.s.get(ctx, &u, "SELECT * FROM users WHERE external_id = $1", externalID); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r users) Count(ctx context.Context) (int, error) {
+	var n int
+	err := r.s.get(ctx, &n, "SELECT COUNT(*) FROM users")
+	return n, err
+}
+
+func (r users) Create(ctx context.Context, u *models.User) error {
+	return r.s.get(ctx, u,
+		"INSERT INTO users (username, password_hash, admin, disabled, external_id) VALUES ($1, $2, $3, $4, $5) RETURNING *",
+		u.Username, u.PasswordHash, u.Admin, u.Disabled, u.ExternalID)
+}
+
+func (r users) Update(ctx context.Context, u *models.User) error {
+	return updateRow(ctx, r.s, u, "users", u.ID, u.UpdatedAt,
+		"password_hash = $3, admin = $4, disabled = $5, external_id = $6", u.PasswordHash, u.Admin, u.Disabled, u.ExternalID)
+}
+
+func (r users) RecordLogin(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "UPDATE users SET last_login_at = $2 WHERE id = $1", id, time.Now().UTC())
+}
+
+func (r users) Usage(ctx context.Context, id int64) (int64, error) {
+	var n int64
+	err := r.s.get(ctx, &n, "SELECT COALESCE(SUM(size), 0) FROM model_files WHERE uploaded_by = $1", id)
+	return n, err
+}
+
+type sessions struct{ s *Store }
+
+func (r sessions) Create(ctx context.Context, sess *models.Session) error {
+	now := time.Now().UTC()
+	if _, err := r.s.exec(ctx, "DELETE FROM sessions WHERE expires_at <= $1", now); err != nil {
+		return err
+	}
+	return r.s.get(ctx, sess,
+		"INSERT INTO sessions (id, user_id, expires_at) VALUES ($1, $2, $3) RETURNING *",
+		sess.ID, sess.UserID, sess.ExpiresAt.UTC())
+}
+
+func (r sessions) Get(ctx context.Context, id string) (*models.Session, error) {
+	var sess models.Session
+	if err := r.s.get(ctx, &sess, "SELECT * FROM sessions WHERE id = $1 AND expires_at > $2", id, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (r sessions) Delete(ctx context.Context, id string) error {
+	return r.s.execOne(ctx, "DELETE FROM sessions WHERE id = $1", id)
+}
+
+func (r sessions) DeleteForUser(ctx context.Context, userID int64) error {
+	_, err := r.s.exec(ctx, "DELETE FROM sessions WHERE user_id = $1", userID)
+	return err
+}
+
+type tokens struct{ s *Store }
+
+func (r tokens) ListForUser(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	list := []models.APIToken{}
+	err := r.s.selectAll(ctx, &list, "SELECT * FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC, id DESC", userID)
+	return list, err
+}
+
+func (r tokens) GetByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	var t models.APIToken
+	err := r.s.get(ctx, &t, "SELECT * FROM api_tokens WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > $2)", hash, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r tokens) Create(ctx context.Context, t *models.APIToken) error {
+	return r.s.get(ctx, t,
+		"INSERT INTO api_tokens (user_id, name, token_hash, prefix, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING *",
+		t.UserID, t.Name, t.Hash, t.Prefix, t.ExpiresAt)
+}
+
+func (r tokens) Delete(ctx context.Context, userID, id int64) error {
+	return r.s.execOne(ctx, "DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+func (r tokens) Touch(ctx context.Context, id int64) error {
+	return r.s.execOne(ctx, "UPDATE api_tokens SET last_used_at = $2 WHERE id = $1", id, time.Now().UTC())
+}
@@ -0,0 +1,331 @@
+// Package store is the data access layer shared by the HTTP handlers and the
+// background jobs. sqlstore implements it on a SQL database and memstore in
+// memory.
+package store
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/search"
+	"context"
+	"errors"
+	"time"
+)
+
+// Store gives access to the repositories. Repositories obtained from the
+// Store passed to InTx's callback run inside that transaction.
+type Store interface {
+	Libraries() Libraries
+	Models() Models
+	Files() Files
+	Tags() Tags
+	Collections() Collections
+	SavedSearches() SavedSearches
+	Users() Users
+	Sessions() Sessions
+	Tokens() Tokens
+	Members() Members
+	Shares() Shares
+	Audit() Audit
+	Trash() Trash
+	BulkJobs() BulkJobs
+
+	// InTx runs fn in a transaction that commits when fn returns nil and
+	// rolls back otherwise. Calling InTx on a transaction's Store nests, so
+	// an inner failure only undoes the inner work.
+	InTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+type Libraries interface {
+	List(ctx context.Context) ([]models.Library, error)
+	Get(ctx context.Context, id int64) (*models.Library, error)
+	Create(ctx context.Context, l *models.Library) error
+	// Update saves name, path, storage and quota. It fails with ErrStale
+	// when the library changed after l was read.
+	Update(ctx context.Context, l *models.Library) error
+	// Delete moves the library to the trash along with its models and
+	// files.
+	Delete(ctx context.Context, id int64) error
+	// Restore brings a library back from the trash with the models and
+	// files deleted along with it.
+	Restore(ctx context.Context, id int64) (*models.Library, error)
+	// Purge removes a library in the trash for good and returns the files
+	// it had.
+	Purge(ctx context.Context, id int64) ([]models.ModelFile, error)
+	// Usage returns the bytes the library's files take, counting those in
+	// the trash.
+	Usage(ctx context.Context, id int64) (int64, error)
+}
+
+type Models interface {
+	Get(ctx context.Context, id int64) (*models.Model, error)
+	GetMany(ctx context.Context, ids []int64) (map[int64]models.Model, error)
+	List(ctx context.Context, f ModelFilter, p PageRequest) (*Page[models.Model], error)
+	IDs(ctx context.Context, f ModelFilter) ([]int64, error)
+	Facets(ctx context.Context, f ModelFilter) (*Facets, error)
+
+	// Create inserts the model or, when one already exists at the same
+	// library and path, replaces its name and description. It fails with
+	// ErrDuplicate when that one is in the trash.
+	Create(ctx context.Context, m *models.Model) error
+	// Ensure inserts the model unless one exists at the same library and
+	// path, in which case that one is loaded into m and touched. A model
+	// in the trash is loaded but stays there.
+	Ensure(ctx context.Context, m *models.Model) error
+	// Update saves name, description, preview, library and path. It fails
+	// with ErrStale when the model changed after m was read.
+	Update(ctx context.Context, m *models.Model) error
+	// Delete moves the model to the trash along with its files.
+	Delete(ctx context.Context, id int64) error
+	// Restore brings a model back from the trash with the files deleted
+	// along with it. It fails with ErrReference while its library is in
+	// the trash.
+	Restore(ctx context.Context, id int64) (*models.Model, error)
+	// Purge removes a model in the trash for good and returns the files it
+	// had.
+	Purge(ctx context.Context, id int64) ([]models.ModelFile, error)
+
+	SetPreview(ctx context.Context, id int64, fileID *int64) error
+	RecordPrint(ctx context.Context, id int64) error
+	// RefreshStats recomputes the size and dimensions from the files.
+	// Dimensions are the largest part on each axis.
+	RefreshStats(ctx context.Context, id int64) error
+	// Move puts the model in another library at path and rewrites the paths
+	// of its files to match.
+	Move(ctx context.Context, id, libraryID int64, path string) error
+	// RewritePaths replaces the oldRoot prefix of every model and file path
+	// in a library, after the library itself moved.
+	RewritePaths(ctx context.Context, libraryID int64, oldRoot, newRoot string) error
+}
+
+type Files interface {
+	Get(ctx context.Context, id int64) (*models.ModelFile, error)
+	ListByModel(ctx context.Context, modelID int64, p PageRequest) (*Page[models.ModelFile], error)
+	AllByModel(ctx context.Context, modelID int64) ([]models.ModelFile, error)
+	// Upsert inserts the file or updates the one stored at the same path,
+	// which stays in the trash if it is there. A nil UploadedBy keeps the
+	// uploader already recorded.
+	Upsert(ctx context.Context, f *models.ModelFile) error
+	// Delete moves the file to the trash and returns it.
+	Delete(ctx context.Context, id int64) (*models.ModelFile, error)
+	// Restore brings a file back from the trash. It fails with
+	// ErrReference while its model is in the trash.
+	Restore(ctx context.Context, id int64) (*models.ModelFile, error)
+	// Purge removes a file in the trash for good and returns it.
+	Purge(ctx context.Context, id int64) (*models.ModelFile, error)
+	// SetTrashPath records where a file deleted from disk was moved, or
+	// clears it with nil. It works on files in the trash.
+	SetTrashPath(ctx context.Context, id int64, path *string) error
+
+	// Ignore makes scans of the library skip path, relative to its root,
+	// and Unignore lets them find it again.
+	Ignore(ctx context.Context, libraryID int64, path string) error
+	Unignore(ctx context.Context, libraryID int64, path string) error
+	Ignored(ctx context.Context, libraryID int64) ([]string, error)
+}
+
+type Tags interface {
+	List(ctx context.Context, name string, p PageRequest) (*Page[models.Tag], error)
+	Get(ctx context.Context, id int64) (*models.Tag, error)
+	// Ensure returns the tag with the given name, creating it if needed.
+	Ensure(ctx context.Context, name string) (*models.Tag, error)
+	Update(ctx context.Context, t *models.Tag) error
+	Delete(ctx context.Context, id int64) error
+
+	ForModel(ctx context.Context, modelID int64) ([]models.Tag, error)
+	// IDsForModels maps each model to its tag ids.
+	IDsForModels(ctx context.Context, modelIDs []int64) (map[int64][]int64, error)
+	Attach(ctx context.Context, modelID, tagID int64) error
+	Detach(ctx context.Context, modelID, tagID int64) error
+}
+
+type Collections interface {
+	List(ctx context.Context, name string, p PageRequest) (*Page[models.Collection], error)
+	Get(ctx context.Context, id int64) (*models.Collection, error)
+	Create(ctx context.Context, c *models.Collection) error
+	// Update saves name and query. It fails with ErrStale when the
+	// collection changed after c was read.
+	Update(ctx context.Context, c *models.Collection) error
+	Delete(ctx context.Context, id int64) error
+
+	AddModel(ctx context.Context, collectionID, modelID int64) error
+	RemoveModel(ctx context.Context, collectionID, modelID int64) error
+}
+
+// SavedSearches are personal: every method only sees the searches of the
+// given user.
+type SavedSearches interface {
+	// List returns the user's searches by name.
+	List(ctx context.Context, userID int64) ([]models.SavedSearch, error)
+	Get(ctx context.Context, userID, id int64) (*models.SavedSearch, error)
+	Create(ctx context.Context, s *models.SavedSearch) error
+	// Update saves name and query of one of s.UserID's searches.
+	Update(ctx context.Context, s *models.SavedSearch) error
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+type Users interface {
+	List(ctx context.Context) ([]models.User, error)
+	Get(ctx context.Context, id int64) (*models.User, error)
+	// GetByName matches the username regardless of case.
+	GetByName(ctx context.Context, username string) (*models.User, error)
+	GetByExternalID(ctx context.Context, externalID string) (*models.User, error)
+	Count(ctx context.Context) (int, error)
+	Create(ctx context.Context, u *models.User) error
+	// Update saves the password hash, admin, disabled and external ID. It
+	// fails with ErrStale when the user changed after u was read.
+	Update(ctx context.Context, u *models.User) error
+	RecordLogin(ctx context.Context, id int64) error
+	// Usage returns the bytes of the files the user uploaded, counting
+	// those in the trash.
+	Usage(ctx context.Context, id int64) (int64, error)
+}
+
+type Sessions interface {
+	// Create stores the session and drops expired ones.
+	Create(ctx context.Context, s *models.Session) error
+	// Get returns the session unless it is missing or expired.
+	Get(ctx context.Context, id string) (*models.Session, error)
+	Delete(ctx context.Context, id string) error
+	DeleteForUser(ctx context.Context, userID int64) error
+}
+
+type Tokens interface {
+	ListForUser(ctx context.Context, userID int64) ([]models.APIToken, error)
+	// GetByHash returns the token unless it is missing or expired.
+	GetByHash(ctx context.Context, hash string) (*models.APIToken, error)
+	Create(ctx context.Context, t *models.APIToken) error
+	// Delete removes one of the user's tokens.
+	Delete(ctx context.Context, userID, id int64) error
+	Touch(ctx context.Context, id int64) error
+}
+
+type Members interface {
+	// ListForLibrary returns the library's members by username.
+	ListForLibrary(ctx context.Context, libraryID int64) ([]models.LibraryMember, error)
+	ForUser(ctx context.Context, userID int64) ([]models.LibraryMember, error)
+	// Set gives the user m.Role in the library, replacing any role they
+	// had there.
+	Set(ctx context.Context, m *models.LibraryMember) error
+	Remove(ctx context.Context, libraryID, userID int64) error
+}
+
+type Shares interface {
+	// List returns the user's links, newest first, or everyone's for
+	// userID 0.
+	List(ctx context.Context, userID int64) ([]models.ShareLink, error)
+	Get(ctx context.Context, id int64) (*models.ShareLink, error)
+	// GetByHash returns the link even when it has expired, so it can be
+	// told apart from one that never existed.
+	GetByHash(ctx context.Context, hash string) (*models.ShareLink, error)
+	Create(ctx context.Context, l *models.ShareLink) error
+	Delete(ctx context.Context, id int64) error
+	// CountDownload records a download. It fails with ErrNotFound once the
+	// link's download limit is used up.
+	CountDownload(ctx context.Context, id int64) error
+}
+
+type Audit interface {
+	// Append adds an entry. There is no way to change or remove one.
+	Append(ctx context.Context, e *models.AuditEntry) error
+	List(ctx context.Context, f AuditFilter, p PageRequest) (*Page[models.AuditEntry], error)
+}
+
+// BulkJobs are the bulk operations too large to run inside a request,
+// queued to run in the background.
+type BulkJobs interface {
+	Get(ctx context.Context, id int64) (*models.BulkJob, error)
+	// Create inserts the job as queued.
+	Create(ctx context.Context, j *models.BulkJob) error
+	// Start marks the job running.
+	Start(ctx context.Context, id int64) error
+	// Finish marks the job done with its results, which are JSON.
+	Finish(ctx context.Context, id int64, results string) error
+	// Fail marks the job failed with the reason.
+	Fail(ctx context.Context, id int64, reason string) error
+}
+
+type Trash interface {
+	// List returns what was deleted, most recent first. Models and files
+	// deleted along with their library or model are left out; they come
+	// back with it.
+	List(ctx context.Context, f TrashFilter) (*TrashItems, error)
+}
+
+// TrashFilter narrows trash listings. Zero fields do not filter.
+type TrashFilter struct {
+	// LibraryIDs, unless nil, limits the trash to these libraries.
+	LibraryIDs []int64
+	// Before selects what was deleted before this time.
+	Before time.Time
+}
+
+type TrashItems struct {
+	Libraries []models.Library   `json:"libraries"`
+	Models    []models.Model     `json:"models"`
+	Files     []models.ModelFile `json:"files"`
+}
+
+// AuditFilter narrows audit log listings. Zero fields do not filter; Since
+// is inclusive and Until exclusive.
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	Entity   string
+	EntityID int64
+	Since    time.Time
+	Until    time.Time
+}
+
+// ModelFilter narrows model listings. Zero fields do not filter.
+type ModelFilter struct {
+	LibraryID int64
+	// LibraryIDs, unless nil, limits models to these libraries; empty
+	// matches nothing.
+	LibraryIDs []int64
+	// CollectionID selects the manual members of a collection.
+	CollectionID int64
+	Query        *search.Query
+}
+
+type FacetCount struct {
+	Value string `db:"value" json:"value"`
+	Count int    `db:"count" json:"count"`
+}
+
+type Facets struct {
+	Tags        []FacetCount `json:"tags"`
+	Formats     []FacetCount `json:"formats"`
+	Libraries   []FacetCount `json:"libraries"`
+	Collections []FacetCount `json:"collections"`
+}
+
+// MaxFacetValues is how many values each facet reports.
+const MaxFacetValues = 50
+
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrStale means an update lost a race with another writer.
+	ErrStale = errors.New("modified since it was read")
+	// ErrDuplicate is a unique constraint violation.
+	ErrDuplicate = errors.New("already exists")
+	// ErrReference is a reference to a missing row, or a delete of a row
+	// that is still referenced.
+	ErrReference = errors.New("invalid reference")
+)
+
+// Error adds the database's description to one of the errors above.
+type Error struct {
+	Kind   error
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Kind.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
@@ -0,0 +1,365 @@
+// Package storetest holds the cases every store.Store must pass, so memstore
+// and the SQL backends are checked against the same expectations.
+package storetest
+
+import (
+	"3d-library/internal/models"
+	"3d-library/internal/search"
+	"3d-library/internal/store"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Run runs the cases against stores made by open, which returns an empty
+// store for each.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	t.Run("Search", func(t *testing.T) { testSearch(t, open(t)) })
+	t.Run("SearchTrash", func(t *testing.T) { testSearchTrash(t, open(t)) })
+	t.Run("Paging", func(t *testing.T) { testPaging(t, open(t)) })
+	t.Run("Facets", func(t *testing.T) { testFacets(t, open(t)) })
+}
+
+type fixture struct {
+	st        store.Store
+	minis     models.Library
+	terrain   models.Library
+	dragon    models.Model
+	goblin    models.Model
+	wall      models.Model
+	ox        models.Model
+	wing      models.ModelFile
+	favourite models.Collection
+}
+
+// file is a model file to add to the fixture. Zero dimensions are unknown.
+type file struct {
+	name, format string
+	size         int64
+	height       float64
+}
+
+// newFixture fills st with four models:
+//
+//	Red Dragon   Minis    "A dragon with wings"  dragon_body.stl, wing.3mf  1500 B  50 mm  painted, dragon  printed twice  Favourites
+//	Goblin       Minis    no description         goblin.stl                 200 B   12 mm  painted
+//	Castle Wall  Terrain  "Ruined wall"          wall.obj                   5000 B  -      -                printed once
+//	Ox           Minis    no description         no files                   0 B     -
+func newFixture(t *testing.T, st store.Store) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	fx := &fixture{st: st}
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fx.minis = models.Library{Name: "Minis", Path: "/libraries/minis", Storage: "local"}
+	check(st.Libraries().Create(ctx, &fx.minis))
+	fx.terrain = models.Library{Name: "Terrain", Path: "/libraries/terrain", Storage: "local"}
+	check(st.Libraries().Create(ctx, &fx.terrain))
+
+	model := func(lib models.Library, name, path string, description *string, files ...file) (models.Model, []models.ModelFile) {
+		t.Helper()
+		m := models.Model{LibraryID: lib.ID, Name: name, Path: lib.Path + "/" + path, Description: description}
+		check(st.Models().Create(ctx, &m))
+		var added []models.ModelFile
+		for _, f := range files {
+			mf := models.ModelFile{ModelID: m.ID, Filename: f.name, Path: m.Path + "/" + f.name, Size: f.size, Format: &f.format}
+			if f.height != 0 {
+				mf.Height = &f.height
+			}
+			check(st.Files().Upsert(ctx, &mf))
+			added = append(added, mf)
+		}
+		check(st.Models().RefreshStats(ctx, m.ID))
+		stored, err := st.Models().Get(ctx, m.ID)
+		check(err)
+		return *stored, added
+	}
+	describe := func(s string) *string { return &s }
+
+	var files []models.ModelFile
+	fx.dragon, files = model(fx.minis, "Red Dragon", "dragon", describe("A dragon with wings"),
+		file{"dragon_body.stl", "stl", 1000, 30}, file{"wing.3mf", "3mf", 500, 50})
+	fx.wing = files[1]
+	fx.goblin, _ = model(fx.minis, "Goblin", "goblin", nil, file{"goblin.stl", "stl", 200, 12})
+	fx.wall, _ = model(fx.terrain, "Castle Wall", "wall", describe("Ruined wall"), file{"wall.obj", "obj", 5000, 0})
+	fx.ox, _ = model(fx.minis, "Ox", "ox", nil)
+
+	tag := func(m models.Model, name string) {
+		t.Helper()
+		tg, err := st.Tags().Ensure(ctx, name)
+		check(err)
+		check(st.Tags().Attach(ctx, m.ID, tg.ID))
+	}
+	tag(fx.dragon, "painted")
+	tag(fx.dragon, "dragon")
+	tag(fx.goblin, "painted")
+
+	fx.favourite = models.Collection{Name: "Favourites"}
+	check(st.Collections().Create(ctx, &fx.favourite))
+	check(st.Collections().AddModel(ctx, fx.favourite.ID, fx.dragon.ID))
+
+	for _, id := range []int64{fx.dragon.ID, fx.dragon.ID, fx.wall.ID} {
+		check(st.Models().RecordPrint(ctx, id))
+	}
+	return fx
+}
+
+// search returns the names of the models matching the query, sorted.
+func (fx *fixture) search(t *testing.T, query string) []string {
+	t.Helper()
+	q, err := search.Parse(query)
+	if err != nil {
+		t.Fatalf("%q: %v", query, err)
+	}
+	page, err := fx.st.Models().List(context.Background(), store.ModelFilter{Query: q}, store.PageRequest{Limit: 100, Sort: "name"})
+	if err != nil {
+		t.Fatalf("%q: %v", query, err)
+	}
+	names := []string{}
+	for _, m := range page.Items {
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testSearch(t *testing.T, st store.Store) {
+	fx := newFixture(t, st)
+	all := []string{"Castle Wall", "Goblin", "Ox", "Red Dragon"}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", all},
+		// Words of three or more characters go through FTS on SQLite.
+		{"dragon", []string{"Red Dragon"}},
+		{"DRAGON", []string{"Red Dragon"}},
+		{"wings", []string{"Red Dragon"}},
+		{"body", []string{"Red Dragon"}},
+		{"minis/", []string{"Goblin", "Ox", "Red Dragon"}},
+		{"dragon wings", []string{"Red Dragon"}},
+		{"-dragon", []string{"Castle Wall", "Goblin", "Ox"}},
+		{"-wings", []string{"Castle Wall", "Goblin", "Ox"}},
+		// Shorter ones fall back to LIKE.
+		{"go", []string{"Goblin", "Red Dragon"}},
+		{"ox", []string{"Ox"}},
+		{"-ox", []string{"Castle Wall", "Goblin", "Red Dragon"}},
+		{"-go", []string{"Castle Wall", "Ox"}},
+		{"a%", nil},
+		{"a_", nil},
+
+		{"tag:painted", []string{"Goblin", "Red Dragon"}},
+		{"tag:PAINTED", []string{"Goblin", "Red Dragon"}},
+		{"-tag:painted", []string{"Castle Wall", "Ox"}},
+		{"fmt:stl", []string{"Goblin", "Red Dragon"}},
+		{"ext:.3MF", []string{"Red Dragon"}},
+		{"-fmt:stl", []string{"Castle Wall", "Ox"}},
+		{"library:terrain", []string{"Castle Wall"}},
+		{"-lib:terrain", []string{"Goblin", "Ox", "Red Dragon"}},
+		{"collection:favourites", []string{"Red Dragon"}},
+		{"-col:favourites", []string{"Castle Wall", "Goblin", "Ox"}},
+		{"printed:yes", []string{"Castle Wall", "Red Dragon"}},
+		{"printed:no", []string{"Goblin", "Ox"}},
+		{"name:wall", []string{"Castle Wall"}},
+		{"path:terrain/", []string{"Castle Wall"}},
+
+		{"size:>1000", []string{"Castle Wall", "Red Dragon"}},
+		{"size:<1kb", []string{"Goblin", "Ox"}},
+		{"size:200..1500", []string{"Goblin", "Red Dragon"}},
+		{"-size:200..1500", []string{"Castle Wall", "Ox"}},
+		// Unknown heights match neither a comparison nor its negation.
+		{"height:>20", []string{"Red Dragon"}},
+		{"-height:>20", []string{"Goblin"}},
+		{"height:1cm..2cm", []string{"Goblin"}},
+		{"height:<=50mm", []string{"Goblin", "Red Dragon"}},
+
+		{"tag:painted -fmt:3mf", []string{"Goblin"}},
+		{"dragon tag:painted printed:yes", []string{"Red Dragon"}},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if want == nil {
+			want = []string{}
+		}
+		if got := fx.search(t, tt.query); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, want)
+		}
+	}
+}
+
+func testSearchTrash(t *testing.T, st store.Store) {
+	fx := newFixture(t, st)
+	ctx := context.Background()
+	expect := func(query string, want ...string) {
+		t.Helper()
+		if want == nil {
+			want = []string{}
+		}
+		if got := fx.search(t, query); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", query, got, want)
+		}
+	}
+
+	expect("wing.3mf", "Red Dragon")
+	if _, err := st.Files().Delete(ctx, fx.wing.ID); err != nil {
+		t.Fatal(err)
+	}
+	// A file in the trash no longer makes its model match.
+	expect("wing.3mf")
+	expect("fmt:3mf")
+	expect("wings", "Red Dragon")
+	if _, err := st.Files().Restore(ctx, fx.wing.ID); err != nil {
+		t.Fatal(err)
+	}
+	expect("wing.3mf", "Red Dragon")
+	expect("fmt:3mf", "Red Dragon")
+
+	if err := st.Models().Delete(ctx, fx.goblin.ID); err != nil {
+		t.Fatal(err)
+	}
+	expect("goblin")
+	expect("tag:painted", "Red Dragon")
+	if err := st.Libraries().Delete(ctx, fx.terrain.ID); err != nil {
+		t.Fatal(err)
+	}
+	expect("", "Ox", "Red Dragon")
+
+	if _, err := st.Models().Restore(ctx, fx.goblin.ID); err != nil {
+		t.Fatal(err)
+	}
+	expect("goblin.stl", "Goblin")
+	if _, err := st.Libraries().Restore(ctx, fx.terrain.ID); err != nil {
+		t.Fatal(err)
+	}
+	expect("wall.obj", "Castle Wall")
+}
+
+func testPaging(t *testing.T, st store.Store) {
+	newFixture(t, st)
+	ctx := context.Background()
+	// Ties, including timestamps equal to the store's precision, are
+	// broken by id, which follows creation order.
+	order := map[string][]string{
+		"name":    {"Castle Wall", "Goblin", "Ox", "Red Dragon"},
+		"created": {"Red Dragon", "Goblin", "Castle Wall", "Ox"},
+		"size":    {"Ox", "Goblin", "Red Dragon", "Castle Wall"},
+		"prints":  {"Goblin", "Ox", "Castle Wall", "Red Dragon"},
+	}
+	// Printing touched the models, so updated is whatever order the store
+	// recorded; paging must still follow it.
+	updated, err := st.Models().List(ctx, store.ModelFilter{}, store.PageRequest{Limit: 100, Sort: "updated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range updated.Items {
+		order["updated"] = append(order["updated"], m.Name)
+	}
+
+	for _, sortName := range store.ModelSorts {
+		for _, desc := range []bool{false, true} {
+			want := append([]string{}, order[sortName]...)
+			if desc {
+				for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+					want[i], want[j] = want[j], want[i]
+				}
+			}
+
+			var got []string
+			p := store.PageRequest{Limit: 1, Sort: sortName, Desc: desc}
+			for pages := 0; pages <= len(want); pages++ {
+				page, err := st.Models().List(ctx, store.ModelFilter{}, p)
+				if err != nil {
+					t.Fatalf("%s desc=%v: %v", sortName, desc, err)
+				}
+				// Only the first page pays for a count.
+				switch {
+				case p.After == nil && (page.Total == nil || *page.Total != len(want)):
+					t.Errorf("%s desc=%v: first page total %v, want %d", sortName, desc, page.Total, len(want))
+				case p.After != nil && page.Total != nil:
+					t.Errorf("%s desc=%v: total %d after a cursor", sortName, desc, *page.Total)
+				}
+				for _, m := range page.Items {
+					got = append(got, m.Name)
+				}
+				if page.Next == nil {
+					break
+				}
+				p.After = page.Next
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s desc=%v: got %v, want %v", sortName, desc, got, want)
+			}
+		}
+	}
+
+	if _, err := st.Models().List(ctx, store.ModelFilter{}, store.PageRequest{Limit: 1, Sort: "nope"}); err == nil {
+		t.Error("unknown sort accepted")
+	}
+}
+
+func testFacets(t *testing.T, st store.Store) {
+	fx := newFixture(t, st)
+	counts := func(pairs ...interface{}) []store.FacetCount {
+		list := []store.FacetCount{}
+		for i := 0; i < len(pairs); i += 2 {
+			list = append(list, store.FacetCount{Value: pairs[i].(string), Count: pairs[i+1].(int)})
+		}
+		return list
+	}
+
+	tests := []struct {
+		query string
+		want  store.Facets
+	}{
+		{"", store.Facets{
+			Tags:        counts("painted", 2, "dragon", 1),
+			Formats:     counts("stl", 2, "3mf", 1, "obj", 1),
+			Libraries:   counts("Minis", 3, "Terrain", 1),
+			Collections: counts("Favourites", 1),
+		}},
+		{"tag:painted", store.Facets{
+			Tags:        counts("painted", 2, "dragon", 1),
+			Formats:     counts("stl", 2, "3mf", 1),
+			Libraries:   counts("Minis", 2),
+			Collections: counts("Favourites", 1),
+		}},
+		{"-tag:painted", store.Facets{
+			Tags:        counts(),
+			Formats:     counts("obj", 1),
+			Libraries:   counts("Minis", 1, "Terrain", 1),
+			Collections: counts(),
+		}},
+	}
+	for _, tt := range tests {
+		q, err := search.Parse(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := st.Models().Facets(context.Background(), store.ModelFilter{Query: q})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.query, *got, tt.want)
+		}
+	}
+
+	// Files in the trash drop out of the format counts.
+	if _, err := st.Files().Delete(context.Background(), fx.wing.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.Models().Facets(context.Background(), store.ModelFilter{LibraryID: fx.minis.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := counts("stl", 2); !reflect.DeepEqual(got.Formats, want) {
+		t.Errorf("formats after deleting wing.3mf: got %v, want %v", got.Formats, want)
+	}
+}
@@ -0,0 +1,103 @@
+package thumbnail
+
+import (
+	"3d-library/internal/mesh"
+	"image"
+	"image/color"
+	"math"
+)
+
+var modelColor = [3]float64{0x6c, 0x8e, 0xbf}
+
+// Render draws the mesh with flat shading from a fixed three-quarter view
+// looking down at 30 degrees, Z up, matching how prints sit on the bed.
+func Render(m *mesh.Mesh, size int) *image.RGBA {
+	img := blank(size)
+
+	min, max := m.Bounds()
+	center := mesh.Vec3{(min[0] + max[0]) / 2, (min[1] + max[1]) / 2, (min[2] + max[2]) / 2}
+	radius := max.Sub(min).Len() / 2
+	if radius == 0 {
+		return img
+	}
+	scale := float64(size) * 0.45 / radius
+
+	yaw, pitch := math.Pi/4, math.Pi/6
+	cy, sy := math.Cos(yaw), math.Sin(yaw)
+	cp, sp := math.Cos(pitch), math.Sin(pitch)
+	project := func(v mesh.Vec3) mesh.Vec3 {
+		x, y, z := v[0]-center[0], v[1]-center[1], v[2]-center[2]
+		rx := cy*x - sy*y
+		ry := sy*x + cy*y
+		// Screen x, screen y (down), depth (towards the viewer is smaller).
+		return mesh.Vec3{
+			float64(size)/2 + rx*scale,
+			float64(size)/2 - (z*cp-ry*sp)*scale,
+			-(ry*cp + z*sp),
+		}
+	}
+
+	light := mesh.Vec3{-0.4, -0.5, -0.75}
+	depth := make([]float64, size*size)
+	for i := range depth {
+		depth[i] = math.Inf(1)
+	}
+
+	for _, t := range m.Triangles {
+		a, b, c := project(t[0]), project(t[1]), project(t[2])
+
+		// Two-sided Lambert shading so inconsistent winding still renders.
+		n := cross(b.Sub(a), c.Sub(a))
+		l := n.Len()
+		if l == 0 {
+			continue
+		}
+		shade := 0.25 + 0.75*math.Abs((n[0]*light[0]+n[1]*light[1]+n[2]*light[2])/l/light.Len())
+		col := color.RGBA{
+			uint8(math.Min(255, modelColor[0]*shade*1.4)),
+			uint8(math.Min(255, modelColor[1]*shade*1.4)),
+			uint8(math.Min(255, modelColor[2]*shade*1.4)),
+			0xff,
+		}
+
+		x0 := int(math.Max(0, math.Floor(math.Min(a[0], math.Min(b[0], c[0])))))
+		x1 := int(math.Min(float64(size-1), math.Ceil(math.Max(a[0], math.Max(b[0], c[0])))))
+		y0 := int(math.Max(0, math.Floor(math.Min(a[1], math.Min(b[1], c[1])))))
+		y1 := int(math.Min(float64(size-1), math.Ceil(math.Max(a[1], math.Max(b[1], c[1])))))
+		area := edge(a, b, c[0], c[1])
+		if area == 0 {
+			continue
+		}
+
+		for y := y0; y <= y1; y++ {
+			py := float64(y) + 0.5
+			for x := x0; x <= x1; x++ {
+				px := float64(x) + 0.5
+				w0 := edge(b, c, px, py) / area
+				w1 := edge(c, a, px, py) / area
+				w2 := edge(a, b, px, py) / area
+				if w0 < 0 || w1 < 0 || w2 < 0 {
+					continue
+				}
+				z := w0*a[2] + w1*b[2] + w2*c[2]
+				if z < depth[y*size+x] {
+					depth[y*size+x] = z
+					img.SetRGBA(x, y, col)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func edge(a, b mesh.Vec3, x, y float64) float64 {
+	return (b[0]-a[0])*(y-a[1]) - (b[1]-a[1])*(x-a[0])
+}
+
+func cross(a, b mesh.Vec3) mesh.Vec3 {
+	return mesh.Vec3{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
@@ -0,0 +1,117 @@
+package thumbnail
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const Size = 256
+
+var Background = color.RGBA{0x1a, 0x1a, 0x2e, 0xff}
+
+// CacheDir is where rendered thumbnails are cached. The binaries set it
+// from the thumbnails.dir setting.
+var CacheDir = filepath.Join("data", "thumbnails")
+
+// MaxPixels caps the images Decode accepts. A header can claim a size far
+// beyond the file's, and decoding allocates whatever it claims.
+const MaxPixels = 40_000_000
+
+var ErrTooLarge = errors.New("image too large")
+
+// Decode reads the image header and refuses images over MaxPixels before
+// decoding the rest.
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels, the limit is %d megapixels", ErrTooLarge, cfg.Width, cfg.Height, MaxPixels/1_000_000)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+func Dir() string {
+	return CacheDir
+}
+
+func Path(modelID int64) string {
+	return filepath.Join(Dir(), fmt.Sprintf("%d.png", modelID))
+}
+
+// Save writes the PNG atomically so readers never see a partial file.
+func Save(modelID int64, img image.Image) error {
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(Dir(), "thumb-*.png")
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), Path(modelID))
+}
+
+func blank(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = Background.R, Background.G, Background.B, 0xff
+	}
+	return img
+}
+
+// Fit scales an image to fit inside a size x size square on the thumbnail
+// background, averaging source pixels so downscaling does not alias.
+func Fit(src image.Image, size int) *image.RGBA {
+	dst := blank(size)
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+	scale := float64(size) / float64(max(sw, sh))
+	w, h := int(float64(sw)*scale), int(float64(sh)*scale)
+	ox, oy := (size-w)/2, (size-h)/2
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*sh/h
+		y1 := max(b.Min.Y+(y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*sw/w
+			x1 := max(b.Min.X+(x+1)*sw/w, x0+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			// Composite transparent pixels onto the background.
+			alpha := float64(a) / float64(n) / 0xffff
+			mix := func(c uint64, bg uint8) uint8 {
+				return uint8(float64(c)/float64(n)/0x101 + (1-alpha)*float64(bg))
+			}
+			dst.SetRGBA(ox+x, oy+y, color.RGBA{mix(r, Background.R), mix(g, Background.G), mix(bl, Background.B), 0xff})
+		}
+	}
+	return dst
+}
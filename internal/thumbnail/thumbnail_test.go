@@ -0,0 +1,43 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	img, err := Decode(bytes.NewReader(encodePNG(t, 30, 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 30 || b.Dy() != 20 {
+		t.Errorf("decoded %v, want 30x20", b)
+	}
+}
+
+func TestDecodeRefusesHugeHeader(t *testing.T) {
+	// A few hundred bytes whose header claims 60000x60000 pixels.
+	data := encodePNG(t, 1, 1)
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], 60000)
+	binary.BigEndian.PutUint32(ihdr[4:8], 60000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+
+	if _, err := Decode(bytes.NewReader(data)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+}
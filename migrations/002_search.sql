@@ -0,0 +1,36 @@
+-- +goose Up
+ALTER TABLE models ADD COLUMN total_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN width DOUBLE PRECISION;
+ALTER TABLE models ADD COLUMN depth DOUBLE PRECISION;
+ALTER TABLE models ADD COLUMN height DOUBLE PRECISION;
+ALTER TABLE models ADD COLUMN print_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN last_printed_at TIMESTAMP;
+
+ALTER TABLE model_files ADD COLUMN format TEXT;
+ALTER TABLE model_files ADD COLUMN width DOUBLE PRECISION;
+ALTER TABLE model_files ADD COLUMN depth DOUBLE PRECISION;
+ALTER TABLE model_files ADD COLUMN height DOUBLE PRECISION;
+
+UPDATE model_files SET format = LOWER(SUBSTRING(filename FROM '\.([^.]+)$'));
+UPDATE models m SET total_size = COALESCE((SELECT SUM(size) FROM model_files WHERE model_id = m.id), 0);
+
+CREATE INDEX idx_model_files_format ON model_files(format);
+CREATE INDEX idx_model_tags_tag ON model_tags(tag_id);
+CREATE INDEX idx_model_collections_collection ON model_collections(collection_id);
+
+-- +goose Down
+DROP INDEX idx_model_collections_collection;
+DROP INDEX idx_model_tags_tag;
+DROP INDEX idx_model_files_format;
+
+ALTER TABLE model_files DROP COLUMN height;
+ALTER TABLE model_files DROP COLUMN depth;
+ALTER TABLE model_files DROP COLUMN width;
+ALTER TABLE model_files DROP COLUMN format;
+
+ALTER TABLE models DROP COLUMN last_printed_at;
+ALTER TABLE models DROP COLUMN print_count;
+ALTER TABLE models DROP COLUMN height;
+ALTER TABLE models DROP COLUMN depth;
+ALTER TABLE models DROP COLUMN width;
+ALTER TABLE models DROP COLUMN total_size;
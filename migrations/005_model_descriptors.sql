@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE model_descriptors (
+    model_id INTEGER PRIMARY KEY REFERENCES models(id) ON DELETE CASCADE,
+    file_id INTEGER REFERENCES model_files(id) ON DELETE CASCADE,
+    digest TEXT,
+    histogram TEXT NOT NULL,
+    ratio_mid DOUBLE PRECISION NOT NULL,
+    ratio_min DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_model_descriptors_ratios ON model_descriptors(ratio_mid, ratio_min);
+
+-- +goose Down
+DROP TABLE model_descriptors;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE model_image_features (
+    model_id INTEGER PRIMARY KEY REFERENCES models(id) ON DELETE CASCADE,
+    source_file_id INTEGER REFERENCES model_files(id) ON DELETE CASCADE,
+    source_digest TEXT,
+    phash BIGINT NOT NULL,
+    color TEXT NOT NULL,
+    edges TEXT NOT NULL,
+    computed_at TIMESTAMP DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE model_image_features;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE collections ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE tags ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE tags DROP COLUMN updated_at;
+ALTER TABLE collections DROP COLUMN updated_at;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- IF NOT EXISTS covers databases where the column was added by hand.
+ALTER TABLE models ADD COLUMN IF NOT EXISTS preview_file_id INTEGER REFERENCES model_files(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE models DROP COLUMN preview_file_id;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE library_members (
+    library_id INTEGER NOT NULL REFERENCES libraries(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'contributor', 'editor', 'admin')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (library_id, user_id)
+);
+
+CREATE INDEX idx_library_members_user ON library_members(user_id);
+
+ALTER TABLE bulk_jobs ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE bulk_jobs DROP COLUMN user_id;
+DROP TABLE library_members;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- A share link gives anyone holding its secret read-only access to one
+-- model or one collection. Like API tokens, only a hash of the secret is
+-- stored.
+CREATE TABLE share_links (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    model_id INTEGER REFERENCES models(id) ON DELETE CASCADE,
+    collection_id INTEGER REFERENCES collections(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    password_hash TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    max_downloads INTEGER,
+    downloads INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    CHECK ((model_id IS NULL) <> (collection_id IS NULL))
+);
+
+CREATE INDEX idx_share_links_user ON share_links(user_id);
+
+-- +goose Down
+DROP TABLE share_links;
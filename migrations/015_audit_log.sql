@@ -0,0 +1,38 @@
+-- +goose Up
+-- The audit log records every change with who made it and from where.
+-- Rows are never updated or deleted, so it has no foreign keys and keeps
+-- the actor's name alongside their id.
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id INTEGER,
+    before TEXT,
+    after TEXT,
+    source_ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_created ON audit_log(created_at);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- Bulk jobs remember where they were queued from for the entries they add.
+ALTER TABLE bulk_jobs ADD COLUMN source_ip TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE bulk_jobs DROP COLUMN source_ip;
+DROP TABLE audit_log;
+DROP FUNCTION audit_log_append_only();
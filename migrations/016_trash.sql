@@ -0,0 +1,23 @@
+-- +goose Up
+-- Deleting a library, model or file moves it to the trash by setting
+-- deleted_at; everything deleted along with it gets the same time so a
+-- restore can bring back exactly that. Tags and collection links are kept.
+ALTER TABLE libraries ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE models ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE model_files ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_libraries_deleted ON libraries(deleted_at);
+CREATE INDEX idx_models_deleted ON models(deleted_at);
+CREATE INDEX idx_model_files_deleted ON model_files(deleted_at);
+
+-- +goose Down
+-- What is in the trash was deleted, so it goes for good.
+DELETE FROM libraries WHERE deleted_at IS NOT NULL;
+DELETE FROM models WHERE deleted_at IS NOT NULL;
+DELETE FROM model_files WHERE deleted_at IS NOT NULL;
+DROP INDEX idx_model_files_deleted;
+DROP INDEX idx_models_deleted;
+DROP INDEX idx_libraries_deleted;
+ALTER TABLE model_files DROP COLUMN deleted_at;
+ALTER TABLE models DROP COLUMN deleted_at;
+ALTER TABLE libraries DROP COLUMN deleted_at;
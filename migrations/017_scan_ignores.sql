@@ -0,0 +1,17 @@
+-- +goose Up
+-- A file deleted from disk is moved into its library's .trash folder, and
+-- trash_path says where so restoring can move it back.
+ALTER TABLE model_files ADD COLUMN trash_path TEXT;
+
+-- A file forgotten stays on disk; scans skip the paths listed here,
+-- relative to the library root, so it does not come back.
+CREATE TABLE scan_ignores (
+    library_id INTEGER NOT NULL REFERENCES libraries(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (library_id, path)
+);
+
+-- +goose Down
+DROP TABLE scan_ignores;
+ALTER TABLE model_files DROP COLUMN trash_path;
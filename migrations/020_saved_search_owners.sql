@@ -0,0 +1,16 @@
+-- +goose Up
+-- Saved searches are personal. Those saved before go to the first site
+-- administrator, or are dropped when there is none.
+ALTER TABLE saved_searches ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+UPDATE saved_searches SET user_id = (SELECT MIN(id) FROM users WHERE admin);
+DELETE FROM saved_searches WHERE user_id IS NULL;
+ALTER TABLE saved_searches ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE saved_searches DROP CONSTRAINT saved_searches_name_key;
+ALTER TABLE saved_searches ADD CONSTRAINT saved_searches_user_id_name_key UNIQUE (user_id, name);
+
+-- +goose Down
+-- Names are unique again, so only the oldest search of each name is kept.
+ALTER TABLE saved_searches DROP CONSTRAINT saved_searches_user_id_name_key;
+DELETE FROM saved_searches s WHERE EXISTS (SELECT 1 FROM saved_searches o WHERE o.name = s.name AND o.id < s.id);
+ALTER TABLE saved_searches ADD CONSTRAINT saved_searches_name_key UNIQUE (name);
+ALTER TABLE saved_searches DROP COLUMN user_id;
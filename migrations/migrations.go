@@ -0,0 +1,9 @@
+// Package migrations embeds the schema migrations so the binary can apply
+// them. Files use goose annotations; sqlite/ holds the SQLite versions of
+// the same steps.
+package migrations
+
+import "embed"
+
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
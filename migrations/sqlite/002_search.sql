@@ -0,0 +1,86 @@
+-- +goose Up
+ALTER TABLE models ADD COLUMN total_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN width DOUBLE PRECISION;
+ALTER TABLE models ADD COLUMN depth DOUBLE PRECISION;
+ALTER TABLE models ADD COLUMN height DOUBLE PRECISION;
+ALTER TABLE models ADD COLUMN print_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN last_printed_at TIMESTAMP;
+
+ALTER TABLE model_files ADD COLUMN format TEXT;
+ALTER TABLE model_files ADD COLUMN width DOUBLE PRECISION;
+ALTER TABLE model_files ADD COLUMN depth DOUBLE PRECISION;
+ALTER TABLE model_files ADD COLUMN height DOUBLE PRECISION;
+
+CREATE INDEX idx_model_files_format ON model_files(format);
+CREATE INDEX idx_model_tags_tag ON model_tags(tag_id);
+CREATE INDEX idx_model_collections_collection ON model_collections(collection_id);
+
+-- Free-text search. The trigram tokenizer matches substrings the way ILIKE
+-- does on Postgres; files holds the model's file names.
+CREATE VIRTUAL TABLE model_search USING fts5(name, description, path, files, tokenize = 'trigram');
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_insert AFTER INSERT ON models BEGIN
+    INSERT INTO model_search (rowid, name, description, path, files)
+    VALUES (NEW.id, NEW.name, COALESCE(NEW.description, ''), NEW.path, '');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_update AFTER UPDATE OF name, description, path ON models BEGIN
+    UPDATE model_search SET name = NEW.name, description = COALESCE(NEW.description, ''), path = NEW.path
+    WHERE rowid = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_delete AFTER DELETE ON models BEGIN
+    DELETE FROM model_search WHERE rowid = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_insert AFTER INSERT ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = NEW.model_id)
+    WHERE rowid = NEW.model_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_update AFTER UPDATE OF filename, model_id ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = model_search.rowid)
+    WHERE rowid IN (OLD.model_id, NEW.model_id);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_delete AFTER DELETE ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = OLD.model_id)
+    WHERE rowid = OLD.model_id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER model_search_files_delete;
+DROP TRIGGER model_search_files_update;
+DROP TRIGGER model_search_files_insert;
+DROP TRIGGER model_search_delete;
+DROP TRIGGER model_search_update;
+DROP TRIGGER model_search_insert;
+DROP TABLE model_search;
+
+DROP INDEX idx_model_collections_collection;
+DROP INDEX idx_model_tags_tag;
+DROP INDEX idx_model_files_format;
+
+ALTER TABLE model_files DROP COLUMN height;
+ALTER TABLE model_files DROP COLUMN depth;
+ALTER TABLE model_files DROP COLUMN width;
+ALTER TABLE model_files DROP COLUMN format;
+
+ALTER TABLE models DROP COLUMN last_printed_at;
+ALTER TABLE models DROP COLUMN print_count;
+ALTER TABLE models DROP COLUMN height;
+ALTER TABLE models DROP COLUMN depth;
+ALTER TABLE models DROP COLUMN width;
+ALTER TABLE models DROP COLUMN total_size;
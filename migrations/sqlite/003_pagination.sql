@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE INDEX idx_models_created ON models(created_at, id);
+CREATE INDEX idx_models_updated ON models(updated_at, id);
+CREATE INDEX idx_models_name ON models(name, id);
+CREATE INDEX idx_models_total_size ON models(total_size, id);
+CREATE INDEX idx_models_print_count ON models(print_count, id);
+CREATE INDEX idx_models_library_created ON models(library_id, created_at, id);
+CREATE INDEX idx_model_files_model_filename ON model_files(model_id, filename, id);
+CREATE INDEX idx_collections_created ON collections(created_at, id);
+
+-- +goose Down
+DROP INDEX idx_collections_created;
+DROP INDEX idx_model_files_model_filename;
+DROP INDEX idx_models_library_created;
+DROP INDEX idx_models_print_count;
+DROP INDEX idx_models_total_size;
+DROP INDEX idx_models_name;
+DROP INDEX idx_models_updated;
+DROP INDEX idx_models_created;
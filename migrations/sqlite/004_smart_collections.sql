@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE collections ADD COLUMN query TEXT;
+
+CREATE TABLE saved_searches (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW())
+);
+
+-- +goose Down
+DROP TABLE saved_searches;
+ALTER TABLE collections DROP COLUMN query;
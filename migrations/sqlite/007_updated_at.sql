@@ -0,0 +1,6 @@
+-- +goose Up
+-- collections.updated_at and tags.updated_at are created in 001 on SQLite.
+SELECT 1;
+
+-- +goose Down
+SELECT 1;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE bulk_jobs (
+    id INTEGER PRIMARY KEY,
+    operation TEXT NOT NULL,
+    request TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    total INTEGER NOT NULL,
+    results TEXT,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT (NOW()),
+    finished_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE bulk_jobs;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE models ADD COLUMN preview_file_id INTEGER REFERENCES model_files(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE models DROP COLUMN preview_file_id;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Tasks for the embedded job queue, used instead of Redis on small installs.
+CREATE TABLE job_queue (
+    id TEXT PRIMARY KEY,
+    queue TEXT NOT NULL DEFAULT 'default',
+    type TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending',
+    max_retry INTEGER NOT NULL,
+    retried INTEGER NOT NULL DEFAULT 0,
+    timeout_seconds BIGINT NOT NULL DEFAULT 0,
+    deadline TIMESTAMP,
+    run_at TIMESTAMP NOT NULL,
+    lease_until TIMESTAMP,
+    unique_key TEXT UNIQUE,
+    unique_until TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT (NOW())
+);
+
+CREATE INDEX idx_job_queue_ready ON job_queue(state, run_at);
+
+-- +goose Down
+DROP TABLE job_queue;
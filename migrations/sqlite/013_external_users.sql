@@ -0,0 +1,10 @@
+-- +goose Up
+-- external_id ties a user to an identity provider account, as
+-- "ISSUER#SUBJECT", so a renamed account keeps its Go3D user.
+ALTER TABLE users ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX idx_users_external_id ON users(external_id);
+
+-- +goose Down
+DROP INDEX idx_users_external_id;
+ALTER TABLE users DROP COLUMN external_id;
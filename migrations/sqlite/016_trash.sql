@@ -0,0 +1,74 @@
+-- +goose Up
+-- Deleting a library, model or file moves it to the trash by setting
+-- deleted_at; everything deleted along with it gets the same time so a
+-- restore can bring back exactly that. Tags and collection links are kept.
+ALTER TABLE libraries ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE models ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE model_files ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_libraries_deleted ON libraries(deleted_at);
+CREATE INDEX idx_models_deleted ON models(deleted_at);
+CREATE INDEX idx_model_files_deleted ON model_files(deleted_at);
+
+-- Search only sees the file names outside the trash.
+DROP TRIGGER model_search_files_insert;
+DROP TRIGGER model_search_files_update;
+DROP TRIGGER model_search_files_delete;
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_insert AFTER INSERT ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = NEW.model_id AND deleted_at IS NULL)
+    WHERE rowid = NEW.model_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_update AFTER UPDATE OF filename, model_id, deleted_at ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = model_search.rowid AND deleted_at IS NULL)
+    WHERE rowid IN (OLD.model_id, NEW.model_id);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_delete AFTER DELETE ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = OLD.model_id AND deleted_at IS NULL)
+    WHERE rowid = OLD.model_id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER model_search_files_insert;
+DROP TRIGGER model_search_files_update;
+DROP TRIGGER model_search_files_delete;
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_insert AFTER INSERT ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = NEW.model_id)
+    WHERE rowid = NEW.model_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_update AFTER UPDATE OF filename, model_id ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = model_search.rowid)
+    WHERE rowid IN (OLD.model_id, NEW.model_id);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER model_search_files_delete AFTER DELETE ON model_files BEGIN
+    UPDATE model_search SET files = (SELECT COALESCE(GROUP_CONCAT(filename, ' '), '') FROM model_files WHERE model_id = OLD.model_id)
+    WHERE rowid = OLD.model_id;
+END;
+-- +goose StatementEnd
+
+-- What is in the trash was deleted, so it goes for good.
+DELETE FROM libraries WHERE deleted_at IS NOT NULL;
+DELETE FROM models WHERE deleted_at IS NOT NULL;
+DELETE FROM model_files WHERE deleted_at IS NOT NULL;
+DROP INDEX idx_model_files_deleted;
+DROP INDEX idx_models_deleted;
+DROP INDEX idx_libraries_deleted;
+ALTER TABLE model_files DROP COLUMN deleted_at;
+ALTER TABLE models DROP COLUMN deleted_at;
+ALTER TABLE libraries DROP COLUMN deleted_at;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- A library may cap the space its files take, and files remember who
+-- uploaded them so each user's uploads can be capped too.
+ALTER TABLE libraries ADD COLUMN quota_mb INTEGER;
+ALTER TABLE model_files ADD COLUMN uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX idx_model_files_uploaded_by ON model_files(uploaded_by);
+
+-- +goose Down
+DROP INDEX idx_model_files_uploaded_by;
+ALTER TABLE model_files DROP COLUMN uploaded_by;
+ALTER TABLE libraries DROP COLUMN quota_mb;
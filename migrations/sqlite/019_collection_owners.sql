@@ -0,0 +1,7 @@
+-- +goose Up
+-- A collection belongs to the user who created it; only they and site
+-- administrators may change it. Collections made before have no owner.
+ALTER TABLE collections ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE collections DROP COLUMN user_id;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Saved searches are personal. Those saved before go to the first site
+-- administrator, or are dropped when there is none. SQLite cannot change a
+-- table's unique keys, so the table is rebuilt.
+CREATE TABLE saved_searches_new (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW()),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, name)
+);
+INSERT INTO saved_searches_new (id, name, query, created_at, user_id)
+SELECT s.id, s.name, s.query, s.created_at, u.id
+FROM saved_searches s, (SELECT MIN(id) AS id FROM users WHERE admin) u
+WHERE u.id IS NOT NULL;
+DROP TABLE saved_searches;
+ALTER TABLE saved_searches_new RENAME TO saved_searches;
+
+-- +goose Down
+-- Names are unique again, so only the oldest search of each name is kept.
+CREATE TABLE saved_searches_old (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW())
+);
+INSERT INTO saved_searches_old (id, name, query, created_at)
+SELECT id, name, query, created_at FROM saved_searches s
+WHERE NOT EXISTS (SELECT 1 FROM saved_searches o WHERE o.name = s.name AND o.id < s.id);
+DROP TABLE saved_searches;
+ALTER TABLE saved_searches_old RENAME TO saved_searches;
@@ -0,0 +1,1689 @@
+// Code generated by internal/api/clientgen from internal/api/openapi.json; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type APIError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type Library struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Storage string `json:"storage"`
+	// Space the library's files may take, counting the trash; null is no quota
+	QuotaMb   *int64    `json:"quota_mb,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Set while in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type LibraryCreate struct {
+	Name string `json:"name"`
+	// Existing absolute directory
+	Path    string  `json:"path"`
+	Storage *string `json:"storage,omitempty"`
+	QuotaMb *int64  `json:"quota_mb,omitempty"`
+}
+
+type LibraryUpdate struct {
+	Name    *string `json:"name,omitempty"`
+	Path    *string `json:"path,omitempty"`
+	Storage *string `json:"storage,omitempty"`
+	// Site administrators only; null removes the quota
+	QuotaMb *int64 `json:"quota_mb,omitempty"`
+}
+
+type Model struct {
+	ID            int64      `json:"id"`
+	LibraryID     int64      `json:"library_id"`
+	Name          string     `json:"name"`
+	Path          string     `json:"path"`
+	Description   *string    `json:"description"`
+	PreviewFileID *int64     `json:"preview_file_id"`
+	TotalSize     int64      `json:"total_size"`
+	Width         *float64   `json:"width"`
+	Depth         *float64   `json:"depth"`
+	Height        *float64   `json:"height"`
+	PrintCount    int        `json:"print_count"`
+	LastPrintedAt *time.Time `json:"last_printed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	// Set while in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type ModelCreate struct {
+	LibraryID   int64   `json:"library_id"`
+	Name        string  `json:"name"`
+	Path        string  `json:"path"`
+	Description *string `json:"description,omitempty"`
+}
+
+type ModelUpdate struct {
+	Name          *string `json:"name,omitempty"`
+	Description   *string `json:"description,omitempty"`
+	PreviewFileID *int64  `json:"preview_file_id,omitempty"`
+}
+
+type ModelPage struct {
+	Items []Model `json:"items"`
+	// Rows across all pages; counted on the first page only, null on pages fetched with a cursor
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type ModelFile struct {
+	ID        int64     `json:"id"`
+	ModelID   int64     `json:"model_id"`
+	Filename  string    `json:"filename"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	MimeType  *string   `json:"mime_type"`
+	Digest    *string   `json:"digest"`
+	Format    *string   `json:"format"`
+	Width     *float64  `json:"width"`
+	Depth     *float64  `json:"depth"`
+	Height    *float64  `json:"height"`
+	CreatedAt time.Time `json:"created_at"`
+	// Set while in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Where the file was moved in the library's .trash folder when deleted from disk
+	TrashPath *string `json:"trash_path,omitempty"`
+	// User who uploaded the file; scanned files have none
+	UploadedBy *int64 `json:"uploaded_by,omitempty"`
+}
+
+type ModelFilePage struct {
+	Items []ModelFile `json:"items"`
+	// Rows across all pages; counted on the first page only, null on pages fetched with a cursor
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type Trash struct {
+	Libraries []Library   `json:"libraries"`
+	Models    []Model     `json:"models"`
+	Files     []ModelFile `json:"files"`
+}
+
+type PreviewRequest struct {
+	FileID *int64 `json:"file_id"`
+}
+
+type Tag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagPage struct {
+	Items []Tag `json:"items"`
+	// Rows across all pages; counted on the first page only, null on pages fetched with a cursor
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type TagAdd struct {
+	Tag string `json:"tag"`
+}
+
+type TagUpdate struct {
+	Name *string `json:"name,omitempty"`
+}
+
+type Collection struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Set for smart collections
+	Query *string `json:"query"`
+	// The owner; only the owner or an administrator can change the collection
+	UserID    *int64    `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CollectionPage struct {
+	Items []Collection `json:"items"`
+	// Rows across all pages; counted on the first page only, null on pages fetched with a cursor
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type CollectionCreate struct {
+	Name  string  `json:"name"`
+	Query *string `json:"query,omitempty"`
+}
+
+type CollectionUpdate struct {
+	Name  *string `json:"name,omitempty"`
+	Query *string `json:"query,omitempty"`
+}
+
+type CollectionQuery struct {
+	Query *string `json:"query"`
+}
+
+type CollectionModel struct {
+	ModelID int64 `json:"model_id"`
+}
+
+type ScanResponse struct {
+	Message string `json:"message"`
+	JobID   string `json:"job_id"`
+}
+
+type UploadResponse struct {
+	Uploaded []string `json:"uploaded"`
+	Count    int      `json:"count"`
+}
+
+type SimilarModel struct {
+	Model Model    `json:"model"`
+	Score float64  `json:"score"`
+	Shape *float64 `json:"shape"`
+	Name  float64  `json:"name"`
+	Tags  float64  `json:"tags"`
+}
+
+type ImageMatch struct {
+	Model Model   `json:"model"`
+	Score float64 `json:"score"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type Facets struct {
+	Tags        []FacetCount `json:"tags"`
+	Formats     []FacetCount `json:"formats"`
+	Libraries   []FacetCount `json:"libraries"`
+	Collections []FacetCount `json:"collections"`
+}
+
+type SearchResult struct {
+	Items []Model `json:"items"`
+	// Rows across all pages; counted on the first page only, null on pages fetched with a cursor
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+	Facets     Facets  `json:"facets"`
+}
+
+type SavedSearch struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SavedSearchInput struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+type BulkRequest struct {
+	Operation string  `json:"operation"`
+	ModelIDs  []int64 `json:"model_ids,omitempty"`
+	// Select models by search query instead of model_ids
+	Query        *string  `json:"query,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	CollectionID *int64   `json:"collection_id,omitempty"`
+	LibraryID    *int64   `json:"library_id,omitempty"`
+}
+
+type BulkResult struct {
+	ModelID int64   `json:"model_id"`
+	OK      bool    `json:"ok"`
+	Error   *string `json:"error,omitempty"`
+}
+
+type BulkSummary struct {
+	Operation string       `json:"operation"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+type BulkJob struct {
+	ID        int64   `json:"id"`
+	Operation string  `json:"operation"`
+	Status    string  `json:"status"`
+	Total     int     `json:"total"`
+	Error     *string `json:"error"`
+	// User the job runs as; null for the system
+	UserID     *int64       `json:"user_id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt *time.Time   `json:"finished_at"`
+	Summary    *BulkSummary `json:"summary,omitempty"`
+}
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+	Disabled bool   `json:"disabled"`
+	// Identity provider issuer and subject, for users who sign in with OIDC.
+	ExternalID  *string    `json:"external_id"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type AuthMethods struct {
+	Password bool `json:"password"`
+	// Single sign-on through an OpenID Connect provider.
+	Oidc bool `json:"oidc"`
+	// A trusted reverse proxy names the user in a header.
+	Proxy bool `json:"proxy"`
+}
+
+type UserCreate struct {
+	// Letters, digits and . _ @ -, at most 64 characters; unique ignoring case
+	Username string `json:"username"`
+	// 8 to 72 bytes
+	Password string `json:"password"`
+	Admin    *bool  `json:"admin,omitempty"`
+}
+
+type UserUpdate struct {
+	Password *string `json:"password,omitempty"`
+	Admin    *bool   `json:"admin,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+}
+
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type LibraryMember struct {
+	LibraryID int64     `json:"library_id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LibraryMemberSet struct {
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+}
+
+// Role: viewer browses, searches and downloads; contributor also uploads, adds models, tags and records prints; editor also changes and deletes models and files and scans; admin also changes the library and its members
+type Role string
+
+type APIToken struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	// Start of the token, to tell tokens apart
+	Prefix     string     `json:"prefix"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type APITokenCreate struct {
+	Name string `json:"name"`
+	// Omit for a token that does not expire
+	ExpiresInDays *int `json:"expires_in_days,omitempty"`
+}
+
+type NewAPIToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Send as "Authorization: Bearer TOKEN"; shown only once
+	Token string `json:"token"`
+}
+
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// Null for the system and anonymous requests
+	ActorID *int64 `json:"actor_id"`
+	// Username when the change was made
+	Actor    string `json:"actor"`
+	Action   string `json:"action"`
+	Entity   string `json:"entity"`
+	EntityID *int64 `json:"entity_id"`
+	// The entity before the change, as the API shows it
+	Before map[string]interface{} `json:"before"`
+	// The entity after the change
+	After map[string]interface{} `json:"after"`
+	// Client address; empty for jobs and the go3d command
+	SourceIP  string    `json:"source_ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditPage struct {
+	Items []AuditEntry `json:"items"`
+	// Rows across all pages; counted on the first page only, null on pages fetched with a cursor
+	Total      *int    `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type ShareLink struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `json:"user_id"`
+	ModelID      *int64 `json:"model_id"`
+	CollectionID *int64 `json:"collection_id"`
+	// Start of the token, to tell links apart
+	Prefix       string     `json:"prefix"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	MaxDownloads *int       `json:"max_downloads"`
+	Downloads    int        `json:"downloads"`
+	HasPassword  bool       `json:"has_password"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ShareCreate: Set either model_id or collection_id.
+type ShareCreate struct {
+	ModelID      *int64 `json:"model_id,omitempty"`
+	CollectionID *int64 `json:"collection_id,omitempty"`
+	// Omit for a link that does not expire
+	ExpiresInHours *int `json:"expires_in_hours,omitempty"`
+	// Asked for on the page, or sent as X-Share-Password
+	Password *string `json:"password,omitempty"`
+	// File downloads allowed; omit for no limit
+	MaxDownloads *int `json:"max_downloads,omitempty"`
+}
+
+type NewShareLink struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `json:"user_id"`
+	ModelID      *int64 `json:"model_id"`
+	CollectionID *int64 `json:"collection_id"`
+	// Start of the token, to tell links apart
+	Prefix       string     `json:"prefix"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	MaxDownloads *int       `json:"max_downloads"`
+	Downloads    int        `json:"downloads"`
+	HasPassword  bool       `json:"has_password"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// Shown only once
+	Token string `json:"token"`
+	// The page to send to people
+	URL string `json:"url"`
+}
+
+// GetOpenAPI: This document.
+//
+// GET /openapi.json
+func (c *Client) GetOpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	req := request{method: "GET", path: "/openapi.json"}
+	var out map[string]interface{}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Login: Sign in and start a session.
+//
+// POST /auth/login
+func (c *Client) Login(ctx context.Context, body Login) (*User, error) {
+	req := request{method: "POST", path: "/auth/login"}
+	req.json = body
+	out := new(User)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Logout: End the current session.
+//
+// POST /auth/logout
+func (c *Client) Logout(ctx context.Context) error {
+	req := request{method: "POST", path: "/auth/logout"}
+	return c.do(ctx, req, nil)
+}
+
+// GetCurrentUser: The signed-in user.
+//
+// GET /auth/me
+func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
+	req := request{method: "GET", path: "/auth/me"}
+	out := new(User)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetAuthMethods: Which ways of signing in are enabled.
+//
+// GET /auth/methods
+func (c *Client) GetAuthMethods(ctx context.Context) (*AuthMethods, error) {
+	req := request{method: "GET", path: "/auth/methods"}
+	out := new(AuthMethods)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StartOIDCLoginParams holds the query and header parameters of StartOIDCLogin.
+type StartOIDCLoginParams struct {
+	// Path on this server to return to after signing in.
+	Return *string
+}
+
+// StartOIDCLogin: Redirect the browser to the identity provider to sign in.
+//
+// GET /auth/oidc/login
+func (c *Client) StartOIDCLogin(ctx context.Context, params *StartOIDCLoginParams) error {
+	req := request{method: "GET", path: "/auth/oidc/login"}
+	if params != nil {
+		if params.Return != nil {
+			req.query().Set("return", fmt.Sprint(*params.Return))
+		}
+	}
+	return c.do(ctx, req, nil)
+}
+
+// FinishOIDCLoginParams holds the query and header parameters of FinishOIDCLogin.
+type FinishOIDCLoginParams struct {
+	State *string
+	Code  *string
+	Error *string
+}
+
+// FinishOIDCLogin: Where the identity provider sends the browser back to; starts a session.
+//
+// GET /auth/oidc/callback
+func (c *Client) FinishOIDCLogin(ctx context.Context, params *FinishOIDCLoginParams) error {
+	req := request{method: "GET", path: "/auth/oidc/callback"}
+	if params != nil {
+		if params.State != nil {
+			req.query().Set("state", fmt.Sprint(*params.State))
+		}
+		if params.Code != nil {
+			req.query().Set("code", fmt.Sprint(*params.Code))
+		}
+		if params.Error != nil {
+			req.query().Set("error", fmt.Sprint(*params.Error))
+		}
+	}
+	return c.do(ctx, req, nil)
+}
+
+// ChangePassword: Change your password.
+//
+// POST /auth/password
+func (c *Client) ChangePassword(ctx context.Context, body PasswordChange) error {
+	req := request{method: "POST", path: "/auth/password"}
+	req.json = body
+	return c.do(ctx, req, nil)
+}
+
+// ListTokens: List your API tokens.
+//
+// GET /auth/tokens
+func (c *Client) ListTokens(ctx context.Context) ([]APIToken, error) {
+	req := request{method: "GET", path: "/auth/tokens"}
+	var out []APIToken
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateToken: Create an API token.
+//
+// POST /auth/tokens
+func (c *Client) CreateToken(ctx context.Context, body APITokenCreate) (*NewAPIToken, error) {
+	req := request{method: "POST", path: "/auth/tokens"}
+	req.json = body
+	out := new(NewAPIToken)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteToken: Revoke one of your API tokens.
+//
+// DELETE /auth/tokens/{id}
+func (c *Client) DeleteToken(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/auth/tokens/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListSharesParams holds the query and header parameters of ListShares.
+type ListSharesParams struct {
+	// Another user's links, or 0 for everyone's (admin).
+	UserID *int64
+}
+
+// ListShares: List your share links.
+//
+// GET /shares
+func (c *Client) ListShares(ctx context.Context, params *ListSharesParams) ([]ShareLink, error) {
+	req := request{method: "GET", path: "/shares"}
+	if params != nil {
+		if params.UserID != nil {
+			req.query().Set("user_id", fmt.Sprint(*params.UserID))
+		}
+	}
+	var out []ShareLink
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateShare: Share a model or collection through a link.
+//
+// POST /shares
+func (c *Client) CreateShare(ctx context.Context, body ShareCreate) (*NewShareLink, error) {
+	req := request{method: "POST", path: "/shares"}
+	req.json = body
+	out := new(NewShareLink)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteShare: Revoke a share link.
+//
+// DELETE /shares/{id}
+func (c *Client) DeleteShare(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/shares/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListUsers: List users (admin).
+//
+// GET /users
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	req := request{method: "GET", path: "/users"}
+	var out []User
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateUser: Create a user (admin).
+//
+// POST /users
+func (c *Client) CreateUser(ctx context.Context, body UserCreate) (*User, error) {
+	req := request{method: "POST", path: "/users"}
+	req.json = body
+	out := new(User)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetUser: Get a user (admin).
+//
+// GET /users/{id}
+func (c *Client) GetUser(ctx context.Context, id int64) (*User, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/users/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(User)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateUserParams holds the query and header parameters of UpdateUser.
+type UpdateUserParams struct {
+	// ETag from a previous GET; the update fails with 412 if it no longer matches
+	IfMatch *string
+}
+
+// UpdateUser: Reset a password, change admin rights or disable a user (admin).
+//
+// PATCH /users/{id}
+func (c *Client) UpdateUser(ctx context.Context, id int64, body UserUpdate, params *UpdateUserParams) (*User, error) {
+	req := request{method: "PATCH", path: fmt.Sprintf("/users/%v", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.IfMatch != nil {
+			req.header().Set("If-Match", fmt.Sprint(*params.IfMatch))
+		}
+	}
+	req.json = body
+	out := new(User)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListAuditParams holds the query and header parameters of ListAudit.
+type ListAuditParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+	// Username, or go3d for the system
+	Actor  *string
+	Action *string
+	// library, model, file, tag, collection, saved_search, user, token or share_link
+	Entity   *string
+	EntityID *int64
+	// Entries at or after this RFC 3339 time or date
+	Since *string
+	// Entries before this RFC 3339 time or date
+	Until *string
+}
+
+// ListAudit: List audit log entries (admin).
+//
+// GET /audit
+func (c *Client) ListAudit(ctx context.Context, params *ListAuditParams) (*AuditPage, error) {
+	req := request{method: "GET", path: "/audit"}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+		if params.Actor != nil {
+			req.query().Set("actor", fmt.Sprint(*params.Actor))
+		}
+		if params.Action != nil {
+			req.query().Set("action", fmt.Sprint(*params.Action))
+		}
+		if params.Entity != nil {
+			req.query().Set("entity", fmt.Sprint(*params.Entity))
+		}
+		if params.EntityID != nil {
+			req.query().Set("entity_id", fmt.Sprint(*params.EntityID))
+		}
+		if params.Since != nil {
+			req.query().Set("since", fmt.Sprint(*params.Since))
+		}
+		if params.Until != nil {
+			req.query().Set("until", fmt.Sprint(*params.Until))
+		}
+	}
+	out := new(AuditPage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExportAuditParams holds the query and header parameters of ExportAudit.
+type ExportAuditParams struct {
+	// csv or json, the default
+	Format *string
+	Order  *string
+	// Username, or go3d for the system
+	Actor  *string
+	Action *string
+	// library, model, file, tag, collection, saved_search, user, token or share_link
+	Entity   *string
+	EntityID *int64
+	// Entries at or after this RFC 3339 time or date
+	Since *string
+	// Entries before this RFC 3339 time or date
+	Until *string
+}
+
+// ExportAudit: Download audit log entries as CSV or JSON (admin).
+//
+// GET /audit/export
+func (c *Client) ExportAudit(ctx context.Context, params *ExportAuditParams) (io.ReadCloser, error) {
+	req := request{method: "GET", path: "/audit/export"}
+	if params != nil {
+		if params.Format != nil {
+			req.query().Set("format", fmt.Sprint(*params.Format))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Actor != nil {
+			req.query().Set("actor", fmt.Sprint(*params.Actor))
+		}
+		if params.Action != nil {
+			req.query().Set("action", fmt.Sprint(*params.Action))
+		}
+		if params.Entity != nil {
+			req.query().Set("entity", fmt.Sprint(*params.Entity))
+		}
+		if params.EntityID != nil {
+			req.query().Set("entity_id", fmt.Sprint(*params.EntityID))
+		}
+		if params.Since != nil {
+			req.query().Set("since", fmt.Sprint(*params.Since))
+		}
+		if params.Until != nil {
+			req.query().Set("until", fmt.Sprint(*params.Until))
+		}
+	}
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ListLibraries: List the libraries you can see.
+//
+// GET /libraries
+func (c *Client) ListLibraries(ctx context.Context) ([]Library, error) {
+	req := request{method: "GET", path: "/libraries"}
+	var out []Library
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateLibrary: Create a library (admin).
+//
+// POST /libraries
+func (c *Client) CreateLibrary(ctx context.Context, body LibraryCreate) (*Library, error) {
+	req := request{method: "POST", path: "/libraries"}
+	req.json = body
+	out := new(Library)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetLibrary: Get a library.
+//
+// GET /libraries/{id}
+func (c *Client) GetLibrary(ctx context.Context, id int64) (*Library, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/libraries/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(Library)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateLibraryParams holds the query and header parameters of UpdateLibrary.
+type UpdateLibraryParams struct {
+	// ETag from a previous GET; the update fails with 412 if it no longer matches
+	IfMatch *string
+}
+
+// UpdateLibrary: Rename a library or move its path.
+//
+// PATCH /libraries/{id}
+func (c *Client) UpdateLibrary(ctx context.Context, id int64, body LibraryUpdate, params *UpdateLibraryParams) (*Library, error) {
+	req := request{method: "PATCH", path: fmt.Sprintf("/libraries/%v", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.IfMatch != nil {
+			req.header().Set("If-Match", fmt.Sprint(*params.IfMatch))
+		}
+	}
+	req.json = body
+	out := new(Library)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteLibrary: Move a library and its models to the trash.
+//
+// DELETE /libraries/{id}
+func (c *Client) DeleteLibrary(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/libraries/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// RestoreLibrary: Restore a library and its models from the trash.
+//
+// POST /libraries/{id}/restore
+func (c *Client) RestoreLibrary(ctx context.Context, id int64) (*Library, error) {
+	req := request{method: "POST", path: fmt.Sprintf("/libraries/%v/restore", url.PathEscape(fmt.Sprint(id)))}
+	out := new(Library)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ScanLibrary: Queue a scan of the library path.
+//
+// POST /libraries/{id}/scan
+func (c *Client) ScanLibrary(ctx context.Context, id int64) (*ScanResponse, error) {
+	req := request{method: "POST", path: fmt.Sprintf("/libraries/%v/scan", url.PathEscape(fmt.Sprint(id)))}
+	out := new(ScanResponse)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UploadModel: Upload files (or ZIP archives) as a model.
+//
+// POST /libraries/{id}/upload
+//
+// The body is multipart/form-data; see EncodeMultipart.
+func (c *Client) UploadModel(ctx context.Context, id int64, body io.Reader, contentType string) (*UploadResponse, error) {
+	req := request{method: "POST", path: fmt.Sprintf("/libraries/%v/upload", url.PathEscape(fmt.Sprint(id)))}
+	req.body, req.contentType = body, contentType
+	out := new(UploadResponse)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListLibraryMembers: List the users with a role in the library (library admin).
+//
+// GET /libraries/{id}/members
+func (c *Client) ListLibraryMembers(ctx context.Context, id int64) ([]LibraryMember, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/libraries/%v/members", url.PathEscape(fmt.Sprint(id)))}
+	var out []LibraryMember
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetLibraryMember: Give a user a role in the library, replacing any they had (library admin).
+//
+// POST /libraries/{id}/members
+func (c *Client) SetLibraryMember(ctx context.Context, id int64, body LibraryMemberSet) (*LibraryMember, error) {
+	req := request{method: "POST", path: fmt.Sprintf("/libraries/%v/members", url.PathEscape(fmt.Sprint(id)))}
+	req.json = body
+	out := new(LibraryMember)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RemoveLibraryMember: Take away a user's role in the library (library admin).
+//
+// DELETE /libraries/{id}/members/{userID}
+func (c *Client) RemoveLibraryMember(ctx context.Context, id int64, userID int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/libraries/%v/members/%v", url.PathEscape(fmt.Sprint(id)), url.PathEscape(fmt.Sprint(userID)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListModelsParams holds the query and header parameters of ListModels.
+type ListModelsParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor    *string
+	LibraryID *int64
+	// Search query filter
+	Q *string
+}
+
+// ListModels: List models.
+//
+// GET /models
+func (c *Client) ListModels(ctx context.Context, params *ListModelsParams) (*ModelPage, error) {
+	req := request{method: "GET", path: "/models"}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+		if params.LibraryID != nil {
+			req.query().Set("library_id", fmt.Sprint(*params.LibraryID))
+		}
+		if params.Q != nil {
+			req.query().Set("q", fmt.Sprint(*params.Q))
+		}
+	}
+	out := new(ModelPage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateModel: Create or update a model by library and path.
+//
+// POST /models
+func (c *Client) CreateModel(ctx context.Context, body ModelCreate) (*Model, error) {
+	req := request{method: "POST", path: "/models"}
+	req.json = body
+	out := new(Model)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetModel: Get a model.
+//
+// GET /models/{id}
+func (c *Client) GetModel(ctx context.Context, id int64) (*Model, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/models/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(Model)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateModelParams holds the query and header parameters of UpdateModel.
+type UpdateModelParams struct {
+	// ETag from a previous GET; the update fails with 412 if it no longer matches
+	IfMatch *string
+}
+
+// UpdateModel: Update name, description or preview file.
+//
+// PATCH /models/{id}
+func (c *Client) UpdateModel(ctx context.Context, id int64, body ModelUpdate, params *UpdateModelParams) (*Model, error) {
+	req := request{method: "PATCH", path: fmt.Sprintf("/models/%v", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.IfMatch != nil {
+			req.header().Set("If-Match", fmt.Sprint(*params.IfMatch))
+		}
+	}
+	req.json = body
+	out := new(Model)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteModel: Move a model and its files to the trash.
+//
+// DELETE /models/{id}
+func (c *Client) DeleteModel(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/models/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// RestoreModel: Restore a model from the trash.
+//
+// POST /models/{id}/restore
+func (c *Client) RestoreModel(ctx context.Context, id int64) (*Model, error) {
+	req := request{method: "POST", path: fmt.Sprintf("/models/%v/restore", url.PathEscape(fmt.Sprint(id)))}
+	out := new(Model)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListModelFilesParams holds the query and header parameters of ListModelFiles.
+type ListModelFilesParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+}
+
+// ListModelFiles: List the files of a model.
+//
+// GET /models/{id}/files
+func (c *Client) ListModelFiles(ctx context.Context, id int64, params *ListModelFilesParams) (*ModelFilePage, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/models/%v/files", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+	}
+	out := new(ModelFilePage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetModelPreview: Choose the preview file, or null for automatic selection.
+//
+// POST /models/{id}/preview
+func (c *Client) SetModelPreview(ctx context.Context, id int64, body PreviewRequest) error {
+	req := request{method: "POST", path: fmt.Sprintf("/models/%v/preview", url.PathEscape(fmt.Sprint(id)))}
+	req.json = body
+	return c.do(ctx, req, nil)
+}
+
+// RecordModelPrint: Record that the model was printed.
+//
+// POST /models/{id}/prints
+func (c *Client) RecordModelPrint(ctx context.Context, id int64) error {
+	req := request{method: "POST", path: fmt.Sprintf("/models/%v/prints", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListSimilarModelsParams holds the query and header parameters of ListSimilarModels.
+type ListSimilarModelsParams struct {
+	Limit *int
+}
+
+// ListSimilarModels: Models ranked by shape, name and tag similarity.
+//
+// GET /models/{id}/similar
+func (c *Client) ListSimilarModels(ctx context.Context, id int64, params *ListSimilarModelsParams) ([]SimilarModel, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/models/%v/similar", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+	}
+	var out []SimilarModel
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetModelThumbnail: Server-rendered PNG thumbnail.
+//
+// GET /models/{id}/thumbnail
+func (c *Client) GetModelThumbnail(ctx context.Context, id int64) (io.ReadCloser, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/models/%v/thumbnail", url.PathEscape(fmt.Sprint(id)))}
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ListModelTags: Tags of a model.
+//
+// GET /models/{id}/tags
+func (c *Client) ListModelTags(ctx context.Context, id int64) ([]Tag, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/models/%v/tags", url.PathEscape(fmt.Sprint(id)))}
+	var out []Tag
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddModelTag: Tag a model, creating the tag if needed.
+//
+// POST /models/{id}/tags
+func (c *Client) AddModelTag(ctx context.Context, id int64, body TagAdd) error {
+	req := request{method: "POST", path: fmt.Sprintf("/models/%v/tags", url.PathEscape(fmt.Sprint(id)))}
+	req.json = body
+	return c.do(ctx, req, nil)
+}
+
+// RemoveModelTag: Remove a tag from a model.
+//
+// DELETE /models/{id}/tags/{tagID}
+func (c *Client) RemoveModelTag(ctx context.Context, id int64, tagID int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/models/%v/tags/%v", url.PathEscape(fmt.Sprint(id)), url.PathEscape(fmt.Sprint(tagID)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListCollectionsParams holds the query and header parameters of ListCollections.
+type ListCollectionsParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+	// Name filter
+	Q *string
+}
+
+// ListCollections: List collections.
+//
+// GET /collections
+func (c *Client) ListCollections(ctx context.Context, params *ListCollectionsParams) (*CollectionPage, error) {
+	req := request{method: "GET", path: "/collections"}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+		if params.Q != nil {
+			req.query().Set("q", fmt.Sprint(*params.Q))
+		}
+	}
+	out := new(CollectionPage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateCollection: Create a manual or smart collection.
+//
+// POST /collections
+func (c *Client) CreateCollection(ctx context.Context, body CollectionCreate) (*Collection, error) {
+	req := request{method: "POST", path: "/collections"}
+	req.json = body
+	out := new(Collection)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetCollection: Get a collection.
+//
+// GET /collections/{id}
+func (c *Client) GetCollection(ctx context.Context, id int64) (*Collection, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/collections/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(Collection)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateCollectionParams holds the query and header parameters of UpdateCollection.
+type UpdateCollectionParams struct {
+	// ETag from a previous GET; the update fails with 412 if it no longer matches
+	IfMatch *string
+}
+
+// UpdateCollection: Rename a collection or change its query (owner or admin).
+//
+// PATCH /collections/{id}
+func (c *Client) UpdateCollection(ctx context.Context, id int64, body CollectionUpdate, params *UpdateCollectionParams) (*Collection, error) {
+	req := request{method: "PATCH", path: fmt.Sprintf("/collections/%v", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.IfMatch != nil {
+			req.header().Set("If-Match", fmt.Sprint(*params.IfMatch))
+		}
+	}
+	req.json = body
+	out := new(Collection)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteCollection: Delete a collection (owner or admin).
+//
+// DELETE /collections/{id}
+func (c *Client) DeleteCollection(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/collections/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListCollectionModelsParams holds the query and header parameters of ListCollectionModels.
+type ListCollectionModelsParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+}
+
+// ListCollectionModels: Models in a collection.
+//
+// GET /collections/{id}/models
+func (c *Client) ListCollectionModels(ctx context.Context, id int64, params *ListCollectionModelsParams) (*ModelPage, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/collections/%v/models", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+	}
+	out := new(ModelPage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddCollectionModel: Add a model to a manual collection (owner or admin).
+//
+// POST /collections/{id}/models
+func (c *Client) AddCollectionModel(ctx context.Context, id int64, body CollectionModel) error {
+	req := request{method: "POST", path: fmt.Sprintf("/collections/%v/models", url.PathEscape(fmt.Sprint(id)))}
+	req.json = body
+	return c.do(ctx, req, nil)
+}
+
+// RemoveCollectionModel: Remove a model from a collection (owner or admin).
+//
+// DELETE /collections/{id}/models/{modelID}
+func (c *Client) RemoveCollectionModel(ctx context.Context, id int64, modelID int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/collections/%v/models/%v", url.PathEscape(fmt.Sprint(id)), url.PathEscape(fmt.Sprint(modelID)))}
+	return c.do(ctx, req, nil)
+}
+
+// SetCollectionQueryParams holds the query and header parameters of SetCollectionQuery.
+type SetCollectionQueryParams struct {
+	// ETag from a previous GET; the update fails with 412 if it no longer matches
+	IfMatch *string
+}
+
+// SetCollectionQuery: Set or clear the smart collection query (owner or admin).
+//
+// PUT /collections/{id}/query
+func (c *Client) SetCollectionQuery(ctx context.Context, id int64, body CollectionQuery, params *SetCollectionQueryParams) (*Collection, error) {
+	req := request{method: "PUT", path: fmt.Sprintf("/collections/%v/query", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.IfMatch != nil {
+			req.header().Set("If-Match", fmt.Sprint(*params.IfMatch))
+		}
+	}
+	req.json = body
+	out := new(Collection)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetFile: Get file metadata.
+//
+// GET /files/{id}
+func (c *Client) GetFile(ctx context.Context, id int64) (*ModelFile, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/files/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(ModelFile)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteFileParams holds the query and header parameters of DeleteFile.
+type DeleteFileParams struct {
+	// forget leaves the file on disk and makes scans skip it; disk moves it into the library's .trash folder
+	Mode string
+}
+
+// DeleteFile: Move a file to the trash.
+//
+// DELETE /files/{id}
+func (c *Client) DeleteFile(ctx context.Context, id int64, params *DeleteFileParams) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/files/%v", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		req.query().Set("mode", fmt.Sprint(params.Mode))
+	}
+	return c.do(ctx, req, nil)
+}
+
+// RestoreFile: Restore a file from the trash.
+//
+// POST /files/{id}/restore
+func (c *Client) RestoreFile(ctx context.Context, id int64) (*ModelFile, error) {
+	req := request{method: "POST", path: fmt.Sprintf("/files/%v/restore", url.PathEscape(fmt.Sprint(id)))}
+	out := new(ModelFile)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DownloadFile: Download the file contents.
+//
+// GET /files/{id}/download
+func (c *Client) DownloadFile(ctx context.Context, id int64) (io.ReadCloser, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/files/%v/download", url.PathEscape(fmt.Sprint(id)))}
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ListTrash: List deleted libraries, models and files the user can restore.
+//
+// GET /trash
+func (c *Client) ListTrash(ctx context.Context) (*Trash, error) {
+	req := request{method: "GET", path: "/trash"}
+	out := new(Trash)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListTagsParams holds the query and header parameters of ListTags.
+type ListTagsParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+	// Name filter
+	Q *string
+}
+
+// ListTags: List tags.
+//
+// GET /tags
+func (c *Client) ListTags(ctx context.Context, params *ListTagsParams) (*TagPage, error) {
+	req := request{method: "GET", path: "/tags"}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+		if params.Q != nil {
+			req.query().Set("q", fmt.Sprint(*params.Q))
+		}
+	}
+	out := new(TagPage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetTag: Get a tag.
+//
+// GET /tags/{id}
+func (c *Client) GetTag(ctx context.Context, id int64) (*Tag, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/tags/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(Tag)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateTagParams holds the query and header parameters of UpdateTag.
+type UpdateTagParams struct {
+	// ETag from a previous GET; the update fails with 412 if it no longer matches
+	IfMatch *string
+}
+
+// UpdateTag: Rename a tag.
+//
+// PATCH /tags/{id}
+func (c *Client) UpdateTag(ctx context.Context, id int64, body TagUpdate, params *UpdateTagParams) (*Tag, error) {
+	req := request{method: "PATCH", path: fmt.Sprintf("/tags/%v", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.IfMatch != nil {
+			req.header().Set("If-Match", fmt.Sprint(*params.IfMatch))
+		}
+	}
+	req.json = body
+	out := new(Tag)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteTag: Delete a tag.
+//
+// DELETE /tags/{id}
+func (c *Client) DeleteTag(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/tags/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// RunBulkResponse holds whichever body the server returned.
+type RunBulkResponse struct {
+	StatusCode  int
+	BulkSummary *BulkSummary
+	BulkJob     *BulkJob
+}
+
+// RunBulk: Apply one operation to many models.
+//
+// POST /bulk
+func (c *Client) RunBulk(ctx context.Context, body BulkRequest) (*RunBulkResponse, error) {
+	req := request{method: "POST", path: "/bulk"}
+	req.json = body
+	out := &RunBulkResponse{}
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out.StatusCode = resp.StatusCode
+	switch resp.StatusCode {
+	case 200:
+		out.BulkSummary = new(BulkSummary)
+		return out, decode(resp, out.BulkSummary)
+	case 202:
+		out.BulkJob = new(BulkJob)
+		return out, decode(resp, out.BulkJob)
+	}
+	return out, nil
+}
+
+// GetBulkJob: Status of a background bulk job.
+//
+// GET /bulk/{id}
+func (c *Client) GetBulkJob(ctx context.Context, id int64) (*BulkJob, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/bulk/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(BulkJob)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchParams holds the query and header parameters of Search.
+type SearchParams struct {
+	Q string
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+}
+
+// Search: Search models with facets.
+//
+// GET /search
+func (c *Client) Search(ctx context.Context, params *SearchParams) (*SearchResult, error) {
+	req := request{method: "GET", path: "/search"}
+	if params != nil {
+		req.query().Set("q", fmt.Sprint(params.Q))
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+	}
+	out := new(SearchResult)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchImage: Find models whose thumbnails look like an image.
+//
+// POST /search/image
+//
+// The body is multipart/form-data; see EncodeMultipart.
+func (c *Client) SearchImage(ctx context.Context, body io.Reader, contentType string) ([]ImageMatch, error) {
+	req := request{method: "POST", path: "/search/image"}
+	req.body, req.contentType = body, contentType
+	var out []ImageMatch
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListSavedSearches: List saved searches.
+//
+// GET /searches
+func (c *Client) ListSavedSearches(ctx context.Context) ([]SavedSearch, error) {
+	req := request{method: "GET", path: "/searches"}
+	var out []SavedSearch
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateSavedSearch: Save a search.
+//
+// POST /searches
+func (c *Client) CreateSavedSearch(ctx context.Context, body SavedSearchInput) (*SavedSearch, error) {
+	req := request{method: "POST", path: "/searches"}
+	req.json = body
+	out := new(SavedSearch)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetSavedSearch: Get a saved search.
+//
+// GET /searches/{id}
+func (c *Client) GetSavedSearch(ctx context.Context, id int64) (*SavedSearch, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/searches/%v", url.PathEscape(fmt.Sprint(id)))}
+	out := new(SavedSearch)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateSavedSearch: Replace a saved search.
+//
+// PUT /searches/{id}
+func (c *Client) UpdateSavedSearch(ctx context.Context, id int64, body SavedSearchInput) (*SavedSearch, error) {
+	req := request{method: "PUT", path: fmt.Sprintf("/searches/%v", url.PathEscape(fmt.Sprint(id)))}
+	req.json = body
+	out := new(SavedSearch)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteSavedSearch: Delete a saved search.
+//
+// DELETE /searches/{id}
+func (c *Client) DeleteSavedSearch(ctx context.Context, id int64) error {
+	req := request{method: "DELETE", path: fmt.Sprintf("/searches/%v", url.PathEscape(fmt.Sprint(id)))}
+	return c.do(ctx, req, nil)
+}
+
+// ListSavedSearchResultsParams holds the query and header parameters of ListSavedSearchResults.
+type ListSavedSearchResultsParams struct {
+	// Page size, default 50, max 500
+	Limit *int
+	Sort  *string
+	Order *string
+	// next_cursor from the previous page
+	Cursor *string
+}
+
+// ListSavedSearchResults: Run a saved search.
+//
+// GET /searches/{id}/results
+func (c *Client) ListSavedSearchResults(ctx context.Context, id int64, params *ListSavedSearchResultsParams) (*ModelPage, error) {
+	req := request{method: "GET", path: fmt.Sprintf("/searches/%v/results", url.PathEscape(fmt.Sprint(id)))}
+	if params != nil {
+		if params.Limit != nil {
+			req.query().Set("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Sort != nil {
+			req.query().Set("sort", fmt.Sprint(*params.Sort))
+		}
+		if params.Order != nil {
+			req.query().Set("order", fmt.Sprint(*params.Order))
+		}
+		if params.Cursor != nil {
+			req.query().Set("cursor", fmt.Sprint(*params.Cursor))
+		}
+	}
+	out := new(ModelPage)
+	if err := c.do(ctx, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,178 @@
+// Package client is a typed client for the 3D Library REST API. The types
+// and methods in client.gen.go are generated from internal/api/openapi.json;
+// run go generate ./internal/api after changing the document.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the API at BaseURL, e.g. "http://localhost:3000/api".
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Header is added to every request.
+	Header http.Header
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		Header:     make(http.Header),
+	}
+}
+
+// Error is a non-2xx response decoded from the API error envelope.
+type Error struct {
+	StatusCode int
+	// RetryAfter is how long a 429 response asked to wait.
+	RetryAfter time.Duration
+	APIError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	for field, problem := range e.Fields {
+		msg += fmt.Sprintf("; %s %s", field, problem)
+	}
+	return msg
+}
+
+type request struct {
+	method      string
+	path        string
+	params      url.Values
+	headers     http.Header
+	json        interface{}
+	body        io.Reader
+	contentType string
+}
+
+func (r *request) query() url.Values {
+	if r.params == nil {
+		r.params = make(url.Values)
+	}
+	return r.params
+}
+
+func (r *request) header() http.Header {
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
+	return r.headers
+}
+
+// send performs the request and returns the response if it is a 2xx; other
+// statuses are turned into *Error.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	u := c.BaseURL + r.path
+	if len(r.params) > 0 {
+		u += "?" + r.params.Encode()
+	}
+
+	body, contentType := r.body, r.contentType
+	if r.json != nil {
+		raw, err := json.Marshal(r.json)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = bytes.NewReader(raw), "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	for k, v := range r.headers {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		var envelope ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil {
+			apiErr.APIError = envelope.Error
+		} else {
+			apiErr.Code, apiErr.Message = "unknown", resp.Status
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// do sends the request and decodes a JSON body into out unless it is nil.
+func (c *Client) do(ctx context.Context, r request, out interface{}) error {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return decode(resp, out)
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// File is one file part of a multipart request.
+type File struct {
+	Field   string
+	Name    string
+	Content io.Reader
+}
+
+// EncodeMultipart builds a multipart/form-data body for the upload and image
+// search endpoints and returns it with its content type.
+func EncodeMultipart(fields map[string]string, files ...File) (io.Reader, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, f := range files {
+		part, err := w.CreateFormFile(f.Field, f.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.Copy(part, f.Content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
@@ -1,3 +1,4 @@
+import { initAuth } from "./modules/auth.js";
 import { initNavigation, switchView, viewModelFiles, handleSetPreview, handleScanLibrary, loadPreview } from "./modules/ui.js";
 
 // Expose functions to global scope for onclick handlers
@@ -9,5 +10,5 @@ window.handleScanLibrary = handleScanLibrary;
 // Initialize app
 document.addEventListener("DOMContentLoaded", () => {
     initNavigation();
-    switchView("dashboard");
+    initAuth(() => switchView("dashboard"));
 });
@@ -1,49 +1,112 @@
 import { API_BASE } from "./config.js";
 
-export async function fetchModels() {
-    const response = await fetch(`${API_BASE}/models`);
-    if (!response.ok) throw new Error("Failed to fetch models");
+// apiFetch is fetch for the API. A 401 fires "go3d:unauthorized" so the
+// login form can be shown.
+async function apiFetch(path, options = {}) {
+    const response = await fetch(`${API_BASE}${path}`, options);
+    if (response.status === 401) {
+        window.dispatchEvent(new CustomEvent("go3d:unauthorized"));
+    }
+    return response;
+}
+
+async function errorMessage(response, fallback) {
+    try {
+        return (await response.json()).error.message;
+    } catch {
+        return fallback;
+    }
+}
+
+export async function login(username, password) {
+    const response = await fetch(`${API_BASE}/auth/login`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ username, password })
+    });
+    if (!response.ok) throw new Error(await errorMessage(response, "Sign in failed"));
+    return response.json();
+}
+
+export async function logout() {
+    await fetch(`${API_BASE}/auth/logout`, { method: "POST" });
+}
+
+// fetchAuthMethods reports which ways of signing in the server offers.
+export async function fetchAuthMethods() {
+    const response = await fetch(`${API_BASE}/auth/methods`);
+    if (!response.ok) return { password: true, oidc: false, proxy: false };
     return response.json();
 }
 
+// oidcLoginURL starts single sign-on, coming back to the current page.
+export function oidcLoginURL() {
+    return `${API_BASE}/auth/oidc/login?return=${encodeURIComponent(location.pathname)}`;
+}
+
+// fetchCurrentUser returns the signed-in user, or null when anonymous.
+export async function fetchCurrentUser() {
+    const response = await fetch(`${API_BASE}/auth/me`);
+    if (response.status === 401) return null;
+    if (!response.ok) throw new Error("Failed to fetch current user");
+    return response.json();
+}
+
+async function fetchPage(path, params = {}) {
+    const query = new URLSearchParams(params).toString();
+    const response = await apiFetch(`${path}${query ? "?" + query : ""}`);
+    if (!response.ok) throw new Error(`Failed to fetch ${path}`);
+    return response.json();
+}
+
+export async function fetchModelPage(params = {}) {
+    return fetchPage("/models", params);
+}
+
+export async function fetchModels(params = { limit: 100 }) {
+    return (await fetchModelPage(params)).items;
+}
+
 export async function fetchModel(id) {
-    const response = await fetch(`${API_BASE}/models/${id}`);
+    const response = await apiFetch(`/models/${id}`);
     if (!response.ok) throw new Error("Failed to fetch model");
     return response.json();
 }
 
 export async function fetchModelFiles(id) {
-    const response = await fetch(`${API_BASE}/models/${id}/files`);
-    if (!response.ok) throw new Error("Failed to fetch model files");
-    return response.json();
+    return (await fetchPage(`/models/${id}/files`, { limit: 500 })).items;
 }
 
 export async function fetchFile(id) {
-    const response = await fetch(`${API_BASE}/files/${id}`);
+    const response = await apiFetch(`/files/${id}`);
     if (!response.ok) throw new Error("Failed to fetch file");
     return response.json();
 }
 
 export async function fetchLibraries() {
-    const response = await fetch(`${API_BASE}/libraries`);
+    const response = await apiFetch(`/libraries`);
     if (!response.ok) throw new Error("Failed to fetch libraries");
     return response.json();
 }
 
+export async function fetchCollectionPage(params = {}) {
+    return fetchPage("/collections", params);
+}
+
 export async function fetchCollections() {
-    const response = await fetch(`${API_BASE}/collections`);
-    if (!response.ok) throw new Error("Failed to fetch collections");
-    return response.json();
+    return (await fetchCollectionPage({ limit: 500 })).items;
+}
+
+export async function fetchTagPage(params = {}) {
+    return fetchPage("/tags", params);
 }
 
 export async function fetchTags() {
-    const response = await fetch(`${API_BASE}/tags`);
-    if (!response.ok) throw new Error("Failed to fetch tags");
-    return response.json();
+    return (await fetchTagPage({ limit: 500 })).items;
 }
 
 export async function setModelPreview(modelId, fileId) {
-    const response = await fetch(`${API_BASE}/models/${modelId}/preview`, {
+    const response = await apiFetch(`/models/${modelId}/preview`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ file_id: fileId })
@@ -52,7 +115,7 @@ export async function setModelPreview(modelId, fileId) {
 }
 
 export async function scanLibrary(id) {
-    const response = await fetch(`${API_BASE}/libraries/${id}/scan`, {
+    const response = await apiFetch(`/libraries/${id}/scan`, {
         method: "POST"
     });
     if (!response.ok) throw new Error("Failed to scan library");
@@ -0,0 +1,71 @@
+import { login, logout, fetchCurrentUser, fetchAuthMethods, oidcLoginURL } from "./api.js";
+
+let onSignedIn = () => {};
+
+// initAuth wires the login form, shown whenever the API answers 401, and
+// calls ready to load the page, again after each sign-in.
+export async function initAuth(ready) {
+    onSignedIn = ready;
+    window.addEventListener("go3d:unauthorized", showLogin);
+    document.getElementById("loginForm").addEventListener("submit", handleLogin);
+    document.getElementById("signOutButton").addEventListener("click", handleSignOut);
+
+    const methods = await fetchAuthMethods();
+    const sso = document.getElementById("ssoButton");
+    sso.hidden = !methods.oidc;
+    sso.href = oidcLoginURL();
+
+    // A failed single sign-on comes back as ?auth_error=.
+    const params = new URLSearchParams(location.search);
+    if (params.has("auth_error")) {
+        document.getElementById("loginError").textContent = params.get("auth_error");
+        params.delete("auth_error");
+        const query = params.toString();
+        history.replaceState(null, "", location.pathname + (query ? "?" + query : ""));
+        showLogin();
+    }
+
+    // Load even when signed out: with anonymous reads allowed the library
+    // shows, and otherwise its first request brings up the login form.
+    showUser(await fetchCurrentUser());
+    ready();
+}
+
+function showLogin() {
+    document.getElementById("loginModal").classList.add("active");
+    document.getElementById("loginUsername").focus();
+}
+
+function showUser(user) {
+    const button = document.getElementById("signOutButton");
+    button.title = user ? `Sign out ${user.username}` : "Sign in";
+    button.dataset.signedIn = user ? "true" : "";
+}
+
+async function handleLogin(e) {
+    e.preventDefault();
+    const error = document.getElementById("loginError");
+    error.textContent = "";
+    try {
+        const user = await login(
+            document.getElementById("loginUsername").value,
+            document.getElementById("loginPassword").value
+        );
+        document.getElementById("loginPassword").value = "";
+        document.getElementById("loginModal").classList.remove("active");
+        showUser(user);
+        onSignedIn();
+    } catch (err) {
+        error.textContent = err.message;
+    }
+}
+
+async function handleSignOut() {
+    if (!document.getElementById("signOutButton").dataset.signedIn) {
+        showLogin();
+        return;
+    }
+    await logout();
+    showUser(null);
+    showLogin();
+}
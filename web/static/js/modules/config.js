@@ -1,4 +1,4 @@
-export const API_BASE = "http://192.168.3.26:3000/api";
+export const API_BASE = "/api";
 export const MAX_RENDERERS = 16;
 export const PREVIEW_SIZE = 300;
 export const CARD_CAMERA_DISTANCE = 60;
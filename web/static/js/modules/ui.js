@@ -1,4 +1,4 @@
-import { fetchModels, fetchModel, fetchModelFiles, fetchLibraries, fetchCollections, fetchTags, setModelPreview, scanLibrary, getFileDownloadUrl } from "./api.js";
+import { fetchModels, fetchModelPage, fetchModel, fetchModelFiles, fetchLibraries, fetchCollections, fetchCollectionPage, fetchTagPage, setModelPreview, scanLibrary, getFileDownloadUrl } from "./api.js";
 import { loadDetailPreview, loadCardPreview, loadImagePreview, loadCardImagePreview } from "./model-viewer.js";
 import { is3DFile, isImageFile } from "./three-utils.js";
 import { rendererPool } from "./renderer-pool.js";
@@ -45,18 +45,18 @@ async function loadViewData(view) {
 async function loadDashboard() {
     try {
         const [models, libs, colls, tags] = await Promise.all([
-            fetchModels(),
+            fetchModelPage({ limit: 6 }),
             fetchLibraries(),
-            fetchCollections(),
-            fetchTags()
+            fetchCollectionPage({ limit: 1 }),
+            fetchTagPage({ limit: 1 })
         ]);
 
-        document.getElementById("statModels").textContent = models?.length || 0;
+        document.getElementById("statModels").textContent = models?.total || 0;
         document.getElementById("statLibraries").textContent = libs?.length || 0;
-        document.getElementById("statCollections").textContent = colls?.length || 0;
-        document.getElementById("statTags").textContent = tags?.length || 0;
+        document.getElementById("statCollections").textContent = colls?.total || 0;
+        document.getElementById("statTags").textContent = tags?.total || 0;
 
-        renderModels(models?.slice(0, 6) || [], "recentModels");
+        renderModels(models?.items || [], "recentModels");
     } catch (error) {
         console.error("Failed to load dashboard:", error);
     }